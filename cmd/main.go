@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"os"
 	"os/signal"
 	"syscall"
@@ -9,8 +10,10 @@ import (
 	"github.com/joho/godotenv"
 	"github.com/ktuty/todo-app"
 	"github.com/ktuty/todo-app/pkg/handler"
+	"github.com/ktuty/todo-app/pkg/mailer"
 	"github.com/ktuty/todo-app/pkg/repository"
 	"github.com/ktuty/todo-app/pkg/service"
+	"github.com/ktuty/todo-app/pkg/storage"
 	_ "github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -49,21 +52,116 @@ func main() {
 		logrus.Fatalf("error loading env variables: %s", err.Error())
 	}
 
-	db, err := repository.NewPostgresDB(repository.Config{
+	dbConfig := repository.Config{
 		Host:     viper.GetString("db.host"),
 		Port:     viper.GetString("db.port"),
 		Username: viper.GetString("db.username"),
 		DBName:   viper.GetString("db.dbname"),
 		SSLMode:  viper.GetString("db.sslmode"),
 		Password: os.Getenv("DB_PASSWORD"),
-	})
+	}
+
+	db, err := repository.NewPostgresDB(dbConfig)
 	if err != nil {
 		logrus.Fatalf("failed to initialize db: %s", err.Error())
 	}
 
+	eventListener, err := repository.NewEventListener(dbConfig)
+	if err != nil {
+		logrus.Fatalf("failed to listen for events: %s", err.Error())
+	}
+
+	mail, err := mailer.New(mailer.Config{
+		Driver:   viper.GetString("mailer.driver"),
+		From:     viper.GetString("mailer.from"),
+		Host:     viper.GetString("mailer.host"),
+		Port:     viper.GetString("mailer.port"),
+		Username: viper.GetString("mailer.username"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		Dir:      viper.GetString("mailer.dir"),
+	})
+	if err != nil {
+		logrus.Fatalf("failed to initialize mailer: %s", err.Error())
+	}
+
+	store, err := storage.New(storage.Config{
+		Driver:    viper.GetString("storage.driver"),
+		Dir:       viper.GetString("storage.dir"),
+		Endpoint:  viper.GetString("storage.endpoint"),
+		Region:    viper.GetString("storage.region"),
+		Bucket:    viper.GetString("storage.bucket"),
+		AccessKey: viper.GetString("storage.access_key"),
+		SecretKey: os.Getenv("S3_SECRET_KEY"),
+	})
+	if err != nil {
+		logrus.Fatalf("failed to initialize blob store: %s", err.Error())
+	}
+
+	signingKey := []byte(os.Getenv("ATTACHMENT_SIGNING_KEY"))
+	if len(signingKey) == 0 {
+		// Ссылки, подписанные одной репликой, не будут приняты другими и перестанут работать после перезапуска
+		logrus.Warn("ATTACHMENT_SIGNING_KEY is not set, using a random key")
+		signingKey = make([]byte, 32)
+		rand.Read(signingKey)
+	}
+
 	repos := repository.NewRepository(db)
-	services := service.NewService(repos)
-	handlers := handler.NewHandler(services)
+	services := service.NewService(repos, mail, store, service.AttachmentConfig{
+		SigningKey: signingKey,
+		ReserveFor: viper.GetDuration("attachments.reserve_for"),
+	})
+	handlers := handler.NewHandler(services, handler.Config{
+		LegacyV1Errors:       viper.GetBool("api.v1_legacy_errors"),
+		BulkMaxOperations:    viper.GetInt("api.bulk_max_operations"),
+		StreamHeartbeat:      viper.GetDuration("stream.heartbeat"),
+		StreamAllowedOrigins: viper.GetStringSlice("stream.allowed_origins"),
+		AttachmentMaxSize:    viper.GetInt64("attachments.max_size"),
+		AttachmentTypes:      viper.GetStringSlice("attachments.types"),
+		AttachmentURLTTL:     viper.GetDuration("attachments.url_ttl"),
+		UploadTimeout:        viper.GetDuration("attachments.upload_timeout"),
+		DownloadTimeout:      viper.GetDuration("attachments.download_timeout"),
+	})
+
+	go services.Events.Dispatch(eventListener.Ids())
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	go services.Events.RunRetention(workerCtx, service.EventRetentionConfig{
+		Retention:    viper.GetDuration("stream.retention"),
+		PollInterval: viper.GetDuration("stream.cleanup_interval"),
+		BatchSize:    viper.GetInt("stream.cleanup_batch_size"),
+	})
+
+	go services.Webhooks.RunWorker(workerCtx, service.WebhookWorkerConfig{
+		PollInterval: viper.GetDuration("webhooks.poll_interval"),
+		BatchSize:    viper.GetInt("webhooks.batch_size"),
+		Timeout:      viper.GetDuration("webhooks.timeout"),
+		MaxAttempts:  viper.GetInt("webhooks.max_attempts"),
+		BaseDelay:    viper.GetDuration("webhooks.base_delay"),
+		MaxDelay:     viper.GetDuration("webhooks.max_delay"),
+	})
+
+	go services.Reminders.RunScheduler(workerCtx, service.ReminderSchedulerConfig{
+		PollInterval: viper.GetDuration("reminders.poll_interval"),
+		BatchSize:    viper.GetInt("reminders.batch_size"),
+		Timeout:      viper.GetDuration("reminders.timeout"),
+		RetryDelay:   viper.GetDuration("reminders.retry_delay"),
+		MaxLateness:  viper.GetDuration("reminders.max_lateness"),
+	})
+
+	go services.Digests.RunScheduler(workerCtx, service.DigestSchedulerConfig{
+		PollInterval: viper.GetDuration("digests.poll_interval"),
+		BatchSize:    viper.GetInt("digests.batch_size"),
+		Timeout:      viper.GetDuration("digests.timeout"),
+		RetryDelay:   viper.GetDuration("digests.retry_delay"),
+		MaxLateness:  viper.GetDuration("digests.max_lateness"),
+	})
+
+	go services.Attachments.RunCleaner(workerCtx, service.AttachmentCleanerConfig{
+		PollInterval: viper.GetDuration("attachments.cleanup_interval"),
+		BatchSize:    viper.GetInt("attachments.cleanup_batch_size"),
+	})
 
 	srv := new(todo.Server)
 	go func() {
@@ -84,6 +182,12 @@ func main() {
 		logrus.Errorf("error occured on server shutting down: %s", err.Error())
 	}
 
+	stopWorkers()
+
+	if err := eventListener.Close(); err != nil {
+		logrus.Errorf("error occured on events listener close: %s", err.Error())
+	}
+
 	if err := db.Close(); err != nil {
 		logrus.Errorf("error occured on db connection close: %s", err.Error())
 	}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+)
+
+type activityResponse struct {
+	Data []todo.AuditEntry `json:"data"`
+	Meta paginationMeta    `json:"meta"`
+}
+
+// GetListActivity возвращает журнал изменений списка и его items
+// @Summary List activity (v2)
+// @Description Audit log of the list and its items, newest first
+// @Security ApiKeyAuth
+// @Tags activity-v2
+// @Produce json
+// @Param id path int true "List ID"
+// @Param action query string false "Comma-separated actions, e.g. item.completed,item.deleted"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} activityResponse
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/lists/{id}/activity [get]
+func (h *Handler) getListActivity(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	filter, page, ok := activityFilter(c)
+	if !ok {
+		return
+	}
+
+	entries, total, err := h.services.Audit.GetListActivity(userId, id, filter)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	writeActivity(c, entries, total, page, filter.Limit)
+}
+
+// GetActivityFeed возвращает ленту изменений пользователя
+// @Summary Activity feed (v2)
+// @Description Changes in all lists the user can access and changes made by the user, newest first
+// @Security ApiKeyAuth
+// @Tags activity-v2
+// @Produce json
+// @Param action query string false "Comma-separated actions, e.g. list.archived,item.completed"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} activityResponse
+// @Failure 400 {object} problemDetails
+// @Router /api/v2/activity [get]
+func (h *Handler) getActivityFeed(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	filter, page, ok := activityFilter(c)
+	if !ok {
+		return
+	}
+
+	entries, total, err := h.services.Audit.GetFeed(userId, filter)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	writeActivity(c, entries, total, page, filter.Limit)
+}
+
+// activityFilter разбирает параметры фильтрации и пагинации; при ошибке отвечает 400
+func activityFilter(c *gin.Context) (todo.AuditFilter, int, bool) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	filter := todo.AuditFilter{Offset: (page - 1) * limit, Limit: limit}
+	if actions := c.Query("action"); actions != "" {
+		for _, action := range strings.Split(actions, ",") {
+			action = strings.TrimSpace(action)
+			if !todo.IsAuditAction(action) {
+				newErrorResponse(c, http.StatusBadRequest, "invalid action "+strconv.Quote(action))
+				return filter, 0, false
+			}
+			filter.Actions = append(filter.Actions, action)
+		}
+	}
+
+	return filter, page, true
+}
+
+func writeActivity(c *gin.Context, entries []todo.AuditEntry, total, page, limit int) {
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	c.Header("X-Page", strconv.Itoa(page))
+	c.Header("X-Limit", strconv.Itoa(limit))
+
+	c.JSON(http.StatusOK, activityResponse{
+		Data: entries,
+		Meta: paginationMeta{
+			Page:  page,
+			Limit: limit,
+			Total: total,
+			Pages: (total + limit - 1) / limit,
+		},
+	})
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+)
+
+// AssignItem назначает ответственного за item
+// @Summary Assign item
+// @Description Make a user with access to the item's list responsible for the item. The user is notified.
+// @Description Returns 201 for a new assignee and 200 if the user was already assigned.
+// @Security ApiKeyAuth
+// @Tags items-v2
+// @Accept json
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param input body todo.AssignItemInput true "Assignee"
+// @Success 201 {object} todo.TodoItem
+// @Success 200 {object} todo.TodoItem
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/items/{id}/assignees [post]
+func (h *Handler) assignItem(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.AssignItemInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	added, err := h.requestServices(c).TodoItem.Assign(userId, itemId, input.UserId)
+	if err != nil {
+		if errors.Is(err, todo.ErrAssigneeNoAccess) {
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	item, err := h.services.TodoItem.GetById(userId, itemId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	status := http.StatusOK
+	if added {
+		status = http.StatusCreated
+	}
+	c.Header("ETag", formatETag(item.Version))
+	c.JSON(status, item)
+}
+
+// UnassignItem снимает ответственного с item
+// @Summary Unassign item
+// @Description Remove a user from the item's assignees. The user is notified.
+// @Security ApiKeyAuth
+// @Tags items-v2
+// @Param id path int true "Item ID"
+// @Param user_id path int true "Assignee user ID"
+// @Success 204
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/items/{id}/assignees/{user_id} [delete]
+func (h *Handler) unassignItem(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	assigneeId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid user_id param")
+		return
+	}
+
+	if err := h.requestServices(c).TodoItem.Unassign(userId, itemId, assigneeId); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
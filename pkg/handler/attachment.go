@@ -0,0 +1,405 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/storage"
+)
+
+// attachmentFormField - поле multipart-формы с файлом
+const attachmentFormField = "file"
+
+// multipartOverhead - запас к лимиту размера файла на заголовки частей multipart-формы
+const multipartOverhead = 64 << 10
+
+var errAttachmentTooLarge = errors.New("file is too large")
+
+type attachmentsResponse struct {
+	Data []todo.Attachment `json:"data"`
+}
+
+// UploadAttachment прикрепляет файл к item
+// @Summary Upload attachment
+// @Description Multipart upload in the "file" field. The type is detected from the content and must be allowed by the server (screenshots and PDFs by default).
+// @Security ApiKeyAuth
+// @Tags attachments-v2
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param file formData file true "File"
+// @Success 201 {object} todo.Attachment
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Failure 413 {object} problemDetails
+// @Failure 415 {object} problemDetails
+// @Router /api/v2/items/{id}/attachments [post]
+func (h *Handler) uploadAttachment(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.config.AttachmentMaxSize+multipartOverhead)
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "request must be multipart/form-data")
+		return
+	}
+
+	var part io.Reader
+	var filename string
+	for {
+		p, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			newUploadErrorResponse(c, h.config.AttachmentMaxSize, err)
+			return
+		}
+		if p.FormName() == attachmentFormField {
+			part, filename = p, p.FileName()
+			break
+		}
+	}
+	if part == nil {
+		newErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("%q field with a file is required", attachmentFormField))
+		return
+	}
+
+	file, upload, err := spoolUpload(part, h.config.AttachmentMaxSize)
+	if file != nil {
+		defer os.Remove(file.Name())
+		defer file.Close()
+	}
+	if err != nil {
+		newUploadErrorResponse(c, h.config.AttachmentMaxSize, err)
+		return
+	}
+
+	if !h.attachmentTypeAllowed(upload.ContentType) {
+		newErrorResponse(c, http.StatusUnsupportedMediaType,
+			fmt.Sprintf("file type %s is not allowed, allowed: %s", upload.ContentType, strings.Join(h.config.AttachmentTypes, ", ")))
+		return
+	}
+	upload.Filename = attachmentFilename(filename)
+
+	attachment, err := h.requestServices(c).Attachments.Create(c.Request.Context(), userId, itemId, upload)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, attachment)
+}
+
+// spoolUpload сохраняет файл во временный файл, проверяя размер, и определяет тип
+// по содержимому. Размер нужен хранилищу заранее, поэтому файл не передается потоком
+func spoolUpload(r io.Reader, maxSize int64) (*os.File, todo.AttachmentUpload, error) {
+	file, err := os.CreateTemp("", "attachment-*")
+	if err != nil {
+		return nil, todo.AttachmentUpload{}, err
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return file, todo.AttachmentUpload{}, err
+	}
+	if size > maxSize {
+		return file, todo.AttachmentUpload{}, errAttachmentTooLarge
+	}
+	if size == 0 {
+		return file, todo.AttachmentUpload{}, errors.New("file is empty")
+	}
+
+	head := make([]byte, 512)
+	n, err := file.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return file, todo.AttachmentUpload{}, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return file, todo.AttachmentUpload{}, err
+	}
+
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	return file, todo.AttachmentUpload{
+		ContentType: contentType,
+		Size:        size,
+		Checksum:    hex.EncodeToString(hash.Sum(nil)),
+		Body:        file,
+	}, nil
+}
+
+func newUploadErrorResponse(c *gin.Context, maxSize int64, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.Is(err, errAttachmentTooLarge) || errors.As(err, &maxBytesErr) {
+		newErrorResponse(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("file must be at most %d bytes", maxSize))
+		return
+	}
+	newErrorResponse(c, http.StatusBadRequest, "invalid upload: "+err.Error())
+}
+
+func (h *Handler) attachmentTypeAllowed(contentType string) bool {
+	for _, allowed := range h.config.AttachmentTypes {
+		if allowed == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+// attachmentFilename оставляет от имени файла клиента только базовое имя допустимой длины
+func attachmentFilename(name string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, `\`, "/")))
+	if name == "." || name == "/" || name == "" || !utf8.ValidString(name) {
+		return "file"
+	}
+	for utf8.RuneCountInString(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// GetAttachments возвращает вложения item
+// @Summary Get item attachments
+// @Security ApiKeyAuth
+// @Tags attachments-v2
+// @Produce json
+// @Param id path int true "Item ID"
+// @Success 200 {object} attachmentsResponse
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/items/{id}/attachments [get]
+func (h *Handler) getAttachments(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	attachments, err := h.services.Attachments.GetAll(userId, itemId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, attachmentsResponse{Data: attachments})
+}
+
+// GetAttachmentById возвращает метаданные вложения
+// @Summary Get attachment
+// @Security ApiKeyAuth
+// @Tags attachments-v2
+// @Produce json
+// @Param id path int true "Attachment ID"
+// @Success 200 {object} todo.Attachment
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/attachments/{id} [get]
+func (h *Handler) getAttachmentById(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	attachment, err := h.services.Attachments.GetById(userId, id)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, attachment)
+}
+
+// DownloadAttachment отдает содержимое вложения
+// @Summary Download attachment
+// @Security ApiKeyAuth
+// @Tags attachments-v2
+// @Produce octet-stream
+// @Param id path int true "Attachment ID"
+// @Success 200 {file} file
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/attachments/{id}/content [get]
+func (h *Handler) downloadAttachment(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	attachment, body, err := h.services.Attachments.Open(c.Request.Context(), userId, id)
+	if err != nil {
+		newAttachmentErrorResponse(c, err)
+		return
+	}
+	defer body.Close()
+
+	writeAttachment(c, attachment, body)
+}
+
+// SignAttachmentURL выдает временную ссылку на скачивание вложения
+// @Summary Create signed download URL
+// @Description Short-lived URL that downloads the attachment without the Authorization header, e.g. for <img> tags
+// @Security ApiKeyAuth
+// @Tags attachments-v2
+// @Produce json
+// @Param id path int true "Attachment ID"
+// @Success 200 {object} todo.AttachmentURL
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/attachments/{id}/url [post]
+func (h *Handler) signAttachmentURL(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	path, expiresAt, err := h.services.Attachments.SignURL(userId, id, h.config.AttachmentURLTTL)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	c.JSON(http.StatusOK, todo.AttachmentURL{URL: scheme + "://" + c.Request.Host + path, ExpiresAt: expiresAt})
+}
+
+// DownloadSignedAttachment отдает содержимое вложения по подписанной ссылке
+// @Summary Download attachment by signed URL
+// @Tags attachments-v2
+// @Produce octet-stream
+// @Param id path int true "Attachment ID"
+// @Param expires query int true "Expiration time, unix seconds"
+// @Param signature query string true "Signature"
+// @Success 200 {file} file
+// @Failure 403 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /files/attachments/{id} [get]
+func (h *Handler) downloadSignedAttachment(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		newErrorResponse(c, http.StatusForbidden, "invalid or expired signature")
+		return
+	}
+
+	attachment, body, err := h.services.Attachments.OpenSigned(c.Request.Context(), id, expires, c.Query("signature"))
+	if err != nil {
+		if errors.Is(err, todo.ErrForbidden) {
+			newErrorResponse(c, http.StatusForbidden, "invalid or expired signature")
+			return
+		}
+		newAttachmentErrorResponse(c, err)
+		return
+	}
+	defer body.Close()
+
+	writeAttachment(c, attachment, body)
+}
+
+// DeleteAttachment удаляет вложение
+// @Summary Delete attachment
+// @Security ApiKeyAuth
+// @Tags attachments-v2
+// @Param id path int true "Attachment ID"
+// @Success 204
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/attachments/{id} [delete]
+func (h *Handler) deleteAttachment(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.requestServices(c).Attachments.Delete(userId, id); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// newAttachmentErrorResponse - newServiceErrorResponse с учетом отсутствия содержимого в хранилище
+func newAttachmentErrorResponse(c *gin.Context, err error) {
+	if errors.Is(err, storage.ErrNotFound) {
+		newErrorResponse(c, http.StatusNotFound, "attachment content not found")
+		return
+	}
+	newServiceErrorResponse(c, err)
+}
+
+// writeAttachment отдает содержимое вложения как файл для сохранения. nosniff не дает
+// браузеру интерпретировать содержимое иначе, чем по проверенному при загрузке типу
+func writeAttachment(c *gin.Context, attachment todo.Attachment, body io.Reader) {
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, body, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}),
+		"X-Content-Type-Options": "nosniff",
+		"Cache-Control":          "private, max-age=0",
+		"ETag":                   `"` + attachment.Checksum + `"`,
+	})
+}
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -15,14 +17,14 @@ import (
 // @Produce json
 // @Param input body todo.User true "User credentials"
 // @Success 201 {object} map[string]interface{}
-// @Failure 400 {object} errorResponse
-// @Failure 500 {object} errorResponse
+// @Failure 400 {object} problemDetails
+// @Failure 500 {object} problemDetails
 // @Router /auth/sign-up [post]
 func (h *Handler) signUp(c *gin.Context) {
 	var input todo.User
 
-	if err := c.BindJSON(&input); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
 		return
 	}
 
@@ -50,19 +52,23 @@ type signInInput struct {
 // @Produce json
 // @Param input body todo.User true "User credentials"
 // @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} errorResponse
-// @Failure 401 {object} errorResponse
-// @Failure 500 {object} errorResponse
+// @Failure 400 {object} problemDetails
+// @Failure 401 {object} problemDetails
+// @Failure 500 {object} problemDetails
 // @Router /auth/sign-in [post]
 func (h *Handler) signIn(c *gin.Context) {
 	var input signInInput
 
-	if err := c.BindJSON(&input); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
 		return
 	}
 
 	token, err := h.services.Authorization.GenerateTocken(input.UserName, input.Password)
+	if errors.Is(err, sql.ErrNoRows) {
+		newErrorResponse(c, http.StatusUnauthorized, "invalid username or password")
+		return
+	}
 	if err != nil {
 		newErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+)
+
+const (
+	bulkModeAtomic     = "atomic"
+	bulkModeBestEffort = "best_effort"
+
+	defaultBulkMaxOperations = 100
+)
+
+type bulkItemsRequest struct {
+	Mode       string                   `json:"mode" binding:"omitempty,oneof=atomic best_effort"`
+	Operations []todo.BulkItemOperation `json:"operations" binding:"required,min=1,dive"`
+}
+
+func (r *bulkItemsRequest) Normalize() {
+	for i := range r.Operations {
+		r.Operations[i].Normalize()
+	}
+}
+
+type bulkOperationResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	ItemId int    `json:"item_id,omitempty"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type bulkItemsResponse struct {
+	Mode      string                `json:"mode"`
+	Committed bool                  `json:"committed"`
+	Succeeded int                   `json:"succeeded"`
+	Failed    int                   `json:"failed"`
+	Results   []bulkOperationResult `json:"results"`
+}
+
+// BulkItems выполняет пакет операций над items
+// @Summary Bulk item operations (v2)
+// @Description Execute create/update/complete/archive/move/delete/purge operations in one request.
+// @Description delete archives the item like DELETE /api/v2/items/{id}; purge deletes it permanently.
+// @Description Mode "atomic" (default) applies all operations or none, "best_effort" applies each independently.
+// @Security ApiKeyAuth
+// @Tags items-v2
+// @Accept json
+// @Produce json
+// @Param input body bulkItemsRequest true "Batch of operations"
+// @Success 200 {object} bulkItemsResponse
+// @Success 207 {object} bulkItemsResponse
+// @Failure 400 {object} problemDetails
+// @Failure 409 {object} bulkItemsResponse
+// @Failure 413 {object} problemDetails
+// @Failure 500 {object} problemDetails
+// @Router /api/v2/items/bulk [post]
+func (h *Handler) bulkItems(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var input bulkItemsRequest
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	maxOperations := h.config.BulkMaxOperations
+	if maxOperations <= 0 {
+		maxOperations = defaultBulkMaxOperations
+	}
+	if len(input.Operations) > maxOperations {
+		newErrorResponse(c, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("batch contains %d operations, maximum is %d", len(input.Operations), maxOperations))
+		return
+	}
+
+	var fields []fieldError
+	for i, op := range input.Operations {
+		if op.Op == todo.BulkOpUpdate {
+			if err := op.Update.Validate(); err != nil {
+				fields = append(fields, fieldError{
+					Field:   fmt.Sprintf("operations[%d].update", i),
+					Rule:    "required",
+					Message: err.Error(),
+				})
+			}
+		}
+	}
+	if len(fields) > 0 {
+		writeProblem(c, problemDetails{
+			Type:   problemTypeValidation,
+			Title:  "Validation failed",
+			Status: http.StatusBadRequest,
+			Detail: "request contains invalid fields",
+			Errors: fields,
+		})
+		return
+	}
+
+	if input.Mode == "" {
+		input.Mode = bulkModeAtomic
+	}
+	atomic := input.Mode == bulkModeAtomic
+
+	results, err := h.requestServices(c).TodoItem.Bulk(userId, input.Operations, atomic)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	response := bulkItemsResponse{
+		Mode:    input.Mode,
+		Results: make([]bulkOperationResult, len(results)),
+	}
+	for i, result := range results {
+		status, message := bulkResultStatus(result)
+		response.Results[i] = bulkOperationResult{
+			Index:  result.Index,
+			Op:     result.Op,
+			ItemId: result.ItemId,
+			Status: status,
+			Error:  message,
+		}
+		if result.Err == nil {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+	}
+	response.Committed = !atomic || response.Failed == 0
+
+	switch {
+	case response.Failed == 0:
+		c.JSON(http.StatusOK, response)
+	case atomic:
+		c.JSON(http.StatusConflict, response)
+	default:
+		c.JSON(http.StatusMultiStatus, response)
+	}
+}
+
+func bulkResultStatus(result todo.BulkItemResult) (int, string) {
+	switch {
+	case result.Err == nil:
+		if result.Op == todo.BulkOpCreate {
+			return http.StatusCreated, ""
+		}
+		return http.StatusOK, ""
+	case errors.Is(result.Err, todo.ErrBulkAborted):
+		return http.StatusFailedDependency, result.Err.Error()
+	case errors.Is(result.Err, sql.ErrNoRows):
+		return http.StatusNotFound, "item or list not found"
+	case errors.Is(result.Err, todo.ErrVersionMismatch):
+		return http.StatusPreconditionFailed, result.Err.Error()
+	case errors.Is(result.Err, todo.ErrItemBlocked):
+		return http.StatusConflict, result.Err.Error()
+	case errors.Is(result.Err, todo.ErrInvalidStatus), errors.Is(result.Err, todo.ErrInvalidSection):
+		return http.StatusBadRequest, result.Err.Error()
+	default:
+		return http.StatusInternalServerError, result.Err.Error()
+	}
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+)
+
+func serveBulk(h *Handler, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v2/items/bulk", strings.NewReader(body))
+	c.Set(userCtx, 1)
+	h.bulkItems(c)
+	return w
+}
+
+func TestBulkItemsValidation(t *testing.T) {
+	h := &Handler{config: Config{BulkMaxOperations: 2}}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		field  string
+	}{
+		{"unknown op", `{"operations":[{"op":"erase","item_id":1}]}`, http.StatusBadRequest, "operations[0].op"},
+		{"missing item id", `{"operations":[{"op":"purge"}]}`, http.StatusBadRequest, "operations[0].item_id"},
+		{"create without list", `{"operations":[{"op":"create","item":{"title":"a"}}]}`, http.StatusBadRequest, "operations[0].list_id"},
+		{"empty update", `{"operations":[{"op":"update","item_id":1,"update":{}}]}`, http.StatusBadRequest, "operations[0].update"},
+		{"unknown mode", `{"mode":"eventual","operations":[{"op":"delete","item_id":1}]}`, http.StatusBadRequest, "mode"},
+		{"too many", `{"operations":[{"op":"delete","item_id":1},{"op":"delete","item_id":2},{"op":"purge","item_id":3}]}`, http.StatusRequestEntityTooLarge, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveBulk(h, tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.field == "" {
+				return
+			}
+
+			var p problemDetails
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+				t.Fatal(err)
+			}
+			if len(p.Errors) != 1 || p.Errors[0].Field != tt.field {
+				t.Errorf("errors = %+v, want field %s", p.Errors, tt.field)
+			}
+		})
+	}
+}
+
+func TestBulkResultStatus(t *testing.T) {
+	tests := []struct {
+		result todo.BulkItemResult
+		status int
+	}{
+		{todo.BulkItemResult{Op: todo.BulkOpCreate}, http.StatusCreated},
+		{todo.BulkItemResult{Op: todo.BulkOpPurge}, http.StatusOK},
+		{todo.BulkItemResult{Err: todo.ErrBulkAborted}, http.StatusFailedDependency},
+		{todo.BulkItemResult{Err: fmt.Errorf("lookup: %w", sql.ErrNoRows)}, http.StatusNotFound},
+		{todo.BulkItemResult{Err: todo.ErrVersionMismatch}, http.StatusPreconditionFailed},
+		{todo.BulkItemResult{Err: todo.ErrItemBlocked}, http.StatusConflict},
+		{todo.BulkItemResult{Err: todo.ErrInvalidSection}, http.StatusBadRequest},
+		{todo.BulkItemResult{Err: errors.New("boom")}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if status, _ := bulkResultStatus(tt.result); status != tt.status {
+			t.Errorf("bulkResultStatus(%+v) = %d, want %d", tt.result, status, tt.status)
+		}
+	}
+}
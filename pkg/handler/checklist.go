@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+)
+
+type checklistResponse struct {
+	Data []todo.ChecklistItem `json:"data"`
+}
+
+// GetChecklist возвращает чек-лист item
+// @Summary Get item checklist
+// @Security ApiKeyAuth
+// @Tags checklist-v2
+// @Produce json
+// @Param id path int true "Item ID"
+// @Success 200 {object} checklistResponse
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/items/{id}/checklist [get]
+func (h *Handler) getChecklist(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	entries, err := h.services.TodoItem.GetChecklist(userId, itemId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, checklistResponse{Data: entries})
+}
+
+// AddChecklistItem добавляет пункт в чек-лист item
+// @Summary Add checklist item
+// @Description Add an entry to the item's checklist. Without position the entry is appended.
+// @Description If the item has checklist_auto_complete set and every entry is checked, the item is marked done.
+// @Security ApiKeyAuth
+// @Tags checklist-v2
+// @Accept json
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param input body todo.ChecklistItemInput true "Checklist entry"
+// @Success 201 {object} todo.ChecklistItem
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/items/{id}/checklist [post]
+func (h *Handler) addChecklistItem(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.ChecklistItemInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	entry, err := h.requestServices(c).TodoItem.AddChecklistItem(userId, itemId, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+// UpdateChecklistItem изменяет текст или отметку пункта чек-листа
+// @Summary Update checklist item
+// @Description Change the text of a checklist entry or check it off.
+// @Description If the item has checklist_auto_complete set and every entry is checked, the item is marked done.
+// @Security ApiKeyAuth
+// @Tags checklist-v2
+// @Accept json
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param entry_id path int true "Checklist entry ID"
+// @Param input body todo.UpdateChecklistItemInput true "Checklist entry update"
+// @Success 200 {object} todo.ChecklistItem
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/items/{id}/checklist/{entry_id} [put]
+func (h *Handler) updateChecklistItem(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	entryId, err := strconv.Atoi(c.Param("entry_id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid entry_id param")
+		return
+	}
+
+	var input todo.UpdateChecklistItemInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entry, err := h.requestServices(c).TodoItem.UpdateChecklistItem(userId, itemId, entryId, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// DeleteChecklistItem удаляет пункт чек-листа
+// @Summary Delete checklist item
+// @Security ApiKeyAuth
+// @Tags checklist-v2
+// @Param id path int true "Item ID"
+// @Param entry_id path int true "Checklist entry ID"
+// @Success 204
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/items/{id}/checklist/{entry_id} [delete]
+func (h *Handler) deleteChecklistItem(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	entryId, err := strconv.Atoi(c.Param("entry_id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid entry_id param")
+		return
+	}
+
+	if err := h.requestServices(c).TodoItem.DeleteChecklistItem(userId, itemId, entryId); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ReorderChecklist задает новый порядок пунктов чек-листа
+// @Summary Reorder checklist
+// @Description Put checklist entries in the given order. ids must list every entry of the item exactly once.
+// @Security ApiKeyAuth
+// @Tags checklist-v2
+// @Accept json
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param input body todo.ReorderChecklistInput true "Entry ids in the new order"
+// @Success 200 {object} checklistResponse
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/items/{id}/checklist/order [put]
+func (h *Handler) reorderChecklist(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.ReorderChecklistInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, err := h.requestServices(c).TodoItem.ReorderChecklist(userId, itemId, input)
+	if err != nil {
+		if errors.Is(err, todo.ErrChecklistOrder) {
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, checklistResponse{Data: entries})
+}
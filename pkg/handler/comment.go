@@ -0,0 +1,260 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+)
+
+type commentsResponse struct {
+	Data []todo.Comment `json:"data"`
+	Meta paginationMeta `json:"meta"`
+}
+
+type commentEditsResponse struct {
+	Data []todo.CommentEdit `json:"data"`
+}
+
+// CreateComment добавляет комментарий к item
+// @Summary Create comment
+// @Description Body is Markdown. @username mentions of users with access to the list are resolved and notified.
+// @Description Set parent_id to reply to another comment of the same item.
+// @Security ApiKeyAuth
+// @Tags comments-v2
+// @Accept json
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param input body todo.CommentInput true "Comment"
+// @Success 201 {object} todo.Comment
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/items/{id}/comments [post]
+func (h *Handler) createComment(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.CommentInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	comment, err := h.services.Comments.Create(userId, itemId, input)
+	if err != nil {
+		if errors.Is(err, todo.ErrInvalidParentComment) {
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, comment)
+}
+
+// GetComments возвращает комментарии item
+// @Summary Get item comments
+// @Description Comments in creation order. Deleted comments are kept with empty body so replies stay in their thread.
+// @Security ApiKeyAuth
+// @Tags comments-v2
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(50)
+// @Success 200 {object} commentsResponse
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/items/{id}/comments [get]
+func (h *Handler) getComments(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	comments, total, err := h.services.Comments.GetAll(userId, itemId, (page-1)*limit, limit)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	c.Header("X-Page", strconv.Itoa(page))
+	c.Header("X-Limit", strconv.Itoa(limit))
+
+	c.JSON(http.StatusOK, commentsResponse{
+		Data: comments,
+		Meta: paginationMeta{
+			Page:  page,
+			Limit: limit,
+			Total: total,
+			Pages: (total + limit - 1) / limit,
+		},
+	})
+}
+
+// GetCommentById возвращает комментарий
+// @Summary Get comment
+// @Security ApiKeyAuth
+// @Tags comments-v2
+// @Produce json
+// @Param id path int true "Comment ID"
+// @Success 200 {object} todo.Comment
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/comments/{id} [get]
+func (h *Handler) getCommentById(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	comment, err := h.services.Comments.GetById(userId, id)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, comment)
+}
+
+// UpdateComment меняет текст комментария
+// @Summary Update comment
+// @Description Only the author can edit. The previous text is kept in the edit history; newly mentioned users are notified.
+// @Security ApiKeyAuth
+// @Tags comments-v2
+// @Accept json
+// @Produce json
+// @Param id path int true "Comment ID"
+// @Param input body todo.UpdateCommentInput true "Comment text"
+// @Success 200 {object} todo.Comment
+// @Failure 400 {object} problemDetails
+// @Failure 403 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/comments/{id} [put]
+func (h *Handler) updateComment(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.UpdateCommentInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	comment, err := h.services.Comments.Update(userId, id, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, comment)
+}
+
+// DeleteComment удаляет комментарий
+// @Summary Delete comment
+// @Description Only the author can delete. The comment stays in its thread with empty body.
+// @Security ApiKeyAuth
+// @Tags comments-v2
+// @Param id path int true "Comment ID"
+// @Success 204
+// @Failure 400 {object} problemDetails
+// @Failure 403 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/comments/{id} [delete]
+func (h *Handler) deleteComment(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.services.Comments.Delete(userId, id); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetCommentEdits возвращает историю правок комментария
+// @Summary Get comment edit history
+// @Description Previous versions of the comment text, newest first
+// @Security ApiKeyAuth
+// @Tags comments-v2
+// @Produce json
+// @Param id path int true "Comment ID"
+// @Success 200 {object} commentEditsResponse
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/comments/{id}/edits [get]
+func (h *Handler) getCommentEdits(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	edits, err := h.services.Comments.GetEdits(userId, id)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, commentEditsResponse{Data: edits})
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+)
+
+// forceParam сообщает, что item нужно отметить выполненным, несмотря на невыполненные
+// блокирующие items
+func forceParam(c *gin.Context) bool {
+	return c.Query("force") == "true"
+}
+
+// AddDependency отмечает, что item заблокирован другим item
+// @Summary Add item dependency
+// @Description Mark the item as blocked by another item the user can access, possibly in another list.
+// @Description The item can't be completed while the blocking item is open unless force=true is passed.
+// @Description Returns 201 for a new dependency, 200 if it already exists and 409 if it would create a cycle.
+// @Security ApiKeyAuth
+// @Tags items-v2
+// @Accept json
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param input body todo.AddDependencyInput true "Blocking item"
+// @Success 201 {object} todo.TodoItem
+// @Success 200 {object} todo.TodoItem
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Failure 409 {object} problemDetails
+// @Router /api/v2/items/{id}/dependencies [post]
+func (h *Handler) addDependency(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.AddDependencyInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	added, err := h.requestServices(c).TodoItem.AddDependency(userId, itemId, input.BlockedById)
+	if err != nil {
+		switch {
+		case errors.Is(err, todo.ErrBlockerNotFound):
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, todo.ErrDependencyCycle):
+			newErrorResponse(c, http.StatusConflict, err.Error())
+		default:
+			newServiceErrorResponse(c, err)
+		}
+		return
+	}
+
+	item, err := h.services.TodoItem.GetById(userId, itemId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	status := http.StatusOK
+	if added {
+		status = http.StatusCreated
+	}
+	c.Header("ETag", formatETag(item.Version))
+	c.JSON(status, item)
+}
+
+// RemoveDependency снимает блокировку item другим item
+// @Summary Remove item dependency
+// @Security ApiKeyAuth
+// @Tags items-v2
+// @Param id path int true "Item ID"
+// @Param blocked_by_id path int true "Blocking item ID"
+// @Success 204
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/items/{id}/dependencies/{blocked_by_id} [delete]
+func (h *Handler) removeDependency(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	blockedById, err := strconv.Atoi(c.Param("blocked_by_id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid blocked_by_id param")
+		return
+	}
+
+	if err := h.requestServices(c).TodoItem.RemoveDependency(userId, itemId, blockedById); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetDependencyGraph возвращает граф зависимостей между items
+// @Summary Get dependency graph
+// @Description Items linked by dependencies in topological order: every item comes after all items blocking it.
+// @Description Only items the user can access are included. list_id keeps dependencies touching items of that list.
+// @Security ApiKeyAuth
+// @Tags items-v2
+// @Produce json
+// @Param list_id query int false "Filter by list ID"
+// @Success 200 {object} todo.DependencyGraph
+// @Failure 400 {object} problemDetails
+// @Router /api/v2/dependencies [get]
+func (h *Handler) getDependencyGraph(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	listId := 0
+	if value := c.Query("list_id"); value != "" {
+		listId, err = strconv.Atoi(value)
+		if err != nil || listId < 1 {
+			newErrorResponse(c, http.StatusBadRequest, "invalid list_id param")
+			return
+		}
+	}
+
+	graph, err := h.services.TodoItem.GetDependencyGraph(userId, listId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, graph)
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+)
+
+// GetDigestPreferences возвращает настройки писем-дайджестов
+// @Summary Get digest preferences
+// @Description Digests are opt-in. send_at (HH:MM) and weekday (1 - Monday, for weekly digests) are in the user's time zone.
+// @Security ApiKeyAuth
+// @Tags digest-v2
+// @Produce json
+// @Success 200 {object} todo.DigestPreferences
+// @Failure 500 {object} problemDetails
+// @Router /api/v2/digest/preferences [get]
+func (h *Handler) getDigestPreferences(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	preferences, err := h.services.Digests.GetPreferences(userId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
+
+// UpdateDigestPreferences изменяет настройки писем-дайджестов
+// @Summary Update digest preferences
+// @Description Omitted fields keep their value. time_zone is shared with /api/v2/settings.
+// @Description Digests are sent to the email from /api/v2/settings; empty digests are not sent.
+// @Security ApiKeyAuth
+// @Tags digest-v2
+// @Accept json
+// @Produce json
+// @Param input body todo.UpdateDigestPreferencesInput true "Preferences"
+// @Success 200 {object} todo.DigestPreferences
+// @Failure 400 {object} problemDetails
+// @Router /api/v2/digest/preferences [put]
+func (h *Handler) updateDigestPreferences(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var input todo.UpdateDigestPreferencesInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	preferences, err := h.services.Digests.UpdatePreferences(userId, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
+
+// PreviewDigest показывает дайджест, который пользователь получил бы сейчас
+// @Summary Preview digest
+// @Description The digest as it would be sent now: JSON by default, the email HTML with format=html or Accept: text/html
+// @Security ApiKeyAuth
+// @Tags digest-v2
+// @Produce json,html
+// @Param frequency query string false "daily or weekly; defaults to the saved preference"
+// @Param format query string false "json or html"
+// @Success 200 {object} todo.Digest
+// @Failure 400 {object} problemDetails
+// @Router /api/v2/digest/preview [get]
+func (h *Handler) previewDigest(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	frequency := c.Query("frequency")
+	if frequency != "" && frequency != todo.DigestDaily && frequency != todo.DigestWeekly {
+		newErrorResponse(c, http.StatusBadRequest, "frequency must be one of: daily, weekly")
+		return
+	}
+
+	format := c.Query("format")
+	if format == "" && strings.Contains(c.GetHeader("Accept"), "text/html") {
+		format = "html"
+	}
+	if format != "" && format != "json" && format != "html" {
+		newErrorResponse(c, http.StatusBadRequest, "format must be one of: json, html")
+		return
+	}
+
+	digest, err := h.services.Digests.Build(userId, frequency)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	if format != "html" {
+		c.JSON(http.StatusOK, digest)
+		return
+	}
+
+	html, err := h.services.Digests.RenderHTML(digest)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(html))
+}
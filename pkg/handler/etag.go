@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ifMatchHeader     = "If-Match"
+	ifNoneMatchHeader = "If-None-Match"
+)
+
+// formatETag строит ETag из версии ресурса
+func formatETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// etagMatches проверяет, содержит ли заголовок If-Match/If-None-Match ETag текущей версии.
+// При слабом сравнении (If-None-Match) ETag W/"..." сравнивается по значению, при строгом
+// (If-Match, RFC 7232 §3.1) слабый ETag не совпадает ни с какой версией
+func etagMatches(header string, version int, weak bool) bool {
+	current := formatETag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified выставляет ETag и отвечает 304, если клиент уже имеет текущую версию ресурса
+func notModified(c *gin.Context, version int) bool {
+	c.Header("ETag", formatETag(version))
+
+	if header := c.GetHeader(ifNoneMatchHeader); header != "" && etagMatches(header, version, true) {
+		c.AbortWithStatus(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// preconditionVersion сверяет If-Match с текущей версией ресурса.
+// Возвращает версию для условного обновления (0, если заголовок не передан);
+// при несовпадении отвечает 412 и возвращает false
+func preconditionVersion(c *gin.Context, current func() (int, error)) (int, bool) {
+	header := c.GetHeader(ifMatchHeader)
+	if header == "" {
+		return 0, true
+	}
+
+	version, err := current()
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return 0, false
+	}
+
+	if !etagMatches(header, version, false) {
+		c.Header("ETag", formatETag(version))
+		newErrorResponse(c, http.StatusPreconditionFailed, "resource has been modified, If-Match does not match current ETag")
+		return 0, false
+	}
+
+	return version, true
+}
+
+func (h *Handler) listVersion(userId, listId int) func() (int, error) {
+	return func() (int, error) {
+		list, err := h.services.TodoList.GetById(userId, listId)
+		return list.Version, err
+	}
+}
+
+func (h *Handler) itemVersion(userId, itemId int) func() (int, error) {
+	return func() (int, error) {
+		item, err := h.services.TodoItem.GetById(userId, itemId)
+		return item.Version, err
+	}
+}
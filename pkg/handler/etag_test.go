@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestEtagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		weak   bool
+		want   bool
+	}{
+		{`"3"`, false, true},
+		{`"2"`, false, false},
+		{`"1", "3"`, false, true},
+		{`*`, false, true},
+		{`W/"3"`, false, false},
+		{`W/"3"`, true, true},
+		{`W/"2", "3"`, true, true},
+		{`3`, true, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, 3, tt.weak); got != tt.want {
+			t.Errorf("etagMatches(%s, weak=%v) = %v, want %v", tt.header, tt.weak, got, tt.want)
+		}
+	}
+}
+
+func newHeaderContext(header, value string) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	if value != "" {
+		c.Request.Header.Set(header, value)
+	}
+	return c, w
+}
+
+func TestNotModified(t *testing.T) {
+	c, w := newHeaderContext(ifNoneMatchHeader, `W/"7"`)
+	if !notModified(c, 7) {
+		t.Fatal("weak If-None-Match must match the current version")
+	}
+	if c.Writer.Status() != http.StatusNotModified || w.Header().Get("ETag") != `"7"` {
+		t.Errorf("status = %d, ETag = %q", c.Writer.Status(), w.Header().Get("ETag"))
+	}
+
+	c, _ = newHeaderContext(ifNoneMatchHeader, `"6"`)
+	if notModified(c, 7) {
+		t.Error("stale If-None-Match must not match")
+	}
+}
+
+func TestPreconditionVersion(t *testing.T) {
+	current := func() (int, error) { return 4, nil }
+
+	tests := []struct {
+		name    string
+		ifMatch string
+		version int
+		ok      bool
+	}{
+		{"no header", "", 0, true},
+		{"current", `"4"`, 4, true},
+		{"any", `*`, 4, true},
+		{"stale", `"3"`, 0, false},
+		{"weak", `W/"4"`, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newHeaderContext(ifMatchHeader, tt.ifMatch)
+			version, ok := preconditionVersion(c, current)
+			if version != tt.version || ok != tt.ok {
+				t.Errorf("preconditionVersion() = %d, %v, want %d, %v", version, ok, tt.version, tt.ok)
+			}
+			if !tt.ok && w.Code != http.StatusPreconditionFailed {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusPreconditionFailed)
+			}
+		})
+	}
+
+	c, w := newHeaderContext(ifMatchHeader, `"4"`)
+	if _, ok := preconditionVersion(c, func() (int, error) { return 0, errors.New("boom") }); ok || w.Code != http.StatusInternalServerError {
+		t.Errorf("lookup error: ok = %v, status = %d", ok, w.Code)
+	}
+}
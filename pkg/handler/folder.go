@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+)
+
+// newFolderErrorResponse отвечает на ошибки операций с папками: указанная в запросе
+// папка не найдена - 400, перенос папки внутрь себя - 409
+func newFolderErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, todo.ErrFolderNotFound):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, todo.ErrFolderCycle):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	default:
+		newServiceErrorResponse(c, err)
+	}
+}
+
+// CreateFolder создает папку
+// @Summary Create folder
+// @Description Create a folder of the current user in the root or in another folder
+// @Security ApiKeyAuth
+// @Tags folders
+// @Accept json
+// @Produce json
+// @Param input body todo.FolderInput true "Folder"
+// @Success 201 {object} todo.Folder
+// @Failure 400 {object} problemDetails
+// @Router /api/v2/folders [post]
+func (h *Handler) createFolder(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var input todo.FolderInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	input.Normalize()
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	folder, err := h.services.Folders.Create(userId, input)
+	if err != nil {
+		newFolderErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, folder)
+}
+
+// GetFolderTree возвращает дерево папок и списков
+// @Summary Get folder tree
+// @Description Folders of the current user with nested folders and non-archived lists, sorted by name.
+// @Description item_count is the number of non-archived items in a list or in all lists of a folder and its subfolders.
+// @Security ApiKeyAuth
+// @Tags folders
+// @Produce json
+// @Success 200 {object} todo.FolderTree
+// @Router /api/v2/folders/tree [get]
+func (h *Handler) getFolderTree(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	tree, err := h.services.Folders.GetTree(userId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tree)
+}
+
+// GetFolderById возвращает папку
+// @Summary Get folder
+// @Security ApiKeyAuth
+// @Tags folders
+// @Produce json
+// @Param id path int true "Folder ID"
+// @Success 200 {object} todo.Folder
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/folders/{id} [get]
+func (h *Handler) getFolderById(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	folder, err := h.services.Folders.GetById(userId, id)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, folder)
+}
+
+// UpdateFolder переименовывает и переносит папку
+// @Summary Update folder
+// @Description Rename the folder and/or move it to another folder; parent_id = null moves it to the root.
+// @Description Moving a folder into itself or its subfolder returns 409.
+// @Security ApiKeyAuth
+// @Tags folders
+// @Accept json
+// @Produce json
+// @Param id path int true "Folder ID"
+// @Param input body todo.UpdateFolderInput true "Folder changes"
+// @Success 200 {object} todo.Folder
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Failure 409 {object} problemDetails
+// @Router /api/v2/folders/{id} [put]
+func (h *Handler) updateFolder(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.UpdateFolderInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	input.Normalize()
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	folder, err := h.services.Folders.Update(userId, id, input)
+	if err != nil {
+		newFolderErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, folder)
+}
+
+// DeleteFolder удаляет папку
+// @Summary Delete folder
+// @Description mode=reparent (default) moves subfolders and lists of the folder to its parent.
+// @Description mode=cascade deletes subfolders and the lists in the folder and its subfolders for all their members.
+// @Security ApiKeyAuth
+// @Tags folders
+// @Param id path int true "Folder ID"
+// @Param mode query string false "reparent or cascade" default(reparent)
+// @Success 204
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/folders/{id} [delete]
+func (h *Handler) deleteFolder(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	mode := c.DefaultQuery("mode", todo.FolderDeleteReparent)
+	if mode != todo.FolderDeleteReparent && mode != todo.FolderDeleteCascade {
+		newErrorResponse(c, http.StatusBadRequest, "invalid mode param")
+		return
+	}
+
+	if err := h.requestServices(c).Folders.Delete(userId, id, mode); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// MoveListToFolder кладет список в папку
+// @Summary Move list to folder
+// @Description Put the list into a folder of the current user; folder_id = null moves it to the root.
+// @Description Other members of a shared list keep it in their own folders.
+// @Security ApiKeyAuth
+// @Tags folders
+// @Accept json
+// @Produce json
+// @Param id path int true "List ID"
+// @Param input body todo.MoveListInput true "Target folder"
+// @Success 200 {object} todo.TodoList
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/lists/{id}/folder [put]
+func (h *Handler) moveListToFolder(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.MoveListInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	list, err := h.services.Folders.MoveList(userId, listId, input.FolderId)
+	if err != nil {
+		newFolderErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
@@ -1,7 +1,10 @@
 package handler
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
 	"github.com/ktuty/todo-app/pkg/handler/middleware"
 	"github.com/ktuty/todo-app/pkg/service"
 	swaggerFiles "github.com/swaggo/files"
@@ -12,17 +15,46 @@ import (
 
 type Handler struct {
 	services *service.Service
+	config   Config
+}
+
+// Config - настройки HTTP-слоя
+type Config struct {
+	// LegacyV1Errors включает для v1 API старый формат ошибок {"message": ...}
+	// вместо application/problem+json
+	LegacyV1Errors bool
+	// BulkMaxOperations - максимальное число операций в одном пакетном запросе
+	BulkMaxOperations int
+	// StreamHeartbeat - интервал heartbeat в потоке событий
+	StreamHeartbeat time.Duration
+	// StreamAllowedOrigins - источники (scheme://host[:port]), с которых браузер может открыть
+	// WebSocket-поток, помимо собственного хоста сервера
+	StreamAllowedOrigins []string
+	// AttachmentMaxSize - максимальный размер вложения в байтах
+	AttachmentMaxSize int64
+	// AttachmentTypes - допустимые MIME-типы вложений
+	AttachmentTypes []string
+	// AttachmentURLTTL - срок действия подписанной ссылки на скачивание
+	AttachmentURLTTL time.Duration
+	// UploadTimeout и DownloadTimeout заменяют таймауты сервера для загрузки и скачивания файлов
+	UploadTimeout   time.Duration
+	DownloadTimeout time.Duration
 }
 
-func NewHandler(services *service.Service) *Handler {
-	return &Handler{services: services}
+func NewHandler(services *service.Service, config Config) *Handler {
+	return &Handler{services: services, config: config}
 }
 
 func (h *Handler) InitRoutes() *gin.Engine {
 	router := gin.New()
+	router.Use(h.requestId)
+	if h.config.LegacyV1Errors {
+		router.Use(h.legacyErrors)
+	}
 
 	// Rate limiter: 100 запросов в минуту для всех эндпоинтов
 	rateLimiter := middleware.NewRateLimiter(100, 100)
+	rateLimiter.WithExceededHandler(newRateLimitResponse)
 	router.Use(rateLimiter.RateLimit())
 
 	// Swagger документация
@@ -47,8 +79,41 @@ func (h *Handler) InitRoutes() *gin.Engine {
 	{
 		h.initListRoutesV2(v2)
 		h.initItemRoutesV2(v2)
+		h.initWebhookRoutes(v2)
+		h.initCommentRoutes(v2)
+		h.initNotificationRoutes(v2)
+		h.initDigestRoutes(v2)
+		h.initAttachmentRoutes(v2)
+		h.initFolderRoutes(v2)
+		h.initTemplateRoutes(v2)
+
+		v2.DELETE("/reminders/:id", h.deleteReminder)
+		v2.GET("/settings", h.getSettings)
+		v2.PUT("/settings", h.updateSettings)
+
+		v2.GET("/dependencies", h.getDependencyGraph)
+
+		v2.GET("/timer", h.getRunningTimer)
+		v2.POST("/timer/stop", h.stopTimer)
+		v2.DELETE("/time-entries/:id", h.deleteTimeEntry)
+		v2.GET("/reports/time", h.getTimeReport)
+
+		v2.GET("/activity", h.getActivityFeed)
+		v2.GET("/sync", h.getSyncChanges)
+		v2.POST("/sync", h.applySyncChanges)
+	}
+
+	// Поток событий вне группы v2: долгоживущее соединение не должно расходовать
+	// лимит запросов. Токен в query-параметре принимается только при upgrade до WebSocket
+	stream := router.Group("/api/v2/stream")
+	{
+		stream.GET("", h.userIdentity, h.streamEvents)
+		stream.GET("/ws", h.streamToken, h.userIdentity, h.streamEventsWS)
 	}
 
+	// Скачивание по подписанной ссылке: авторизация заменяется подписью
+	router.GET("/files/attachments/:id", deadlines(0, h.config.DownloadTimeout), h.downloadSignedAttachment)
+
 	router.GET("/health", h.healthCheck)
 
 	return router
@@ -84,23 +149,148 @@ func (h *Handler) initItemRoutes(api *gin.RouterGroup) {
 func (h *Handler) initListRoutesV2(api *gin.RouterGroup) {
 	lists := api.Group("/lists")
 	{
-		lists.POST("/", h.createListV2)            // с поддержкой идемпотентности
-		lists.GET("/", h.getAllListsV2)            // с пагинацией и фильтрацией
-		lists.GET("/:id", h.getListByIdV2)         // с расширенной информацией
-		lists.PUT("/:id", h.updateListV2)          // с частичным обновлением
-		lists.DELETE("/:id", h.deleteListV2)       // с мягким удалением
-		lists.PATCH("/:id/archive", h.archiveList) // новая возможность - архивация
+		lists.POST("/", h.createListV2)               // с поддержкой идемпотентности
+		lists.GET("/", h.getAllListsV2)               // с пагинацией и фильтрацией
+		lists.GET("/:id", h.getListByIdV2)            // с расширенной информацией
+		lists.PUT("/:id", h.updateListV2)             // с частичным обновлением
+		lists.PATCH("/:id", h.patchListV2)            // JSON Merge Patch / JSON Patch
+		lists.DELETE("/:id", h.deleteListV2)          // с мягким удалением
+		lists.PATCH("/:id/archive", h.archiveList)    // новая возможность - архивация
+		lists.GET("/:id/activity", h.getListActivity) // журнал изменений
+		lists.GET("/:id/members", h.getListMembers)   // совместный доступ
+		lists.POST("/:id/members", h.addListMember)
+		lists.DELETE("/:id/members/:user_id", h.removeListMember)
+		lists.GET("/:id/workflow", h.getWorkflow) // статусы items
+		lists.PUT("/:id/workflow", h.setWorkflow)
+		lists.GET("/:id/board", h.getBoard)           // Kanban-доска по статусам
+		lists.PUT("/:id/folder", h.moveListToFolder)  // папка текущего пользователя
+		lists.GET("/:id/sections", h.getListSections) // разделы с ручным порядком
+		lists.POST("/:id/sections", h.createSection)
+		lists.PUT("/:id/sections/order", h.reorderSections)
+		lists.PUT("/:id/sections/:section_id", h.updateSection)
+		lists.DELETE("/:id/sections/:section_id", h.deleteSection)
+		lists.PUT("/:id/items/order", h.moveItemsToSection)
+		lists.POST("/:id/template", h.saveListAsTemplate) // сохранение шаблоном
+		lists.POST("/:id/duplicate", h.duplicateList)     // копия со всеми items
+		lists.POST("/:id/merge", h.mergeList)             // перенос items другого списка
 	}
+	h.initRevisionRoutes(lists, todo.EntityList)
 }
 
 func (h *Handler) initItemRoutesV2(api *gin.RouterGroup) {
 	items := api.Group("items")
 	{
 		items.POST("/", h.createItemV2)              // с поддержкой идемпотентности
+		items.POST("/bulk", h.bulkItems)             // пакетные операции
 		items.GET("/", h.getAllItemsV2)              // с пагинацией
 		items.GET("/:id", h.getItemByIdV2)           // с расширенной информацией
 		items.PUT("/:id", h.updateItemV2)            // с частичным обновлением
+		items.PATCH("/:id", h.patchItemV2)           // JSON Merge Patch / JSON Patch
 		items.DELETE("/:id", h.deleteItemV2)         // с мягким удалением
 		items.PATCH("/:id/complete", h.completeItem) // новая возможность - отметка выполнения
+		items.GET("/:id/comments", h.getComments)    // обсуждение item
+		items.POST("/:id/comments", h.createComment)
+		items.GET("/:id/reminders", h.getReminders) // напоминания о сроке
+		items.POST("/:id/reminders", h.createReminder)
+		items.POST("/:id/assignees", h.assignItem) // ответственные
+		items.DELETE("/:id/assignees/:user_id", h.unassignItem)
+		items.POST("/:id/dependencies", h.addDependency) // блокирующие items
+		items.DELETE("/:id/dependencies/:blocked_by_id", h.removeDependency)
+		items.GET("/:id/checklist", h.getChecklist) // чек-лист
+		items.POST("/:id/checklist", h.addChecklistItem)
+		items.PUT("/:id/checklist/order", h.reorderChecklist)
+		items.PUT("/:id/checklist/:entry_id", h.updateChecklistItem)
+		items.DELETE("/:id/checklist/:entry_id", h.deleteChecklistItem)
+		items.GET("/:id/attachments", h.getAttachments) // файлы
+		items.POST("/:id/attachments", deadlines(h.config.UploadTimeout, h.config.UploadTimeout), h.uploadAttachment)
+		items.POST("/:id/timer", h.startTimer) // учет времени
+		items.GET("/:id/time-entries", h.getTimeEntries)
+		items.POST("/:id/time-entries", h.createTimeEntry)
+	}
+	h.initRevisionRoutes(items, todo.EntityItem)
+}
+
+func (h *Handler) initRevisionRoutes(group *gin.RouterGroup, entity string) {
+	revisions := group.Group("/:id/revisions")
+	{
+		revisions.GET("/", h.getRevisions(entity))
+		revisions.GET("/diff", h.diffRevisions(entity))
+		revisions.GET("/:rev", h.getRevision(entity))
+		revisions.POST("/:rev/revert", h.revertRevision(entity))
+	}
+}
+
+func (h *Handler) initCommentRoutes(api *gin.RouterGroup) {
+	comments := api.Group("/comments")
+	{
+		comments.GET("/:id", h.getCommentById)
+		comments.PUT("/:id", h.updateComment)
+		comments.DELETE("/:id", h.deleteComment)
+		comments.GET("/:id/edits", h.getCommentEdits)
+	}
+}
+
+func (h *Handler) initNotificationRoutes(api *gin.RouterGroup) {
+	notifications := api.Group("/notifications")
+	{
+		notifications.GET("/", h.getNotifications)
+		notifications.POST("/read-all", h.markAllNotificationsRead)
+		notifications.POST("/:id/read", h.markNotificationRead)
+		notifications.GET("/preferences", h.getNotificationPreferences)
+		notifications.PUT("/preferences", h.updateNotificationPreferences)
+	}
+}
+
+func (h *Handler) initDigestRoutes(api *gin.RouterGroup) {
+	digest := api.Group("/digest")
+	{
+		digest.GET("/preferences", h.getDigestPreferences)
+		digest.PUT("/preferences", h.updateDigestPreferences)
+		digest.GET("/preview", h.previewDigest)
+	}
+}
+
+func (h *Handler) initAttachmentRoutes(api *gin.RouterGroup) {
+	attachments := api.Group("/attachments")
+	{
+		attachments.GET("/:id", h.getAttachmentById)
+		attachments.DELETE("/:id", h.deleteAttachment)
+		attachments.GET("/:id/content", deadlines(0, h.config.DownloadTimeout), h.downloadAttachment)
+		attachments.POST("/:id/url", h.signAttachmentURL)
+	}
+}
+
+func (h *Handler) initFolderRoutes(api *gin.RouterGroup) {
+	folders := api.Group("/folders")
+	{
+		folders.POST("/", h.createFolder)
+		folders.GET("/tree", h.getFolderTree)
+		folders.GET("/:id", h.getFolderById)
+		folders.PUT("/:id", h.updateFolder)
+		folders.DELETE("/:id", h.deleteFolder)
+	}
+}
+
+func (h *Handler) initTemplateRoutes(api *gin.RouterGroup) {
+	templates := api.Group("/templates")
+	{
+		templates.POST("/", h.createTemplate)
+		templates.GET("/", h.getTemplates)
+		templates.GET("/:id", h.getTemplateById)
+		templates.PUT("/:id", h.updateTemplate)
+		templates.DELETE("/:id", h.deleteTemplate)
+		templates.POST("/:id/instantiate", h.instantiateTemplate)
+	}
+}
+
+func (h *Handler) initWebhookRoutes(api *gin.RouterGroup) {
+	webhooks := api.Group("/webhooks")
+	{
+		webhooks.POST("/", h.createWebhook)
+		webhooks.GET("/", h.getAllWebhooks)
+		webhooks.GET("/:id", h.getWebhookById)
+		webhooks.DELETE("/:id", h.deleteWebhook)
+		webhooks.GET("/:id/deliveries", h.getWebhookDeliveries)
+		webhooks.POST("/:id/deliveries/:delivery_id/redeliver", h.redeliverWebhook)
 	}
 }
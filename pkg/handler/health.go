@@ -2,6 +2,7 @@ package handler
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 	"net/http"
 	"runtime"
 	"time"
@@ -14,12 +15,20 @@ import (
 // @Accept json
 // @Produce json
 // @Success 200 {object} healthResponse
+// @Failure 503 {object} problemDetails
 // @Router /health [get]
 func (h *Handler) healthCheck(c *gin.Context) {
-	// Проверяем статус базы данных
-	dbStatus := "connected"
+	// Проверяем статус базы данных. Причина ошибки пишется только в лог:
+	// эндпоинт доступен без авторизации, а в ней бывают адрес и данные драйвера
 	if err := h.checkDatabase(); err != nil {
-		dbStatus = "disconnected"
+		logrus.Errorf("health check: database is unreachable: %s", err.Error())
+		writeProblem(c, problemDetails{
+			Type:   problemTypeUnavailable,
+			Title:  "Service unavailable",
+			Status: http.StatusServiceUnavailable,
+			Detail: "database is unreachable",
+		})
+		return
 	}
 
 	// Получаем информацию о системе
@@ -31,7 +40,7 @@ func (h *Handler) healthCheck(c *gin.Context) {
 		Timestamp: time.Now().Format(time.RFC3339),
 		Version:   "2.0",
 		Dependencies: dependencies{
-			Database: dbStatus,
+			Database: "connected",
 		},
 		System: systemInfo{
 			Goroutines: runtime.NumGoroutine(),
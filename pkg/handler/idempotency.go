@@ -3,8 +3,10 @@ package handler
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -21,14 +23,14 @@ import (
 // @Param input body createListV2Request true "List info with idempotency key"
 // @Success 201 {object} todo.TodoList
 // @Success 200 {object} idempotentResponse
-// @Failure 400 {object} errorResponse
-// @Failure 500 {object} errorResponse
+// @Failure 400 {object} problemDetails
+// @Failure 500 {object} problemDetails
 // @Router /api/v2/lists [post]
 func (h *Handler) createListV2(c *gin.Context) {
 	var input createListV2Request
 
-	if err := c.BindJSON(&input); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
 		return
 	}
 
@@ -71,7 +73,7 @@ func (h *Handler) createListV2(c *gin.Context) {
 		Description: input.Description,
 	}
 
-	id, err := h.services.TodoList.Create(userId, list)
+	id, err := h.requestServices(c).TodoList.Create(userId, list)
 	if err != nil {
 		newErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -107,7 +109,7 @@ func (h *Handler) createListV2(c *gin.Context) {
 // @Param limit query int false "Items per page" default(10)
 // @Param archived query bool false "Filter by archived status"
 // @Success 200 {object} getAllListsV2Response
-// @Failure 500 {object} errorResponse
+// @Failure 500 {object} problemDetails
 // @Router /api/v2/lists [get]
 func (h *Handler) getAllListsV2(c *gin.Context) {
 	userId, err := getUserId(c)
@@ -163,8 +165,9 @@ func (h *Handler) getAllListsV2(c *gin.Context) {
 // @Produce json
 // @Param id path int true "List ID"
 // @Success 200 {object} todoListV2Response
-// @Failure 400 {object} errorResponse
-// @Failure 500 {object} errorResponse
+// @Success 304 {string} string "Not Modified"
+// @Failure 400 {object} problemDetails
+// @Failure 500 {object} problemDetails
 // @Router /api/v2/lists/{id} [get]
 func (h *Handler) getListByIdV2(c *gin.Context) {
 	userId, err := getUserId(c)
@@ -181,7 +184,11 @@ func (h *Handler) getListByIdV2(c *gin.Context) {
 
 	list, err := h.services.TodoList.GetById(userId, id)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	if notModified(c, list.Version) {
 		return
 	}
 
@@ -210,10 +217,12 @@ func (h *Handler) getListByIdV2(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path int true "List ID"
+// @Param If-Match header string false "ETag of the version being updated"
 // @Param input body updateListV2Request true "List update data"
 // @Success 200 {object} todo.TodoList
-// @Failure 400 {object} errorResponse
-// @Failure 500 {object} errorResponse
+// @Failure 400 {object} problemDetails
+// @Failure 412 {object} problemDetails
+// @Failure 500 {object} problemDetails
 // @Router /api/v2/lists/{id} [put]
 func (h *Handler) updateListV2(c *gin.Context) {
 	userId, err := getUserId(c)
@@ -229,8 +238,8 @@ func (h *Handler) updateListV2(c *gin.Context) {
 	}
 
 	var input updateListV2Request
-	if err := c.BindJSON(&input); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
 		return
 	}
 
@@ -241,18 +250,29 @@ func (h *Handler) updateListV2(c *gin.Context) {
 		Archived:    input.Archived,
 	}
 
-	if err := h.services.TodoList.Update(userId, id, updateInput); err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+	version, ok := preconditionVersion(c, h.listVersion(userId, id))
+	if !ok {
+		return
+	}
+
+	if version > 0 {
+		err = h.requestServices(c).TodoList.UpdateIfVersion(userId, id, version, updateInput)
+	} else {
+		err = h.requestServices(c).TodoList.Update(userId, id, updateInput)
+	}
+	if err != nil {
+		newServiceErrorResponse(c, err)
 		return
 	}
 
 	// Возвращаем обновленный список
 	list, err := h.services.TodoList.GetById(userId, id)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newServiceErrorResponse(c, err)
 		return
 	}
 
+	c.Header("ETag", formatETag(list.Version))
 	c.JSON(http.StatusOK, list)
 }
 
@@ -264,9 +284,11 @@ func (h *Handler) updateListV2(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path int true "List ID"
+// @Param If-Match header string false "ETag of the current version"
 // @Success 200 {object} statusResponse
-// @Failure 400 {object} errorResponse
-// @Failure 500 {object} errorResponse
+// @Failure 400 {object} problemDetails
+// @Failure 412 {object} problemDetails
+// @Failure 500 {object} problemDetails
 // @Router /api/v2/lists/{id} [delete]
 func (h *Handler) deleteListV2(c *gin.Context) {
 	userId, err := getUserId(c)
@@ -282,9 +304,7 @@ func (h *Handler) deleteListV2(c *gin.Context) {
 	}
 
 	// Мягкое удаление - устанавливаем флаг archived
-	err = h.services.TodoList.ArchiveList(userId, id)
-	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+	if !h.archiveListWithPrecondition(c, userId, id) {
 		return
 	}
 
@@ -301,9 +321,11 @@ func (h *Handler) deleteListV2(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path int true "List ID"
+// @Param If-Match header string false "ETag of the current version"
 // @Success 200 {object} statusResponse
-// @Failure 400 {object} errorResponse
-// @Failure 500 {object} errorResponse
+// @Failure 400 {object} problemDetails
+// @Failure 412 {object} problemDetails
+// @Failure 500 {object} problemDetails
 // @Router /api/v2/lists/{id}/archive [patch]
 func (h *Handler) archiveList(c *gin.Context) {
 	userId, err := getUserId(c)
@@ -318,9 +340,7 @@ func (h *Handler) archiveList(c *gin.Context) {
 		return
 	}
 
-	err = h.services.TodoList.ArchiveList(userId, id)
-	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+	if !h.archiveListWithPrecondition(c, userId, id) {
 		return
 	}
 
@@ -339,14 +359,14 @@ func (h *Handler) archiveList(c *gin.Context) {
 // @Param input body createItemV2Request true "Item info with idempotency key"
 // @Success 201 {object} todo.TodoItem
 // @Success 200 {object} idempotentResponse
-// @Failure 400 {object} errorResponse
-// @Failure 500 {object} errorResponse
+// @Failure 400 {object} problemDetails
+// @Failure 500 {object} problemDetails
 // @Router /api/v2/items [post]
 func (h *Handler) createItemV2(c *gin.Context) {
 	var input createItemV2Request
 
-	if err := c.BindJSON(&input); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
 		return
 	}
 
@@ -383,11 +403,21 @@ func (h *Handler) createItemV2(c *gin.Context) {
 	}
 
 	item := todo.TodoItem{
-		Title:       input.Title,
-		Description: input.Description,
+		Title:           input.Title,
+		Description:     input.Description,
+		DueAt:           input.DueAt,
+		AutoComplete:    input.AutoComplete,
+		StatusId:        input.StatusId,
+		Priority:        input.Priority,
+		EstimateMinutes: input.Estimate,
+		SectionId:       input.SectionId,
+	}
+
+	id, err := h.requestServices(c).TodoItem.Create(userId, input.ListId, item)
+	if errors.Is(err, todo.ErrInvalidStatus) || errors.Is(err, todo.ErrInvalidSection) {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
 	}
-
-	id, err := h.services.TodoItem.Create(userId, input.ListId, item)
 	if err != nil {
 		newErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -414,8 +444,12 @@ func (h *Handler) createItemV2(c *gin.Context) {
 // @Param limit query int false "Items per page" default(10)
 // @Param list_id query int false "Filter by list ID"
 // @Param completed query bool false "Filter by completion status"
+// @Param assignee query string false "Filter by assignee: user ID or me"
+// @Param blocked query bool false "Filter by having unfinished blocking items"
+// @Param status query string false "Filter by workflow status IDs, comma-separated"
 // @Success 200 {object} getAllItemsV2Response
-// @Failure 500 {object} errorResponse
+// @Failure 400 {object} problemDetails
+// @Failure 500 {object} problemDetails
 // @Router /api/v2/items [get]
 func (h *Handler) getAllItemsV2(c *gin.Context) {
 	userId, err := getUserId(c)
@@ -428,6 +462,12 @@ func (h *Handler) getAllItemsV2(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
 	listId, _ := strconv.Atoi(c.Query("list_id"))
 	completed := c.Query("completed")
+	blocked := c.Query("blocked")
+
+	if blocked != "" && blocked != "true" && blocked != "false" {
+		newErrorResponse(c, http.StatusBadRequest, "invalid blocked param")
+		return
+	}
 
 	if page < 1 {
 		page = 1
@@ -436,9 +476,39 @@ func (h *Handler) getAllItemsV2(c *gin.Context) {
 		limit = 10
 	}
 
+	assigneeId := 0
+	if assignee := c.Query("assignee"); assignee == "me" {
+		assigneeId = userId
+	} else if assignee != "" {
+		assigneeId, err = strconv.Atoi(assignee)
+		if err != nil || assigneeId < 1 {
+			newErrorResponse(c, http.StatusBadRequest, "invalid assignee param")
+			return
+		}
+	}
+
+	var statusIds []int
+	if status := c.Query("status"); status != "" {
+		for _, part := range strings.Split(status, ",") {
+			statusId, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || statusId < 1 {
+				newErrorResponse(c, http.StatusBadRequest, "invalid status param")
+				return
+			}
+			statusIds = append(statusIds, statusId)
+		}
+	}
+
 	offset := (page - 1) * limit
 
-	items, total, err := h.services.TodoItem.GetAllWithPagination(userId, listId, offset, limit, completed)
+	filter := todo.ItemFilter{
+		ListId:     listId,
+		AssigneeId: assigneeId,
+		Completed:  completed,
+		Blocked:    blocked,
+		StatusIds:  statusIds,
+	}
+	items, total, err := h.services.TodoItem.GetAllWithPagination(userId, filter, offset, limit)
 	if err != nil {
 		newErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -468,8 +538,9 @@ func (h *Handler) getAllItemsV2(c *gin.Context) {
 // @Produce json
 // @Param id path int true "Item ID"
 // @Success 200 {object} todo.TodoItem
-// @Failure 400 {object} errorResponse
-// @Failure 500 {object} errorResponse
+// @Success 304 {string} string "Not Modified"
+// @Failure 400 {object} problemDetails
+// @Failure 500 {object} problemDetails
 // @Router /api/v2/items/{id} [get]
 func (h *Handler) getItemByIdV2(c *gin.Context) {
 	h.getItemById(c)
@@ -483,10 +554,14 @@ func (h *Handler) getItemByIdV2(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path int true "Item ID"
+// @Param If-Match header string false "ETag of the version being updated"
 // @Param input body todo.UpdateItemInput true "Item update data"
+// @Param force query bool false "Complete even if blocking items are unfinished"
 // @Success 200 {object} todo.TodoItem
-// @Failure 400 {object} errorResponse
-// @Failure 500 {object} errorResponse
+// @Failure 400 {object} problemDetails
+// @Failure 409 {object} problemDetails
+// @Failure 412 {object} problemDetails
+// @Failure 500 {object} problemDetails
 // @Router /api/v2/items/{id} [put]
 func (h *Handler) updateItemV2(c *gin.Context) {
 	h.updateItem(c)
@@ -500,9 +575,11 @@ func (h *Handler) updateItemV2(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path int true "Item ID"
+// @Param If-Match header string false "ETag of the current version"
 // @Success 200 {object} statusResponse
-// @Failure 400 {object} errorResponse
-// @Failure 500 {object} errorResponse
+// @Failure 400 {object} problemDetails
+// @Failure 412 {object} problemDetails
+// @Failure 500 {object} problemDetails
 // @Router /api/v2/items/{id} [delete]
 func (h *Handler) deleteItemV2(c *gin.Context) {
 	userId, err := getUserId(c)
@@ -517,9 +594,19 @@ func (h *Handler) deleteItemV2(c *gin.Context) {
 		return
 	}
 
-	err = h.services.TodoItem.ArchiveItem(userId, id)
+	version, ok := preconditionVersion(c, h.itemVersion(userId, id))
+	if !ok {
+		return
+	}
+
+	if version > 0 {
+		archived := true
+		err = h.requestServices(c).TodoItem.UpdateIfVersion(userId, id, version, todo.UpdateItemInput{Archived: &archived})
+	} else {
+		err = h.requestServices(c).TodoItem.ArchiveItem(userId, id)
+	}
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newServiceErrorResponse(c, err)
 		return
 	}
 
@@ -530,15 +617,19 @@ func (h *Handler) deleteItemV2(c *gin.Context) {
 
 // CompleteItem отмечает item как выполненный
 // @Summary Complete item
-// @Description Mark todo item as completed
+// @Description Mark todo item as completed. An item with unfinished blocking items is rejected with 409 unless force=true.
 // @Security ApiKeyAuth
 // @Tags items-v2
 // @Accept json
 // @Produce json
 // @Param id path int true "Item ID"
+// @Param If-Match header string false "ETag of the current version"
+// @Param force query bool false "Complete even if blocking items are unfinished"
 // @Success 200 {object} statusResponse
-// @Failure 400 {object} errorResponse
-// @Failure 500 {object} errorResponse
+// @Failure 400 {object} problemDetails
+// @Failure 409 {object} problemDetails
+// @Failure 412 {object} problemDetails
+// @Failure 500 {object} problemDetails
 // @Router /api/v2/items/{id}/complete [patch]
 func (h *Handler) completeItem(c *gin.Context) {
 	userId, err := getUserId(c)
@@ -553,9 +644,20 @@ func (h *Handler) completeItem(c *gin.Context) {
 		return
 	}
 
-	err = h.services.TodoItem.CompleteItem(userId, id)
+	version, ok := preconditionVersion(c, h.itemVersion(userId, id))
+	if !ok {
+		return
+	}
+
+	force := forceParam(c)
+	if version > 0 {
+		done := true
+		err = h.requestServices(c).TodoItem.UpdateIfVersion(userId, id, version, todo.UpdateItemInput{Done: &done, Force: force})
+	} else {
+		err = h.requestServices(c).TodoItem.CompleteItem(userId, id, force)
+	}
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newServiceErrorResponse(c, err)
 		return
 	}
 
@@ -566,24 +668,45 @@ func (h *Handler) completeItem(c *gin.Context) {
 
 // Вспомогательные структуры для v2 API
 type createListV2Request struct {
-	Title          string `json:"title" binding:"required"`
-	Description    string `json:"description"`
-	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	Title          string `json:"title" binding:"required,max=255"`
+	Description    string `json:"description" binding:"max=255"`
+	IdempotencyKey string `json:"idempotency_key,omitempty" binding:"max=255"`
+}
+
+func (r *createListV2Request) Normalize() {
+	r.Title = strings.TrimSpace(r.Title)
+	r.Description = strings.TrimSpace(r.Description)
 }
 
 type createItemV2Request struct {
-	Title          string `json:"title" binding:"required"`
-	Description    string `json:"description"`
-	ListId         int    `json:"list_id" binding:"required"`
-	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	Title          string     `json:"title" binding:"required,max=255"`
+	Description    string     `json:"description" binding:"max=255"`
+	ListId         int        `json:"list_id" binding:"required,gt=0"`
+	DueAt          *time.Time `json:"due_at,omitempty"`
+	AutoComplete   bool       `json:"checklist_auto_complete"`
+	StatusId       *int       `json:"status_id,omitempty" binding:"omitempty,gt=0"`
+	Priority       *int       `json:"priority,omitempty" binding:"omitempty,gte=0,lte=3"`
+	Estimate       *int       `json:"estimate_minutes,omitempty" binding:"omitempty,gt=0,lte=525600"`
+	SectionId      *int       `json:"section_id,omitempty" binding:"omitempty,gt=0"`
+	IdempotencyKey string     `json:"idempotency_key,omitempty" binding:"max=255"`
+}
+
+func (r *createItemV2Request) Normalize() {
+	r.Title = strings.TrimSpace(r.Title)
+	r.Description = strings.TrimSpace(r.Description)
 }
 
 type updateListV2Request struct {
-	Title       *string `json:"title"`
-	Description *string `json:"description"`
+	Title       *string `json:"title" binding:"omitempty,min=1,max=255"`
+	Description *string `json:"description" binding:"omitempty,max=255"`
 	Archived    *bool   `json:"archived"`
 }
 
+func (r *updateListV2Request) Normalize() {
+	input := todo.UpdateListInput{Title: r.Title, Description: r.Description}
+	input.Normalize()
+}
+
 type idempotentResponse struct {
 	Message string      `json:"message"`
 	Status  string      `json:"status"`
@@ -614,6 +737,29 @@ type paginationMeta struct {
 	Pages int `json:"pages"`
 }
 
+// archiveListWithPrecondition архивирует список с учетом заголовка If-Match.
+// Возвращает false, если ответ с ошибкой уже отправлен
+func (h *Handler) archiveListWithPrecondition(c *gin.Context, userId, listId int) bool {
+	version, ok := preconditionVersion(c, h.listVersion(userId, listId))
+	if !ok {
+		return false
+	}
+
+	var err error
+	if version > 0 {
+		archived := true
+		err = h.requestServices(c).TodoList.UpdateIfVersion(userId, listId, version, todo.UpdateListInput{Archived: &archived})
+	} else {
+		err = h.requestServices(c).TodoList.ArchiveList(userId, listId)
+	}
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return false
+	}
+
+	return true
+}
+
 func generateIdempotencyKey(authHeader, userKey string) string {
 	hash := sha256.Sum256([]byte(authHeader + userKey))
 	return hex.EncodeToString(hash[:])
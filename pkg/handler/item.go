@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -19,9 +20,9 @@ import (
 // @Param id path int true "List ID"
 // @Param input body todo.TodoItem true "item info"
 // @Success 200 {integer} integer 1
-// @Failure 400,404 {object} errorResponse
-// @Failure 500 {object} errorResponse
-// @Failure default {object} errorResponse
+// @Failure 400,404 {object} problemDetails
+// @Failure 500 {object} problemDetails
+// @Failure default {object} problemDetails
 // @Router /api/v1/lists/{id}/items [post]
 func (h *Handler) createItem(c *gin.Context) {
 	userId, err := getUserId(c)
@@ -37,12 +38,16 @@ func (h *Handler) createItem(c *gin.Context) {
 	}
 
 	var input todo.TodoItem
-	if err := c.BindJSON(&input); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
 		return
 	}
 
-	id, err := h.services.TodoItem.Create(userId, listId, input)
+	id, err := h.requestServices(c).TodoItem.Create(userId, listId, input)
+	if errors.Is(err, todo.ErrInvalidStatus) || errors.Is(err, todo.ErrInvalidSection) {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		newErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -63,9 +68,9 @@ func (h *Handler) createItem(c *gin.Context) {
 // @Produce  json
 // @Param id path int true "List ID"
 // @Success 200 {object} []todo.TodoItem
-// @Failure 400,404 {object} errorResponse
-// @Failure 500 {object} errorResponse
-// @Failure default {object} errorResponse
+// @Failure 400,404 {object} problemDetails
+// @Failure 500 {object} problemDetails
+// @Failure default {object} problemDetails
 // @Router /api/v1/lists/{id}/items [get]
 func (h *Handler) getAllItems(c *gin.Context) {
 	userId, err := getUserId(c)
@@ -99,9 +104,10 @@ func (h *Handler) getAllItems(c *gin.Context) {
 // @Produce  json
 // @Param id path int true "Item ID"
 // @Success 200 {object} todo.TodoItem
-// @Failure 400,404 {object} errorResponse
-// @Failure 500 {object} errorResponse
-// @Failure default {object} errorResponse
+// @Success 304 {string} string "Not Modified"
+// @Failure 400,404 {object} problemDetails
+// @Failure 500 {object} problemDetails
+// @Failure default {object} problemDetails
 // @Router /api/v1/items/{id} [get]
 func (h *Handler) getItemById(c *gin.Context) {
 	userId, err := getUserId(c)
@@ -118,7 +124,11 @@ func (h *Handler) getItemById(c *gin.Context) {
 
 	item, err := h.services.TodoItem.GetById(userId, itemId)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	if notModified(c, item.Version) {
 		return
 	}
 
@@ -134,11 +144,15 @@ func (h *Handler) getItemById(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Item ID"
+// @Param If-Match header string false "ETag of the version being updated"
 // @Param input body todo.UpdateItemInput true "Item update data"
+// @Param force query bool false "Complete even if blocking items are unfinished"
 // @Success 200 {object} statusResponse
-// @Failure 400,404 {object} errorResponse
-// @Failure 500 {object} errorResponse
-// @Failure default {object} errorResponse
+// @Failure 400,404 {object} problemDetails
+// @Failure 409 {object} problemDetails
+// @Failure 412 {object} problemDetails
+// @Failure 500 {object} problemDetails
+// @Failure default {object} problemDetails
 // @Router /api/v1/items/{id} [put]
 func (h *Handler) updateItem(c *gin.Context) {
 	userId, err := getUserId(c)
@@ -154,13 +168,24 @@ func (h *Handler) updateItem(c *gin.Context) {
 	}
 
 	var input todo.UpdateItemInput
-	if err := c.BindJSON(&input); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
 		return
 	}
+	input.Force = forceParam(c)
 
-	if err := h.services.TodoItem.Update(userId, id, input); err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+	version, ok := preconditionVersion(c, h.itemVersion(userId, id))
+	if !ok {
+		return
+	}
+
+	if version > 0 {
+		err = h.requestServices(c).TodoItem.UpdateIfVersion(userId, id, version, input)
+	} else {
+		err = h.requestServices(c).TodoItem.Update(userId, id, input)
+	}
+	if err != nil {
+		newServiceErrorResponse(c, err)
 		return
 	}
 
@@ -176,10 +201,12 @@ func (h *Handler) updateItem(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param id path int true "Item ID"
+// @Param If-Match header string false "ETag of the version being deleted"
 // @Success 200 {object} statusResponse
-// @Failure 400,404 {object} errorResponse
-// @Failure 500 {object} errorResponse
-// @Failure default {object} errorResponse
+// @Failure 400,404 {object} problemDetails
+// @Failure 412 {object} problemDetails
+// @Failure 500 {object} problemDetails
+// @Failure default {object} problemDetails
 // @Router /api/v1/items/{id} [delete]
 func (h *Handler) deleteItem(c *gin.Context) {
 	userId, err := getUserId(c)
@@ -194,9 +221,18 @@ func (h *Handler) deleteItem(c *gin.Context) {
 		return
 	}
 
-	err = h.services.TodoItem.Delete(userId, itemId)
+	version, ok := preconditionVersion(c, h.itemVersion(userId, itemId))
+	if !ok {
+		return
+	}
+
+	if version > 0 {
+		err = h.requestServices(c).TodoItem.DeleteIfVersion(userId, itemId, version)
+	} else {
+		err = h.requestServices(c).TodoItem.Delete(userId, itemId)
+	}
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newServiceErrorResponse(c, err)
 		return
 	}
 
@@ -18,9 +18,9 @@ import (
 // @Produce  json
 // @Param input body todo.TodoList true "list info"
 // @Success 200 {integer} integer 1
-// @Failure 400,404 {object} errorResponse
-// @Failure 500 {object} errorResponse
-// @Failure default {object} errorResponse
+// @Failure 400,404 {object} problemDetails
+// @Failure 500 {object} problemDetails
+// @Failure default {object} problemDetails
 // @Router /api/v1/lists [post]
 func (h *Handler) createList(c *gin.Context) {
 	userId, err := getUserId(c)
@@ -30,12 +30,12 @@ func (h *Handler) createList(c *gin.Context) {
 	}
 
 	var input todo.TodoList
-	if err := c.BindJSON(&input); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
 		return
 	}
 
-	id, err := h.services.TodoList.Create(userId, input)
+	id, err := h.requestServices(c).TodoList.Create(userId, input)
 	if err != nil {
 		newErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -59,9 +59,9 @@ type getAllListsResponse struct {
 // @Accept  json
 // @Produce  json
 // @Success 200 {object} getAllListsResponse
-// @Failure 400,404 {object} errorResponse
-// @Failure 500 {object} errorResponse
-// @Failure default {object} errorResponse
+// @Failure 400,404 {object} problemDetails
+// @Failure 500 {object} problemDetails
+// @Failure default {object} problemDetails
 // @Router /api/v1/lists [get]
 func (h *Handler) getAllLists(c *gin.Context) {
 	userId, err := getUserId(c)
@@ -91,9 +91,10 @@ func (h *Handler) getAllLists(c *gin.Context) {
 // @Produce  json
 // @Param id path int true "List ID"
 // @Success 200 {object} todo.TodoList
-// @Failure 400 {object} errorResponse
-// @Failure 404 {object} errorResponse
-// @Failure 500 {object} errorResponse
+// @Success 304 {string} string "Not Modified"
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Failure 500 {object} problemDetails
 // @Router /api/v1/lists/{id} [get]
 func (h *Handler) getListById(c *gin.Context) {
 	userId, err := getUserId(c)
@@ -110,7 +111,11 @@ func (h *Handler) getListById(c *gin.Context) {
 
 	list, err := h.services.TodoList.GetById(userId, id)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	if notModified(c, list.Version) {
 		return
 	}
 
@@ -126,11 +131,13 @@ func (h *Handler) getListById(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param id path int true "List ID"
+// @Param If-Match header string false "ETag of the version being updated"
 // @Param input body todo.UpdateListInput true "List update data"
 // @Success 200 {object} statusResponse
-// @Failure 400 {object} errorResponse
-// @Failure 404 {object} errorResponse
-// @Failure 500 {object} errorResponse
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Failure 412 {object} problemDetails
+// @Failure 500 {object} problemDetails
 // @Router /api/v1/lists/{id} [put]
 func (h *Handler) updateList(c *gin.Context) {
 	userId, err := getUserId(c)
@@ -146,13 +153,23 @@ func (h *Handler) updateList(c *gin.Context) {
 	}
 
 	var input todo.UpdateListInput
-	if err := c.BindJSON(&input); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
 		return
 	}
 
-	if err := h.services.TodoList.Update(userId, id, input); err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+	version, ok := preconditionVersion(c, h.listVersion(userId, id))
+	if !ok {
+		return
+	}
+
+	if version > 0 {
+		err = h.requestServices(c).TodoList.UpdateIfVersion(userId, id, version, input)
+	} else {
+		err = h.requestServices(c).TodoList.Update(userId, id, input)
+	}
+	if err != nil {
+		newServiceErrorResponse(c, err)
 		return
 	}
 
@@ -168,10 +185,12 @@ func (h *Handler) updateList(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param id path int true "List ID"
+// @Param If-Match header string false "ETag of the version being deleted"
 // @Success 200 {object} statusResponse
-// @Failure 400 {object} errorResponse
-// @Failure 404 {object} errorResponse
-// @Failure 500 {object} errorResponse
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Failure 412 {object} problemDetails
+// @Failure 500 {object} problemDetails
 // @Router /api/v1/lists/{id} [delete]
 func (h *Handler) deleteList(c *gin.Context) {
 	userId, err := getUserId(c)
@@ -186,9 +205,18 @@ func (h *Handler) deleteList(c *gin.Context) {
 		return
 	}
 
-	err = h.services.TodoList.Delete(userId, id)
+	version, ok := preconditionVersion(c, h.listVersion(userId, id))
+	if !ok {
+		return
+	}
+
+	if version > 0 {
+		err = h.requestServices(c).TodoList.DeleteIfVersion(userId, id, version)
+	} else {
+		err = h.requestServices(c).TodoList.Delete(userId, id)
+	}
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newServiceErrorResponse(c, err)
 		return
 	}
 
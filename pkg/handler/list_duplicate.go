@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+)
+
+// DuplicateList копирует список с items
+// @Summary Duplicate list
+// @Description Copy the list with its sections, workflow, items, checklists, dependencies and assignees in one transaction.
+// @Description Completed items are copied unless include_completed is false; archived items only with include_archived.
+// @Description include_members gives the members of the list access to the copy; reset_done unchecks items and checklist entries.
+// @Security ApiKeyAuth
+// @Tags lists-v2
+// @Accept json
+// @Produce json
+// @Param id path int true "List ID"
+// @Param input body todo.DuplicateListInput false "Copy options"
+// @Success 201 {object} todo.TodoList
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/lists/{id}/duplicate [post]
+func (h *Handler) duplicateList(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	input := todo.NewDuplicateListInput()
+	if c.Request.ContentLength != 0 {
+		if err := bindJSON(c, &input); err != nil {
+			newValidationErrorResponse(c, err)
+			return
+		}
+	}
+
+	list, err := h.requestServices(c).TodoList.Duplicate(userId, listId, input)
+	if err != nil {
+		if errors.Is(err, todo.ErrEmptyTitle) {
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, list)
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+)
+
+type listMembersResponse struct {
+	Data []todo.ListMember `json:"data"`
+}
+
+// GetListMembers возвращает пользователей с доступом к списку
+// @Summary Get list members
+// @Security ApiKeyAuth
+// @Tags lists-v2
+// @Produce json
+// @Param id path int true "List ID"
+// @Success 200 {object} listMembersResponse
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/lists/{id}/members [get]
+func (h *Handler) getListMembers(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	members, err := h.services.ListMembers.GetAll(userId, listId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, listMembersResponse{Data: members})
+}
+
+// AddListMember открывает доступ к списку другому пользователю
+// @Summary Share list
+// @Description Give the user with this username access to the list. The user is notified.
+// @Description Returns 201 for a new member and 200 if the user already had access.
+// @Security ApiKeyAuth
+// @Tags lists-v2
+// @Accept json
+// @Produce json
+// @Param id path int true "List ID"
+// @Param input body todo.AddListMemberInput true "Member"
+// @Success 201 {object} todo.ListMember
+// @Success 200 {object} todo.ListMember
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/lists/{id}/members [post]
+func (h *Handler) addListMember(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.AddListMemberInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	member, added, err := h.requestServices(c).ListMembers.Add(userId, listId, input.Username)
+	if err != nil {
+		if errors.Is(err, todo.ErrUserNotFound) {
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	status := http.StatusOK
+	if added {
+		status = http.StatusCreated
+	}
+	c.JSON(status, member)
+}
+
+// RemoveListMember закрывает пользователю доступ к списку
+// @Summary Remove list member
+// @Description Revoke access of a member (or leave the list by removing yourself). Only the list creator can remove other members. The last member cannot be removed.
+// @Security ApiKeyAuth
+// @Tags lists-v2
+// @Param id path int true "List ID"
+// @Param user_id path int true "Member user ID"
+// @Success 204
+// @Failure 400 {object} problemDetails
+// @Failure 403 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Failure 409 {object} problemDetails
+// @Router /api/v2/lists/{id}/members/{user_id} [delete]
+func (h *Handler) removeListMember(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	memberId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid user_id param")
+		return
+	}
+
+	if err := h.requestServices(c).ListMembers.Remove(userId, listId, memberId); err != nil {
+		if errors.Is(err, todo.ErrLastListMember) {
+			newErrorResponse(c, http.StatusConflict, err.Error())
+			return
+		}
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
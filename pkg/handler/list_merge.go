@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+)
+
+// MergeList переносит в список items другого списка
+// @Summary Merge list
+// @Description Move all items of the source list into the list in one transaction, keeping their order and sections.
+// @Description Sections of the source join sections with the same name or are added after the sections of the list.
+// @Description Members of the source get access to the list. The source is archived, or deleted with delete_source.
+// @Description The merge is recorded as list.merged in the activity of the list. Both lists must be available to the user.
+// @Security ApiKeyAuth
+// @Tags lists-v2
+// @Accept json
+// @Produce json
+// @Param id path int true "List ID"
+// @Param input body todo.MergeListInput true "Source list"
+// @Success 200 {object} todo.TodoList
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/lists/{id}/merge [post]
+func (h *Handler) mergeList(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.MergeListInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	list, err := h.requestServices(c).TodoList.Merge(userId, listId, input)
+	if err != nil {
+		if errors.Is(err, todo.ErrMergeIntoItself) {
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
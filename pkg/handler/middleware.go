@@ -1,18 +1,87 @@
 package handler
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/service"
 )
 
 const (
 	authorizationHeader = "Authorization"
+	requestIdHeader     = "X-Request-ID"
 	userCtx             = "userId"
+	requestIdCtx        = "requestId"
+	legacyErrorsCtx     = "legacyErrors"
 )
 
+// requestSeq нумерует запросы, если генератор случайных чисел недоступен
+var requestSeq atomic.Uint64
+
+// requestId присваивает запросу идентификатор (или берет переданный клиентом)
+// и возвращает его в заголовке ответа
+func (h *Handler) requestId(c *gin.Context) {
+	id := c.GetHeader(requestIdHeader)
+	if id == "" || len(id) > 128 {
+		id = newRequestId()
+	}
+
+	c.Set(requestIdCtx, id)
+	c.Header(requestIdHeader, id)
+}
+
+// newRequestId возвращает случайный идентификатор, а при ошибке генератора -
+// время запуска процесса и порядковый номер запроса, уникальные в пределах процесса
+func newRequestId() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(startTime.UnixNano(), 36) + "-" + strconv.FormatUint(requestSeq.Add(1), 36)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// requestServices возвращает сервисы, записывающие в журнал аудита автора и сведения
+// о текущем запросе
+func (h *Handler) requestServices(c *gin.Context) *service.Service {
+	return h.services.WithRequest(todo.RequestMeta{
+		ActorId:   c.GetInt(userCtx),
+		RequestId: c.GetString(requestIdCtx),
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// deadlines заменяет таймауты чтения и записи сервера для маршрутов с большими телами
+// запросов и ответов; нулевое значение оставляет таймаут сервера
+func deadlines(read, write time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		controller := http.NewResponseController(c.Writer)
+		now := time.Now()
+		if read > 0 {
+			controller.SetReadDeadline(now.Add(read))
+		}
+		if write > 0 {
+			controller.SetWriteDeadline(now.Add(write))
+		}
+	}
+}
+
+// legacyErrors переключает ответы об ошибках v1 API на формат {"message": ...}.
+// Регистрируется до глобальных middleware, чтобы в этом формате отвечали и они
+func (h *Handler) legacyErrors(c *gin.Context) {
+	if strings.HasPrefix(c.Request.URL.Path, "/api/v1/") || c.Request.URL.Path == "/api/v1" {
+		c.Set(legacyErrorsCtx, true)
+	}
+}
+
 func (h *Handler) userIdentity(c *gin.Context) {
 	header := c.GetHeader(authorizationHeader)
 	if header == "" {
@@ -9,16 +9,33 @@ import (
 	"golang.org/x/time/rate"
 )
 
+// ExceededHandler формирует ответ, когда лимит запросов исчерпан
+type ExceededHandler func(c *gin.Context, retryAfter int64, limit int)
+
 type RateLimiter struct {
-	limiter *rate.Limiter
+	limiter  *rate.Limiter
+	exceeded ExceededHandler
 }
 
 func NewRateLimiter(r rate.Limit, b int) *RateLimiter {
 	return &RateLimiter{
-		limiter: rate.NewLimiter(r, b),
+		limiter:  rate.NewLimiter(r, b),
+		exceeded: defaultExceededHandler,
 	}
 }
 
+// WithExceededHandler заменяет ответ по умолчанию на превышение лимита
+func (rl *RateLimiter) WithExceededHandler(fn ExceededHandler) *RateLimiter {
+	rl.exceeded = fn
+	return rl
+}
+
+func defaultExceededHandler(c *gin.Context, retryAfter int64, limit int) {
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error": "Too many requests",
+	})
+}
+
 func (rl *RateLimiter) RateLimit() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if !rl.limiter.Allow() {
@@ -29,10 +46,7 @@ func (rl *RateLimiter) RateLimit() gin.HandlerFunc {
 			c.Header("X-RateLimit-Reset", strconv.FormatInt(retryAfter, 10))
 			c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
 
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error": "Too many requests",
-			})
-			c.Abort()
+			rl.exceeded(c, retryAfter, rl.limiter.Burst())
 			return
 		}
 
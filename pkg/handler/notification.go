@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+)
+
+type notificationsResponse struct {
+	Data   []todo.Notification `json:"data"`
+	Unread int                 `json:"unread"`
+	Meta   paginationMeta      `json:"meta"`
+}
+
+type markAllReadResponse struct {
+	Updated int64 `json:"updated"`
+}
+
+type notificationPreferencesResponse struct {
+	Data map[string]bool `json:"data"`
+}
+
+// GetNotifications возвращает входящие уведомления пользователя
+// @Summary Get notifications
+// @Description Notifications of the current user, newest first, with the number of unread ones
+// @Security ApiKeyAuth
+// @Tags notifications-v2
+// @Produce json
+// @Param unread query bool false "Only unread notifications"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} notificationsResponse
+// @Failure 500 {object} problemDetails
+// @Router /api/v2/notifications [get]
+func (h *Handler) getNotifications(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	unreadOnly := c.Query("unread") == "true"
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	notifications, total, err := h.services.Notifications.GetAll(userId, unreadOnly, (page-1)*limit, limit)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	unread, err := h.services.Notifications.CountUnread(userId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	c.Header("X-Page", strconv.Itoa(page))
+	c.Header("X-Limit", strconv.Itoa(limit))
+
+	c.JSON(http.StatusOK, notificationsResponse{
+		Data:   notifications,
+		Unread: unread,
+		Meta: paginationMeta{
+			Page:  page,
+			Limit: limit,
+			Total: total,
+			Pages: (total + limit - 1) / limit,
+		},
+	})
+}
+
+// MarkNotificationRead отмечает уведомление прочитанным
+// @Summary Mark notification as read
+// @Security ApiKeyAuth
+// @Tags notifications-v2
+// @Param id path int true "Notification ID"
+// @Success 204
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/notifications/{id}/read [post]
+func (h *Handler) markNotificationRead(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.services.Notifications.MarkRead(userId, id); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// MarkAllNotificationsRead отмечает прочитанными все уведомления
+// @Summary Mark all notifications as read
+// @Security ApiKeyAuth
+// @Tags notifications-v2
+// @Produce json
+// @Success 200 {object} markAllReadResponse
+// @Failure 500 {object} problemDetails
+// @Router /api/v2/notifications/read-all [post]
+func (h *Handler) markAllNotificationsRead(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	updated, err := h.services.Notifications.MarkAllRead(userId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, markAllReadResponse{Updated: updated})
+}
+
+// GetNotificationPreferences возвращает настройки уведомлений
+// @Summary Get notification preferences
+// @Description Whether each notification type is enabled; types are enabled by default
+// @Security ApiKeyAuth
+// @Tags notifications-v2
+// @Produce json
+// @Success 200 {object} notificationPreferencesResponse
+// @Failure 500 {object} problemDetails
+// @Router /api/v2/notifications/preferences [get]
+func (h *Handler) getNotificationPreferences(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	preferences, err := h.services.Notifications.GetPreferences(userId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, notificationPreferencesResponse{Data: preferences})
+}
+
+// UpdateNotificationPreferences включает или отключает типы уведомлений
+// @Summary Update notification preferences
+// @Description Enable or disable notification types, e.g. {"mention": true, "list_shared": false}. Omitted types keep their setting.
+// @Security ApiKeyAuth
+// @Tags notifications-v2
+// @Accept json
+// @Produce json
+// @Param input body map[string]bool true "Preferences by type"
+// @Success 200 {object} notificationPreferencesResponse
+// @Failure 400 {object} problemDetails
+// @Router /api/v2/notifications/preferences [put]
+func (h *Handler) updateNotificationPreferences(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var input map[string]bool
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	for notificationType := range input {
+		if !todo.IsNotificationType(notificationType) {
+			newErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("unknown notification type %q", notificationType))
+			return
+		}
+	}
+
+	preferences, err := h.services.Notifications.SetPreferences(userId, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, notificationPreferencesResponse{Data: preferences})
+}
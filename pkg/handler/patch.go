@@ -0,0 +1,269 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/handler/patch"
+)
+
+// Поля представления, которые нельзя изменить патчем
+var readOnlyPatchFields = []string{"id", "created_at", "updated_at", "version", "comment_count", "assignee_ids",
+	"checklist_total", "checklist_checked", "blocked_by_ids", "blocked", "tracked_minutes",
+	"folder_id", "position"}
+
+// PatchListV2 частично обновляет список
+// @Summary Patch list (v2)
+// @Description Partially update todo list with JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902)
+// @Description null clears description to an empty string
+// @Security ApiKeyAuth
+// @Tags lists-v2
+// @Accept application/merge-patch+json
+// @Accept application/json-patch+json
+// @Produce json
+// @Param id path int true "List ID"
+// @Param If-Match header string false "ETag of the version being patched"
+// @Param input body object true "Merge patch object or array of JSON Patch operations"
+// @Success 200 {object} todo.TodoList
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Failure 409 {object} problemDetails
+// @Failure 412 {object} problemDetails
+// @Failure 415 {object} problemDetails
+// @Failure 422 {object} problemDetails
+// @Failure 500 {object} problemDetails
+// @Router /api/v2/lists/{id} [patch]
+func (h *Handler) patchListV2(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	current, err := h.services.TodoList.GetById(userId, id)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	if _, ok := preconditionVersion(c, func() (int, error) { return current.Version, nil }); !ok {
+		return
+	}
+
+	var patched todo.TodoList
+	if !applyPatch(c, current, &patched) {
+		return
+	}
+
+	// Все поля применяются одним обновлением с проверкой версии,
+	// поэтому параллельное изменение приведет к 412, а не к потере данных
+	input := todo.UpdateListInput{
+		Title:       &patched.Title,
+		Description: &patched.Description,
+		Archived:    &patched.Archived,
+		Color:       &patched.Color,
+		Priority:    &patched.Priority,
+	}
+	if err := h.requestServices(c).TodoList.UpdateIfVersion(userId, id, current.Version, input); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	list, err := h.services.TodoList.GetById(userId, id)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.Header("ETag", formatETag(list.Version))
+	c.JSON(http.StatusOK, list)
+}
+
+// PatchItemV2 частично обновляет item
+// @Summary Patch item (v2)
+// @Description Partially update todo item with JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902)
+// @Description null clears description to an empty string
+// @Security ApiKeyAuth
+// @Tags items-v2
+// @Accept application/merge-patch+json
+// @Accept application/json-patch+json
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param If-Match header string false "ETag of the version being patched"
+// @Param input body object true "Merge patch object or array of JSON Patch operations"
+// @Param force query bool false "Complete even if blocking items are unfinished"
+// @Success 200 {object} todo.TodoItem
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Failure 409 {object} problemDetails
+// @Failure 412 {object} problemDetails
+// @Failure 415 {object} problemDetails
+// @Failure 422 {object} problemDetails
+// @Failure 500 {object} problemDetails
+// @Router /api/v2/items/{id} [patch]
+func (h *Handler) patchItemV2(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	current, err := h.services.TodoItem.GetById(userId, id)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	if _, ok := preconditionVersion(c, func() (int, error) { return current.Version, nil }); !ok {
+		return
+	}
+
+	var patched todo.TodoItem
+	if !applyPatch(c, current, &patched) {
+		return
+	}
+
+	input := todo.UpdateItemInput{
+		Title:           &patched.Title,
+		Description:     &patched.Description,
+		Done:            &patched.Done,
+		Archived:        &patched.Archived,
+		DueAt:           todo.NewOptionalTime(patched.DueAt),
+		AutoComplete:    &patched.AutoComplete,
+		Priority:        todo.NewOptionalInt(patched.Priority),
+		EstimateMinutes: todo.NewOptionalInt(patched.EstimateMinutes),
+		Force:           forceParam(c),
+	}
+	// Статус передается, только если изменился: иначе он перекрыл бы изменение done
+	if patched.StatusId != nil && (current.StatusId == nil || *patched.StatusId != *current.StatusId) {
+		input.StatusId = patched.StatusId
+	}
+	// Раздел тоже только при изменении, чтобы не пересчитывать позицию item
+	if (patched.SectionId == nil) != (current.SectionId == nil) ||
+		patched.SectionId != nil && *patched.SectionId != *current.SectionId {
+		input.SectionId = todo.NewOptionalInt(patched.SectionId)
+	}
+	if err := h.requestServices(c).TodoItem.UpdateIfVersion(userId, id, current.Version, input); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	item, err := h.services.TodoItem.GetById(userId, id)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.Header("ETag", formatETag(item.Version))
+	c.JSON(http.StatusOK, item)
+}
+
+// applyPatch применяет патч из тела запроса к JSON-представлению current
+// и декодирует результат в out с теми же правилами валидации, что и при создании.
+// Строковые поля без значения хранятся пустой строкой, поэтому null (или удаление
+// поля) очищает их до "". Возвращает false, если ответ с ошибкой уже отправлен
+func applyPatch(c *gin.Context, current interface{}, out interface{}) bool {
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if mediaType != patch.MergePatchContentType && mediaType != patch.JSONPatchContentType {
+		c.Header("Accept-Patch", patch.MergePatchContentType+", "+patch.JSONPatchContentType)
+		newErrorResponse(c, http.StatusUnsupportedMediaType,
+			"use "+patch.MergePatchContentType+" or "+patch.JSONPatchContentType)
+		return false
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return false
+	}
+
+	original, err := json.Marshal(current)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return false
+	}
+
+	var patched []byte
+	if mediaType == patch.MergePatchContentType {
+		patched, err = patch.MergePatch(original, body)
+	} else {
+		patched, err = patch.JSONPatch(original, body)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, patch.ErrTestFailed):
+			newErrorResponse(c, http.StatusConflict, err.Error())
+		case errors.Is(err, patch.ErrPathNotFound):
+			newErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		default:
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+		}
+		return false
+	}
+
+	if fields := readOnlyChanges(original, patched); len(fields) > 0 {
+		writeProblem(c, problemDetails{
+			Type:   problemTypeValidation,
+			Title:  "Validation failed",
+			Status: http.StatusUnprocessableEntity,
+			Detail: "patch modifies read-only fields",
+			Errors: fields,
+		})
+		return false
+	}
+
+	if err := decodeJSON(bytes.NewReader(patched), out); err != nil {
+		newValidationErrorResponse(c, err)
+		return false
+	}
+
+	return true
+}
+
+// readOnlyChanges возвращает ошибки для служебных полей, измененных патчем
+func readOnlyChanges(original, patched []byte) []fieldError {
+	var before, after map[string]json.RawMessage
+	if json.Unmarshal(original, &before) != nil || json.Unmarshal(patched, &after) != nil {
+		return []fieldError{{Field: "", Rule: "type", Message: "patched document must be an object"}}
+	}
+
+	var fields []fieldError
+	for _, name := range readOnlyPatchFields {
+		if !bytes.Equal(compactJSON(before[name]), compactJSON(after[name])) {
+			fields = append(fields, fieldError{
+				Field:   name,
+				Rule:    "readonly",
+				Message: "is read-only",
+			})
+		}
+	}
+	return fields
+}
+
+func compactJSON(raw json.RawMessage) []byte {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return []byte(strings.TrimSpace(string(raw)))
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/handler/patch"
+)
+
+func newPatchContext(contentType, body string) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPatch, "/api/v2/lists/1", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", contentType)
+	return c, w
+}
+
+func TestApplyPatch(t *testing.T) {
+	current := todo.TodoList{Id: 1, Title: "Work", Description: "Office tasks", Color: "blue", Priority: 2, Version: 3}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		check       func(t *testing.T, list todo.TodoList)
+	}{
+		{
+			name:        "merge patch",
+			contentType: patch.MergePatchContentType,
+			body:        `{"title":" Home ","priority":4}`,
+			check: func(t *testing.T, list todo.TodoList) {
+				if list.Title != "Home" || list.Priority != 4 || list.Description != "Office tasks" {
+					t.Errorf("list = %+v", list)
+				}
+			},
+		},
+		{
+			name:        "merge patch null clears description",
+			contentType: patch.MergePatchContentType + "; charset=utf-8",
+			body:        `{"description":null}`,
+			check: func(t *testing.T, list todo.TodoList) {
+				if list.Description != "" || list.Title != "Work" {
+					t.Errorf("list = %+v", list)
+				}
+			},
+		},
+		{
+			name:        "json patch null clears description",
+			contentType: patch.JSONPatchContentType,
+			body:        `[{"op":"test","path":"/title","value":"Work"},{"op":"replace","path":"/description","value":null}]`,
+			check: func(t *testing.T, list todo.TodoList) {
+				if list.Description != "" {
+					t.Errorf("description = %q", list.Description)
+				}
+			},
+		},
+		{"unsupported media type", "application/json", `{"title":"x"}`, http.StatusUnsupportedMediaType, nil},
+		{"failed test", patch.JSONPatchContentType, `[{"op":"test","path":"/title","value":"Home"}]`, http.StatusConflict, nil},
+		{"missing path", patch.JSONPatchContentType, `[{"op":"remove","path":"/nope"}]`, http.StatusUnprocessableEntity, nil},
+		{"malformed patch", patch.JSONPatchContentType, `{"op":"remove"}`, http.StatusBadRequest, nil},
+		{"read-only field", patch.MergePatchContentType, `{"version":9}`, http.StatusUnprocessableEntity, nil},
+		{"validation rules", patch.MergePatchContentType, `{"title":""}`, http.StatusBadRequest, nil},
+		{"invalid color", patch.JSONPatchContentType, `[{"op":"replace","path":"/color","value":"magenta"}]`, http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := newPatchContext(tt.contentType, tt.body)
+			var patched todo.TodoList
+			ok := applyPatch(c, current, &patched)
+
+			if tt.check != nil {
+				if !ok {
+					t.Fatalf("applyPatch failed: %d %s", w.Code, w.Body.String())
+				}
+				tt.check(t, patched)
+				return
+			}
+			if ok || w.Code != tt.status {
+				t.Errorf("ok = %v, status = %d, want %d: %s", ok, w.Code, tt.status, w.Body.String())
+			}
+		})
+	}
+}
@@ -9,5 +9,6 @@ import (
 func (h *Handler) rateLimit(c *gin.Context) {
 	// Создаем rate limiter специально для v2 API (более либеральные лимиты)
 	v2RateLimiter := middleware.NewRateLimiter(200, 300) // 200 запросов в минуту, burst 300
+	v2RateLimiter.WithExceededHandler(newRateLimitResponse)
 	v2RateLimiter.RateLimit()(c)
 }
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+)
+
+type remindersResponse struct {
+	Data []todo.Reminder `json:"data"`
+}
+
+// GetReminders возвращает напоминания пользователя о сроке item
+// @Summary Get item reminders
+// @Description Reminders of the current user for the item with the next fire time computed from the current due date
+// @Security ApiKeyAuth
+// @Tags items-v2
+// @Produce json
+// @Param id path int true "Item ID"
+// @Success 200 {object} remindersResponse
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/items/{id}/reminders [get]
+func (h *Handler) getReminders(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	reminders, err := h.services.Reminders.GetAll(userId, itemId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, remindersResponse{Data: reminders})
+}
+
+// CreateReminder добавляет напоминание о сроке item
+// @Summary Create item reminder
+// @Description Either offset_minutes before the due date, or at_time (HH:MM in the user's time zone) days_before the due day.
+// @Description The reminder is emailed to the address from /api/v2/settings and follows due date changes.
+// @Description Each reminder also creates a due_soon notification, even for users without an email address.
+// @Security ApiKeyAuth
+// @Tags items-v2
+// @Accept json
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param input body todo.ReminderInput true "Reminder"
+// @Success 201 {object} todo.Reminder
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/items/{id}/reminders [post]
+func (h *Handler) createReminder(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.ReminderInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	reminder, err := h.services.Reminders.Create(userId, itemId, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, reminder)
+}
+
+// DeleteReminder удаляет напоминание
+// @Summary Delete reminder
+// @Security ApiKeyAuth
+// @Tags items-v2
+// @Param id path int true "Reminder ID"
+// @Success 204
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/reminders/{id} [delete]
+func (h *Handler) deleteReminder(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.services.Reminders.Delete(userId, id); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -1,40 +1,147 @@
 package handler
 
 import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
 	"github.com/sirupsen/logrus"
-	"time"
 )
 
+const (
+	problemContentType = "application/problem+json"
+
+	// Типы ошибок (RFC 7807). Относительные URI разрешаются относительно хоста API
+	problemTypeBlank       = "about:blank"
+	problemTypeValidation  = "/problems/validation-error"
+	problemTypeRateLimit   = "/problems/rate-limit-exceeded"
+	problemTypeUnavailable = "/problems/service-unavailable"
+)
+
+// errorResponse - устаревший формат ошибок v1, доступен при включенном legacy-режиме
 type errorResponse struct {
 	Message string `json:"message"`
 }
 
+// problemDetails - ответ об ошибке в формате application/problem+json (RFC 7807)
+type problemDetails struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestId string       `json:"request_id,omitempty"`
+	Errors    []fieldError `json:"errors,omitempty"`
+
+	// Расширения для ответов rate limiting
+	RetryAfter int64 `json:"retry_after,omitempty"`
+	Limit      int   `json:"limit,omitempty"`
+	Remaining  *int  `json:"remaining,omitempty"`
+}
+
+// fieldError описывает ошибку валидации конкретного поля запроса
+type fieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
 type statusResponse struct {
 	Status string `json:"status"`
 }
 
-type rateLimitResponse struct {
-	Error      string `json:"error"`
-	RetryAfter int    `json:"retry_after,omitempty"`
-	Limit      int    `json:"limit,omitempty"`
-	Remaining  int    `json:"remaining,omitempty"`
+func newErrorResponse(c *gin.Context, statusCode int, message string) {
+	writeProblem(c, problemDetails{
+		Type:   problemTypeBlank,
+		Title:  http.StatusText(statusCode),
+		Status: statusCode,
+		Detail: message,
+	})
 }
 
-func newErrorResponse(c *gin.Context, statusCode int, message string) {
-	logrus.Error(message)
-	c.AbortWithStatusJSON(statusCode, errorResponse{message})
+// newServiceErrorResponse подбирает HTTP-статус по ошибке сервисного слоя
+func newServiceErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		newErrorResponse(c, http.StatusNotFound, "resource not found")
+	case errors.Is(err, todo.ErrVersionMismatch):
+		newErrorResponse(c, http.StatusPreconditionFailed, err.Error())
+	case errors.Is(err, todo.ErrForbidden):
+		newErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, todo.ErrItemBlocked):
+		newErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, todo.ErrInvalidStatus), errors.Is(err, todo.ErrInvalidSection),
+		errors.Is(err, todo.ErrWebhookURLNotAllowed), errors.Is(err, todo.ErrInvalidUpdate):
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// newValidationErrorResponse отвечает 400 с перечнем ошибок по полям,
+// если err содержит ошибки валидации, иначе - обычной ошибкой 400
+func newValidationErrorResponse(c *gin.Context, err error) {
+	fields := fieldErrorsFrom(err)
+	if len(fields) == 0 || c.GetBool(legacyErrorsCtx) {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeProblem(c, problemDetails{
+		Type:   problemTypeValidation,
+		Title:  "Validation failed",
+		Status: http.StatusBadRequest,
+		Detail: "request contains invalid fields",
+		Errors: fields,
+	})
 }
 
-func newRateLimitResponse(c *gin.Context, retryAfter int, limit int, remaining int) {
-	c.AbortWithStatusJSON(429, rateLimitResponse{
-		Error:      "Rate limit exceeded",
+func newRateLimitResponse(c *gin.Context, retryAfter int64, limit int) {
+	remaining := 0
+	writeProblem(c, problemDetails{
+		Type:       problemTypeRateLimit,
+		Title:      "Rate limit exceeded",
+		Status:     http.StatusTooManyRequests,
+		Detail:     "too many requests, retry later",
 		RetryAfter: retryAfter,
 		Limit:      limit,
-		Remaining:  remaining,
+		Remaining:  &remaining,
 	})
 }
 
+func writeProblem(c *gin.Context, p problemDetails) {
+	if p.Instance == "" {
+		p.Instance = c.Request.URL.Path
+	}
+	p.RequestId = c.GetString(requestIdCtx)
+
+	// Ошибки клиента не являются ошибками сервиса
+	entry := logrus.WithFields(logrus.Fields{
+		"status":     p.Status,
+		"path":       p.Instance,
+		"request_id": p.RequestId,
+	})
+	switch {
+	case p.Status >= http.StatusInternalServerError:
+		entry.Error(p.Detail)
+	case p.Status == http.StatusUnauthorized || p.Status == http.StatusForbidden || p.Status == http.StatusTooManyRequests:
+		entry.Info(p.Detail)
+	default:
+		entry.Debug(p.Detail)
+	}
+
+	if c.GetBool(legacyErrorsCtx) {
+		c.AbortWithStatusJSON(p.Status, errorResponse{Message: p.Detail})
+		return
+	}
+
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(p.Status, p)
+}
+
 // Дополнительные структуры для ответа
 type healthResponse struct {
 	Status       string       `json:"status"`
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app/pkg/service"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func newTestRouter(config Config) *gin.Engine {
+	return NewHandler(&service.Service{}, config).InitRoutes()
+}
+
+func serve(router http.Handler, method, target, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestErrorResponseIsProblemJSON(t *testing.T) {
+	router := newTestRouter(Config{})
+	w := serve(router, http.MethodGet, "/api/v2/lists/", "", map[string]string{requestIdHeader: "req-1"})
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, problemContentType) {
+		t.Fatalf("Content-Type = %q, want %q", ct, problemContentType)
+	}
+
+	var p problemDetails
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Type != problemTypeBlank || p.Status != http.StatusUnauthorized || p.Title != "Unauthorized" {
+		t.Errorf("problem = %+v", p)
+	}
+	if p.Detail != "empty auth header" || p.Instance != "/api/v2/lists/" || p.RequestId != "req-1" {
+		t.Errorf("problem = %+v", p)
+	}
+}
+
+func TestLegacyV1Errors(t *testing.T) {
+	router := newTestRouter(Config{LegacyV1Errors: true})
+
+	w := serve(router, http.MethodGet, "/api/v1/lists/", "", nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if body := strings.TrimSpace(w.Body.String()); body != `{"message":"empty auth header"}` {
+		t.Errorf("v1 body = %s", body)
+	}
+
+	w = serve(router, http.MethodGet, "/api/v2/lists/", "", nil)
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, problemContentType) {
+		t.Errorf("v2 Content-Type = %q, want %q", ct, problemContentType)
+	}
+}
+
+func TestLegacyV1ErrorsRateLimit(t *testing.T) {
+	router := newTestRouter(Config{LegacyV1Errors: true})
+
+	var w *httptest.ResponseRecorder
+	for i := 0; i < 200; i++ {
+		if w = serve(router, http.MethodGet, "/api/v1/lists/", "", nil); w.Code == http.StatusTooManyRequests {
+			break
+		}
+	}
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if ct := w.Header().Get("Content-Type"); strings.HasPrefix(ct, problemContentType) {
+		t.Errorf("v1 rate limit response is %s", ct)
+	}
+	var legacy errorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &legacy); err != nil || legacy.Message == "" {
+		t.Errorf("v1 rate limit body = %s", w.Body.String())
+	}
+}
+
+func TestRequestId(t *testing.T) {
+	router := newTestRouter(Config{})
+	generated := regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"client id", "client-request", true},
+		{"missing", "", false},
+		{"too long", strings.Repeat("x", 129), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(router, http.MethodGet, "/health", "", map[string]string{requestIdHeader: tt.header})
+			id := w.Header().Get(requestIdHeader)
+			if tt.keep && id != tt.header {
+				t.Errorf("id = %q, want %q", id, tt.header)
+			}
+			if !tt.keep && !generated.MatchString(id) {
+				t.Errorf("generated id = %q", id)
+			}
+		})
+	}
+}
+
+func TestValidationErrorResponse(t *testing.T) {
+	var input struct {
+		Title string `json:"title" binding:"required,max=5"`
+	}
+
+	tests := []struct {
+		body  string
+		field string
+		rule  string
+	}{
+		{`{}`, "title", "required"},
+		{`{"title":"too long"}`, "title", "max"},
+		{`{"title":1}`, "title", "type"},
+		{`{"name":"x"}`, "name", "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			fields := fieldErrorsFrom(decodeJSON(strings.NewReader(tt.body), &input))
+			if len(fields) != 1 || fields[0].Field != tt.field || fields[0].Rule != tt.rule {
+				t.Errorf("fields = %+v, want %s/%s", fields, tt.field, tt.rule)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+)
+
+type revisionsResponse struct {
+	Data []todo.Revision `json:"data"`
+	Meta paginationMeta  `json:"meta"`
+}
+
+// revisionTarget разбирает id ресурса из пути; при ошибке отвечает 400
+func revisionTarget(c *gin.Context) (int, int, bool) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return 0, 0, false
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return 0, 0, false
+	}
+
+	return userId, id, true
+}
+
+// GetRevisions возвращает ревизии списка или item
+// @Summary Get revisions
+// @Description Full snapshots of every version, newest first
+// @Security ApiKeyAuth
+// @Tags revisions-v2
+// @Produce json
+// @Param id path int true "List or item ID"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} revisionsResponse
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/lists/{id}/revisions [get]
+// @Router /api/v2/items/{id}/revisions [get]
+func (h *Handler) getRevisions(entity string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, id, ok := revisionTarget(c)
+		if !ok {
+			return
+		}
+
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		if page < 1 {
+			page = 1
+		}
+		if limit < 1 || limit > 100 {
+			limit = 20
+		}
+
+		revisions, total, err := h.services.Revisions.GetAll(userId, entity, id, (page-1)*limit, limit)
+		if err != nil {
+			newServiceErrorResponse(c, err)
+			return
+		}
+
+		c.Header("X-Total-Count", strconv.Itoa(total))
+		c.JSON(http.StatusOK, revisionsResponse{
+			Data: revisions,
+			Meta: paginationMeta{
+				Page:  page,
+				Limit: limit,
+				Total: total,
+				Pages: (total + limit - 1) / limit,
+			},
+		})
+	}
+}
+
+// GetRevision возвращает одну ревизию
+// @Summary Get revision
+// @Security ApiKeyAuth
+// @Tags revisions-v2
+// @Produce json
+// @Param id path int true "List or item ID"
+// @Param rev path int true "Revision (version number)"
+// @Success 200 {object} todo.Revision
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/lists/{id}/revisions/{rev} [get]
+// @Router /api/v2/items/{id}/revisions/{rev} [get]
+func (h *Handler) getRevision(entity string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, id, ok := revisionTarget(c)
+		if !ok {
+			return
+		}
+
+		rev, err := strconv.Atoi(c.Param("rev"))
+		if err != nil {
+			newErrorResponse(c, http.StatusBadRequest, "invalid rev param")
+			return
+		}
+
+		revision, err := h.services.Revisions.GetByVersion(userId, entity, id, rev)
+		if err != nil {
+			newServiceErrorResponse(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, revision)
+	}
+}
+
+// DiffRevisions возвращает изменения полей между двумя ревизиями
+// @Summary Diff revisions
+// @Description Field-level diff between any two revisions (updated_at and version are omitted)
+// @Security ApiKeyAuth
+// @Tags revisions-v2
+// @Produce json
+// @Param id path int true "List or item ID"
+// @Param from query int true "Base revision"
+// @Param to query int true "Compared revision"
+// @Success 200 {object} todo.RevisionDiff
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/lists/{id}/revisions/diff [get]
+// @Router /api/v2/items/{id}/revisions/diff [get]
+func (h *Handler) diffRevisions(entity string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, id, ok := revisionTarget(c)
+		if !ok {
+			return
+		}
+
+		from, err := strconv.Atoi(c.Query("from"))
+		if err != nil {
+			newErrorResponse(c, http.StatusBadRequest, "invalid from param")
+			return
+		}
+		to, err := strconv.Atoi(c.Query("to"))
+		if err != nil {
+			newErrorResponse(c, http.StatusBadRequest, "invalid to param")
+			return
+		}
+
+		diff, err := h.services.Revisions.Diff(userId, entity, id, from, to)
+		if err != nil {
+			newServiceErrorResponse(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, diff)
+	}
+}
+
+// RevertRevision восстанавливает содержимое ревизии, создавая новую ревизию
+// @Summary Revert to revision
+// @Description Restore the content of a revision. History is kept: the revert is saved as a new revision.
+// @Security ApiKeyAuth
+// @Tags revisions-v2
+// @Produce json
+// @Param id path int true "List or item ID"
+// @Param rev path int true "Revision to restore"
+// @Param If-Match header string false "ETag of the current version"
+// @Success 200 {object} todo.Revision
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Failure 412 {object} problemDetails
+// @Router /api/v2/lists/{id}/revisions/{rev}/revert [post]
+// @Router /api/v2/items/{id}/revisions/{rev}/revert [post]
+func (h *Handler) revertRevision(entity string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, id, ok := revisionTarget(c)
+		if !ok {
+			return
+		}
+
+		rev, err := strconv.Atoi(c.Param("rev"))
+		if err != nil {
+			newErrorResponse(c, http.StatusBadRequest, "invalid rev param")
+			return
+		}
+
+		current := h.itemVersion(userId, id)
+		if entity == todo.EntityList {
+			current = h.listVersion(userId, id)
+		}
+		version, ok := preconditionVersion(c, current)
+		if !ok {
+			return
+		}
+
+		revision, err := h.requestServices(c).Revisions.Revert(userId, entity, id, rev, version)
+		if err != nil {
+			newServiceErrorResponse(c, err)
+			return
+		}
+
+		c.Header("ETag", formatETag(revision.Version))
+		c.JSON(http.StatusOK, revision)
+	}
+}
@@ -0,0 +1,288 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+)
+
+type sectionsResponse struct {
+	Data []todo.ListSection `json:"data"`
+}
+
+// GetListSections возвращает items списка по разделам
+// @Summary Get list sections
+// @Description Non-archived items of the list grouped by section: items without a section first, then sections in their order.
+// @Description Items keep their manual order inside a section.
+// @Security ApiKeyAuth
+// @Tags sections
+// @Produce json
+// @Param id path int true "List ID"
+// @Success 200 {object} todo.ListSections
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/lists/{id}/sections [get]
+func (h *Handler) getListSections(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	sections, err := h.services.TodoItem.GetSectionItems(userId, listId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sections)
+}
+
+// CreateSection добавляет раздел в список
+// @Summary Create section
+// @Description Add a named section to the list. Without position the section is appended.
+// @Security ApiKeyAuth
+// @Tags sections
+// @Accept json
+// @Produce json
+// @Param id path int true "List ID"
+// @Param input body todo.SectionInput true "Section"
+// @Success 201 {object} todo.ListSection
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/lists/{id}/sections [post]
+func (h *Handler) createSection(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.SectionInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	input.Normalize()
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	section, err := h.requestServices(c).TodoList.CreateSection(userId, listId, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, section)
+}
+
+// UpdateSection переименовывает раздел
+// @Summary Rename section
+// @Security ApiKeyAuth
+// @Tags sections
+// @Accept json
+// @Produce json
+// @Param id path int true "List ID"
+// @Param section_id path int true "Section ID"
+// @Param input body todo.UpdateSectionInput true "Section name"
+// @Success 200 {object} todo.ListSection
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/lists/{id}/sections/{section_id} [put]
+func (h *Handler) updateSection(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	sectionId, err := strconv.Atoi(c.Param("section_id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid section_id param")
+		return
+	}
+
+	var input todo.UpdateSectionInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	input.Normalize()
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	section, err := h.requestServices(c).TodoList.RenameSection(userId, listId, sectionId, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, section)
+}
+
+// ReorderSections задает новый порядок разделов списка
+// @Summary Reorder sections
+// @Description Put sections of the list in the given order. ids must list every section of the list exactly once.
+// @Security ApiKeyAuth
+// @Tags sections
+// @Accept json
+// @Produce json
+// @Param id path int true "List ID"
+// @Param input body todo.ReorderSectionsInput true "Section ids in the new order"
+// @Success 200 {object} sectionsResponse
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/lists/{id}/sections/order [put]
+func (h *Handler) reorderSections(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.ReorderSectionsInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sections, err := h.requestServices(c).TodoList.ReorderSections(userId, listId, input)
+	if err != nil {
+		if errors.Is(err, todo.ErrSectionOrder) {
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sectionsResponse{Data: sections})
+}
+
+// DeleteSection удаляет раздел
+// @Summary Delete section
+// @Description Items of the deleted section keep their order and move to the end of the items without a section.
+// @Security ApiKeyAuth
+// @Tags sections
+// @Param id path int true "List ID"
+// @Param section_id path int true "Section ID"
+// @Success 204
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/lists/{id}/sections/{section_id} [delete]
+func (h *Handler) deleteSection(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	sectionId, err := strconv.Atoi(c.Param("section_id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid section_id param")
+		return
+	}
+
+	if err := h.requestServices(c).TodoList.DeleteSection(userId, listId, sectionId); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// MoveItemsToSection переносит items в раздел в заданном порядке
+// @Summary Order items in section
+// @Description Move items of the list to the section (section_id = null - items without a section) and put them
+// @Description first in the given order; other items of the section follow in their previous order.
+// @Security ApiKeyAuth
+// @Tags sections
+// @Accept json
+// @Produce json
+// @Param id path int true "List ID"
+// @Param input body todo.MoveItemsInput true "Target section and item ids"
+// @Success 200 {object} todo.ListSections
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/lists/{id}/items/order [put]
+func (h *Handler) moveItemsToSection(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.MoveItemsInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.requestServices(c).TodoItem.MoveItems(userId, listId, input); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	sections, err := h.services.TodoItem.GetSectionItems(userId, listId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sections)
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+)
+
+// GetSettings возвращает настройки пользователя
+// @Summary Get user settings
+// @Description Email for reminders and the time zone used to schedule them
+// @Security ApiKeyAuth
+// @Tags settings-v2
+// @Produce json
+// @Success 200 {object} todo.UserSettings
+// @Failure 500 {object} problemDetails
+// @Router /api/v2/settings [get]
+func (h *Handler) getSettings(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	settings, err := h.services.Settings.Get(userId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateSettings изменяет настройки пользователя
+// @Summary Update user settings
+// @Description Omitted fields keep their value; an empty email disables reminder emails
+// @Security ApiKeyAuth
+// @Tags settings-v2
+// @Accept json
+// @Produce json
+// @Param input body todo.UpdateSettingsInput true "Settings"
+// @Success 200 {object} todo.UserSettings
+// @Failure 400 {object} problemDetails
+// @Router /api/v2/settings [put]
+func (h *Handler) updateSettings(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var input todo.UpdateSettingsInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	settings, err := h.services.Settings.Update(userId, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
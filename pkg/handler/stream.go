@@ -0,0 +1,266 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	lastEventIdHeader       = "Last-Event-ID"
+	defaultStreamHeartbeat  = 15 * time.Second
+	streamReplayBatchSize   = 500
+	streamAccessTokenQuery  = "access_token"
+	streamHeartbeatEvent    = "heartbeat"
+	streamEventsContentType = "text/event-stream"
+)
+
+// eventWriter - транспорт потока событий (SSE или WebSocket)
+type eventWriter interface {
+	WriteEvent(event todo.Event) error
+	WriteHeartbeat() error
+}
+
+// streamToken позволяет передать токен в query-параметре access_token:
+// WebSocket в браузере не умеет выставлять заголовок Authorization.
+// Токен принимается только в запросе на upgrade, чтобы он не попадал в ссылки и логи обычных запросов
+func (h *Handler) streamToken(c *gin.Context) {
+	if c.GetHeader(authorizationHeader) != "" {
+		return
+	}
+	if !strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		return
+	}
+
+	if token := c.Query(streamAccessTokenQuery); token != "" {
+		c.Request.Header.Set(authorizationHeader, "Bearer "+token)
+	}
+}
+
+// wsHandshake отклоняет upgrade со страниц чужих сайтов: браузер отправляет cookie и
+// query-токен на любой хост, поэтому Origin должен совпадать с хостом сервера или
+// входить в StreamAllowedOrigins. Клиенты вне браузера Origin не отправляют
+func (h *Handler) wsHandshake(config *websocket.Config, req *http.Request) error {
+	if config.Origin == nil {
+		return nil
+	}
+	if strings.EqualFold(config.Origin.Host, req.Host) {
+		return nil
+	}
+
+	origin := config.Origin.Scheme + "://" + config.Origin.Host
+	for _, allowed := range h.config.StreamAllowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %s is not allowed", origin)
+}
+
+// StreamEvents отдает поток событий через Server-Sent Events
+// @Summary Event stream (SSE)
+// @Description Push list.* and item.* events for resources the user can access.
+// @Description Reconnect with Last-Event-ID header (or last_event_id query) to resume.
+// @Security ApiKeyAuth
+// @Tags stream-v2
+// @Produce text/event-stream
+// @Param Last-Event-ID header string false "Id of the last received event"
+// @Param last_event_id query int false "Id of the last received event"
+// @Success 200 {object} todo.Event
+// @Failure 400 {object} problemDetails
+// @Failure 401 {object} problemDetails
+// @Router /api/v2/stream [get]
+func (h *Handler) streamEvents(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	lastEventId, err := parseLastEventId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Поток живет дольше WriteTimeout сервера
+	controller := http.NewResponseController(c.Writer)
+	controller.SetWriteDeadline(time.Time{})
+
+	c.Header("Content-Type", streamEventsContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	h.runStream(c.Request.Context(), userId, lastEventId, &sseWriter{c: c})
+}
+
+// StreamEventsWS отдает поток событий через WebSocket
+// @Summary Event stream (WebSocket)
+// @Description Same events as /api/v2/stream as JSON text messages over WebSocket.
+// @Description Browsers may connect only from the server's own origin or an allowed one.
+// @Security ApiKeyAuth
+// @Tags stream-v2
+// @Param last_event_id query int false "Id of the last received event"
+// @Param access_token query string false "JWT token for clients that cannot set headers"
+// @Success 101 {string} string "Switching Protocols"
+// @Failure 400 {object} problemDetails
+// @Failure 401 {object} problemDetails
+// @Failure 403 {string} string "Origin is not allowed"
+// @Router /api/v2/stream/ws [get]
+func (h *Handler) streamEventsWS(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	lastEventId, err := parseLastEventId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	server := websocket.Server{
+		Handshake: h.wsHandshake,
+		Handler: func(conn *websocket.Conn) {
+			defer conn.Close()
+			conn.SetDeadline(time.Time{})
+
+			ctx, cancel := context.WithCancel(c.Request.Context())
+			defer cancel()
+
+			// Входящие сообщения не нужны, чтение только отслеживает закрытие соединения
+			go func() {
+				defer cancel()
+				var discard string
+				for websocket.Message.Receive(conn, &discard) == nil {
+				}
+			}()
+
+			h.runStream(ctx, userId, lastEventId, &wsWriter{conn: conn})
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// runStream досылает пропущенные события, затем передает новые и heartbeat
+// до отключения клиента
+func (h *Handler) runStream(ctx context.Context, userId int, lastEventId int64, w eventWriter) {
+	// Подписываемся до чтения истории, чтобы не потерять события между ними
+	events, unsubscribe := h.services.Events.Subscribe(userId)
+	defer unsubscribe()
+
+	for {
+		backlog, err := h.services.Events.GetSince(userId, lastEventId, streamReplayBatchSize)
+		if err != nil {
+			return
+		}
+		for _, event := range backlog {
+			if err := w.WriteEvent(event); err != nil {
+				return
+			}
+			lastEventId = event.Id
+		}
+		if len(backlog) < streamReplayBatchSize {
+			break
+		}
+	}
+
+	interval := h.config.StreamHeartbeat
+	if interval <= 0 {
+		interval = defaultStreamHeartbeat
+	}
+	heartbeat := time.NewTicker(interval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			// Канал закрывается, если клиент не успевает читать; он переподключится
+			// и получит пропущенное по Last-Event-ID
+			if !ok {
+				return
+			}
+			if event.Id <= lastEventId {
+				continue
+			}
+			if err := w.WriteEvent(event); err != nil {
+				return
+			}
+			lastEventId = event.Id
+		case <-heartbeat.C:
+			if err := w.WriteHeartbeat(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func parseLastEventId(c *gin.Context) (int64, error) {
+	value := c.GetHeader(lastEventIdHeader)
+	if value == "" {
+		value = c.Query("last_event_id")
+	}
+	if value == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("invalid last event id %q", value)
+	}
+	return id, nil
+}
+
+type sseWriter struct {
+	c *gin.Context
+}
+
+func (w *sseWriter) WriteEvent(event todo.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w.c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.Id, event.Type, data); err != nil {
+		return err
+	}
+	w.c.Writer.Flush()
+	return nil
+}
+
+func (w *sseWriter) WriteHeartbeat() error {
+	if _, err := fmt.Fprintf(w.c.Writer, ": %s %d\n\n", streamHeartbeatEvent, time.Now().Unix()); err != nil {
+		return err
+	}
+	w.c.Writer.Flush()
+	return nil
+}
+
+type wsWriter struct {
+	conn *websocket.Conn
+}
+
+func (w *wsWriter) WriteEvent(event todo.Event) error {
+	return websocket.JSON.Send(w.conn, event)
+}
+
+func (w *wsWriter) WriteHeartbeat() error {
+	return websocket.JSON.Send(w.conn, map[string]interface{}{
+		"type": streamHeartbeatEvent,
+		"time": time.Now().Unix(),
+	})
+}
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/service"
+	"golang.org/x/net/websocket"
+)
+
+func TestStreamTokenOnlyOnUpgrade(t *testing.T) {
+	h := &Handler{}
+
+	tests := []struct {
+		name    string
+		upgrade string
+		want    string
+	}{
+		{"websocket upgrade", "websocket", "Bearer secret"},
+		{"plain request", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v2/stream/ws?access_token=secret", nil)
+			if tt.upgrade != "" {
+				c.Request.Header.Set("Upgrade", tt.upgrade)
+			}
+
+			h.streamToken(c)
+			if got := c.Request.Header.Get(authorizationHeader); got != tt.want {
+				t.Errorf("Authorization = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSSEIgnoresQueryToken(t *testing.T) {
+	router := newTestRouter(Config{})
+
+	w := serve(router, http.MethodGet, "/api/v2/stream?access_token=secret", "", nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestWsHandshake(t *testing.T) {
+	h := &Handler{config: Config{StreamAllowedOrigins: []string{"https://app.example.com/"}}}
+
+	tests := []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true},
+		{"https://api.example.com", true},
+		{"https://APP.example.com", true},
+		{"http://app.example.com", false},
+		{"https://evil.example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			config := &websocket.Config{}
+			if tt.origin != "" {
+				origin, err := url.ParseRequestURI(tt.origin)
+				if err != nil {
+					t.Fatal(err)
+				}
+				config.Origin = origin
+			}
+			req := httptest.NewRequest(http.MethodGet, "https://api.example.com/api/v2/stream/ws", nil)
+
+			if err := h.wsHandshake(config, req); (err == nil) != tt.ok {
+				t.Errorf("wsHandshake() = %v, want ok = %t", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseLastEventId(t *testing.T) {
+	tests := []struct {
+		header string
+		query  string
+		id     int64
+		ok     bool
+	}{
+		{"", "", 0, true},
+		{"42", "7", 42, true},
+		{"", "7", 7, true},
+		{"-1", "", 0, false},
+		{"abc", "", 0, false},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/v2/stream?last_event_id="+tt.query, nil)
+		c.Request.Header.Set(lastEventIdHeader, tt.header)
+
+		id, err := parseLastEventId(c)
+		if id != tt.id || (err == nil) != tt.ok {
+			t.Errorf("parseLastEventId(%q, %q) = %d, %v", tt.header, tt.query, id, err)
+		}
+	}
+}
+
+// streamEvents отдает историю из backlog и живые события из live
+type streamEvents struct {
+	service.Events
+	backlog []todo.Event
+	live    chan todo.Event
+	since   []int64
+}
+
+func (e *streamEvents) GetSince(userId int, afterId int64, limit int) ([]todo.Event, error) {
+	e.since = append(e.since, afterId)
+	var events []todo.Event
+	for _, event := range e.backlog {
+		if event.Id > afterId && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (e *streamEvents) Subscribe(userId int) (<-chan todo.Event, func()) {
+	return e.live, func() {}
+}
+
+// recordedStream запоминает id отправленных событий и отменяет поток после последнего
+type recordedStream struct {
+	ids    []int64
+	lastId int64
+	cancel func()
+}
+
+func (w *recordedStream) WriteEvent(event todo.Event) error {
+	w.ids = append(w.ids, event.Id)
+	if event.Id == w.lastId {
+		w.cancel()
+	}
+	return nil
+}
+
+func (w *recordedStream) WriteHeartbeat() error {
+	return nil
+}
+
+func TestRunStream(t *testing.T) {
+	events := &streamEvents{live: make(chan todo.Event, 3)}
+	for id := int64(3); id < 3+streamReplayBatchSize+1; id++ {
+		events.backlog = append(events.backlog, todo.Event{Id: id})
+	}
+	last := events.backlog[len(events.backlog)-1].Id
+
+	// Событие, пришедшее и в истории, и в подписке, отправляется один раз
+	events.live <- todo.Event{Id: last}
+	events.live <- todo.Event{Id: last + 1}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &recordedStream{lastId: last + 1, cancel: cancel}
+	h := &Handler{services: &service.Service{Events: events}}
+	h.runStream(ctx, 1, 2, w)
+
+	want := []int64{}
+	for id := int64(3); id <= last+1; id++ {
+		want = append(want, id)
+	}
+	if !reflect.DeepEqual(w.ids, want) {
+		t.Errorf("sent %d events %d..%d, want %d..%d", len(w.ids), w.ids[0], w.ids[len(w.ids)-1], want[0], want[len(want)-1])
+	}
+	if !reflect.DeepEqual(events.since, []int64{2, 2 + streamReplayBatchSize}) {
+		t.Errorf("replay cursors = %v", events.since)
+	}
+}
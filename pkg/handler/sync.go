@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+)
+
+const (
+	defaultSyncLimit = 500
+	maxSyncLimit     = 1000
+)
+
+type syncChangesResponse struct {
+	Changes   []todo.SyncChange `json:"changes"`
+	NextToken string            `json:"next_token"`
+	HasMore   bool              `json:"has_more"`
+}
+
+type syncApplyRequest struct {
+	Changes []todo.SyncClientChange `json:"changes" binding:"required,min=1,dive"`
+}
+
+func (r *syncApplyRequest) Normalize() {
+	for i := range r.Changes {
+		r.Changes[i].Normalize()
+	}
+}
+
+type syncApplyResponse struct {
+	Applied   int                     `json:"applied"`
+	Conflicts int                     `json:"conflicts"`
+	Failed    int                     `json:"failed"`
+	Results   []todo.SyncChangeResult `json:"results"`
+}
+
+// GetSyncChanges возвращает изменения после токена синхронизации
+// @Summary Delta sync (v2)
+// @Description Lists and items created, updated or deleted since the token, ordered by seq.
+// @Description Deleted resources are returned as tombstones (deleted=true). A list tombstone removes its items too.
+// @Description Without a token all current resources are returned. Request pages with next_token while has_more is true,
+// @Description then keep next_token for the next sync.
+// @Security ApiKeyAuth
+// @Tags sync-v2
+// @Produce json
+// @Param since query string false "Token from the previous response"
+// @Param limit query int false "Max changes per page (1-1000)" default(500)
+// @Success 200 {object} syncChangesResponse
+// @Failure 400 {object} problemDetails
+// @Failure 500 {object} problemDetails
+// @Router /api/v2/sync [get]
+func (h *Handler) getSyncChanges(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSyncLimit)))
+	if limit < 1 || limit > maxSyncLimit {
+		limit = defaultSyncLimit
+	}
+
+	changes, nextToken, hasMore, err := h.services.Sync.GetChanges(userId, c.Query("since"), limit)
+	if err != nil {
+		if errors.Is(err, todo.ErrInvalidSyncToken) {
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, syncChangesResponse{
+		Changes:   changes,
+		NextToken: nextToken,
+		HasMore:   hasMore,
+	})
+}
+
+// ApplySyncChanges применяет изменения, сделанные клиентом офлайн
+// @Summary Apply client changes (v2)
+// @Description Apply a batch of offline changes in order. Each change is applied independently.
+// @Description A change made to the current version (base_version) is applied; otherwise the later of
+// @Description client_updated_at and server updated_at wins. Rejected changes get status "conflict" with the server state.
+// @Description Items may reference lists created in the same batch via list_client_id.
+// @Description Op delete archives the resource like DELETE in v2, op purge deletes it permanently.
+// @Security ApiKeyAuth
+// @Tags sync-v2
+// @Accept json
+// @Produce json
+// @Param input body syncApplyRequest true "Client changes"
+// @Success 200 {object} syncApplyResponse
+// @Failure 400 {object} problemDetails
+// @Failure 413 {object} problemDetails
+// @Router /api/v2/sync [post]
+func (h *Handler) applySyncChanges(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var input syncApplyRequest
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	maxChanges := h.config.BulkMaxOperations
+	if maxChanges <= 0 {
+		maxChanges = defaultBulkMaxOperations
+	}
+	if len(input.Changes) > maxChanges {
+		newErrorResponse(c, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("batch contains %d changes, maximum is %d", len(input.Changes), maxChanges))
+		return
+	}
+
+	results := h.requestServices(c).Sync.Apply(userId, input.Changes)
+
+	response := syncApplyResponse{Results: results}
+	for _, result := range results {
+		switch result.Status {
+		case todo.SyncApplied:
+			response.Applied++
+		case todo.SyncConflict:
+			response.Conflicts++
+		default:
+			response.Failed++
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
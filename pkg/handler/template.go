@@ -0,0 +1,282 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+)
+
+type templatesResponse struct {
+	Data []todo.ListTemplate `json:"data"`
+}
+
+// CreateTemplate создает шаблон списка
+// @Summary Create list template
+// @Description Create a template from its content. Titles, descriptions, section names and checklist entries
+// @Description may contain variables like {{name}} that are substituted when a list is created from the template.
+// @Security ApiKeyAuth
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param input body todo.TemplateInput true "Template"
+// @Success 201 {object} todo.ListTemplate
+// @Failure 400 {object} problemDetails
+// @Router /api/v2/templates [post]
+func (h *Handler) createTemplate(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var input todo.TemplateInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	input.Normalize()
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	template, err := h.services.Templates.Create(userId, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// GetTemplates возвращает шаблоны пользователя
+// @Summary Get list templates
+// @Description Templates of the current user sorted by name
+// @Security ApiKeyAuth
+// @Tags templates
+// @Produce json
+// @Success 200 {object} templatesResponse
+// @Router /api/v2/templates [get]
+func (h *Handler) getTemplates(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	templates, err := h.services.Templates.GetAll(userId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, templatesResponse{Data: templates})
+}
+
+// GetTemplateById возвращает шаблон
+// @Summary Get list template
+// @Security ApiKeyAuth
+// @Tags templates
+// @Produce json
+// @Param id path int true "Template ID"
+// @Success 200 {object} todo.ListTemplate
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/templates/{id} [get]
+func (h *Handler) getTemplateById(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	template, err := h.services.Templates.GetById(userId, id)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// UpdateTemplate изменяет шаблон
+// @Summary Update list template
+// @Description Change the name, the description and/or replace the content of the template
+// @Security ApiKeyAuth
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param id path int true "Template ID"
+// @Param input body todo.UpdateTemplateInput true "Template changes"
+// @Success 200 {object} todo.ListTemplate
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/templates/{id} [put]
+func (h *Handler) updateTemplate(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.UpdateTemplateInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	input.Normalize()
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	template, err := h.services.Templates.Update(userId, id, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// DeleteTemplate удаляет шаблон
+// @Summary Delete list template
+// @Description Lists created from the template are kept
+// @Security ApiKeyAuth
+// @Tags templates
+// @Param id path int true "Template ID"
+// @Success 204
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/templates/{id} [delete]
+func (h *Handler) deleteTemplate(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.services.Templates.Delete(userId, id); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// InstantiateTemplate создает список из шаблона
+// @Summary Create list from template
+// @Description Create a list with the sections, items and checklists of the template. Due dates of items are
+// @Description counted from anchor; every variable of the template must have a value in variables.
+// @Security ApiKeyAuth
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param id path int true "Template ID"
+// @Param input body todo.InstantiateTemplateInput true "Anchor date and variable values"
+// @Success 201 {object} todo.TodoList
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/templates/{id}/instantiate [post]
+func (h *Handler) instantiateTemplate(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.InstantiateTemplateInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	list, err := h.requestServices(c).Templates.Instantiate(userId, id, input)
+	if err != nil {
+		if errors.Is(err, todo.ErrTemplateRender) {
+			newErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, list)
+}
+
+// SaveListAsTemplate сохраняет список шаблоном
+// @Summary Save list as template
+// @Description Save the non-archived items of the list with their sections and checklist entries as a new template.
+// @Description Due dates are stored relative to anchor (the list creation time by default). Completion and statuses are not saved.
+// @Security ApiKeyAuth
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param id path int true "List ID"
+// @Param input body todo.SaveTemplateInput true "Template name and anchor"
+// @Success 201 {object} todo.ListTemplate
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/lists/{id}/template [post]
+func (h *Handler) saveListAsTemplate(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.SaveTemplateInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	input.Normalize()
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	template, err := h.services.Templates.SaveList(userId, listId, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
@@ -0,0 +1,289 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+)
+
+// maxTimeReportDays - наибольшая длина периода отчета по учтенному времени
+const maxTimeReportDays = 366
+
+type timeEntriesResponse struct {
+	Data []todo.TimeEntry `json:"data"`
+}
+
+type timeReportResponse struct {
+	Data []todo.TimeReportRow `json:"data"`
+}
+
+// StartTimer запускает таймер по item
+// @Summary Start item timer
+// @Description Start tracking time on the item. A timer of the current user that is already running is stopped first,
+// @Description so only one timer runs at a time.
+// @Security ApiKeyAuth
+// @Tags time-tracking
+// @Accept json
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param input body todo.StartTimerInput false "Timer note"
+// @Success 201 {object} todo.TimeEntry
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/items/{id}/timer [post]
+func (h *Handler) startTimer(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.StartTimerInput
+	if c.Request.ContentLength != 0 {
+		if err := bindJSON(c, &input); err != nil {
+			newValidationErrorResponse(c, err)
+			return
+		}
+	}
+
+	entry, err := h.requestServices(c).TimeEntries.StartTimer(userId, itemId, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+// GetRunningTimer возвращает запущенный таймер пользователя
+// @Summary Get running timer
+// @Security ApiKeyAuth
+// @Tags time-tracking
+// @Produce json
+// @Success 200 {object} todo.TimeEntry
+// @Failure 404 {object} problemDetails "No timer is running"
+// @Router /api/v2/timer [get]
+func (h *Handler) getRunningTimer(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	entry, err := h.services.TimeEntries.GetRunning(userId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// StopTimer останавливает запущенный таймер пользователя
+// @Summary Stop running timer
+// @Security ApiKeyAuth
+// @Tags time-tracking
+// @Produce json
+// @Success 200 {object} todo.TimeEntry
+// @Failure 404 {object} problemDetails "No timer is running"
+// @Router /api/v2/timer/stop [post]
+func (h *Handler) stopTimer(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	entry, err := h.requestServices(c).TimeEntries.StopTimer(userId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// GetTimeEntries возвращает учтенное время по item
+// @Summary Get item time entries
+// @Description Time entries of all users for the item, newest first. Running timers have no ended_at.
+// @Security ApiKeyAuth
+// @Tags time-tracking
+// @Produce json
+// @Param id path int true "Item ID"
+// @Success 200 {object} timeEntriesResponse
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/items/{id}/time-entries [get]
+func (h *Handler) getTimeEntries(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	entries, err := h.services.TimeEntries.GetAll(userId, itemId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, timeEntriesResponse{Data: entries})
+}
+
+// CreateTimeEntry учитывает время по item вручную
+// @Summary Log time on item
+// @Description Log minutes spent on the item starting at started_at. The entry can't end in the future.
+// @Security ApiKeyAuth
+// @Tags time-tracking
+// @Accept json
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param input body todo.TimeEntryInput true "Time entry"
+// @Success 201 {object} todo.TimeEntry
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/items/{id}/time-entries [post]
+func (h *Handler) createTimeEntry(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.TimeEntryInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	input.Normalize()
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entry, err := h.requestServices(c).TimeEntries.Create(userId, itemId, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+// DeleteTimeEntry удаляет запись учтенного времени
+// @Summary Delete time entry
+// @Description Only the author can delete the entry; deleting a running timer stops tracking it.
+// @Security ApiKeyAuth
+// @Tags time-tracking
+// @Param id path int true "Time entry ID"
+// @Success 204
+// @Failure 400 {object} problemDetails
+// @Failure 403 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/time-entries/{id} [delete]
+func (h *Handler) deleteTimeEntry(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.requestServices(c).TimeEntries.Delete(userId, id); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetTimeReport возвращает учтенное время по дням и спискам
+// @Summary Get time report
+// @Description Minutes logged per day and list on the lists of the current user, by all members or by one user.
+// @Description Days are counted in the user's time zone from /api/v2/settings; entries spanning midnight are split.
+// @Security ApiKeyAuth
+// @Tags time-tracking
+// @Produce json
+// @Param from query string true "First day, YYYY-MM-DD"
+// @Param to query string true "Last day, YYYY-MM-DD"
+// @Param list_id query int false "Filter by list ID"
+// @Param user query string false "Filter by user: user ID or me"
+// @Success 200 {object} timeReportResponse
+// @Failure 400 {object} problemDetails
+// @Router /api/v2/reports/time [get]
+func (h *Handler) getTimeReport(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	from, err := time.Parse("2006-01-02", c.Query("from"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid from param")
+		return
+	}
+	to, err := time.Parse("2006-01-02", c.Query("to"))
+	if err != nil || to.Before(from) {
+		newErrorResponse(c, http.StatusBadRequest, "invalid to param")
+		return
+	}
+	if to.Sub(from) >= maxTimeReportDays*24*time.Hour {
+		newErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("report period can't exceed %d days", maxTimeReportDays))
+		return
+	}
+
+	filter := todo.TimeReportFilter{From: from, To: to}
+	if listId := c.Query("list_id"); listId != "" {
+		filter.ListId, err = strconv.Atoi(listId)
+		if err != nil || filter.ListId < 1 {
+			newErrorResponse(c, http.StatusBadRequest, "invalid list_id param")
+			return
+		}
+	}
+	if user := c.Query("user"); user == "me" {
+		filter.UserId = userId
+	} else if user != "" {
+		filter.UserId, err = strconv.Atoi(user)
+		if err != nil || filter.UserId < 1 {
+			newErrorResponse(c, http.StatusBadRequest, "invalid user param")
+			return
+		}
+	}
+
+	rows, err := h.services.TimeEntries.GetReport(userId, filter)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, timeReportResponse{Data: rows})
+}
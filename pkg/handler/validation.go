@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/ktuty/todo-app"
+)
+
+const unknownFieldPrefix = "json: unknown field "
+
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		// В ошибках валидации используем имена полей из json-тегов
+		v.RegisterTagNameFunc(jsonFieldName)
+		v.RegisterValidation("list_color", func(fl validator.FieldLevel) bool {
+			return todo.IsListColor(fl.Field().String())
+		})
+		v.RegisterValidation("webhook_event", func(fl validator.FieldLevel) bool {
+			return todo.IsWebhookEvent(fl.Field().String())
+		})
+		v.RegisterValidation("time_zone", func(fl validator.FieldLevel) bool {
+			return todo.IsTimeZone(fl.Field().String())
+		})
+		v.RegisterValidation("clock", func(fl validator.FieldLevel) bool {
+			return todo.IsClock(fl.Field().String())
+		})
+	}
+}
+
+// normalizer реализуют входные структуры, которые нужно привести к каноничному виду перед валидацией
+type normalizer interface {
+	Normalize()
+}
+
+// bindJSON декодирует тело запроса, отклоняя неизвестные поля,
+// нормализует входные данные и проверяет правила из binding-тегов
+func bindJSON(c *gin.Context, obj interface{}) error {
+	return decodeJSON(c.Request.Body, obj)
+}
+
+// decodeJSON - то же, что bindJSON, для произвольного источника
+func decodeJSON(r io.Reader, obj interface{}) error {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(obj); err != nil {
+		if errors.Is(err, io.EOF) {
+			return errors.New("request body is empty")
+		}
+		return err
+	}
+
+	if n, ok := obj.(normalizer); ok {
+		n.Normalize()
+	}
+
+	return binding.Validator.ValidateStruct(obj)
+}
+
+func jsonFieldName(f reflect.StructField) string {
+	name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// fieldErrorsFrom извлекает ошибки по полям из ошибок биндинга и валидации
+func fieldErrorsFrom(err error) []fieldError {
+	if err == nil {
+		return nil
+	}
+
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]fieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			fields = append(fields, fieldError{
+				Field:   fieldPath(fe),
+				Rule:    fe.Tag(),
+				Message: validationMessage(fe),
+			})
+		}
+		return fields
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return []fieldError{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: fmt.Sprintf("must be of type %s", typeErr.Type),
+		}}
+	}
+
+	if msg := err.Error(); strings.HasPrefix(msg, unknownFieldPrefix) {
+		return []fieldError{{
+			Field:   strings.Trim(strings.TrimPrefix(msg, unknownFieldPrefix), `"`),
+			Rule:    "unknown",
+			Message: "is not a known field",
+		}}
+	}
+
+	return nil
+}
+
+// fieldPath возвращает путь к полю без имени корневой структуры
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		return ns[i+1:]
+	}
+	return fe.Field()
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_if", "required_unless":
+		return "is required for this operation"
+	case "required_without":
+		return fmt.Sprintf("is required when %s is not set", jsonParamName(fe.Param()))
+	case "excluded_with":
+		return fmt.Sprintf("must not be set together with %s", jsonParamName(fe.Param()))
+	case "max":
+		return fmt.Sprintf("must be at most %s characters long", fe.Param())
+	case "min":
+		if fe.Param() == "1" {
+			return "must not be empty"
+		}
+		return fmt.Sprintf("must be at least %s characters long", fe.Param())
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", fe.Param())
+	case "gte":
+		return fmt.Sprintf("must be greater than or equal to %s", fe.Param())
+	case "lte":
+		return fmt.Sprintf("must be less than or equal to %s", fe.Param())
+	case "list_color":
+		return "must be a hex color (#rgb or #rrggbb) or one of: " + strings.Join(todo.ListColorPalette, ", ")
+	case "webhook_event":
+		return "must be one of: " + strings.Join(todo.WebhookEvents, ", ")
+	case "time_zone":
+		return "must be an IANA time zone, e.g. Europe/Moscow"
+	case "clock":
+		return "must be a time of day in HH:MM format"
+	case "email", "email|len=0":
+		return "must be a valid email address"
+	case "http_url":
+		return "must be an absolute http or https URL"
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", fe.Param())
+	default:
+		return fmt.Sprintf("failed on the '%s' rule", fe.Tag())
+	}
+}
+
+// jsonParamName переводит имя поля структуры из параметра правила (например, AtTime)
+// в вид json-тега (at_time)
+func jsonParamName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ktuty/todo-app"
+)
+
+func TestListAndItemRules(t *testing.T) {
+	long := strings.Repeat("a", 256)
+
+	tests := []struct {
+		name  string
+		body  string
+		input interface{}
+		field string
+		rule  string
+	}{
+		{"list ok", `{"title":" a ","color":"Teal","priority":5}`, &todo.TodoList{}, "", ""},
+		{"list blank title", `{"title":"   "}`, &todo.TodoList{}, "title", "required"},
+		{"list long title", `{"title":"` + long + `"}`, &todo.TodoList{}, "title", "max"},
+		{"list color", `{"title":"a","color":"magenta"}`, &todo.TodoList{}, "color", "list_color"},
+		{"list priority", `{"title":"a","priority":6}`, &todo.TodoList{}, "priority", "lte"},
+		{"update empty title", `{"title":" "}`, &todo.UpdateListInput{}, "title", "min"},
+		{"update negative priority", `{"priority":-1}`, &todo.UpdateListInput{}, "priority", "gte"},
+		{"item long description", `{"title":"a","description":"` + long + `"}`, &todo.TodoItem{}, "description", "max"},
+		{"item update empty title", `{"title":""}`, &todo.UpdateItemInput{}, "title", "min"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := fieldErrorsFrom(decodeJSON(strings.NewReader(tt.body), tt.input))
+			if tt.field == "" {
+				if len(fields) != 0 {
+					t.Errorf("fields = %+v, want none", fields)
+				}
+				return
+			}
+			if len(fields) != 1 || fields[0].Field != tt.field || fields[0].Rule != tt.rule {
+				t.Errorf("fields = %+v, want %s/%s", fields, tt.field, tt.rule)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONNormalizes(t *testing.T) {
+	var list todo.TodoList
+	if err := decodeJSON(strings.NewReader(`{"title":"  Work ","color":" #ABCDEF "}`), &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Title != "Work" || list.Color != "#abcdef" {
+		t.Errorf("list = %+v", list)
+	}
+}
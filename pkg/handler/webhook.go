@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+)
+
+const defaultDeliveriesLimit = 50
+
+type getAllWebhooksResponse struct {
+	Data []todo.Webhook `json:"data"`
+}
+
+type getDeliveriesResponse struct {
+	Data []todo.WebhookDelivery `json:"data"`
+}
+
+// CreateWebhook регистрирует webhook
+// @Summary Create webhook
+// @Description Subscribe a URL to list/item events of all user's lists or of one list (list_id).
+// @Description Requests are signed: X-Webhook-Signature = "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body)).
+// @Description The secret is returned only in this response.
+// @Description URLs resolving to loopback, link-local or private network addresses are rejected.
+// @Security ApiKeyAuth
+// @Tags webhooks-v2
+// @Accept json
+// @Produce json
+// @Param input body todo.Webhook true "Webhook"
+// @Success 201 {object} todo.Webhook
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Failure 500 {object} problemDetails
+// @Router /api/v2/webhooks [post]
+func (h *Handler) createWebhook(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var input todo.Webhook
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	webhook, err := h.services.Webhooks.Create(userId, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+// GetAllWebhooks возвращает webhooks пользователя
+// @Summary Get all webhooks
+// @Security ApiKeyAuth
+// @Tags webhooks-v2
+// @Produce json
+// @Success 200 {object} getAllWebhooksResponse
+// @Failure 500 {object} problemDetails
+// @Router /api/v2/webhooks [get]
+func (h *Handler) getAllWebhooks(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	webhooks, err := h.services.Webhooks.GetAll(userId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, getAllWebhooksResponse{Data: webhooks})
+}
+
+// GetWebhookById возвращает webhook по id
+// @Summary Get webhook by id
+// @Security ApiKeyAuth
+// @Tags webhooks-v2
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} todo.Webhook
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/webhooks/{id} [get]
+func (h *Handler) getWebhookById(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	webhook, err := h.services.Webhooks.GetById(userId, id)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// DeleteWebhook удаляет webhook вместе с журналом доставок
+// @Summary Delete webhook
+// @Security ApiKeyAuth
+// @Tags webhooks-v2
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} statusResponse
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/webhooks/{id} [delete]
+func (h *Handler) deleteWebhook(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.services.Webhooks.Delete(userId, id); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{Status: "ok"})
+}
+
+// GetWebhookDeliveries возвращает журнал доставок webhook
+// @Summary Get webhook deliveries
+// @Description Delivery log, newest first. Status: pending, delivered or dead.
+// @Security ApiKeyAuth
+// @Tags webhooks-v2
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param status query string false "Filter by status" Enums(pending, delivered, dead)
+// @Param limit query int false "Max deliveries (1-200)" default(50)
+// @Success 200 {object} getDeliveriesResponse
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/webhooks/{id}/deliveries [get]
+func (h *Handler) getWebhookDeliveries(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", todo.WebhookDeliveryPending, todo.WebhookDeliveryDelivered, todo.WebhookDeliveryDead:
+	default:
+		newErrorResponse(c, http.StatusBadRequest, "invalid status param")
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultDeliveriesLimit)))
+	if limit < 1 || limit > 200 {
+		limit = defaultDeliveriesLimit
+	}
+
+	deliveries, err := h.services.Webhooks.GetDeliveries(userId, id, status, limit)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, getDeliveriesResponse{Data: deliveries})
+}
+
+// RedeliverWebhook ставит доставку в очередь повторно
+// @Summary Redeliver webhook delivery
+// @Description Enqueue a copy of the delivery (any status); the original log entry is kept.
+// @Security ApiKeyAuth
+// @Tags webhooks-v2
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param delivery_id path int true "Delivery ID"
+// @Success 202 {object} todo.WebhookDelivery
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+func (h *Handler) redeliverWebhook(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	deliveryId, err := strconv.ParseInt(c.Param("delivery_id"), 10, 64)
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid delivery_id param")
+		return
+	}
+
+	original, err := h.services.Webhooks.GetDelivery(userId, deliveryId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+	if original.WebhookId != id {
+		newErrorResponse(c, http.StatusNotFound, "resource not found")
+		return
+	}
+
+	delivery, err := h.services.Webhooks.Redeliver(userId, deliveryId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ktuty/todo-app"
+)
+
+// GetWorkflow возвращает статусы workflow списка
+// @Summary Get list workflow
+// @Description Get the ordered workflow statuses of the list. An empty set means the list has no workflow.
+// @Security ApiKeyAuth
+// @Tags lists-v2
+// @Produce json
+// @Param id path int true "List ID"
+// @Success 200 {object} todo.Workflow
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/lists/{id}/workflow [get]
+func (h *Handler) getWorkflow(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	workflow, err := h.services.TodoList.GetWorkflow(userId, listId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, workflow)
+}
+
+// SetWorkflow заменяет статусы workflow списка
+// @Summary Set list workflow
+// @Description Replace the ordered workflow statuses of the list. Statuses with an id are kept and renamed,
+// @Description statuses without an id are created, missing statuses are removed and their items move to
+// @Description the first status of the matching category. Items' done follows the category of their status.
+// @Description An empty set disables the workflow.
+// @Security ApiKeyAuth
+// @Tags lists-v2
+// @Accept json
+// @Produce json
+// @Param id path int true "List ID"
+// @Param input body todo.WorkflowInput true "Workflow statuses"
+// @Success 200 {object} todo.Workflow
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/lists/{id}/workflow [put]
+func (h *Handler) setWorkflow(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.WorkflowInput
+	if err := bindJSON(c, &input); err != nil {
+		newValidationErrorResponse(c, err)
+		return
+	}
+
+	input.Normalize()
+	if err := input.Validate(); err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	workflow, err := h.requestServices(c).TodoList.SetWorkflow(userId, listId, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, workflow)
+}
+
+// GetBoard возвращает items списка, сгруппированные по колонкам статусов
+// @Summary Get list board
+// @Description Get the list items grouped into Kanban columns, one per workflow status.
+// @Description A list without a workflow has two columns: open and done items.
+// @Security ApiKeyAuth
+// @Tags lists-v2
+// @Produce json
+// @Param id path int true "List ID"
+// @Param limit query int false "Items per column" default(50)
+// @Success 200 {object} todo.Board
+// @Failure 400 {object} problemDetails
+// @Failure 404 {object} problemDetails
+// @Router /api/v2/lists/{id}/board [get]
+func (h *Handler) getBoard(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 100 {
+		newErrorResponse(c, http.StatusBadRequest, "invalid limit param")
+		return
+	}
+
+	board, err := h.services.TodoItem.GetBoard(userId, listId, limit)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, board)
+}
@@ -0,0 +1,147 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Message - письмо. Если задан HTML, отправляется multipart/alternative с текстовой версией
+type Message struct {
+	To      []string
+	Subject string
+	Text    string
+	HTML    string
+	// Headers - дополнительные заголовки, например Message-ID:
+	// по одинаковому Message-ID почтовые клиенты распознают повторную отправку
+	Headers map[string]string
+}
+
+// Mailer отправляет письма
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Драйверы отправки
+const (
+	DriverSMTP = "smtp"
+	DriverFile = "file"
+	DriverLog  = "log"
+)
+
+type Config struct {
+	Driver   string
+	From     string
+	Host     string
+	Port     string
+	Username string
+	Password string
+	Dir      string // Каталог для писем драйвера file
+}
+
+// New создает Mailer по настройкам; драйвер по умолчанию - log
+func New(cfg Config) (Mailer, error) {
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address: %w", err)
+	}
+
+	switch cfg.Driver {
+	case DriverSMTP:
+		if cfg.Host == "" {
+			return nil, errors.New("smtp host is required")
+		}
+		return NewSMTPMailer(cfg.Host, cfg.Port, cfg.Username, cfg.Password, from), nil
+	case DriverFile:
+		if cfg.Dir == "" {
+			return nil, errors.New("mail directory is required")
+		}
+		return NewFileMailer(cfg.Dir, from), nil
+	case DriverLog, "":
+		return NewLogMailer(from), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer driver %q", cfg.Driver)
+	}
+}
+
+// build собирает письмо в формате RFC 5322
+func build(from *mail.Address, msg Message, now time.Time) ([]byte, error) {
+	if len(msg.To) == 0 {
+		return nil, errors.New("message has no recipients")
+	}
+
+	var buf bytes.Buffer
+	headers := map[string]string{
+		"From":         from.String(),
+		"To":           strings.Join(msg.To, ", "),
+		"Subject":      mime.QEncoding.Encode("utf-8", msg.Subject),
+		"Date":         now.Format(time.RFC1123Z),
+		"MIME-Version": "1.0",
+	}
+	for name, value := range msg.Headers {
+		headers[textproto.CanonicalMIMEHeaderKey(name)] = value
+	}
+
+	var body bytes.Buffer
+	if msg.HTML == "" {
+		headers["Content-Type"] = "text/plain; charset=utf-8"
+		headers["Content-Transfer-Encoding"] = "quoted-printable"
+		if err := writeQuotedPrintable(&body, msg.Text); err != nil {
+			return nil, err
+		}
+	} else {
+		parts := multipart.NewWriter(&body)
+		headers["Content-Type"] = "multipart/alternative; boundary=" + parts.Boundary()
+		if err := writePart(parts, "text/plain; charset=utf-8", msg.Text); err != nil {
+			return nil, err
+		}
+		if err := writePart(parts, "text/html; charset=utf-8", msg.HTML); err != nil {
+			return nil, err
+		}
+		if err := parts.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, headers[name])
+	}
+	buf.WriteString("\r\n")
+	buf.Write(body.Bytes())
+
+	return buf.Bytes(), nil
+}
+
+func writePart(parts *multipart.Writer, contentType, content string) error {
+	part, err := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	return writeQuotedPrintable(part, content)
+}
+
+func writeQuotedPrintable(w io.Writer, content string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(content)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
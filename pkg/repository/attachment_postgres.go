@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+)
+
+type AttachmentPostgres struct {
+	db *sqlx.DB
+}
+
+func NewAttachmentPostgres(db *sqlx.DB) *AttachmentPostgres {
+	return &AttachmentPostgres{db: db}
+}
+
+// attachmentColumns - колонки выборки вложений (псевдоним a)
+const attachmentColumns = `a.id, a.item_id, coalesce(a.uploaded_by, 0) AS uploaded_by, a.filename, a.content_type,
+		a.size, a.checksum, a.storage_key, a.created_at`
+
+// Reserve ставит ключ в очередь удаления, отложенного до until. Если метаданные загруженного
+// файла не будут сохранены, например из-за сбоя, объект удалится из хранилища
+func (r *AttachmentPostgres) Reserve(storageKey string, until time.Time) error {
+	query := fmt.Sprintf("INSERT INTO %s (storage_key, claimed_until) VALUES ($1, $2)", blobDeletionsTable)
+	_, err := r.db.Exec(query, storageKey, until)
+
+	return err
+}
+
+// Create сохраняет метаданные загруженного файла и снимает его ключ с очереди удаления
+func (r *AttachmentPostgres) Create(userId, itemId int, attachment todo.Attachment) (int, error) {
+	var id int
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		if _, err := itemListId(tx, userId, itemId); err != nil {
+			return err
+		}
+
+		query := fmt.Sprintf(`
+			INSERT INTO %s (item_id, uploaded_by, filename, content_type, size, checksum, storage_key, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING id`, attachmentsTable)
+		err := tx.Get(&id, query, itemId, userId, attachment.Filename, attachment.ContentType,
+			attachment.Size, attachment.Checksum, attachment.StorageKey, time.Now())
+		if err != nil {
+			return err
+		}
+
+		releaseQuery := fmt.Sprintf("DELETE FROM %s WHERE storage_key = $1", blobDeletionsTable)
+		_, err = tx.Exec(releaseQuery, attachment.StorageKey)
+		return err
+	})
+
+	return id, err
+}
+
+func (r *AttachmentPostgres) GetAll(userId, itemId int) ([]todo.Attachment, error) {
+	if _, err := itemListId(r.db, userId, itemId); err != nil {
+		return nil, err
+	}
+
+	attachments := make([]todo.Attachment, 0)
+	query := fmt.Sprintf("SELECT %s FROM %s a WHERE a.item_id = $1 ORDER BY a.id", attachmentColumns, attachmentsTable)
+	err := r.db.Select(&attachments, query, itemId)
+
+	return attachments, err
+}
+
+func (r *AttachmentPostgres) GetById(userId, attachmentId int) (todo.Attachment, error) {
+	var attachment todo.Attachment
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s a
+		WHERE a.id = $1 AND EXISTS (
+			SELECT 1 FROM %s li
+			INNER JOIN %s ul on ul.list_id = li.list_id
+			WHERE li.item_id = a.item_id AND ul.user_id = $2)`,
+		attachmentColumns, attachmentsTable, listsItemsTable, usersListsTable)
+	err := r.db.Get(&attachment, query, attachmentId, userId)
+
+	return attachment, err
+}
+
+// GetByIdUnchecked возвращает вложение без проверки доступа; используется
+// для скачивания по подписанной ссылке
+func (r *AttachmentPostgres) GetByIdUnchecked(attachmentId int) (todo.Attachment, error) {
+	var attachment todo.Attachment
+	query := fmt.Sprintf("SELECT %s FROM %s a WHERE a.id = $1", attachmentColumns, attachmentsTable)
+	err := r.db.Get(&attachment, query, attachmentId)
+
+	return attachment, err
+}
+
+// Delete удаляет метаданные; содержимое удаляется из хранилища через очередь
+func (r *AttachmentPostgres) Delete(userId, attachmentId int) error {
+	query := fmt.Sprintf(`
+		DELETE FROM %s a
+		USING %s li, %s ul
+		WHERE a.id = $1 AND li.item_id = a.item_id AND ul.list_id = li.list_id AND ul.user_id = $2`,
+		attachmentsTable, listsItemsTable, usersListsTable)
+	result, err := r.db.Exec(query, attachmentId, userId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// ClaimDeletions выбирает объекты, ожидающие удаления, и захватывает их на время lease.
+// Объекты, на которые ссылаются вложения, не удаляются
+func (r *AttachmentPostgres) ClaimDeletions(limit int, lease time.Duration) ([]todo.BlobDeletion, error) {
+	deletions := make([]todo.BlobDeletion, 0)
+	query := fmt.Sprintf(`
+		UPDATE %s SET claimed_until = $3, attempts = attempts + 1
+		WHERE id IN (
+			SELECT bd.id FROM %s bd
+			WHERE (bd.claimed_until IS NULL OR bd.claimed_until < $2)
+				AND NOT EXISTS (SELECT 1 FROM %s a WHERE a.storage_key = bd.storage_key)
+			ORDER BY bd.id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, storage_key`,
+		blobDeletionsTable, blobDeletionsTable, attachmentsTable)
+	now := time.Now()
+	err := r.db.Select(&deletions, query, limit, now, now.Add(lease))
+
+	return deletions, err
+}
+
+// MarkDeleted убирает из очереди объект, удаленный из хранилища
+func (r *AttachmentPostgres) MarkDeleted(deletionId int64) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", blobDeletionsTable)
+	_, err := r.db.Exec(query, deletionId)
+
+	return err
+}
+
+// MarkDeletionFailed сохраняет ошибку удаления; повторная попытка будет после retryAt
+func (r *AttachmentPostgres) MarkDeletionFailed(deletionId int64, reason string, retryAt time.Time) error {
+	query := fmt.Sprintf("UPDATE %s SET last_error = $1, claimed_until = $2 WHERE id = $3", blobDeletionsTable)
+	_, err := r.db.Exec(query, reason, retryAt, deletionId)
+
+	return err
+}
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+	"github.com/lib/pq"
+)
+
+type AuditPostgres struct {
+	db *sqlx.DB
+}
+
+func NewAuditPostgres(db *sqlx.DB) *AuditPostgres {
+	return &AuditPostgres{db: db}
+}
+
+type auditRow struct {
+	todo.AuditEntry
+	Before []byte `db:"before"`
+	After  []byte `db:"after"`
+	Diff   []byte `db:"diff"`
+}
+
+const auditColumns = `id, actor_id, action, entity, entity_id, coalesce(list_id, 0) AS list_id, before, after, diff,
+		coalesce(request_id, '') AS request_id, coalesce(ip, '') AS ip, coalesce(user_agent, '') AS user_agent, created_at`
+
+// setAuditContext сохраняет в настройках транзакции автора изменений и сведения о запросе.
+// Записи аудита, которые делаются в этой транзакции, берут их оттуда
+func setAuditContext(tx *sqlx.Tx, meta todo.RequestMeta) error {
+	_, err := tx.Exec(`SELECT set_config('todo.actor_id', $1, true), set_config('todo.request_id', $2, true),
+		set_config('todo.ip', $3, true), set_config('todo.user_agent', $4, true)`,
+		strconv.Itoa(meta.ActorId), meta.RequestId, meta.IP, meta.UserAgent)
+	return err
+}
+
+// inAuditedTx - то же, что inTx, но записи аудита в транзакции получают автора
+// и сведения о запросе из meta
+func inAuditedTx(db *sqlx.DB, meta todo.RequestMeta, fn func(tx *sqlx.Tx) error) error {
+	return inTx(db, func(tx *sqlx.Tx) error {
+		if err := setAuditContext(tx, meta); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// auditInsert - начало вставки записей аудита: автор и сведения о запросе читаются
+// из настроек транзакции. Запрос-источник должен вернуть action, entity, entity_id,
+// list_id, before и after
+var auditInsert = fmt.Sprintf(`
+	INSERT INTO %s (actor_id, request_id, ip, user_agent, action, entity, entity_id, list_id, before, after, diff)
+	SELECT coalesce(nullif(current_setting('todo.actor_id', true), '')::int, 0),
+		nullif(current_setting('todo.request_id', true), ''), nullif(current_setting('todo.ip', true), ''),
+		nullif(current_setting('todo.user_agent', true), ''),
+		c.action, c.entity, c.entity_id, c.list_id, c.before, c.after, (
+			SELECT jsonb_object_agg(k, jsonb_build_object('from', c.before -> k, 'to', c.after -> k))
+			FROM jsonb_object_keys(c.before || c.after) k
+			WHERE k NOT IN ('updated_at', 'version') AND (c.before -> k) IS DISTINCT FROM (c.after -> k))
+	FROM`, auditLogTable)
+
+// auditPrevious - снимок ресурса до текущей транзакции: последняя ревизия, записанная раньше нее
+const auditPrevious = `(SELECT rv.snapshot FROM %s rv
+		WHERE rv.entity = '%s' AND rv.entity_id = %s AND rv.created_at < now()
+		ORDER BY rv.version DESC LIMIT 1)`
+
+// auditItems записывает в журнал аудита изменение items в транзакции изменения.
+// before - состояние до транзакции, after - текущее; для удаления вызывается до удаления
+// строки, и текущее состояние становится before
+func auditItems(db sqlx.Execer, action string, itemIds []int) error {
+	previous := fmt.Sprintf(auditPrevious, revisionsTable, todo.EntityItem, "ti.id")
+	query := fmt.Sprintf(`%s (
+			SELECT $1::text AS action, $2::text AS entity, ti.id AS entity_id, li.list_id,
+				CASE WHEN $1 = $4 THEN to_jsonb(ti) - 'change_seq' - 'change_xid' ELSE %s END AS before,
+				CASE WHEN $1 = $4 THEN NULL ELSE to_jsonb(ti) - 'change_seq' - 'change_xid' END AS after
+			FROM %s ti
+			INNER JOIN %s li on li.item_id = ti.id
+			WHERE ti.id = ANY($3)
+			ORDER BY ti.id
+		) c`,
+		auditInsert, previous, todoItemsTable, listsItemsTable)
+	_, err := db.Exec(query, action, todo.EntityItem, pq.Array(itemIds), todo.EventItemDeleted)
+	return err
+}
+
+// auditList - то же, что auditItems, для списка. details дополняют снимок after,
+// например перенесенными при слиянии items
+func auditList(db sqlx.Execer, action string, listId int, details map[string]interface{}) error {
+	var extra interface{}
+	if len(details) > 0 {
+		data, err := json.Marshal(details)
+		if err != nil {
+			return err
+		}
+		extra = data
+	}
+
+	previous := fmt.Sprintf(auditPrevious, revisionsTable, todo.EntityList, "tl.id")
+	query := fmt.Sprintf(`%s (
+			SELECT $1::text AS action, $2::text AS entity, tl.id AS entity_id, tl.id AS list_id,
+				CASE WHEN $1 = $4 THEN to_jsonb(tl) - 'change_seq' - 'change_xid' ELSE %s END AS before,
+				CASE WHEN $1 = $4 THEN NULL ELSE (to_jsonb(tl) - 'change_seq' - 'change_xid') || coalesce($5::jsonb, '{}') END AS after
+			FROM %s tl
+			WHERE tl.id = $3
+		) c`,
+		auditInsert, previous, todoListsTable)
+	_, err := db.Exec(query, action, todo.EntityList, listId, todo.EventListDeleted, extra)
+	return err
+}
+
+// auditTimeEntry записывает в журнал аудита изменение записи учтенного времени.
+// Ревизий у записей нет: до остановки таймера запись отличалась только пустым ended_at
+func auditTimeEntry(db sqlx.Execer, action string, entryId int) error {
+	query := fmt.Sprintf(`%s (
+			SELECT $1::text AS action, $2::text AS entity, te.id AS entity_id, li.list_id,
+				CASE $1 WHEN $4 THEN NULL WHEN $5 THEN jsonb_set(to_jsonb(te), '{ended_at}', 'null') ELSE to_jsonb(te) END AS before,
+				CASE $1 WHEN $6 THEN NULL ELSE to_jsonb(te) END AS after
+			FROM %s te
+			INNER JOIN %s li on li.item_id = te.item_id
+			WHERE te.id = $3
+		) c`,
+		auditInsert, timeEntriesTable, listsItemsTable)
+	_, err := db.Exec(query, action, todo.EntityTimeEntry, entryId,
+		todo.AuditTimeEntryCreated, todo.AuditTimeEntryStopped, todo.AuditTimeEntryDeleted)
+	return err
+}
+
+// GetByList возвращает журнал списка, новые записи первыми; список должен быть доступен пользователю
+func (r *AuditPostgres) GetByList(userId, listId int, filter todo.AuditFilter) ([]todo.AuditEntry, int, error) {
+	if err := checkListAccess(r.db, userId, listId); err != nil {
+		return nil, 0, err
+	}
+
+	return r.get("list_id = $1", []interface{}{listId}, filter)
+}
+
+// GetFeed возвращает изменения в списках пользователя и изменения, сделанные им самим
+func (r *AuditPostgres) GetFeed(userId int, filter todo.AuditFilter) ([]todo.AuditEntry, int, error) {
+	where := fmt.Sprintf("(actor_id = $1 OR list_id IN (SELECT list_id FROM %s WHERE user_id = $1))", usersListsTable)
+	return r.get(where, []interface{}{userId}, filter)
+}
+
+func (r *AuditPostgres) get(where string, args []interface{}, filter todo.AuditFilter) ([]todo.AuditEntry, int, error) {
+	if len(filter.Actions) > 0 {
+		args = append(args, pq.StringArray(filter.Actions))
+		where += fmt.Sprintf(" AND action = ANY($%d)", len(args))
+	}
+
+	var total int
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", auditLogTable, where)
+	if err := r.db.Get(&total, countQuery, args...); err != nil {
+		return nil, 0, err
+	}
+
+	var rows []auditRow
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY id DESC LIMIT $%d OFFSET $%d",
+		auditColumns, auditLogTable, where, len(args)+1, len(args)+2)
+	if err := r.db.Select(&rows, query, append(args, filter.Limit, filter.Offset)...); err != nil {
+		return nil, 0, err
+	}
+
+	entries := make([]todo.AuditEntry, len(rows))
+	for i, row := range rows {
+		entries[i] = row.AuditEntry
+		entries[i].Before = row.Before
+		entries[i].After = row.After
+		entries[i].Diff = row.Diff
+	}
+	return entries, total, nil
+}
+
+// recordItemChange записывает изменение item в журнал аудита и ставит в очередь webhooks
+// в транзакции изменения
+func recordItemChange(db sqlx.Execer, action string, itemId int) error {
+	return recordItemsChange(db, action, []int{itemId})
+}
+
+// recordItemsChange - то же, что recordItemChange, одним запросом для набора items
+func recordItemsChange(db sqlx.Execer, action string, itemIds []int) error {
+	if err := auditItems(db, action, itemIds); err != nil {
+		return err
+	}
+	return enqueueItemsWebhooks(db, action, itemIds)
+}
+
+// recordListChange - то же, что recordItemChange, для списка
+func recordListChange(db sqlx.Execer, action string, listId int) error {
+	if err := auditList(db, action, listId, nil); err != nil {
+		return err
+	}
+	return enqueueListWebhooks(db, action, listId)
+}
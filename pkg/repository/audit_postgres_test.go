@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+)
+
+func getAuditRows(t *testing.T, db *sqlx.DB, entity string, entityId int) []auditRow {
+	t.Helper()
+
+	var rows []auditRow
+	query := "SELECT " + auditColumns + " FROM audit_log WHERE entity = $1 AND entity_id = $2 ORDER BY id"
+	if err := db.Select(&rows, query, entity, entityId); err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func decodeAuditJSON(t *testing.T, data []byte) map[string]interface{} {
+	t.Helper()
+
+	if data == nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	return fields
+}
+
+func TestAuditItemChanges(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	listId := createTestList(t, db, owner, "list")
+
+	meta := todo.RequestMeta{ActorId: owner, RequestId: "req-1", IP: "10.0.0.1", UserAgent: "test"}
+	repo := NewTodoItemPostgres(db).WithRequest(meta)
+
+	itemId, err := repo.Create(listId, todo.TodoItem{Title: "item"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	title := "renamed"
+	if err := repo.Update(owner, itemId, todo.UpdateItemInput{Title: &title}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(owner, itemId); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := getAuditRows(t, db, todo.EntityItem, itemId)
+	if len(rows) != 3 {
+		t.Fatalf("got %d audit rows, want created, updated and deleted", len(rows))
+	}
+
+	created, updated, deleted := rows[0], rows[1], rows[2]
+	if created.Action != todo.EventItemCreated || created.Before != nil || created.After == nil {
+		t.Errorf("created row: action %s, before %s, after %s", created.Action, created.Before, created.After)
+	}
+	if created.ActorId != owner || created.RequestId != "req-1" || created.IP != "10.0.0.1" || created.ListId != listId {
+		t.Errorf("created row meta: actor %d, request %q, ip %q, list %d", created.ActorId, created.RequestId, created.IP, created.ListId)
+	}
+
+	if updated.Action != todo.EventItemUpdated {
+		t.Errorf("updated row action = %s", updated.Action)
+	}
+	if updated.ActorId != owner || updated.RequestId != "req-1" || updated.IP != "10.0.0.1" || updated.ListId != listId {
+		t.Errorf("updated row meta: actor %d, request %q, ip %q, list %d", updated.ActorId, updated.RequestId, updated.IP, updated.ListId)
+	}
+	if before := decodeAuditJSON(t, updated.Before); before["title"] != "item" {
+		t.Errorf("before title = %v, want item", before["title"])
+	}
+	if after := decodeAuditJSON(t, updated.After); after["title"] != "renamed" {
+		t.Errorf("after title = %v, want renamed", after["title"])
+	}
+	diff := decodeAuditJSON(t, updated.Diff)
+	if len(diff) != 1 || diff["title"] == nil {
+		t.Errorf("diff = %s, want only title", updated.Diff)
+	}
+
+	if deleted.Action != todo.EventItemDeleted || deleted.After != nil {
+		t.Errorf("deleted row: action %s, after %s", deleted.Action, deleted.After)
+	}
+	if before := decodeAuditJSON(t, deleted.Before); before["title"] != "renamed" {
+		t.Errorf("deleted before title = %v, want renamed", before["title"])
+	}
+}
+
+func TestAuditRolledBackWithChange(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	listId := createTestList(t, db, owner, "list")
+	itemId := createTestItem(t, db, listId, "item")
+
+	// Вторая операция атомарного пакета не проходит проверку версии и откатывает первую
+	title := "renamed"
+	ops := []todo.BulkItemOperation{
+		{Op: todo.BulkOpUpdate, ItemId: itemId, Update: &todo.UpdateItemInput{Title: &title}},
+		{Op: todo.BulkOpComplete, ItemId: itemId, Version: 100},
+	}
+	results, err := NewTodoItemPostgres(db).WithRequest(todo.RequestMeta{ActorId: owner}).Bulk(owner, ops, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[1].Err == nil {
+		t.Fatal("expected the second operation to fail")
+	}
+
+	if rows := getAuditRows(t, db, todo.EntityItem, itemId); len(rows) != 1 {
+		t.Errorf("got %d audit rows, want only item.created", len(rows))
+	}
+}
+
+func TestAuditListMerged(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	listId := createTestList(t, db, owner, "target")
+	sourceId := createTestList(t, db, owner, "source")
+	itemId := createTestItem(t, db, sourceId, "item")
+
+	repo := NewTodoListPostgres(db).WithRequest(todo.RequestMeta{ActorId: owner})
+	if _, err := repo.Merge(owner, listId, sourceId, false); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := getAuditRows(t, db, todo.EntityList, listId)
+	last := rows[len(rows)-1]
+	if last.Action != todo.EventListMerged || last.ActorId != owner {
+		t.Fatalf("last list row: action %s, actor %d", last.Action, last.ActorId)
+	}
+	after := decodeAuditJSON(t, last.After)
+	if after["source_list_id"] != float64(sourceId) {
+		t.Errorf("source_list_id = %v, want %d", after["source_list_id"], sourceId)
+	}
+
+	itemRows := getAuditRows(t, db, todo.EntityItem, itemId)
+	if moved := itemRows[len(itemRows)-1]; moved.Action != todo.EventItemMoved || moved.ListId != listId {
+		t.Errorf("item row: action %s, list %d", moved.Action, moved.ListId)
+	}
+}
+
+func TestAuditTimeEntries(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	listId := createTestList(t, db, owner, "list")
+	itemId := createTestItem(t, db, listId, "item")
+
+	repo := NewTimeEntryPostgres(db).WithRequest(todo.RequestMeta{ActorId: owner, RequestId: "req-2"})
+	firstId, err := repo.StartTimer(owner, itemId, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Новый таймер останавливает запущенный
+	secondId, err := repo.StartTimer(owner, itemId, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.StopTimer(owner); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(owner, secondId); err != nil {
+		t.Fatal(err)
+	}
+
+	first := getAuditRows(t, db, todo.EntityTimeEntry, firstId)
+	if len(first) != 2 || first[0].Action != todo.AuditTimeEntryCreated || first[1].Action != todo.AuditTimeEntryStopped {
+		t.Fatalf("first entry rows = %+v, want created and stopped", first)
+	}
+	stopped := first[1]
+	if stopped.ActorId != owner || stopped.RequestId != "req-2" || stopped.ListId != listId {
+		t.Errorf("stopped row meta: actor %d, request %q, list %d", stopped.ActorId, stopped.RequestId, stopped.ListId)
+	}
+	if diff := decodeAuditJSON(t, stopped.Diff); diff["ended_at"] == nil {
+		t.Errorf("stopped diff = %s, want ended_at", stopped.Diff)
+	}
+
+	second := getAuditRows(t, db, todo.EntityTimeEntry, secondId)
+	var actions []string
+	for _, row := range second {
+		actions = append(actions, row.Action)
+	}
+	want := []string{todo.AuditTimeEntryCreated, todo.AuditTimeEntryStopped, todo.AuditTimeEntryDeleted}
+	if len(actions) != len(want) {
+		t.Fatalf("second entry actions = %v, want %v", actions, want)
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Fatalf("second entry actions = %v, want %v", actions, want)
+		}
+	}
+	if deleted := second[2]; deleted.Before == nil || deleted.After != nil {
+		t.Errorf("deleted row: before %s, after %s", deleted.Before, deleted.After)
+	}
+}
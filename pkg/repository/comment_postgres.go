@@ -0,0 +1,271 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+	"github.com/lib/pq"
+)
+
+type CommentPostgres struct {
+	db *sqlx.DB
+}
+
+func NewCommentPostgres(db *sqlx.DB) *CommentPostgres {
+	return &CommentPostgres{db: db}
+}
+
+type commentRow struct {
+	todo.Comment
+	Mentions pq.StringArray `db:"mentions"`
+}
+
+// commentColumns - колонки выборки комментариев (псевдоним c); текст и упоминания
+// удаленного комментария не отдаются
+var commentColumns = fmt.Sprintf(`c.id, c.item_id, c.parent_id, coalesce(c.author_id, 0) AS author_id, coalesce(u.username, '') AS author,
+		CASE WHEN c.deleted_at IS NULL THEN c.body ELSE '' END AS body, c.deleted_at IS NOT NULL AS deleted,
+		c.created_at, c.updated_at, c.edited_at,
+		array(SELECT mu.username FROM %s m INNER JOIN %s mu on mu.id = m.user_id
+			WHERE m.comment_id = c.id AND c.deleted_at IS NULL ORDER BY mu.username) AS mentions`,
+	commentMentionsTable, usersTable)
+
+// commentAccess - условие доступа пользователя ($2) к item комментария
+var commentAccess = fmt.Sprintf(`EXISTS (SELECT 1 FROM %s li INNER JOIN %s ul on ul.list_id = li.list_id
+		WHERE li.item_id = c.item_id AND ul.user_id = $2)`, listsItemsTable, usersListsTable)
+
+// Create создает комментарий и упоминания; возвращает id комментария и упомянутых пользователей
+func (r *CommentPostgres) Create(userId, itemId int, input todo.CommentInput, mentions []string) (int, []int, error) {
+	var id int
+	var mentioned []int
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		listId, err := itemListId(tx, userId, itemId)
+		if err != nil {
+			return err
+		}
+
+		if input.ParentId != nil {
+			var parentItemId int
+			query := fmt.Sprintf("SELECT item_id FROM %s WHERE id = $1", commentsTable)
+			err := tx.Get(&parentItemId, query, *input.ParentId)
+			if errors.Is(err, sql.ErrNoRows) || (err == nil && parentItemId != itemId) {
+				return todo.ErrInvalidParentComment
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		query := fmt.Sprintf(`
+			INSERT INTO %s (item_id, parent_id, author_id, body, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $5)
+			RETURNING id`, commentsTable)
+		if err := tx.Get(&id, query, itemId, input.ParentId, userId, input.Body, now); err != nil {
+			return err
+		}
+
+		mentioned, err = setMentions(tx, id, listId, mentions)
+		return err
+	})
+
+	return id, mentioned, err
+}
+
+// GetAll возвращает комментарии item в порядке создания, включая удаленные,
+// чтобы клиент мог построить дерево ответов
+func (r *CommentPostgres) GetAll(userId, itemId, offset, limit int) ([]todo.Comment, int, error) {
+	if _, err := itemListId(r.db, userId, itemId); err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE item_id = $1", commentsTable)
+	if err := r.db.Get(&total, countQuery, itemId); err != nil {
+		return nil, 0, err
+	}
+
+	var rows []commentRow
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s c
+		LEFT JOIN %s u on u.id = c.author_id
+		WHERE c.item_id = $1
+		ORDER BY c.id
+		LIMIT $2 OFFSET $3`,
+		commentColumns, commentsTable, usersTable)
+	if err := r.db.Select(&rows, query, itemId, limit, offset); err != nil {
+		return nil, 0, err
+	}
+
+	comments := make([]todo.Comment, len(rows))
+	for i, row := range rows {
+		comments[i] = row.toComment()
+	}
+	return comments, total, nil
+}
+
+func (r *CommentPostgres) GetById(userId, commentId int) (todo.Comment, error) {
+	var row commentRow
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s c
+		LEFT JOIN %s u on u.id = c.author_id
+		WHERE c.id = $1 AND %s`,
+		commentColumns, commentsTable, usersTable, commentAccess)
+	if err := r.db.Get(&row, query, commentId, userId); err != nil {
+		return todo.Comment{}, err
+	}
+
+	return row.toComment(), nil
+}
+
+// Update меняет текст комментария, сохраняя предыдущую версию в истории правок.
+// Возвращает пользователей, упомянутых впервые
+func (r *CommentPostgres) Update(userId, commentId int, body string, mentions []string) ([]int, error) {
+	var mentioned []int
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		comment, err := lockComment(tx, userId, commentId)
+		if err != nil {
+			return err
+		}
+		if comment.Body == body {
+			return nil
+		}
+
+		editQuery := fmt.Sprintf("INSERT INTO %s (comment_id, body, edited_at) VALUES ($1, $2, $3)", commentEditsTable)
+		now := time.Now()
+		if _, err := tx.Exec(editQuery, commentId, comment.Body, now); err != nil {
+			return err
+		}
+
+		query := fmt.Sprintf("UPDATE %s SET body = $1, edited_at = $2, updated_at = $2 WHERE id = $3", commentsTable)
+		if _, err := tx.Exec(query, body, now, commentId); err != nil {
+			return err
+		}
+
+		listId, err := itemListId(tx, userId, comment.ItemId)
+		if err != nil {
+			return err
+		}
+
+		mentioned, err = setMentions(tx, commentId, listId, mentions)
+		return err
+	})
+
+	return mentioned, err
+}
+
+// Delete помечает комментарий удаленным; история правок и упоминания удаляются
+func (r *CommentPostgres) Delete(userId, commentId int) error {
+	return inTx(r.db, func(tx *sqlx.Tx) error {
+		if _, err := lockComment(tx, userId, commentId); err != nil {
+			return err
+		}
+
+		query := fmt.Sprintf("UPDATE %s SET deleted_at = $1, updated_at = $1 WHERE id = $2", commentsTable)
+		if _, err := tx.Exec(query, time.Now(), commentId); err != nil {
+			return err
+		}
+
+		editsQuery := fmt.Sprintf("DELETE FROM %s WHERE comment_id = $1", commentEditsTable)
+		if _, err := tx.Exec(editsQuery, commentId); err != nil {
+			return err
+		}
+
+		mentionsQuery := fmt.Sprintf("DELETE FROM %s WHERE comment_id = $1", commentMentionsTable)
+		_, err := tx.Exec(mentionsQuery, commentId)
+		return err
+	})
+}
+
+// GetEdits возвращает предыдущие версии текста, новые первыми
+func (r *CommentPostgres) GetEdits(userId, commentId int) ([]todo.CommentEdit, error) {
+	var deleted bool
+	query := fmt.Sprintf("SELECT c.deleted_at IS NOT NULL FROM %s c WHERE c.id = $1 AND %s", commentsTable, commentAccess)
+	if err := r.db.Get(&deleted, query, commentId, userId); err != nil {
+		return nil, err
+	}
+	if deleted {
+		return nil, sql.ErrNoRows
+	}
+
+	edits := make([]todo.CommentEdit, 0)
+	editsQuery := fmt.Sprintf("SELECT body, edited_at FROM %s WHERE comment_id = $1 ORDER BY id DESC", commentEditsTable)
+	if err := r.db.Select(&edits, editsQuery, commentId); err != nil {
+		return nil, err
+	}
+
+	return edits, nil
+}
+
+type lockedComment struct {
+	ItemId   int    `db:"item_id"`
+	AuthorId int    `db:"author_id"`
+	Body     string `db:"body"`
+}
+
+// lockComment блокирует неудаленный комментарий, доступный пользователю.
+// Изменять комментарий может только его автор
+func lockComment(tx *sqlx.Tx, userId, commentId int) (lockedComment, error) {
+	var comment lockedComment
+	query := fmt.Sprintf(`
+		SELECT c.item_id, coalesce(c.author_id, 0) AS author_id, c.body
+		FROM %s c
+		WHERE c.id = $1 AND c.deleted_at IS NULL AND %s
+		FOR UPDATE OF c`,
+		commentsTable, commentAccess)
+	if err := tx.Get(&comment, query, commentId, userId); err != nil {
+		return comment, err
+	}
+	if comment.AuthorId != userId {
+		return comment, todo.ErrForbidden
+	}
+
+	return comment, nil
+}
+
+// setMentions приводит упоминания комментария к списку usernames. Упомянуть можно только
+// пользователей с доступом к списку. Возвращает пользователей, упомянутых впервые
+func setMentions(tx *sqlx.Tx, commentId, listId int, usernames []string) ([]int, error) {
+	// Пустой, а не nil массив: с NULL в ANY не удалилось бы ни одно упоминание
+	names := append(pq.StringArray{}, usernames...)
+
+	deleteQuery := fmt.Sprintf(`
+		DELETE FROM %s m
+		USING %s u
+		WHERE m.comment_id = $1 AND u.id = m.user_id AND NOT (u.username = ANY($2))`,
+		commentMentionsTable, usersTable)
+	if _, err := tx.Exec(deleteQuery, commentId, names); err != nil {
+		return nil, err
+	}
+
+	added := make([]int, 0)
+	if len(usernames) == 0 {
+		return added, nil
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s (comment_id, user_id)
+		SELECT DISTINCT $1::int, u.id
+		FROM %s u
+		INNER JOIN %s ul on ul.user_id = u.id
+		WHERE ul.list_id = $2 AND u.username = ANY($3)
+		ON CONFLICT DO NOTHING
+		RETURNING user_id`,
+		commentMentionsTable, usersTable, usersListsTable)
+	err := tx.Select(&added, query, commentId, listId, names)
+	return added, err
+}
+
+func (row commentRow) toComment() todo.Comment {
+	comment := row.Comment
+	comment.Mentions = []string(row.Mentions)
+	if comment.Mentions == nil {
+		comment.Mentions = []string{}
+	}
+	return comment
+}
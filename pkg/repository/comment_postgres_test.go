@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ktuty/todo-app"
+)
+
+func TestCommentMentionsAndEdits(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	member := createTestUser(t, db, "member")
+	createTestUser(t, db, "outsider")
+	listId := createTestList(t, db, owner, "list")
+	itemId := createTestItem(t, db, listId, "item")
+	addTestMember(t, db, owner, listId, "member")
+	repo := NewCommentPostgres(db)
+
+	// Упомянуть можно только участников списка
+	id, mentioned, err := repo.Create(owner, itemId, todo.CommentInput{Body: "hi"}, []string{"member", "outsider"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(mentioned, []int{member}) {
+		t.Errorf("mentioned = %v, want [%d]", mentioned, member)
+	}
+
+	// Повторное упоминание не считается новым, снятое - удаляется
+	mentioned, err = repo.Update(owner, id, "edited", []string{"member", "owner"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(mentioned, []int{owner}) {
+		t.Errorf("mentioned on edit = %v, want [%d]", mentioned, owner)
+	}
+	if _, err := repo.Update(owner, id, "edited again", []string{"owner"}); err != nil {
+		t.Fatal(err)
+	}
+
+	comment, err := repo.GetById(member, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comment.Body != "edited again" || comment.EditedAt == nil || !reflect.DeepEqual(comment.Mentions, []string{"owner"}) {
+		t.Errorf("comment = %+v", comment)
+	}
+
+	edits, err := repo.GetEdits(member, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edits) != 2 || edits[0].Body != "edited" || edits[1].Body != "hi" {
+		t.Errorf("edits = %+v, want newest first", edits)
+	}
+
+	// Менять комментарий может только автор
+	if _, err := repo.Update(member, id, "hijack", nil); !errors.Is(err, todo.ErrForbidden) {
+		t.Errorf("update by member: err = %v, want forbidden", err)
+	}
+	if err := repo.Delete(member, id); !errors.Is(err, todo.ErrForbidden) {
+		t.Errorf("delete by member: err = %v, want forbidden", err)
+	}
+
+	if err := repo.Delete(owner, id); err != nil {
+		t.Fatal(err)
+	}
+	comment, err = repo.GetById(member, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !comment.Deleted || comment.Body != "" || len(comment.Mentions) != 0 {
+		t.Errorf("deleted comment = %+v", comment)
+	}
+	if _, err := repo.GetEdits(member, id); err == nil {
+		t.Error("edits of a deleted comment are readable")
+	}
+}
+
+func TestCommentReplies(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	outsider := createTestUser(t, db, "outsider")
+	listId := createTestList(t, db, owner, "list")
+	itemId := createTestItem(t, db, listId, "item")
+	otherItemId := createTestItem(t, db, listId, "other")
+	repo := NewCommentPostgres(db)
+
+	parentId, _, err := repo.Create(owner, itemId, todo.CommentInput{Body: "parent"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := repo.Create(owner, itemId, todo.CommentInput{Body: "reply", ParentId: &parentId}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ответ должен относиться к тому же item, что и родитель
+	_, _, err = repo.Create(owner, otherItemId, todo.CommentInput{Body: "reply", ParentId: &parentId}, nil)
+	if !errors.Is(err, todo.ErrInvalidParentComment) {
+		t.Errorf("reply on another item: err = %v, want invalid parent", err)
+	}
+
+	comments, total, err := repo.GetAll(owner, itemId, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(comments) != 2 || comments[1].ParentId == nil || *comments[1].ParentId != parentId {
+		t.Errorf("comments = %+v, total %d", comments, total)
+	}
+
+	if _, _, err := repo.GetAll(outsider, itemId, 0, 10); err == nil {
+		t.Error("outsider can read comments")
+	}
+}
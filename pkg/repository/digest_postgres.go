@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+)
+
+type DigestPostgres struct {
+	db *sqlx.DB
+}
+
+func NewDigestPostgres(db *sqlx.DB) *DigestPostgres {
+	return &DigestPostgres{db: db}
+}
+
+// GetPreferences возвращает настройки дайджеста; если пользователь их не сохранял - значения по умолчанию
+func (r *DigestPostgres) GetPreferences(userId int) (todo.DigestPreferences, error) {
+	var preferences todo.DigestPreferences
+	query := fmt.Sprintf(`
+		SELECT coalesce(dp.enabled, false) AS enabled, coalesce(dp.frequency, 'daily') AS frequency,
+			to_char(coalesce(dp.send_at, '08:00'), 'HH24:MI') AS send_at, coalesce(dp.weekday, 1) AS weekday,
+			u.time_zone, dp.sent_at
+		FROM %s u
+		LEFT JOIN %s dp on dp.user_id = u.id
+		WHERE u.id = $1`,
+		usersTable, digestPrefsTable)
+	err := r.db.Get(&preferences, query, userId)
+
+	return preferences, err
+}
+
+// UpdatePreferences сохраняет переданные настройки; часовой пояс записывается в настройки пользователя
+func (r *DigestPostgres) UpdatePreferences(userId int, input todo.UpdateDigestPreferencesInput) error {
+	return inTx(r.db, func(tx *sqlx.Tx) error {
+		query := fmt.Sprintf(`
+			INSERT INTO %s AS dp (user_id, enabled, frequency, send_at, weekday, updated_at)
+			VALUES ($1, coalesce($2::boolean, false), coalesce($3::varchar, 'daily'),
+				coalesce($4::time, '08:00'), coalesce($5::smallint, 1), $6)
+			ON CONFLICT (user_id) DO UPDATE SET
+				enabled = coalesce($2::boolean, dp.enabled),
+				frequency = coalesce($3::varchar, dp.frequency),
+				send_at = coalesce($4::time, dp.send_at),
+				weekday = coalesce($5::smallint, dp.weekday),
+				updated_at = $6`,
+			digestPrefsTable)
+		if _, err := tx.Exec(query, userId, input.Enabled, input.Frequency, input.SendAt, input.Weekday, time.Now()); err != nil {
+			return err
+		}
+
+		if input.TimeZone != nil {
+			userQuery := fmt.Sprintf("UPDATE %s SET time_zone = $1 WHERE id = $2", usersTable)
+			if _, err := tx.Exec(userQuery, *input.TimeZone, userId); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetDue возвращает невыполненные items из доступных пользователю списков со сроком в [from, to).
+// Нулевой from означает все items со сроком до to
+func (r *DigestPostgres) GetDue(userId int, from, to time.Time, limit int) ([]todo.DigestItem, error) {
+	items := make([]todo.DigestItem, 0)
+	query := fmt.Sprintf(`
+		SELECT ti.id AS item_id, ti.title, tl.id AS list_id, tl.title AS list_title, ti.due_at
+		FROM %s ti
+		INNER JOIN %s li on li.item_id = ti.id
+		INNER JOIN %s tl on tl.id = li.list_id
+		WHERE ti.due_at < $3 AND ($2::timestamptz IS NULL OR ti.due_at >= $2)
+			AND ti.done = false AND ti.archived = false AND tl.archived = false
+			AND EXISTS (SELECT 1 FROM %s ul WHERE ul.list_id = tl.id AND ul.user_id = $1)
+		ORDER BY ti.due_at, ti.id
+		LIMIT $4`,
+		todoItemsTable, listsItemsTable, todoListsTable, usersListsTable)
+
+	var fromArg interface{}
+	if !from.IsZero() {
+		fromArg = from
+	}
+	err := r.db.Select(&items, query, userId, fromArg, to, limit)
+
+	return items, err
+}
+
+// GetCompleted возвращает items, отмеченные выполненными другими участниками общих
+// с пользователем списков в [from, to). Источник - журнал аудита; items, снова открытые
+// после выполнения, не попадают в выборку
+func (r *DigestPostgres) GetCompleted(userId int, from, to time.Time, limit int) ([]todo.DigestItem, error) {
+	items := make([]todo.DigestItem, 0)
+	query := fmt.Sprintf(`
+		SELECT * FROM (
+			SELECT DISTINCT ON (a.entity_id) a.entity_id AS item_id, ti.title, tl.id AS list_id,
+				tl.title AS list_title, ti.due_at, u.username AS completed_by, a.created_at AS completed_at
+			FROM %s a
+			INNER JOIN %s ti on ti.id = a.entity_id
+			INNER JOIN %s tl on tl.id = a.list_id
+			INNER JOIN %s u on u.id = a.actor_id
+			WHERE a.action = $2 AND a.actor_id <> $1 AND a.created_at >= $3 AND a.created_at < $4
+				AND ti.done = true
+				AND EXISTS (SELECT 1 FROM %s ul WHERE ul.list_id = a.list_id AND ul.user_id = $1)
+			ORDER BY a.entity_id, a.created_at DESC
+		) completed
+		ORDER BY completed_at, item_id
+		LIMIT $5`,
+		auditLogTable, todoItemsTable, todoListsTable, usersTable, usersListsTable)
+	err := r.db.Select(&items, query, userId, todo.EventItemCompleted, from, to, limit)
+
+	return items, err
+}
+
+// ClaimDue выбирает пользователей, у которых наступило время дайджеста, и захватывает
+// их на время lease. Дайджест отправляется один раз за местную дату и не позже чем
+// через maxLateness после времени отправки, например после простоя
+func (r *DigestPostgres) ClaimDue(limit int, lease, maxLateness time.Duration) ([]todo.DigestDelivery, error) {
+	deliveries := make([]todo.DigestDelivery, 0)
+	query := fmt.Sprintf(`
+		WITH due AS (
+			SELECT dp.user_id, u.name, u.email, u.time_zone, dp.frequency, n.local_now::date AS local_date
+			FROM %s dp
+			INNER JOIN %s u on u.id = dp.user_id
+			CROSS JOIN LATERAL (SELECT $2::timestamptz AT TIME ZONE u.time_zone AS local_now) n
+			WHERE dp.enabled AND coalesce(u.email, '') <> ''
+				AND n.local_now >= n.local_now::date + dp.send_at
+				AND n.local_now < n.local_now::date + dp.send_at + make_interval(secs => $4)
+				AND (dp.frequency = 'daily' OR extract(isodow FROM n.local_now) = dp.weekday)
+				AND dp.sent_for IS DISTINCT FROM n.local_now::date
+				AND (dp.claimed_until IS NULL OR dp.claimed_until < $2)
+			ORDER BY dp.user_id
+			LIMIT $1
+			FOR UPDATE OF dp SKIP LOCKED
+		)
+		UPDATE %s dp SET claimed_until = $3
+		FROM due
+		WHERE dp.user_id = due.user_id
+		RETURNING due.user_id, due.name, due.email, due.time_zone, due.frequency, due.local_date`,
+		digestPrefsTable, usersTable, digestPrefsTable)
+	now := time.Now()
+	err := r.db.Select(&deliveries, query, limit, now, now.Add(lease), maxLateness.Seconds())
+
+	return deliveries, err
+}
+
+// MarkSent фиксирует отправку дайджеста за местную дату date
+func (r *DigestPostgres) MarkSent(userId int, date time.Time) error {
+	query := fmt.Sprintf(`
+		UPDATE %s SET sent_for = $1, sent_at = $2, claimed_until = NULL, last_error = NULL
+		WHERE user_id = $3`, digestPrefsTable)
+	_, err := r.db.Exec(query, date.Format("2006-01-02"), time.Now(), userId)
+
+	return err
+}
+
+// MarkFailed сохраняет ошибку отправки; повторная попытка будет после retryAt
+func (r *DigestPostgres) MarkFailed(userId int, reason string, retryAt time.Time) error {
+	query := fmt.Sprintf("UPDATE %s SET last_error = $1, claimed_until = $2 WHERE user_id = $3", digestPrefsTable)
+	_, err := r.db.Exec(query, reason, retryAt, userId)
+
+	return err
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+)
+
+func enableTestDigest(t *testing.T, db *sqlx.DB, userId int, frequency string, weekday int) {
+	t.Helper()
+
+	if _, err := db.Exec("UPDATE users SET email = $1 WHERE id = $2", "user@example.com", userId); err != nil {
+		t.Fatal(err)
+	}
+
+	// Отправка в полночь: с запасом по опозданию в сутки дайджест уже пора отправить
+	enabled, sendAt := true, "00:00"
+	input := todo.UpdateDigestPreferencesInput{Enabled: &enabled, Frequency: &frequency, SendAt: &sendAt, Weekday: &weekday}
+	if err := NewDigestPostgres(db).UpdatePreferences(userId, input); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDigestClaimDue(t *testing.T) {
+	db := newTestDB(t)
+	daily := createTestUser(t, db, "daily")
+	weekly := createTestUser(t, db, "weekly")
+	createTestUser(t, db, "disabled")
+	repo := NewDigestPostgres(db)
+
+	var today int
+	if err := db.Get(&today, "SELECT extract(isodow FROM now() AT TIME ZONE 'UTC')::int"); err != nil {
+		t.Fatal(err)
+	}
+	enableTestDigest(t, db, daily, todo.DigestDaily, 1)
+	enableTestDigest(t, db, weekly, todo.DigestWeekly, today%7+1)
+
+	deliveries, err := repo.ClaimDue(10, time.Minute, 25*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || deliveries[0].UserId != daily || deliveries[0].Frequency != todo.DigestDaily {
+		t.Fatalf("deliveries = %+v, want only the daily user", deliveries)
+	}
+
+	// Захваченный дайджест не выдается повторно
+	if again, err := repo.ClaimDue(10, time.Minute, 25*time.Hour); err != nil || len(again) != 0 {
+		t.Fatalf("claimed again: %+v, %v", again, err)
+	}
+
+	// После ошибки дайджест снова доступен по наступлении retryAt
+	if err := repo.MarkFailed(daily, "smtp down", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	retried, err := repo.ClaimDue(10, time.Minute, 25*time.Hour)
+	if err != nil || len(retried) != 1 {
+		t.Fatalf("retry: %+v, %v", retried, err)
+	}
+
+	// Отправленный за местную дату дайджест больше не выдается в этот день
+	if err := repo.MarkSent(daily, retried[0].Date); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE digest_preferences SET claimed_until = NULL"); err != nil {
+		t.Fatal(err)
+	}
+	if after, err := repo.ClaimDue(10, time.Minute, 25*time.Hour); err != nil || len(after) != 0 {
+		t.Fatalf("claimed after send: %+v, %v", after, err)
+	}
+}
+
+func TestDigestCompletedByOthers(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	member := createTestUser(t, db, "member")
+	listId := createTestList(t, db, owner, "list")
+	byMember := createTestItem(t, db, listId, "by member")
+	byOwner := createTestItem(t, db, listId, "by owner")
+	reopened := createTestItem(t, db, listId, "reopened")
+	addTestMember(t, db, owner, listId, "member")
+
+	complete := func(userId, itemId int) {
+		t.Helper()
+		repo := NewTodoItemPostgres(db).WithRequest(todo.RequestMeta{ActorId: userId})
+		if err := repo.CompleteItem(userId, itemId, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	complete(member, byMember)
+	complete(owner, byOwner)
+	complete(member, reopened)
+
+	done := false
+	if err := NewTodoItemPostgres(db).Update(owner, reopened, todo.UpdateItemInput{Done: &done}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	items, err := NewDigestPostgres(db).GetCompleted(owner, now.Add(-time.Hour), now.Add(time.Hour), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].ItemId != byMember || items[0].CompletedBy != "member" {
+		t.Errorf("completed = %+v, want only the item completed by member", items)
+	}
+}
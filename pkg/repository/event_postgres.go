@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// eventsChannel - канал LISTEN/NOTIFY, через который реплики узнают о новых событиях
+const eventsChannel = "todo_events"
+
+type EventPostgres struct {
+	db *sqlx.DB
+}
+
+func NewEventPostgres(db *sqlx.DB) *EventPostgres {
+	return &EventPostgres{db: db}
+}
+
+type eventRow struct {
+	todo.Event
+	Audience pq.Int64Array `db:"audience"`
+	Payload  []byte        `db:"payload"`
+}
+
+func (r eventRow) toEvent() todo.Event {
+	event := r.Event
+	event.Audience = make([]int, len(r.Audience))
+	for i, id := range r.Audience {
+		event.Audience[i] = int(id)
+	}
+	event.Data = r.Payload
+	return event
+}
+
+const eventColumns = `id, type, coalesce(actor_id, 0) AS actor_id, coalesce(list_id, 0) AS list_id,
+		coalesce(item_id, 0) AS item_id, audience, payload, created_at`
+
+// Create сохраняет событие и уведомляет слушателей канала eventsChannel.
+// NOTIFY доставляется только после фиксации транзакции.
+// Вставки выполняются под общей блокировкой, чтобы id событий шли в порядке фиксации:
+// иначе событие с меньшим id могло бы стать видимым после большего, и клиент,
+// продолжающий поток с Last-Event-ID, никогда бы его не получил
+func (r *EventPostgres) Create(event todo.Event) (int64, error) {
+	audience := make(pq.Int64Array, len(event.Audience))
+	for i, id := range event.Audience {
+		audience[i] = int64(id)
+	}
+
+	var payload interface{}
+	if len(event.Data) > 0 {
+		payload = []byte(event.Data)
+	}
+
+	query := fmt.Sprintf(`
+		WITH e AS (
+			INSERT INTO %s (type, actor_id, list_id, item_id, audience, payload, created_at) 
+			VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), NULLIF($4, 0), $5, $6, $7) 
+			RETURNING id
+		)
+		SELECT id FROM e, pg_notify($8, e.id::text)`, eventsTable)
+
+	var id int64
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", eventsTable); err != nil {
+			return err
+		}
+		return tx.Get(&id, query,
+			event.Type, event.ActorId, event.ListId, event.ItemId, audience, payload, time.Now(), eventsChannel)
+	})
+	return id, err
+}
+
+func (r *EventPostgres) GetById(eventId int64) (todo.Event, error) {
+	var row eventRow
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE id = $1", eventColumns, eventsTable)
+	if err := r.db.Get(&row, query, eventId); err != nil {
+		return todo.Event{}, err
+	}
+
+	return row.toEvent(), nil
+}
+
+// GetSince возвращает события, доступные пользователю, с id больше afterId
+func (r *EventPostgres) GetSince(userId int, afterId int64, limit int) ([]todo.Event, error) {
+	var rows []eventRow
+	query := fmt.Sprintf(`
+		SELECT %s FROM %s 
+		WHERE id > $1 AND audience @> ARRAY[$2]::int[] 
+		ORDER BY id 
+		LIMIT $3`, eventColumns, eventsTable)
+	if err := r.db.Select(&rows, query, afterId, userId, limit); err != nil {
+		return nil, err
+	}
+
+	events := make([]todo.Event, len(rows))
+	for i, row := range rows {
+		events[i] = row.toEvent()
+	}
+	return events, nil
+}
+
+// DeleteBefore удаляет не больше limit событий, созданных раньше before, и возвращает их число.
+// Клиент, отключившийся дольше срока хранения, получит только оставшиеся события
+func (r *EventPostgres) DeleteBefore(before time.Time, limit int) (int64, error) {
+	query := fmt.Sprintf(`
+		DELETE FROM %s WHERE id IN (
+			SELECT id FROM %s WHERE created_at < $1 ORDER BY created_at LIMIT $2
+		)`, eventsTable, eventsTable)
+	result, err := r.db.Exec(query, before, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetListAudience возвращает пользователей, имеющих доступ к списку
+func (r *EventPostgres) GetListAudience(listId int) ([]int, error) {
+	var userIds []int
+	query := fmt.Sprintf("SELECT DISTINCT user_id FROM %s WHERE list_id = $1", usersListsTable)
+	err := r.db.Select(&userIds, query, listId)
+	return userIds, err
+}
+
+// EventListener получает id новых событий через LISTEN/NOTIFY,
+// в том числе опубликованных другими репликами приложения
+type EventListener struct {
+	listener *pq.Listener
+	ids      chan int64
+}
+
+func NewEventListener(cfg Config) (*EventListener, error) {
+	listener := pq.NewListener(cfg.connectionString(), 10*time.Second, time.Minute,
+		func(event pq.ListenerEventType, err error) {
+			if err != nil {
+				logrus.Errorf("events listener: %s", err.Error())
+			}
+		})
+
+	if err := listener.Listen(eventsChannel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	l := &EventListener{listener: listener, ids: make(chan int64, 256)}
+	go l.run()
+
+	return l, nil
+}
+
+func (l *EventListener) run() {
+	defer close(l.ids)
+
+	for {
+		select {
+		case notification, ok := <-l.listener.Notify:
+			if !ok {
+				return
+			}
+			// nil приходит после переподключения: пропущенные уведомления
+			// клиенты догоняют по Last-Event-ID
+			if notification == nil {
+				continue
+			}
+			id, err := strconv.ParseInt(notification.Extra, 10, 64)
+			if err != nil {
+				logrus.Errorf("events listener: invalid payload %q", notification.Extra)
+				continue
+			}
+			l.ids <- id
+		case <-time.After(90 * time.Second):
+			go l.listener.Ping()
+		}
+	}
+}
+
+// Ids возвращает канал с id опубликованных событий
+func (l *EventListener) Ids() <-chan int64 {
+	return l.ids
+}
+
+func (l *EventListener) Close() error {
+	return l.listener.Close()
+}
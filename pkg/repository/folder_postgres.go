@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+)
+
+type FolderPostgres struct {
+	db *sqlx.DB
+}
+
+func NewFolderPostgres(db *sqlx.DB) *FolderPostgres {
+	return &FolderPostgres{db: db}
+}
+
+// Create создает папку пользователя в корне или в его папке ParentId
+func (r *FolderPostgres) Create(userId int, input todo.FolderInput) (int, error) {
+	var id int
+	err := inTx(r.db, func(tx *sqlx.Tx) error {
+		if err := lockFolders(tx, userId); err != nil {
+			return err
+		}
+		if input.ParentId != nil {
+			if err := checkFolderOwner(tx, userId, *input.ParentId); err != nil {
+				return err
+			}
+		}
+
+		query := fmt.Sprintf(`
+			INSERT INTO %s (user_id, parent_id, name, created_at, updated_at) VALUES ($1, $2, $3, $4, $4)
+			RETURNING id`, foldersTable)
+		return tx.Get(&id, query, userId, input.ParentId, input.Name, time.Now())
+	})
+
+	return id, err
+}
+
+func (r *FolderPostgres) GetById(userId, folderId int) (todo.Folder, error) {
+	var folder todo.Folder
+	query := fmt.Sprintf("SELECT id, parent_id, name, created_at, updated_at FROM %s WHERE id = $1 AND user_id = $2", foldersTable)
+	err := r.db.Get(&folder, query, folderId, userId)
+
+	return folder, err
+}
+
+// Update переименовывает и переносит папку. Перенос в саму папку или во вложенную
+// в нее отклоняется с todo.ErrFolderCycle
+func (r *FolderPostgres) Update(userId, folderId int, input todo.UpdateFolderInput) error {
+	return inTx(r.db, func(tx *sqlx.Tx) error {
+		if err := lockFolders(tx, userId); err != nil {
+			return err
+		}
+
+		var id int
+		query := fmt.Sprintf("SELECT id FROM %s WHERE id = $1 AND user_id = $2", foldersTable)
+		if err := tx.Get(&id, query, folderId, userId); err != nil {
+			return err
+		}
+
+		if input.ParentId.Set && input.ParentId.Value != nil {
+			parentId := *input.ParentId.Value
+			if err := checkFolderOwner(tx, userId, parentId); err != nil {
+				return err
+			}
+
+			// Цикл появится, если новая родительская папка лежит внутри переносимой
+			var cycle bool
+			cycleQuery := fmt.Sprintf(`
+				WITH RECURSIVE subtree (id) AS (
+					SELECT $1::int
+					UNION
+					SELECT f.id FROM %s f INNER JOIN subtree s ON f.parent_id = s.id
+				)
+				SELECT EXISTS (SELECT 1 FROM subtree WHERE id = $2)`,
+				foldersTable)
+			if err := tx.Get(&cycle, cycleQuery, folderId, parentId); err != nil {
+				return err
+			}
+			if cycle {
+				return todo.ErrFolderCycle
+			}
+		}
+
+		setValues := "updated_at = $1"
+		args := []interface{}{time.Now(), folderId}
+		if input.Name != nil {
+			args = append(args, *input.Name)
+			setValues += fmt.Sprintf(", name = $%d", len(args))
+		}
+		if input.ParentId.Set {
+			args = append(args, input.ParentId.Value)
+			setValues += fmt.Sprintf(", parent_id = $%d", len(args))
+		}
+
+		updateQuery := fmt.Sprintf("UPDATE %s SET %s WHERE id = $2", foldersTable, setValues)
+		_, err := tx.Exec(updateQuery, args...)
+		return err
+	})
+}
+
+// Delete удаляет папку. Без cascade вложенные папки и списки пользователя переносятся
+// в родительскую папку; с cascade вложенные папки удаляются, а оставшиеся в них списки
+// попадают в корень - сами списки удаляет сервис до удаления папки
+func (r *FolderPostgres) Delete(userId, folderId int, cascade bool) error {
+	return inTx(r.db, func(tx *sqlx.Tx) error {
+		if err := lockFolders(tx, userId); err != nil {
+			return err
+		}
+
+		var parentId *int
+		query := fmt.Sprintf("SELECT parent_id FROM %s WHERE id = $1 AND user_id = $2", foldersTable)
+		if err := tx.Get(&parentId, query, folderId, userId); err != nil {
+			return err
+		}
+
+		if !cascade {
+			foldersQuery := fmt.Sprintf("UPDATE %s SET parent_id = $1, updated_at = $2 WHERE parent_id = $3", foldersTable)
+			if _, err := tx.Exec(foldersQuery, parentId, time.Now(), folderId); err != nil {
+				return err
+			}
+
+			listsQuery := fmt.Sprintf("UPDATE %s SET folder_id = $1 WHERE folder_id = $2", usersListsTable)
+			if _, err := tx.Exec(listsQuery, parentId, folderId); err != nil {
+				return err
+			}
+		}
+
+		deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE id = $1", foldersTable)
+		_, err := tx.Exec(deleteQuery, folderId)
+		return err
+	})
+}
+
+// GetListIds возвращает списки пользователя в папке и во всех вложенных в нее папках
+func (r *FolderPostgres) GetListIds(userId, folderId int) ([]int, error) {
+	if _, err := r.GetById(userId, folderId); err != nil {
+		return nil, err
+	}
+
+	listIds := make([]int, 0)
+	query := fmt.Sprintf(`
+		WITH RECURSIVE subtree (id) AS (
+			SELECT $1::int
+			UNION
+			SELECT f.id FROM %s f INNER JOIN subtree s ON f.parent_id = s.id
+		)
+		SELECT DISTINCT ul.list_id
+		FROM %s ul
+		INNER JOIN subtree s ON s.id = ul.folder_id
+		WHERE ul.user_id = $2
+		ORDER BY ul.list_id`,
+		foldersTable, usersListsTable)
+	err := r.db.Select(&listIds, query, folderId, userId)
+
+	return listIds, err
+}
+
+// GetTree возвращает за один запрос все папки пользователя и его неархивированные списки
+// с числом неархивированных items, по названию
+func (r *FolderPostgres) GetTree(userId int) ([]todo.FolderTreeRow, error) {
+	rows := make([]todo.FolderTreeRow, 0)
+	query := fmt.Sprintf(`
+		SELECT $2 AS kind, f.id, f.parent_id, f.name, 0 AS item_count
+		FROM %s f
+		WHERE f.user_id = $1
+		UNION ALL
+		SELECT $3, tl.id, ul.folder_id, tl.title, coalesce(c.item_count, 0)
+		FROM %s tl
+		INNER JOIN %s ul on ul.list_id = tl.id
+		LEFT JOIN (
+			SELECT li.list_id, COUNT(*) AS item_count
+			FROM %s li
+			INNER JOIN %s ti on ti.id = li.item_id
+			WHERE ti.archived = false
+			GROUP BY li.list_id
+		) c on c.list_id = tl.id
+		WHERE ul.user_id = $1 AND tl.archived = false
+		ORDER BY kind, name, id`,
+		foldersTable, todoListsTable, usersListsTable, listsItemsTable, todoItemsTable)
+	err := r.db.Select(&rows, query, userId, todo.FolderTreeFolder, todo.FolderTreeList)
+
+	return rows, err
+}
+
+// MoveList кладет список в папку пользователя; folderId = nil - в корень. Папка хранится
+// в строке доступа пользователя, поэтому у других участников список остается на месте
+func (r *FolderPostgres) MoveList(userId, listId int, folderId *int) error {
+	return inTx(r.db, func(tx *sqlx.Tx) error {
+		if err := checkListAccess(tx, userId, listId); err != nil {
+			return err
+		}
+		if folderId != nil {
+			if err := lockFolders(tx, userId); err != nil {
+				return err
+			}
+			if err := checkFolderOwner(tx, userId, *folderId); err != nil {
+				return err
+			}
+		}
+
+		query := fmt.Sprintf("UPDATE %s SET folder_id = $1 WHERE user_id = $2 AND list_id = $3", usersListsTable)
+		_, err := tx.Exec(query, folderId, userId, listId)
+		return err
+	})
+}
+
+// lockFolders упорядочивает изменения дерева папок пользователя: иначе два встречных
+// переноса, проверенных параллельно, замкнули бы цикл
+func lockFolders(tx *sqlx.Tx, userId int) error {
+	_, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1), $2)", foldersTable, userId)
+	return err
+}
+
+// checkFolderOwner возвращает todo.ErrFolderNotFound, если у пользователя нет такой папки
+func checkFolderOwner(db sqlx.Queryer, userId, folderId int) error {
+	var id int
+	query := fmt.Sprintf("SELECT id FROM %s WHERE id = $1 AND user_id = $2", foldersTable)
+	err := sqlx.Get(db, &id, query, folderId, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return todo.ErrFolderNotFound
+	}
+
+	return err
+}
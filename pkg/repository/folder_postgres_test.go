@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+)
+
+func createTestFolder(t *testing.T, repo *FolderPostgres, userId int, name string, parentId *int) int {
+	t.Helper()
+
+	id, err := repo.Create(userId, todo.FolderInput{Name: name, ParentId: parentId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func getTestListFolder(t *testing.T, db *sqlx.DB, userId, listId int) *int {
+	t.Helper()
+
+	var folderId *int
+	if err := db.Get(&folderId, "SELECT folder_id FROM users_lists WHERE user_id = $1 AND list_id = $2", userId, listId); err != nil {
+		t.Fatal(err)
+	}
+	return folderId
+}
+
+func TestFolderCycles(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	stranger := createTestUser(t, db, "stranger")
+	repo := NewFolderPostgres(db)
+
+	root := createTestFolder(t, repo, owner, "root", nil)
+	child := createTestFolder(t, repo, owner, "child", &root)
+	grandchild := createTestFolder(t, repo, owner, "grandchild", &child)
+	foreign := createTestFolder(t, repo, stranger, "foreign", nil)
+
+	tests := []struct {
+		name     string
+		parentId int
+		want     error
+	}{
+		{"into itself", root, todo.ErrFolderCycle},
+		{"into a child", child, todo.ErrFolderCycle},
+		{"into a grandchild", grandchild, todo.ErrFolderCycle},
+		{"into a foreign folder", foreign, todo.ErrFolderNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parentId := tt.parentId
+			err := repo.Update(owner, root, todo.UpdateFolderInput{ParentId: todo.NewOptionalInt(&parentId)})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// Вложенную папку можно поднять в корень и перенести под бывшего родителя
+	if err := repo.Update(owner, grandchild, todo.UpdateFolderInput{ParentId: todo.NewOptionalInt(nil)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Update(owner, root, todo.UpdateFolderInput{ParentId: todo.NewOptionalInt(&grandchild)}); err != nil {
+		t.Fatalf("move root under the former grandchild: %s", err.Error())
+	}
+}
+
+func TestDeleteFolder(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	repo := NewFolderPostgres(db)
+
+	parent := createTestFolder(t, repo, owner, "parent", nil)
+	folder := createTestFolder(t, repo, owner, "folder", &parent)
+	child := createTestFolder(t, repo, owner, "child", &folder)
+	listId := createTestList(t, db, owner, "list")
+	childListId := createTestList(t, db, owner, "child list")
+	if err := repo.MoveList(owner, listId, &folder); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.MoveList(owner, childListId, &child); err != nil {
+		t.Fatal(err)
+	}
+
+	listIds, err := repo.GetListIds(owner, parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(listIds, []int{listId, childListId}) {
+		t.Errorf("lists in parent = %v", listIds)
+	}
+
+	// Без cascade содержимое переходит в родительскую папку
+	if err := repo.Delete(owner, folder, false); err != nil {
+		t.Fatal(err)
+	}
+	moved, err := repo.GetById(owner, child)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.ParentId == nil || *moved.ParentId != parent {
+		t.Errorf("child parent = %v, want %d", moved.ParentId, parent)
+	}
+	if folderId := getTestListFolder(t, db, owner, listId); folderId == nil || *folderId != parent {
+		t.Errorf("list folder = %v, want %d", folderId, parent)
+	}
+
+	// С cascade вложенные папки удаляются, а оставшиеся списки попадают в корень
+	if err := repo.Delete(owner, parent, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetById(owner, child); err == nil {
+		t.Error("nested folder survived a cascade delete")
+	}
+	if folderId := getTestListFolder(t, db, owner, childListId); folderId != nil {
+		t.Errorf("list folder after cascade = %d, want root", *folderId)
+	}
+}
+
+func TestFolderTreeSharedList(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	member := createTestUser(t, db, "member")
+	repo := NewFolderPostgres(db)
+
+	listId := createTestList(t, db, owner, "shared")
+	createTestItem(t, db, listId, "first")
+	archived := createTestItem(t, db, listId, "archived")
+	addTestMember(t, db, owner, listId, "member")
+	if err := NewTodoItemPostgres(db).ArchiveItem(owner, archived); err != nil {
+		t.Fatal(err)
+	}
+
+	folder := createTestFolder(t, repo, owner, "work", nil)
+	if err := repo.MoveList(owner, listId, &folder); err != nil {
+		t.Fatal(err)
+	}
+	// В чужую папку список положить нельзя
+	if err := repo.MoveList(member, listId, &folder); !errors.Is(err, todo.ErrFolderNotFound) {
+		t.Errorf("member moves into owner folder: err = %v, want folder not found", err)
+	}
+
+	rows, err := repo.GetTree(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []todo.FolderTreeRow{
+		{Kind: todo.FolderTreeFolder, Id: folder, Name: "work"},
+		{Kind: todo.FolderTreeList, Id: listId, ParentId: &folder, Name: "shared", ItemCount: 1},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("owner tree = %+v", rows)
+	}
+
+	// У участника список остается в корне
+	rows, err = repo.GetTree(member)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].ParentId != nil {
+		t.Errorf("member tree = %+v", rows)
+	}
+}
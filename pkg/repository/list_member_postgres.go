@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+	"github.com/lib/pq"
+)
+
+type ListMemberPostgres struct {
+	db   *sqlx.DB
+	meta todo.RequestMeta
+}
+
+func NewListMemberPostgres(db *sqlx.DB) *ListMemberPostgres {
+	return &ListMemberPostgres{db: db}
+}
+
+// WithRequest возвращает копию репозитория, изменения через которую записываются
+// в журнал аудита от имени автора запроса meta
+func (r *ListMemberPostgres) WithRequest(meta todo.RequestMeta) ListMembers {
+	scoped := *r
+	scoped.meta = meta
+	return &scoped
+}
+
+func (r *ListMemberPostgres) inTx(fn func(tx *sqlx.Tx) error) error {
+	return inAuditedTx(r.db, r.meta, fn)
+}
+
+// GetAll возвращает пользователей с доступом к списку
+func (r *ListMemberPostgres) GetAll(userId, listId int) ([]todo.ListMember, error) {
+	if err := checkListAccess(r.db, userId, listId); err != nil {
+		return nil, err
+	}
+
+	members := make([]todo.ListMember, 0)
+	query := fmt.Sprintf(`
+		SELECT DISTINCT u.id AS user_id, u.name, u.username
+		FROM %s u
+		INNER JOIN %s ul on ul.user_id = u.id
+		WHERE ul.list_id = $1
+		ORDER BY u.username`,
+		usersTable, usersListsTable)
+	err := r.db.Select(&members, query, listId)
+
+	return members, err
+}
+
+// Add открывает доступ к списку пользователю с указанным username.
+// Возвращает false, если доступ у пользователя уже был
+func (r *ListMemberPostgres) Add(userId, listId int, username string) (todo.ListMember, bool, error) {
+	var member todo.ListMember
+	var added bool
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		if err := lockList(tx, userId, listId); err != nil {
+			return err
+		}
+
+		userQuery := fmt.Sprintf("SELECT id AS user_id, name, username FROM %s WHERE username = $1", usersTable)
+		err := tx.Get(&member, userQuery, username)
+		if errors.Is(err, sql.ErrNoRows) {
+			return todo.ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if checkListAccess(tx, member.UserId, listId) == nil {
+			return nil
+		}
+
+		query := fmt.Sprintf("INSERT INTO %s (user_id, list_id) VALUES ($1, $2)", usersListsTable)
+		if _, err := tx.Exec(query, member.UserId, listId); err != nil {
+			return err
+		}
+		added = true
+		return nil
+	})
+
+	return member, added, err
+}
+
+// Remove закрывает пользователю доступ к списку, снимает его с items списка и удаляет
+// его webhooks этого списка. Возвращает items, с которых он снят; они записываются
+// в журнал как item.unassigned. Участник может удалить себя, других участников удаляет только
+// создатель списка. Последнего участника удалить нельзя, иначе список станет недоступен всем
+func (r *ListMemberPostgres) Remove(userId, listId, memberId int) ([]todo.TodoItem, error) {
+	items := make([]todo.TodoItem, 0)
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		if err := lockList(tx, userId, listId); err != nil {
+			return err
+		}
+
+		if memberId != userId {
+			creatorId, err := listCreator(tx, listId)
+			if err != nil {
+				return err
+			}
+			if creatorId != userId {
+				return todo.ErrForbidden
+			}
+		}
+
+		var others int
+		countQuery := fmt.Sprintf("SELECT COUNT(DISTINCT user_id) FROM %s WHERE list_id = $1 AND user_id <> $2", usersListsTable)
+		if err := tx.Get(&others, countQuery, listId, memberId); err != nil {
+			return err
+		}
+		if others == 0 {
+			return todo.ErrLastListMember
+		}
+
+		query := fmt.Sprintf("DELETE FROM %s WHERE list_id = $1 AND user_id = $2", usersListsTable)
+		result, err := tx.Exec(query, listId, memberId)
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+
+		webhooksQuery := fmt.Sprintf("DELETE FROM %s WHERE list_id = $1 AND user_id = $2", webhooksTable)
+		if _, err := tx.Exec(webhooksQuery, listId, memberId); err != nil {
+			return err
+		}
+
+		itemIds, err := removeAssigneesWithoutAccess(tx, listId, 0)
+		if err != nil {
+			return err
+		}
+		if len(itemIds) == 0 {
+			return nil
+		}
+
+		touchQuery := fmt.Sprintf("UPDATE %s SET updated_at = $1, version = version + 1 WHERE id = ANY($2)", todoItemsTable)
+		if _, err := tx.Exec(touchQuery, time.Now(), pq.Array(itemIds)); err != nil {
+			return err
+		}
+
+		itemsQuery := fmt.Sprintf("SELECT %s FROM %s ti WHERE ti.id = ANY($1) ORDER BY ti.id", itemColumns, todoItemsTable)
+		if err := tx.Select(&items, itemsQuery, pq.Array(itemIds)); err != nil {
+			return err
+		}
+
+		unassigned := make([]int, len(items))
+		for i, item := range items {
+			unassigned[i] = item.Id
+		}
+		return recordItemsChange(tx, todo.EventItemUnassigned, unassigned)
+	})
+
+	return items, err
+}
+
+// listCreator возвращает создателя списка - участника с самым ранним доступом к нему.
+// Если создатель покинул список, его место занимает следующий по времени участник
+func listCreator(db sqlx.Queryer, listId int) (int, error) {
+	var userId int
+	query := fmt.Sprintf("SELECT user_id FROM %s WHERE list_id = $1 ORDER BY id LIMIT 1", usersListsTable)
+	err := sqlx.Get(db, &userId, query, listId)
+
+	return userId, err
+}
+
+// lockList блокирует список, доступный пользователю, чтобы параллельные изменения
+// состава участников не оставили список без участников
+func lockList(tx *sqlx.Tx, userId, listId int) error {
+	var id int
+	query := fmt.Sprintf(`
+		SELECT tl.id
+		FROM %s tl
+		INNER JOIN %s ul on ul.list_id = tl.id
+		WHERE tl.id = $1 AND ul.user_id = $2
+		LIMIT 1
+		FOR UPDATE OF tl`,
+		todoListsTable, usersListsTable)
+
+	return tx.Get(&id, query, listId, userId)
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ktuty/todo-app"
+)
+
+func TestRemoveListMember(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+	listId := createTestList(t, db, owner, "list")
+	itemId := createTestItem(t, db, listId, "item")
+	addTestMember(t, db, owner, listId, "alice")
+	addTestMember(t, db, owner, listId, "bob")
+	repo := NewListMemberPostgres(db).WithRequest(todo.RequestMeta{ActorId: owner})
+
+	if _, err := NewTodoItemPostgres(db).Assign(owner, itemId, bob); err != nil {
+		t.Fatal(err)
+	}
+
+	// Обычный участник не может удалить другого
+	if _, err := repo.Remove(alice, listId, bob); !errors.Is(err, todo.ErrForbidden) {
+		t.Errorf("alice removes bob: err = %v, want forbidden", err)
+	}
+
+	// Себя удалить может любой участник
+	if _, err := repo.Remove(alice, listId, alice); err != nil {
+		t.Fatalf("alice leaves: %s", err.Error())
+	}
+
+	// Создатель удаляет участника, и тот перестает быть ответственным за items списка
+	unassigned, err := repo.Remove(owner, listId, bob)
+	if err != nil {
+		t.Fatalf("owner removes bob: %s", err.Error())
+	}
+	if len(unassigned) != 1 || unassigned[0].Id != itemId || len(unassigned[0].AssigneeIds) != 0 {
+		t.Errorf("unassigned items = %+v, want item %d without assignees", unassigned, itemId)
+	}
+	rows := getAuditRows(t, db, todo.EntityItem, itemId)
+	if last := rows[len(rows)-1]; last.Action != todo.EventItemUnassigned || last.ActorId != owner {
+		t.Errorf("last item row: action %s, actor %d", last.Action, last.ActorId)
+	}
+
+	item, err := NewTodoItemPostgres(db).GetById(owner, itemId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(item.AssigneeIds) != 0 {
+		t.Errorf("assignees after removal = %v", item.AssigneeIds)
+	}
+
+	if _, err := repo.Remove(owner, listId, owner); !errors.Is(err, todo.ErrLastListMember) {
+		t.Errorf("last member leaves: err = %v, want last member", err)
+	}
+
+	members, err := repo.GetAll(owner, listId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 1 || members[0].UserId != owner {
+		t.Errorf("members = %+v, want only owner", members)
+	}
+}
+
+func TestRemoveListMemberAfterCreatorLeft(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+	listId := createTestList(t, db, owner, "list")
+	addTestMember(t, db, owner, listId, "alice")
+	addTestMember(t, db, owner, listId, "bob")
+	repo := NewListMemberPostgres(db)
+
+	if _, err := repo.Remove(owner, listId, owner); err != nil {
+		t.Fatal(err)
+	}
+
+	// Права создателя переходят к участнику с самым ранним доступом
+	if _, err := repo.Remove(bob, listId, alice); !errors.Is(err, todo.ErrForbidden) {
+		t.Errorf("bob removes alice: err = %v, want forbidden", err)
+	}
+	if _, err := repo.Remove(alice, listId, bob); err != nil {
+		t.Errorf("alice removes bob: %s", err.Error())
+	}
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+)
+
+type NotificationPostgres struct {
+	db *sqlx.DB
+}
+
+func NewNotificationPostgres(db *sqlx.DB) *NotificationPostgres {
+	return &NotificationPostgres{db: db}
+}
+
+// Create сохраняет уведомления; уведомления типов, отключенных получателем, пропускаются
+func (r *NotificationPostgres) Create(notifications []todo.Notification) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s (user_id, type, actor_id, list_id, item_id, comment_id, created_at)
+		SELECT $1, $2, NULLIF($3, 0), NULLIF($4, 0), NULLIF($5, 0), NULLIF($6, 0), $7
+		WHERE NOT EXISTS (SELECT 1 FROM %s WHERE user_id = $1 AND type = $2 AND enabled = false)`,
+		notificationsTable, notificationPrefsTable)
+
+	return inTx(r.db, func(tx *sqlx.Tx) error {
+		now := time.Now()
+		for _, n := range notifications {
+			if _, err := tx.Exec(query, n.UserId, n.Type, n.ActorId, n.ListId, n.ItemId, n.CommentId, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetAll возвращает уведомления пользователя, новые первыми
+func (r *NotificationPostgres) GetAll(userId int, unreadOnly bool, offset, limit int) ([]todo.Notification, int, error) {
+	where := "user_id = $1"
+	if unreadOnly {
+		where += " AND read_at IS NULL"
+	}
+
+	var total int
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", notificationsTable, where)
+	if err := r.db.Get(&total, countQuery, userId); err != nil {
+		return nil, 0, err
+	}
+
+	notifications := make([]todo.Notification, 0)
+	query := fmt.Sprintf(`
+		SELECT id, user_id, type, coalesce(actor_id, 0) AS actor_id, coalesce(list_id, 0) AS list_id,
+			coalesce(item_id, 0) AS item_id, coalesce(comment_id, 0) AS comment_id, read_at, created_at
+		FROM %s
+		WHERE %s
+		ORDER BY id DESC
+		LIMIT $2 OFFSET $3`, notificationsTable, where)
+	if err := r.db.Select(&notifications, query, userId, limit, offset); err != nil {
+		return nil, 0, err
+	}
+
+	return notifications, total, nil
+}
+
+func (r *NotificationPostgres) CountUnread(userId int) (int, error) {
+	var count int
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE user_id = $1 AND read_at IS NULL", notificationsTable)
+	err := r.db.Get(&count, query, userId)
+
+	return count, err
+}
+
+// MarkRead отмечает уведомление прочитанным; повторная отметка не меняет время прочтения
+func (r *NotificationPostgres) MarkRead(userId int, notificationId int64) error {
+	query := fmt.Sprintf(`
+		UPDATE %s SET read_at = coalesce(read_at, $1)
+		WHERE id = $2 AND user_id = $3`, notificationsTable)
+	result, err := r.db.Exec(query, time.Now(), notificationId, userId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// MarkAllRead отмечает прочитанными все уведомления пользователя и возвращает их число
+func (r *NotificationPostgres) MarkAllRead(userId int) (int64, error) {
+	query := fmt.Sprintf("UPDATE %s SET read_at = $1 WHERE user_id = $2 AND read_at IS NULL", notificationsTable)
+	result, err := r.db.Exec(query, time.Now(), userId)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// GetPreferences возвращает сохраненные настройки по типам уведомлений
+func (r *NotificationPostgres) GetPreferences(userId int) (map[string]bool, error) {
+	var rows []struct {
+		Type    string `db:"type"`
+		Enabled bool   `db:"enabled"`
+	}
+	query := fmt.Sprintf("SELECT type, enabled FROM %s WHERE user_id = $1", notificationPrefsTable)
+	if err := r.db.Select(&rows, query, userId); err != nil {
+		return nil, err
+	}
+
+	preferences := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		preferences[row.Type] = row.Enabled
+	}
+	return preferences, nil
+}
+
+func (r *NotificationPostgres) SetPreferences(userId int, preferences map[string]bool) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s (user_id, type, enabled) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, type) DO UPDATE SET enabled = EXCLUDED.enabled`, notificationPrefsTable)
+
+	return inTx(r.db, func(tx *sqlx.Tx) error {
+		for notificationType, enabled := range preferences {
+			if _, err := tx.Exec(query, userId, notificationType, enabled); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
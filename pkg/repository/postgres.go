@@ -12,6 +12,30 @@ const (
 	usersListsTable = "users_lists"
 	todoItemsTable  = "todo_items"
 	listsItemsTable = "lists_items"
+	eventsTable     = "events"
+
+	webhooksTable          = "webhooks"
+	webhookDeliveriesTable = "webhook_deliveries"
+	syncTombstonesTable    = "sync_tombstones"
+	auditLogTable          = "audit_log"
+	revisionsTable         = "revisions"
+	commentsTable          = "comments"
+	commentEditsTable      = "comment_edits"
+	commentMentionsTable   = "comment_mentions"
+	notificationsTable     = "notifications"
+	notificationPrefsTable = "notification_preferences"
+	remindersTable         = "reminders"
+	digestPrefsTable       = "digest_preferences"
+	itemAssigneesTable     = "item_assignees"
+	attachmentsTable       = "attachments"
+	blobDeletionsTable     = "blob_deletions"
+	checklistItemsTable    = "checklist_items"
+	itemDependenciesTable  = "item_dependencies"
+	listStatusesTable      = "list_statuses"
+	timeEntriesTable       = "time_entries"
+	foldersTable           = "folders"
+	listSectionsTable      = "list_sections"
+	listTemplatesTable     = "list_templates"
 )
 
 type Config struct {
@@ -23,9 +47,13 @@ type Config struct {
 	SSLMode  string
 }
 
+func (cfg Config) connectionString() string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.DBName, cfg.SSLMode)
+}
+
 func NewPostgresDB(cfg Config) (*sqlx.DB, error) {
-	db, err := sqlx.Open("postgres", fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.DBName, cfg.SSLMode))
+	db, err := sqlx.Open("postgres", cfg.connectionString())
 
 	if err != nil {
 		return nil, err
@@ -38,3 +66,18 @@ func NewPostgresDB(cfg Config) (*sqlx.DB, error) {
 
 	return db, nil
 }
+
+// inTx выполняет fn в транзакции: изменение и записи outbox фиксируются вместе
+func inTx(db *sqlx.DB, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
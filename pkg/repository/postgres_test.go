@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+	_ "github.com/lib/pq"
+)
+
+// testDBEnv - строка подключения к Postgres для тестов SQL. Без нее тесты пропускаются.
+// Каждый тест получает собственную схему с примененными миграциями из schema/
+const testDBEnv = "TEST_DB_DSN"
+
+// newTestDB создает схему с миграциями и возвращает подключение, работающее только в ней.
+// Схема удаляется после теста
+func newTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	dsn := os.Getenv(testDBEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDBEnv)
+	}
+
+	admin, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("drop schema %s: %s", schema, err.Error())
+		}
+	})
+
+	// lib/pq передает неизвестные параметры строки подключения как параметры сессии
+	if strings.Contains(dsn, "://") {
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		dsn += separator + "search_path=" + schema
+	} else {
+		dsn += " search_path=" + schema
+	}
+
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrations, err := filepath.Glob(filepath.Join("..", "..", "schema", "*.up.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(migrations)
+	for _, migration := range migrations {
+		query, err := os.ReadFile(migration)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(query)); err != nil {
+			t.Fatalf("%s: %s", filepath.Base(migration), err.Error())
+		}
+	}
+
+	return db
+}
+
+func createTestUser(t *testing.T, db *sqlx.DB, username string) int {
+	t.Helper()
+
+	id, err := NewAuthPostgres(db).CreateUser(todo.User{Name: username, Username: username, Password: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func createTestList(t *testing.T, db *sqlx.DB, userId int, title string) int {
+	t.Helper()
+
+	id, err := NewTodoListPostgres(db).Create(userId, todo.TodoList{Title: title})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func createTestItem(t *testing.T, db *sqlx.DB, listId int, title string) int {
+	t.Helper()
+
+	id, err := NewTodoItemPostgres(db).Create(listId, todo.TodoItem{Title: title})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func addTestMember(t *testing.T, db *sqlx.DB, userId, listId int, username string) {
+	t.Helper()
+
+	if _, _, err := NewListMemberPostgres(db).Add(userId, listId, username); err != nil {
+		t.Fatal(err)
+	}
+}
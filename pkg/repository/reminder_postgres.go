@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+)
+
+type ReminderPostgres struct {
+	db *sqlx.DB
+}
+
+func NewReminderPostgres(db *sqlx.DB) *ReminderPostgres {
+	return &ReminderPostgres{db: db}
+}
+
+// reminderFireAt - время срабатывания напоминания r для item ti и пользователя u.
+// Время суток at_time отсчитывается в часовом поясе пользователя
+const reminderFireAt = `CASE
+		WHEN r.offset_minutes IS NOT NULL THEN ti.due_at - make_interval(mins => r.offset_minutes)
+		ELSE (((ti.due_at AT TIME ZONE u.time_zone)::date - r.days_before) + r.at_time) AT TIME ZONE u.time_zone
+	END`
+
+func (r *ReminderPostgres) Create(userId, itemId int, input todo.ReminderInput) (int, error) {
+	if _, err := itemListId(r.db, userId, itemId); err != nil {
+		return 0, err
+	}
+
+	var id int
+	query := fmt.Sprintf(`
+		INSERT INTO %s (user_id, item_id, offset_minutes, days_before, at_time, created_at)
+		VALUES ($1, $2, $3, $4, $5::time, $6)
+		RETURNING id`, remindersTable)
+	err := r.db.Get(&id, query, userId, itemId, input.OffsetMinutes, input.DaysBefore, input.AtTime, time.Now())
+
+	return id, err
+}
+
+// GetAll возвращает напоминания пользователя для item с ближайшим временем срабатывания
+func (r *ReminderPostgres) GetAll(userId, itemId int) ([]todo.Reminder, error) {
+	if _, err := itemListId(r.db, userId, itemId); err != nil {
+		return nil, err
+	}
+
+	return r.get("r.user_id = $1 AND r.item_id = $2", userId, itemId)
+}
+
+func (r *ReminderPostgres) GetById(userId, reminderId int) (todo.Reminder, error) {
+	reminders, err := r.get("r.user_id = $1 AND r.id = $2", userId, reminderId)
+	if err != nil {
+		return todo.Reminder{}, err
+	}
+	if len(reminders) == 0 {
+		return todo.Reminder{}, sql.ErrNoRows
+	}
+	return reminders[0], nil
+}
+
+func (r *ReminderPostgres) get(where string, args ...interface{}) ([]todo.Reminder, error) {
+	reminders := make([]todo.Reminder, 0)
+	query := fmt.Sprintf(`
+		SELECT r.id, r.item_id, r.offset_minutes, r.days_before, to_char(r.at_time, 'HH24:MI') AS at_time,
+			%s AS fire_at, r.sent_at, r.created_at
+		FROM %s r
+		INNER JOIN %s ti on ti.id = r.item_id
+		INNER JOIN %s u on u.id = r.user_id
+		WHERE %s
+		ORDER BY r.id`,
+		reminderFireAt, remindersTable, todoItemsTable, usersTable, where)
+	err := r.db.Select(&reminders, query, args...)
+
+	return reminders, err
+}
+
+func (r *ReminderPostgres) Delete(userId, reminderId int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE user_id = $1 AND id = $2", remindersTable)
+	result, err := r.db.Exec(query, userId, reminderId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// ClaimDue выбирает сработавшие напоминания и захватывает их на время lease.
+// Напоминание выбирается, если его время срабатывания наступило не раньше чем maxLateness назад
+// и оно для этого времени еще не отправлено. Напоминания пользователей без email тоже
+// выбираются: для них создается только уведомление. SKIP LOCKED и lease не дают
+// нескольким репликам отправить одно напоминание одновременно
+func (r *ReminderPostgres) ClaimDue(limit int, lease, maxLateness time.Duration) ([]todo.ReminderDelivery, error) {
+	deliveries := make([]todo.ReminderDelivery, 0)
+	query := fmt.Sprintf(`
+		WITH due AS (
+			SELECT r.id, f.fire_at, u.id AS user_id, u.name, coalesce(u.email, '') AS email, u.time_zone,
+				ti.id AS item_id, ti.title AS item_title, ti.due_at, tl.id AS list_id, tl.title AS list_title
+			FROM %s r
+			INNER JOIN %s ti on ti.id = r.item_id
+			INNER JOIN %s u on u.id = r.user_id
+			INNER JOIN %s li on li.item_id = ti.id
+			INNER JOIN %s tl on tl.id = li.list_id
+			CROSS JOIN LATERAL (SELECT %s AS fire_at) f
+			WHERE ti.due_at IS NOT NULL AND ti.done = false AND ti.archived = false
+				AND f.fire_at <= $2 AND f.fire_at > $3
+				AND r.sent_for IS DISTINCT FROM f.fire_at
+				AND (r.claimed_until IS NULL OR r.claimed_until < $2)
+				AND EXISTS (SELECT 1 FROM %s ul WHERE ul.list_id = li.list_id AND ul.user_id = r.user_id)
+			ORDER BY f.fire_at
+			LIMIT $1
+			FOR UPDATE OF r SKIP LOCKED
+		)
+		UPDATE %s r SET claimed_until = $4, attempts = r.attempts + 1
+		FROM due
+		WHERE r.id = due.id
+		RETURNING due.id AS reminder_id, due.fire_at, due.user_id, due.name, due.email, due.time_zone,
+			due.item_id, due.item_title, due.list_id, due.list_title, due.due_at`,
+		remindersTable, todoItemsTable, usersTable, listsItemsTable, todoListsTable,
+		reminderFireAt, usersListsTable, remindersTable)
+	now := time.Now()
+	err := r.db.Select(&deliveries, query, limit, now, now.Add(-maxLateness), now.Add(lease))
+
+	return deliveries, err
+}
+
+// MarkSent фиксирует отправку письма для времени срабатывания fireAt
+func (r *ReminderPostgres) MarkSent(reminderId int, fireAt time.Time) error {
+	query := fmt.Sprintf(`
+		UPDATE %s SET sent_for = $1, sent_at = $2, claimed_until = NULL, attempts = 0, last_error = NULL
+		WHERE id = $3`, remindersTable)
+	_, err := r.db.Exec(query, fireAt, time.Now(), reminderId)
+
+	return err
+}
+
+// MarkFailed сохраняет ошибку отправки; повторная попытка будет после retryAt
+func (r *ReminderPostgres) MarkFailed(reminderId int, reason string, retryAt time.Time) error {
+	query := fmt.Sprintf("UPDATE %s SET last_error = $1, claimed_until = $2 WHERE id = $3", remindersTable)
+	_, err := r.db.Exec(query, reason, retryAt, reminderId)
+
+	return err
+}
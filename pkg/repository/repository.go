@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/jmoiron/sqlx"
 	"github.com/ktuty/todo-app"
 )
@@ -11,33 +13,218 @@ type Authorization interface {
 }
 
 type TodoList interface {
+	WithRequest(meta todo.RequestMeta) TodoList
 	Create(userId int, list todo.TodoList) (int, error)
 	GetAll(userId int) ([]todo.TodoList, error)
 	GetById(userId, listId int) (todo.TodoList, error)
 	Delete(userId, listId int) error
 	Update(userId, listId int, input todo.UpdateListInput) error
+	UpdateIfVersion(userId, listId, version int, input todo.UpdateListInput) error
+	DeleteIfVersion(userId, listId, version int) error
 	// V2 методы
 	GetAllWithPagination(userId, offset, limit int, archived string) ([]todo.TodoList, int, error)
 	GetItemCount(userId, listId int) (int, error)
 	ArchiveList(userId, listId int) error
+	// Workflow
+	GetWorkflow(userId, listId int) ([]todo.ListStatus, error)
+	SetWorkflow(userId, listId int, statuses []todo.StatusInput) ([]todo.ListStatus, error)
+	// Разделы
+	GetSections(userId, listId int) ([]todo.ListSection, error)
+	CreateSection(userId, listId int, input todo.SectionInput) (todo.ListSection, error)
+	RenameSection(userId, listId, sectionId int, name string) (todo.ListSection, error)
+	ReorderSections(userId, listId int, ids []int) ([]todo.ListSection, error)
+	DeleteSection(userId, listId, sectionId int) error
+	// Копирование
+	Duplicate(userId, listId int, input todo.DuplicateListInput) (int, error)
+	// Слияние
+	Merge(userId, listId, sourceId int, deleteSource bool) ([]int, error)
 }
 
 type TodoItem interface {
+	WithRequest(meta todo.RequestMeta) TodoItem
 	Create(listId int, item todo.TodoItem) (int, error)
 	GetAll(userId, listId int) ([]todo.TodoItem, error)
 	GetById(userId, itemId int) (todo.TodoItem, error)
 	Delete(userId, itemId int) error
 	Update(userId, itemId int, input todo.UpdateItemInput) error
+	UpdateIfVersion(userId, itemId, version int, input todo.UpdateItemInput) error
+	DeleteIfVersion(userId, itemId, version int) error
 	// V2 методы
-	GetAllWithPagination(userId, listId, offset, limit int, completed string) ([]todo.TodoItem, int, error)
+	GetAllWithPagination(userId int, filter todo.ItemFilter, offset, limit int) ([]todo.TodoItem, int, error)
 	ArchiveItem(userId, itemId int) error
-	CompleteItem(userId, itemId int) error
+	CompleteItem(userId, itemId int, force bool) error
+	Bulk(userId int, ops []todo.BulkItemOperation, atomic bool) ([]todo.BulkItemResult, error)
+	GetListId(userId, itemId int) (int, error)
+	// Ответственные
+	Assign(userId, itemId, assigneeId int) (bool, error)
+	Unassign(userId, itemId, assigneeId int) error
+	// Чек-лист
+	GetChecklist(userId, itemId int) ([]todo.ChecklistItem, error)
+	AddChecklistItem(userId, itemId int, input todo.ChecklistItemInput) (todo.ChecklistItem, error)
+	UpdateChecklistItem(userId, itemId, entryId int, input todo.UpdateChecklistItemInput) (todo.ChecklistItem, error)
+	DeleteChecklistItem(userId, itemId, entryId int) error
+	ReorderChecklist(userId, itemId int, ids []int) ([]todo.ChecklistItem, error)
+	// Зависимости
+	AddDependency(userId, itemId, blockedById int) (bool, error)
+	RemoveDependency(userId, itemId, blockedById int) error
+	GetDependencyGraph(userId, listId int) ([]todo.DependencyNode, error)
+	// Разделы
+	MoveItems(userId, listId int, sectionId *int, ids []int) ([]int, error)
+}
+
+type Events interface {
+	Create(event todo.Event) (int64, error)
+	GetById(eventId int64) (todo.Event, error)
+	GetSince(userId int, afterId int64, limit int) ([]todo.Event, error)
+	GetListAudience(listId int) ([]int, error)
+	DeleteBefore(before time.Time, limit int) (int64, error)
+}
+
+type Webhooks interface {
+	Create(userId int, webhook todo.Webhook) (int, error)
+	GetAll(userId int) ([]todo.Webhook, error)
+	GetById(userId, webhookId int) (todo.Webhook, error)
+	Delete(userId, webhookId int) error
+	GetDeliveries(userId, webhookId int, status string, limit int) ([]todo.WebhookDelivery, error)
+	GetDelivery(userId int, deliveryId int64) (todo.WebhookDelivery, error)
+	Redeliver(userId int, deliveryId int64) (int64, error)
+	// Методы воркера доставки
+	ClaimDue(limit int, lease time.Duration) ([]todo.WebhookDelivery, error)
+	MarkDelivered(deliveryId int64, statusCode int) error
+	MarkFailed(deliveryId int64, statusCode int, reason string, nextAttemptAt time.Time, dead bool) error
+}
+
+type Sync interface {
+	GetChanges(userId int, since, until string, afterSeq int64, limit int) ([]todo.SyncChange, string, error)
+}
+
+type Audit interface {
+	GetByList(userId, listId int, filter todo.AuditFilter) ([]todo.AuditEntry, int, error)
+	GetFeed(userId int, filter todo.AuditFilter) ([]todo.AuditEntry, int, error)
+}
+
+type Revisions interface {
+	GetAll(entity string, entityId, offset, limit int) ([]todo.Revision, int, error)
+	GetByVersion(entity string, entityId, version int) (todo.Revision, error)
+}
+
+type Comments interface {
+	Create(userId, itemId int, input todo.CommentInput, mentions []string) (int, []int, error)
+	GetAll(userId, itemId, offset, limit int) ([]todo.Comment, int, error)
+	GetById(userId, commentId int) (todo.Comment, error)
+	Update(userId, commentId int, body string, mentions []string) ([]int, error)
+	Delete(userId, commentId int) error
+	GetEdits(userId, commentId int) ([]todo.CommentEdit, error)
+}
+
+type Notifications interface {
+	Create(notifications []todo.Notification) error
+	GetAll(userId int, unreadOnly bool, offset, limit int) ([]todo.Notification, int, error)
+	CountUnread(userId int) (int, error)
+	MarkRead(userId int, notificationId int64) error
+	MarkAllRead(userId int) (int64, error)
+	GetPreferences(userId int) (map[string]bool, error)
+	SetPreferences(userId int, preferences map[string]bool) error
+}
+
+type ListMembers interface {
+	WithRequest(meta todo.RequestMeta) ListMembers
+	GetAll(userId, listId int) ([]todo.ListMember, error)
+	Add(userId, listId int, username string) (todo.ListMember, bool, error)
+	Remove(userId, listId, memberId int) ([]todo.TodoItem, error)
+}
+
+type Settings interface {
+	Get(userId int) (todo.UserSettings, error)
+	Update(userId int, input todo.UpdateSettingsInput) error
+}
+
+type Reminders interface {
+	Create(userId, itemId int, input todo.ReminderInput) (int, error)
+	GetAll(userId, itemId int) ([]todo.Reminder, error)
+	GetById(userId, reminderId int) (todo.Reminder, error)
+	Delete(userId, reminderId int) error
+	// Методы планировщика
+	ClaimDue(limit int, lease, maxLateness time.Duration) ([]todo.ReminderDelivery, error)
+	MarkSent(reminderId int, fireAt time.Time) error
+	MarkFailed(reminderId int, reason string, retryAt time.Time) error
+}
+
+type Digests interface {
+	GetPreferences(userId int) (todo.DigestPreferences, error)
+	UpdatePreferences(userId int, input todo.UpdateDigestPreferencesInput) error
+	GetDue(userId int, from, to time.Time, limit int) ([]todo.DigestItem, error)
+	GetCompleted(userId int, from, to time.Time, limit int) ([]todo.DigestItem, error)
+	// Методы планировщика
+	ClaimDue(limit int, lease, maxLateness time.Duration) ([]todo.DigestDelivery, error)
+	MarkSent(userId int, date time.Time) error
+	MarkFailed(userId int, reason string, retryAt time.Time) error
+}
+
+type Folders interface {
+	Create(userId int, input todo.FolderInput) (int, error)
+	GetById(userId, folderId int) (todo.Folder, error)
+	Update(userId, folderId int, input todo.UpdateFolderInput) error
+	Delete(userId, folderId int, cascade bool) error
+	GetListIds(userId, folderId int) ([]int, error)
+	GetTree(userId int) ([]todo.FolderTreeRow, error)
+	MoveList(userId, listId int, folderId *int) error
+}
+
+type Templates interface {
+	WithRequest(meta todo.RequestMeta) Templates
+	Create(userId int, input todo.TemplateInput) (int, error)
+	GetAll(userId int) ([]todo.ListTemplate, error)
+	GetById(userId, templateId int) (todo.ListTemplate, error)
+	Update(userId, templateId int, input todo.UpdateTemplateInput) error
+	Delete(userId, templateId int) error
+	Instantiate(userId int, content todo.TemplateContent, anchor time.Time) (int, []int, error)
+}
+
+type TimeEntries interface {
+	WithRequest(meta todo.RequestMeta) TimeEntries
+	StartTimer(userId, itemId int, note string) (int, error)
+	StopTimer(userId int) (int, error)
+	GetRunning(userId int) (todo.TimeEntry, error)
+	Create(userId, itemId int, input todo.TimeEntryInput) (int, error)
+	GetAll(userId, itemId int) ([]todo.TimeEntry, error)
+	GetById(userId, entryId int) (todo.TimeEntry, error)
+	Delete(userId, entryId int) error
+	GetReport(userId int, filter todo.TimeReportFilter) ([]todo.TimeReportRow, error)
+}
+
+type Attachments interface {
+	Reserve(storageKey string, until time.Time) error
+	Create(userId, itemId int, attachment todo.Attachment) (int, error)
+	GetAll(userId, itemId int) ([]todo.Attachment, error)
+	GetById(userId, attachmentId int) (todo.Attachment, error)
+	GetByIdUnchecked(attachmentId int) (todo.Attachment, error)
+	Delete(userId, attachmentId int) error
+	// Методы очистки хранилища
+	ClaimDeletions(limit int, lease time.Duration) ([]todo.BlobDeletion, error)
+	MarkDeleted(deletionId int64) error
+	MarkDeletionFailed(deletionId int64, reason string, retryAt time.Time) error
 }
 
 type Repository struct {
 	Authorization
 	TodoList
 	TodoItem
+	Events
+	Webhooks
+	Sync
+	Audit
+	Revisions
+	Comments
+	Notifications
+	ListMembers
+	Settings
+	Reminders
+	Digests
+	Attachments
+	TimeEntries
+	Folders
+	Templates
 }
 
 func NewRepository(db *sqlx.DB) *Repository {
@@ -45,5 +232,20 @@ func NewRepository(db *sqlx.DB) *Repository {
 		Authorization: NewAuthPostgres(db),
 		TodoList:      NewTodoListPostgres(db),
 		TodoItem:      NewTodoItemPostgres(db),
+		Events:        NewEventPostgres(db),
+		Webhooks:      NewWebhookPostgres(db),
+		Sync:          NewSyncPostgres(db),
+		Audit:         NewAuditPostgres(db),
+		Revisions:     NewRevisionPostgres(db),
+		Comments:      NewCommentPostgres(db),
+		Notifications: NewNotificationPostgres(db),
+		ListMembers:   NewListMemberPostgres(db),
+		Settings:      NewSettingsPostgres(db),
+		Reminders:     NewReminderPostgres(db),
+		Digests:       NewDigestPostgres(db),
+		Attachments:   NewAttachmentPostgres(db),
+		TimeEntries:   NewTimeEntryPostgres(db),
+		Folders:       NewFolderPostgres(db),
+		Templates:     NewTemplatePostgres(db),
 	}
 }
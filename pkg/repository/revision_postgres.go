@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+)
+
+type RevisionPostgres struct {
+	db *sqlx.DB
+}
+
+func NewRevisionPostgres(db *sqlx.DB) *RevisionPostgres {
+	return &RevisionPostgres{db: db}
+}
+
+// GetAll возвращает ревизии ресурса, новые первыми
+func (r *RevisionPostgres) GetAll(entity string, entityId, offset, limit int) ([]todo.Revision, int, error) {
+	var total int
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE entity = $1 AND entity_id = $2", revisionsTable)
+	if err := r.db.Get(&total, countQuery, entity, entityId); err != nil {
+		return nil, 0, err
+	}
+
+	revisions := make([]todo.Revision, 0)
+	query := fmt.Sprintf(`
+		SELECT entity, entity_id, version, snapshot, created_at 
+		FROM %s 
+		WHERE entity = $1 AND entity_id = $2 
+		ORDER BY version DESC 
+		LIMIT $3 OFFSET $4`, revisionsTable)
+	if err := r.db.Select(&revisions, query, entity, entityId, limit, offset); err != nil {
+		return nil, 0, err
+	}
+
+	return revisions, total, nil
+}
+
+func (r *RevisionPostgres) GetByVersion(entity string, entityId, version int) (todo.Revision, error) {
+	var revision todo.Revision
+	query := fmt.Sprintf(`
+		SELECT entity, entity_id, version, snapshot, created_at 
+		FROM %s 
+		WHERE entity = $1 AND entity_id = $2 AND version = $3`, revisionsTable)
+	err := r.db.Get(&revision, query, entity, entityId, version)
+
+	return revision, err
+}
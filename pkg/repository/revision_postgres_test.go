@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+)
+
+func getTestRevisions(t *testing.T, db *sqlx.DB, entity string, entityId int) []todo.Revision {
+	t.Helper()
+
+	revisions, total, err := NewRevisionPostgres(db).GetAll(entity, entityId, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != len(revisions) {
+		t.Fatalf("total = %d, got %d revisions", total, len(revisions))
+	}
+	return revisions
+}
+
+func decodeSnapshot(t *testing.T, revision todo.Revision) map[string]interface{} {
+	t.Helper()
+
+	var snapshot map[string]interface{}
+	if err := json.Unmarshal(revision.Snapshot, &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	return snapshot
+}
+
+func TestItemRevisions(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	listId := createTestList(t, db, owner, "list")
+	itemId := createTestItem(t, db, listId, "item")
+	repo := NewTodoItemPostgres(db)
+
+	title := "renamed"
+	if err := repo.Update(owner, itemId, todo.UpdateItemInput{Title: &title}); err != nil {
+		t.Fatal(err)
+	}
+	// Изменение связанных данных тоже увеличивает версию и пишет ревизию
+	if _, err := repo.AddChecklistItem(owner, itemId, todo.ChecklistItemInput{Text: "step"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Откаченное изменение ревизии не оставляет
+	done := true
+	ops := []todo.BulkItemOperation{
+		{Op: todo.BulkOpUpdate, ItemId: itemId, Update: &todo.UpdateItemInput{Done: &done}},
+		{Op: todo.BulkOpArchive, ItemId: itemId, Version: 100},
+	}
+	if _, err := repo.Bulk(owner, ops, true); err != nil {
+		t.Fatal(err)
+	}
+
+	revisions := getTestRevisions(t, db, todo.EntityItem, itemId)
+	if len(revisions) != 3 {
+		t.Fatalf("got %d revisions, want 3", len(revisions))
+	}
+	for i, revision := range revisions {
+		if want := 3 - i; revision.Version != want {
+			t.Errorf("revision %d has version %d, want %d", i, revision.Version, want)
+		}
+	}
+
+	latest := decodeSnapshot(t, revisions[0])
+	if latest["title"] != "renamed" || latest["done"] != false {
+		t.Errorf("latest snapshot = %s", revisions[0].Snapshot)
+	}
+	if _, ok := latest["change_seq"]; ok {
+		t.Error("snapshot contains change_seq")
+	}
+	if _, ok := latest["change_xid"]; ok {
+		t.Error("snapshot contains change_xid")
+	}
+
+	first, err := NewRevisionPostgres(db).GetByVersion(todo.EntityItem, itemId, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot := decodeSnapshot(t, first); snapshot["title"] != "item" {
+		t.Errorf("first snapshot = %s", first.Snapshot)
+	}
+
+	// История переживает удаление item
+	if err := repo.Delete(owner, itemId); err != nil {
+		t.Fatal(err)
+	}
+	if revisions := getTestRevisions(t, db, todo.EntityItem, itemId); len(revisions) != 3 {
+		t.Errorf("got %d revisions after delete, want 3", len(revisions))
+	}
+}
+
+func TestListRevisions(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	listId := createTestList(t, db, owner, "list")
+	repo := NewTodoListPostgres(db)
+
+	title := "renamed"
+	if err := repo.UpdateIfVersion(owner, listId, 1, todo.UpdateListInput{Title: &title}); err != nil {
+		t.Fatal(err)
+	}
+	// Несовпадение версии ничего не меняет
+	stale := "stale"
+	if err := repo.UpdateIfVersion(owner, listId, 1, todo.UpdateListInput{Title: &stale}); !errors.Is(err, todo.ErrVersionMismatch) {
+		t.Fatalf("err = %v, want version mismatch", err)
+	}
+
+	revisions := getTestRevisions(t, db, todo.EntityList, listId)
+	if len(revisions) != 2 || revisions[0].Version != 2 {
+		t.Fatalf("got %d revisions, want versions 2 and 1", len(revisions))
+	}
+	if snapshot := decodeSnapshot(t, revisions[0]); snapshot["title"] != "renamed" {
+		t.Errorf("latest snapshot = %s", revisions[0].Snapshot)
+	}
+}
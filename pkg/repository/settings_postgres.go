@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+)
+
+type SettingsPostgres struct {
+	db *sqlx.DB
+}
+
+func NewSettingsPostgres(db *sqlx.DB) *SettingsPostgres {
+	return &SettingsPostgres{db: db}
+}
+
+func (r *SettingsPostgres) Get(userId int) (todo.UserSettings, error) {
+	var settings todo.UserSettings
+	query := fmt.Sprintf("SELECT coalesce(email, '') AS email, time_zone FROM %s WHERE id = $1", usersTable)
+	err := r.db.Get(&settings, query, userId)
+
+	return settings, err
+}
+
+func (r *SettingsPostgres) Update(userId int, input todo.UpdateSettingsInput) error {
+	setValues := make([]string, 0)
+	args := make([]interface{}, 0)
+	argId := 1
+
+	if input.Email != nil {
+		setValues = append(setValues, fmt.Sprintf("email=NULLIF($%d, '')", argId))
+		args = append(args, *input.Email)
+		argId++
+	}
+
+	if input.TimeZone != nil {
+		setValues = append(setValues, fmt.Sprintf("time_zone=$%d", argId))
+		args = append(args, *input.TimeZone)
+		argId++
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE id = $%d", usersTable, strings.Join(setValues, ", "), argId)
+	args = append(args, userId)
+
+	_, err := r.db.Exec(query, args...)
+	return err
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+)
+
+type SyncPostgres struct {
+	db *sqlx.DB
+}
+
+func NewSyncPostgres(db *sqlx.DB) *SyncPostgres {
+	return &SyncPostgres{db: db}
+}
+
+// GetChanges возвращает изменения ресурсов, доступных пользователю, упорядоченные по seq.
+// since - снимок (pg_snapshot) предыдущей синхронизации: возвращаются строки, изменения которых
+// в нем не были видны; пустой since означает полную выгрузку без tombstones.
+// until - снимок, которым завершится текущая синхронизация; если пуст, берется текущий.
+// afterSeq - курсор постраничной выдачи внутри одной синхронизации
+func (r *SyncPostgres) GetChanges(userId int, since, until string, afterSeq int64, limit int) ([]todo.SyncChange, string, error) {
+	// Снимок и выборка должны относиться к одному моменту
+	tx, err := r.db.BeginTxx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback()
+
+	if until == "" {
+		if err := tx.Get(&until, "SELECT pg_current_snapshot()::text"); err != nil {
+			return nil, "", err
+		}
+	}
+
+	query := fmt.Sprintf(`
+		SELECT kind, id, list_id, seq, deleted, data FROM (
+			SELECT 'list' AS kind, tl.id, tl.id AS list_id, tl.change_seq AS seq, false AS deleted, 
+				to_jsonb(tl) - 'change_seq' - 'change_xid' AS data 
+			FROM %[1]s tl 
+			INNER JOIN %[2]s ul on ul.list_id = tl.id 
+			WHERE ul.user_id = $1 AND tl.change_seq > $2 
+				AND ($3 = '' OR NOT pg_visible_in_snapshot(tl.change_xid, NULLIF($3, '')::pg_snapshot))
+			UNION ALL
+			SELECT 'item', ti.id, li.list_id, ti.change_seq, false, 
+				to_jsonb(ti) - 'change_seq' - 'change_xid' 
+			FROM %[3]s ti 
+			INNER JOIN %[4]s li on li.item_id = ti.id 
+			INNER JOIN %[2]s ul on ul.list_id = li.list_id 
+			WHERE ul.user_id = $1 AND ti.change_seq > $2 
+				AND ($3 = '' OR NOT pg_visible_in_snapshot(ti.change_xid, NULLIF($3, '')::pg_snapshot))
+			UNION ALL
+			SELECT st.kind, st.resource_id, coalesce(st.list_id, 0), st.change_seq, true, NULL 
+			FROM %[5]s st 
+			WHERE $3 <> '' AND st.audience @> ARRAY[$1]::int[] AND st.change_seq > $2 
+				AND NOT pg_visible_in_snapshot(st.change_xid, NULLIF($3, '')::pg_snapshot)
+		) changes 
+		ORDER BY seq 
+		LIMIT $4`,
+		todoListsTable, usersListsTable, todoItemsTable, listsItemsTable, syncTombstonesTable)
+
+	changes := make([]todo.SyncChange, 0)
+	if err := tx.Select(&changes, query, userId, afterSeq, since, limit); err != nil {
+		return nil, "", err
+	}
+
+	return changes, until, nil
+}
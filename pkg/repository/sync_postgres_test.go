@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ktuty/todo-app"
+)
+
+// syncKeys возвращает изменения в виде "kind:id", удаления - "kind:id:deleted"
+func syncKeys(changes []todo.SyncChange) []string {
+	keys := make([]string, len(changes))
+	for i, change := range changes {
+		keys[i] = fmt.Sprintf("%s:%d", change.Kind, change.Id)
+		if change.Deleted {
+			keys[i] += ":deleted"
+		}
+	}
+	return keys
+}
+
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSyncChanges(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewSyncPostgres(db)
+	userId := createTestUser(t, db, "user")
+	otherId := createTestUser(t, db, "other")
+
+	listId := createTestList(t, db, userId, "list")
+	first := createTestItem(t, db, listId, "first")
+	second := createTestItem(t, db, listId, "second")
+	createTestItem(t, db, createTestList(t, db, otherId, "other"), "hidden")
+
+	// Полная выгрузка: все доступные ресурсы
+	changes, initial, err := repo.GetChanges(userId, "", "", 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{fmt.Sprintf("item:%d", first), fmt.Sprintf("item:%d", second), fmt.Sprintf("list:%d", listId)}
+	keys := syncKeys(changes)
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, want) {
+		t.Fatalf("full sync = %v, want %v", keys, want)
+	}
+
+	// Транзакция с меньшим seq фиксируется после синхронизации, которая уже видела больший seq
+	late, err := db.Beginx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer late.Rollback()
+	if _, err := late.Exec("UPDATE todo_items SET title = 'late' WHERE id = $1", first); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE todo_items SET title = 'early' WHERE id = $1", second); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, next, err := repo.GetChanges(userId, initial, "", 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := syncKeys(changes); !reflect.DeepEqual(keys, []string{fmt.Sprintf("item:%d", second)}) {
+		t.Fatalf("sync with uncommitted change = %v", keys)
+	}
+
+	if err := late.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	changes, next, err = repo.GetChanges(userId, next, "", 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := syncKeys(changes); !reflect.DeepEqual(keys, []string{fmt.Sprintf("item:%d", first)}) {
+		t.Fatalf("sync after late commit = %v", keys)
+	}
+
+	// Удаление возвращается tombstone, но только в инкрементальной синхронизации
+	if err := NewTodoItemPostgres(db).Delete(userId, second); err != nil {
+		t.Fatal(err)
+	}
+	changes, _, err = repo.GetChanges(userId, next, "", 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := syncKeys(changes); !containsKey(keys, fmt.Sprintf("item:%d:deleted", second)) {
+		t.Errorf("sync after delete = %v, want tombstone of item %d", keys, second)
+	}
+
+	changes, _, err = repo.GetChanges(userId, "", "", 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := syncKeys(changes); containsKey(keys, fmt.Sprintf("item:%d", second)) ||
+		containsKey(keys, fmt.Sprintf("item:%d:deleted", second)) {
+		t.Errorf("full sync after delete = %v, want no item %d", keys, second)
+	}
+}
+
+func TestSyncChangesPages(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewSyncPostgres(db)
+	userId := createTestUser(t, db, "user")
+	listId := createTestList(t, db, userId, "list")
+	for i := 0; i < 5; i++ {
+		createTestItem(t, db, listId, fmt.Sprintf("item %d", i))
+	}
+
+	var all []string
+	var until string
+	var afterSeq int64
+	for {
+		changes, snapshot, err := repo.GetChanges(userId, "", until, afterSeq, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if until != "" && snapshot != until {
+			t.Fatalf("snapshot changed between pages: %s -> %s", until, snapshot)
+		}
+		until = snapshot
+		all = append(all, syncKeys(changes)...)
+		if len(changes) < 2 {
+			break
+		}
+		afterSeq = changes[len(changes)-1].Seq
+	}
+
+	if len(all) != 6 {
+		t.Errorf("pages returned %v, want 5 items and the list", all)
+	}
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+)
+
+var templateColumns = "t.id, t.name, t.description, t.content, t.created_at, t.updated_at"
+
+type TemplatePostgres struct {
+	db   *sqlx.DB
+	meta todo.RequestMeta
+}
+
+func NewTemplatePostgres(db *sqlx.DB) *TemplatePostgres {
+	return &TemplatePostgres{db: db}
+}
+
+// WithRequest возвращает копию репозитория, изменения через которую записываются
+// в журнал аудита от имени автора запроса meta
+func (r *TemplatePostgres) WithRequest(meta todo.RequestMeta) Templates {
+	scoped := *r
+	scoped.meta = meta
+	return &scoped
+}
+
+func (r *TemplatePostgres) inTx(fn func(tx *sqlx.Tx) error) error {
+	return inAuditedTx(r.db, r.meta, fn)
+}
+
+func (r *TemplatePostgres) Create(userId int, input todo.TemplateInput) (int, error) {
+	content, err := json.Marshal(input.Content)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	query := fmt.Sprintf(`
+		INSERT INTO %s (user_id, name, description, content, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $5)
+		RETURNING id`, listTemplatesTable)
+	err = r.db.Get(&id, query, userId, input.Name, input.Description, content, time.Now())
+
+	return id, err
+}
+
+// GetAll возвращает шаблоны пользователя по названию
+func (r *TemplatePostgres) GetAll(userId int) ([]todo.ListTemplate, error) {
+	templates := make([]todo.ListTemplate, 0)
+	query := fmt.Sprintf("SELECT %s FROM %s t WHERE t.user_id = $1 ORDER BY t.name, t.id", templateColumns, listTemplatesTable)
+	err := r.db.Select(&templates, query, userId)
+
+	return templates, err
+}
+
+func (r *TemplatePostgres) GetById(userId, templateId int) (todo.ListTemplate, error) {
+	var template todo.ListTemplate
+	query := fmt.Sprintf("SELECT %s FROM %s t WHERE t.id = $1 AND t.user_id = $2", templateColumns, listTemplatesTable)
+	err := r.db.Get(&template, query, templateId, userId)
+
+	return template, err
+}
+
+// Update изменяет переданные поля шаблона; содержимое заменяется целиком
+func (r *TemplatePostgres) Update(userId, templateId int, input todo.UpdateTemplateInput) error {
+	setValues := []string{"updated_at = $1"}
+	args := []interface{}{time.Now(), templateId, userId}
+	if input.Name != nil {
+		args = append(args, *input.Name)
+		setValues = append(setValues, fmt.Sprintf("name = $%d", len(args)))
+	}
+	if input.Description != nil {
+		args = append(args, *input.Description)
+		setValues = append(setValues, fmt.Sprintf("description = $%d", len(args)))
+	}
+	if input.Content != nil {
+		content, err := json.Marshal(input.Content)
+		if err != nil {
+			return err
+		}
+		args = append(args, content)
+		setValues = append(setValues, fmt.Sprintf("content = $%d", len(args)))
+	}
+
+	var id int
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE id = $2 AND user_id = $3 RETURNING id", listTemplatesTable, strings.Join(setValues, ", "))
+	return r.db.Get(&id, query, args...)
+}
+
+func (r *TemplatePostgres) Delete(userId, templateId int) error {
+	var id int
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1 AND user_id = $2 RETURNING id", listTemplatesTable)
+	return r.db.Get(&id, query, templateId, userId)
+}
+
+// Instantiate создает по содержимому шаблона список пользователя с разделами, items
+// и чек-листами в одной транзакции. Сроки items отсчитываются от anchor.
+// Возвращает новый список и его items
+func (r *TemplatePostgres) Instantiate(userId int, content todo.TemplateContent, anchor time.Time) (int, []int, error) {
+	var listId int
+	itemIds := make([]int, 0, len(content.Items))
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		var err error
+		listId, err = insertList(tx, userId, todo.TodoList{
+			Title:       content.Title,
+			Description: content.Description,
+			Color:       content.Color,
+			Priority:    content.Priority,
+		})
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		sectionIds := make([]int, 0, len(content.Sections))
+		sectionQuery := fmt.Sprintf(`
+			INSERT INTO %s (list_id, name, position, created_at, updated_at) VALUES ($1, $2, $3, $4, $4)
+			RETURNING id`, listSectionsTable)
+		for position, name := range content.Sections {
+			var sectionId int
+			if err := tx.Get(&sectionId, sectionQuery, listId, name, position, now); err != nil {
+				return err
+			}
+			sectionIds = append(sectionIds, sectionId)
+		}
+
+		checklistQuery := fmt.Sprintf(`
+			INSERT INTO %s (item_id, position, text, checked, created_at, updated_at) VALUES ($1, $2, $3, false, $4, $4)`,
+			checklistItemsTable)
+		for _, templateItem := range content.Items {
+			item := todo.TodoItem{
+				Title:           templateItem.Title,
+				Description:     templateItem.Description,
+				AutoComplete:    templateItem.AutoComplete,
+				Priority:        templateItem.Priority,
+				EstimateMinutes: templateItem.EstimateMinutes,
+			}
+			if templateItem.Section != nil {
+				item.SectionId = &sectionIds[*templateItem.Section]
+			}
+			if templateItem.DueOffsetMinutes != nil {
+				dueAt := anchor.Add(time.Duration(*templateItem.DueOffsetMinutes) * time.Minute)
+				item.DueAt = &dueAt
+			}
+
+			itemId, err := insertItem(tx, listId, item)
+			if err != nil {
+				return err
+			}
+			for position, text := range templateItem.Checklist {
+				if _, err := tx.Exec(checklistQuery, itemId, position, text, now); err != nil {
+					return err
+				}
+			}
+			itemIds = append(itemIds, itemId)
+		}
+
+		return nil
+	})
+
+	return listId, itemIds, err
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ktuty/todo-app"
+)
+
+func TestInstantiateTemplate(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	repo := NewTemplatePostgres(db).WithRequest(todo.RequestMeta{ActorId: owner})
+
+	section, offset, priority := 1, 90, 2
+	content := todo.TemplateContent{
+		Title:    "Trip",
+		Sections: []string{"Before", "During"},
+		Items: []todo.TemplateItem{
+			{Title: "Book hotel", Section: &section, DueOffsetMinutes: &offset, Priority: &priority, Checklist: []string{"Compare", "Pay"}},
+			{Title: "Pack", Checklist: []string{}},
+		},
+	}
+	anchor := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	listId, itemIds, err := repo.Instantiate(owner, content, anchor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(itemIds) != 2 {
+		t.Fatalf("item ids = %v", itemIds)
+	}
+
+	sections, err := NewTodoListPostgres(db).GetSections(owner, listId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 2 || sections[1].Name != "During" || sections[1].Position != 1 {
+		t.Fatalf("sections = %+v", sections)
+	}
+
+	items := NewTodoItemPostgres(db)
+	hotel, err := items.GetById(owner, itemIds[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hotel.SectionId == nil || *hotel.SectionId != sections[1].Id {
+		t.Errorf("hotel section = %v, want %d", hotel.SectionId, sections[1].Id)
+	}
+	if hotel.DueAt == nil || !hotel.DueAt.Equal(anchor.Add(90*time.Minute)) {
+		t.Errorf("hotel due = %v", hotel.DueAt)
+	}
+	if hotel.Priority == nil || *hotel.Priority != 2 || hotel.ChecklistTotal != 2 || hotel.ChecklistChecked != 0 {
+		t.Errorf("hotel: priority %v, checklist %d/%d", hotel.Priority, hotel.ChecklistChecked, hotel.ChecklistTotal)
+	}
+
+	entries, err := items.GetChecklist(owner, itemIds[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Text != "Compare" || entries[1].Position != 1 {
+		t.Errorf("checklist = %+v", entries)
+	}
+
+	pack, err := items.GetById(owner, itemIds[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pack.SectionId != nil || pack.DueAt != nil {
+		t.Errorf("pack: section %v, due %v", pack.SectionId, pack.DueAt)
+	}
+
+	// Список и items записываются в журнал от имени автора запроса
+	if rows := getAuditRows(t, db, todo.EntityList, listId); len(rows) != 1 || rows[0].Action != todo.EventListCreated || rows[0].ActorId != owner {
+		t.Errorf("list audit = %+v", rows)
+	}
+	if rows := getAuditRows(t, db, todo.EntityItem, itemIds[1]); len(rows) != 1 || rows[0].Action != todo.EventItemCreated {
+		t.Errorf("item audit = %+v", rows)
+	}
+}
+
+func TestTemplateOwnership(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	stranger := createTestUser(t, db, "stranger")
+	repo := NewTemplatePostgres(db)
+
+	id, err := repo.Create(owner, todo.TemplateInput{Name: "weekly", Content: todo.TemplateContent{Title: "Week"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Чужой шаблон не читается, не меняется и не удаляется
+	if _, err := repo.GetById(stranger, id); err == nil {
+		t.Error("stranger read the template")
+	}
+	name := "mine"
+	if err := repo.Update(stranger, id, todo.UpdateTemplateInput{Name: &name}); err == nil {
+		t.Error("stranger updated the template")
+	}
+	if err := repo.Delete(stranger, id); err == nil {
+		t.Error("stranger deleted the template")
+	}
+
+	template, err := repo.GetById(owner, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if template.Name != "weekly" || template.Content.Title != "Week" {
+		t.Errorf("template = %+v", template)
+	}
+}
@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+)
+
+type TimeEntryPostgres struct {
+	db   *sqlx.DB
+	meta todo.RequestMeta
+}
+
+func NewTimeEntryPostgres(db *sqlx.DB) *TimeEntryPostgres {
+	return &TimeEntryPostgres{db: db}
+}
+
+// WithRequest возвращает копию репозитория, изменения через которую записываются
+// в журнал аудита от имени автора запроса meta
+func (r *TimeEntryPostgres) WithRequest(meta todo.RequestMeta) TimeEntries {
+	scoped := *r
+	scoped.meta = meta
+	return &scoped
+}
+
+func (r *TimeEntryPostgres) inTx(fn func(tx *sqlx.Tx) error) error {
+	return inAuditedTx(r.db, r.meta, fn)
+}
+
+// timeEntrySeconds - длительность записи te в секундах; запущенный таймер считается до текущего момента
+const timeEntrySeconds = "extract(epoch from coalesce(te.ended_at, now()) - te.started_at)"
+
+// trackedMinutes - сумма записей te в целых минутах, для выборок items и списков
+var trackedMinutes = fmt.Sprintf("floor(coalesce(sum(%s), 0) / 60)::int", timeEntrySeconds)
+
+var timeEntryColumns = fmt.Sprintf("te.id, te.item_id, te.user_id, te.started_at, te.ended_at, floor(%s / 60)::int AS minutes, te.note, te.created_at",
+	timeEntrySeconds)
+
+// timeEntryAccess - условие доступа пользователя ($2) к записи: своя запись или доступ к item
+var timeEntryAccess = fmt.Sprintf(`(te.user_id = $2 OR EXISTS (SELECT 1 FROM %s li INNER JOIN %s ul on ul.list_id = li.list_id
+		WHERE li.item_id = te.item_id AND ul.user_id = $2))`, listsItemsTable, usersListsTable)
+
+// StartTimer запускает таймер пользователя по item. Уже запущенный таймер пользователя
+// останавливается, поэтому одновременно идет не больше одного
+func (r *TimeEntryPostgres) StartTimer(userId, itemId int, note string) (int, error) {
+	var id int
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		if _, err := itemListId(tx, userId, itemId); err != nil {
+			return err
+		}
+
+		// Параллельные запуски одного пользователя выполняются по очереди, иначе второй
+		// не увидел бы таймер первого и нарушил уникальность запущенного таймера
+		if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1), $2)", timeEntriesTable, userId); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if _, err := stopTimer(tx, userId, now); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		query := fmt.Sprintf(`
+			INSERT INTO %s (item_id, user_id, started_at, note, created_at) VALUES ($1, $2, $3, $4, $3)
+			RETURNING id`, timeEntriesTable)
+		if err := tx.Get(&id, query, itemId, userId, now, note); err != nil {
+			return err
+		}
+
+		return auditTimeEntry(tx, todo.AuditTimeEntryCreated, id)
+	})
+
+	return id, err
+}
+
+// StopTimer останавливает запущенный таймер пользователя и возвращает id записи;
+// sql.ErrNoRows, если таймер не запущен
+func (r *TimeEntryPostgres) StopTimer(userId int) (int, error) {
+	var id int
+	err := r.inTx(func(tx *sqlx.Tx) (err error) {
+		id, err = stopTimer(tx, userId, time.Now())
+		return err
+	})
+
+	return id, err
+}
+
+// stopTimer останавливает запущенный таймер пользователя и записывает аудит в транзакции tx
+func stopTimer(tx *sqlx.Tx, userId int, at time.Time) (int, error) {
+	var id int
+	query := fmt.Sprintf(`
+		UPDATE %s SET ended_at = greatest($1, started_at)
+		WHERE user_id = $2 AND ended_at IS NULL
+		RETURNING id`, timeEntriesTable)
+	if err := tx.Get(&id, query, at, userId); err != nil {
+		return 0, err
+	}
+
+	return id, auditTimeEntry(tx, todo.AuditTimeEntryStopped, id)
+}
+
+// GetRunning возвращает запущенный таймер пользователя; sql.ErrNoRows, если его нет
+func (r *TimeEntryPostgres) GetRunning(userId int) (todo.TimeEntry, error) {
+	var entry todo.TimeEntry
+	query := fmt.Sprintf("SELECT %s FROM %s te WHERE te.user_id = $1 AND te.ended_at IS NULL", timeEntryColumns, timeEntriesTable)
+	err := r.db.Get(&entry, query, userId)
+
+	return entry, err
+}
+
+// Create добавляет запись учтенного времени по item вручную
+func (r *TimeEntryPostgres) Create(userId, itemId int, input todo.TimeEntryInput) (int, error) {
+	var id int
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		if _, err := itemListId(tx, userId, itemId); err != nil {
+			return err
+		}
+
+		query := fmt.Sprintf(`
+			INSERT INTO %s (item_id, user_id, started_at, ended_at, note, created_at) VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id`, timeEntriesTable)
+		if err := tx.Get(&id, query, itemId, userId, input.StartedAt, input.EndedAt(), input.Note, time.Now()); err != nil {
+			return err
+		}
+
+		return auditTimeEntry(tx, todo.AuditTimeEntryCreated, id)
+	})
+
+	return id, err
+}
+
+// GetAll возвращает записи всех пользователей по item, новые первыми
+func (r *TimeEntryPostgres) GetAll(userId, itemId int) ([]todo.TimeEntry, error) {
+	if _, err := itemListId(r.db, userId, itemId); err != nil {
+		return nil, err
+	}
+
+	entries := make([]todo.TimeEntry, 0)
+	query := fmt.Sprintf("SELECT %s FROM %s te WHERE te.item_id = $1 ORDER BY te.started_at DESC, te.id DESC",
+		timeEntryColumns, timeEntriesTable)
+	err := r.db.Select(&entries, query, itemId)
+
+	return entries, err
+}
+
+func (r *TimeEntryPostgres) GetById(userId, entryId int) (todo.TimeEntry, error) {
+	var entry todo.TimeEntry
+	query := fmt.Sprintf("SELECT %s FROM %s te WHERE te.id = $1 AND %s", timeEntryColumns, timeEntriesTable, timeEntryAccess)
+	err := r.db.Get(&entry, query, entryId, userId)
+
+	return entry, err
+}
+
+// Delete удаляет запись; удалить можно только свою запись
+func (r *TimeEntryPostgres) Delete(userId, entryId int) error {
+	return r.inTx(func(tx *sqlx.Tx) error {
+		var authorId int
+		query := fmt.Sprintf("SELECT te.user_id FROM %s te WHERE te.id = $1 AND %s FOR UPDATE", timeEntriesTable, timeEntryAccess)
+		if err := tx.Get(&authorId, query, entryId, userId); err != nil {
+			return err
+		}
+		if authorId != userId {
+			return todo.ErrForbidden
+		}
+
+		// Аудит пишется до удаления, пока запись еще существует
+		if err := auditTimeEntry(tx, todo.AuditTimeEntryDeleted, entryId); err != nil {
+			return err
+		}
+
+		deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE id = $1", timeEntriesTable)
+		_, err := tx.Exec(deleteQuery, entryId)
+		return err
+	})
+}
+
+// GetReport возвращает учтенное время по спискам пользователя за каждый день периода.
+// Дни отсчитываются в часовом поясе пользователя; запись, пересекающая полночь,
+// делится между днями, а запущенный таймер учитывается до текущего момента
+func (r *TimeEntryPostgres) GetReport(userId int, filter todo.TimeReportFilter) ([]todo.TimeReportRow, error) {
+	rows := make([]todo.TimeReportRow, 0)
+	query := fmt.Sprintf(`
+		WITH entries AS (
+			SELECT li.list_id,
+				te.started_at AT TIME ZONE u.time_zone AS started_at,
+				coalesce(te.ended_at, now()) AT TIME ZONE u.time_zone AS ended_at
+			FROM %s te
+			INNER JOIN %s li on li.item_id = te.item_id
+			INNER JOIN %s ul on ul.list_id = li.list_id AND ul.user_id = $1
+			CROSS JOIN (SELECT time_zone FROM %s WHERE id = $1) u
+			WHERE ($4 = 0 OR li.list_id = $4) AND ($5 = 0 OR te.user_id = $5)
+				AND te.started_at < ($3::date + 1)::timestamp AT TIME ZONE u.time_zone
+				AND coalesce(te.ended_at, now()) > $2::date::timestamp AT TIME ZONE u.time_zone
+		), days AS (
+			SELECT e.list_id, d.day,
+				extract(epoch from least(e.ended_at, d.day + interval '1 day') - greatest(e.started_at, d.day)) AS seconds
+			FROM entries e
+			CROSS JOIN LATERAL generate_series(date_trunc('day', e.started_at), e.ended_at, interval '1 day') AS d (day)
+			WHERE d.day >= $2::date AND d.day <= $3::date
+		)
+		SELECT to_char(day, 'YYYY-MM-DD') AS date, list_id, floor(sum(seconds) / 60)::int AS minutes
+		FROM days
+		GROUP BY day, list_id
+		HAVING sum(seconds) >= 60
+		ORDER BY day, list_id`,
+		timeEntriesTable, listsItemsTable, usersListsTable, usersTable)
+	err := r.db.Select(&rows, query, userId,
+		filter.From.Format("2006-01-02"), filter.To.Format("2006-01-02"), filter.ListId, filter.UserId)
+
+	return rows, err
+}
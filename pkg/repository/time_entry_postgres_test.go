@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ktuty/todo-app"
+)
+
+func TestTimers(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	listId := createTestList(t, db, owner, "list")
+	first := createTestItem(t, db, listId, "first")
+	second := createTestItem(t, db, listId, "second")
+	repo := NewTimeEntryPostgres(db)
+
+	firstEntry, err := repo.StartTimer(owner, first, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondEntry, err := repo.StartTimer(owner, second, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Одновременно идет только один таймер пользователя
+	running, err := repo.GetRunning(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if running.Id != secondEntry {
+		t.Errorf("running entry = %d, want %d", running.Id, secondEntry)
+	}
+	entry, err := repo.GetById(owner, firstEntry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.EndedAt == nil {
+		t.Error("first timer is still running")
+	}
+
+	if _, err := repo.StopTimer(owner); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.StopTimer(owner); err == nil {
+		t.Error("stopping without a running timer must fail")
+	}
+}
+
+func TestTimeEntryRollupsAndDelete(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	member := createTestUser(t, db, "member")
+	listId := createTestList(t, db, owner, "list")
+	first := createTestItem(t, db, listId, "first")
+	second := createTestItem(t, db, listId, "second")
+	addTestMember(t, db, owner, listId, "member")
+	repo := NewTimeEntryPostgres(db)
+
+	startedAt := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	if _, err := repo.Create(owner, first, todo.TimeEntryInput{StartedAt: startedAt, Minutes: 30}); err != nil {
+		t.Fatal(err)
+	}
+	memberEntry, err := repo.Create(member, first, todo.TimeEntryInput{StartedAt: startedAt, Minutes: 15})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Create(owner, second, todo.TimeEntryInput{StartedAt: startedAt, Minutes: 20}); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := NewTodoItemPostgres(db).GetById(owner, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := NewTodoListPostgres(db).GetById(owner, listId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.TrackedMinutes != 45 || list.TrackedMinutes != 65 {
+		t.Errorf("tracked: item %d, list %d, want 45 and 65", item.TrackedMinutes, list.TrackedMinutes)
+	}
+
+	// Чужую запись можно прочитать, но не удалить
+	if err := repo.Delete(owner, memberEntry); !errors.Is(err, todo.ErrForbidden) {
+		t.Errorf("owner deletes member entry: err = %v, want forbidden", err)
+	}
+	if err := repo.Delete(member, memberEntry); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := repo.GetAll(owner, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("entries after delete = %+v", entries)
+	}
+}
+
+func TestTimeReport(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	member := createTestUser(t, db, "member")
+	work := createTestList(t, db, owner, "work")
+	home := createTestList(t, db, owner, "home")
+	task := createTestItem(t, db, work, "task")
+	chore := createTestItem(t, db, home, "chore")
+	addTestMember(t, db, owner, work, "member")
+	repo := NewTimeEntryPostgres(db)
+
+	if _, err := db.Exec("UPDATE users SET time_zone = 'Europe/Moscow' WHERE id = $1", owner); err != nil {
+		t.Fatal(err)
+	}
+
+	// 23:30-00:30 по Москве делится между днями; запись за пределами периода не учитывается
+	entries := []struct {
+		userId, itemId int
+		startedAt      time.Time
+		minutes        int
+	}{
+		{owner, task, time.Date(2026, 3, 1, 20, 30, 0, 0, time.UTC), 60},
+		{member, task, time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC), 15},
+		{owner, chore, time.Date(2026, 3, 2, 6, 0, 0, 0, time.UTC), 40},
+		{owner, chore, time.Date(2026, 3, 3, 6, 0, 0, 0, time.UTC), 40},
+	}
+	for _, entry := range entries {
+		input := todo.TimeEntryInput{StartedAt: entry.startedAt, Minutes: entry.minutes}
+		if _, err := repo.Create(entry.userId, entry.itemId, input); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filter := todo.TimeReportFilter{
+		From: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		name   string
+		listId int
+		userId int
+		want   []todo.TimeReportRow
+	}{
+		{"all", 0, 0, []todo.TimeReportRow{
+			{Date: "2026-03-01", ListId: work, Minutes: 45},
+			{Date: "2026-03-02", ListId: work, Minutes: 30},
+			{Date: "2026-03-02", ListId: home, Minutes: 40},
+		}},
+		{"list", home, 0, []todo.TimeReportRow{
+			{Date: "2026-03-02", ListId: home, Minutes: 40},
+		}},
+		{"user", 0, member, []todo.TimeReportRow{
+			{Date: "2026-03-01", ListId: work, Minutes: 15},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter.ListId, filter.UserId = tt.listId, tt.userId
+			rows, err := repo.GetReport(owner, filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(rows, tt.want) {
+				t.Errorf("report = %+v, want %+v", rows, tt.want)
+			}
+		})
+	}
+}
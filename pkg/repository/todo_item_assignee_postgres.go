@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+)
+
+// Assign назначает ответственным за item пользователя с доступом к списку item.
+// Возвращает false, если пользователь уже назначен
+func (r *TodoItemPostgres) Assign(userId, itemId, assigneeId int) (bool, error) {
+	var added bool
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		listId, err := itemListId(tx, userId, itemId)
+		if err != nil {
+			return err
+		}
+
+		// Блокировка списка не дает параллельно закрыть назначаемому доступ
+		if err := lockList(tx, userId, listId); err != nil {
+			return err
+		}
+
+		err = checkListAccess(tx, assigneeId, listId)
+		if errors.Is(err, sql.ErrNoRows) {
+			return todo.ErrAssigneeNoAccess
+		}
+		if err != nil {
+			return err
+		}
+
+		query := fmt.Sprintf(`
+			INSERT INTO %s (item_id, user_id, assigned_by, assigned_at) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (item_id, user_id) DO NOTHING`, itemAssigneesTable)
+		result, err := tx.Exec(query, itemId, assigneeId, userId, time.Now())
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return nil
+		}
+		added = true
+
+		return touchItem(tx, todo.EventItemAssigned, itemId)
+	})
+
+	return added, err
+}
+
+// Unassign снимает ответственного с item
+func (r *TodoItemPostgres) Unassign(userId, itemId, assigneeId int) error {
+	return r.inTx(func(tx *sqlx.Tx) error {
+		if _, err := itemListId(tx, userId, itemId); err != nil {
+			return err
+		}
+
+		query := fmt.Sprintf("DELETE FROM %s WHERE item_id = $1 AND user_id = $2", itemAssigneesTable)
+		result, err := tx.Exec(query, itemId, assigneeId)
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+
+		return touchItem(tx, todo.EventItemUnassigned, itemId)
+	})
+}
+
+// touchItem увеличивает версию item после изменения связанных с ним данных (ответственных,
+// зависимостей), чтобы оно попало в синхронизацию и сбросило ETag, и ставит в очередь webhooks
+func touchItem(tx *sqlx.Tx, eventType string, itemId int) error {
+	query := fmt.Sprintf("UPDATE %s SET updated_at = $1, version = version + 1 WHERE id = $2", todoItemsTable)
+	if _, err := tx.Exec(query, time.Now(), itemId); err != nil {
+		return err
+	}
+
+	return recordItemChange(tx, eventType, itemId)
+}
+
+// removeAssigneesWithoutAccess снимает с items списка ответственных, у которых нет доступа к нему,
+// и возвращает затронутые items. itemId > 0 ограничивает удаление одним item
+func removeAssigneesWithoutAccess(tx *sqlx.Tx, listId, itemId int) ([]int, error) {
+	itemIds := make([]int, 0)
+	query := fmt.Sprintf(`
+		DELETE FROM %s a
+		USING %s li
+		WHERE li.item_id = a.item_id AND li.list_id = $1 AND ($2 = 0 OR a.item_id = $2)
+			AND NOT EXISTS (SELECT 1 FROM %s ul WHERE ul.list_id = $1 AND ul.user_id = a.user_id)
+		RETURNING a.item_id`,
+		itemAssigneesTable, listsItemsTable, usersListsTable)
+	err := tx.Select(&itemIds, query, listId, itemId)
+
+	return itemIds, err
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/ktuty/todo-app"
+)
+
+func TestAssignItem(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	member := createTestUser(t, db, "member")
+	stranger := createTestUser(t, db, "stranger")
+	listId := createTestList(t, db, owner, "list")
+	itemId := createTestItem(t, db, listId, "item")
+	otherId := createTestItem(t, db, listId, "other")
+	addTestMember(t, db, owner, listId, "member")
+	repo := NewTodoItemPostgres(db)
+
+	// Назначить можно только пользователя с доступом к списку
+	if _, err := repo.Assign(owner, itemId, stranger); !errors.Is(err, todo.ErrAssigneeNoAccess) {
+		t.Errorf("assign stranger: err = %v, want no access", err)
+	}
+	// Пользователь без доступа к списку не может назначать
+	if _, err := repo.Assign(stranger, itemId, stranger); err == nil {
+		t.Error("stranger assigned themselves")
+	}
+
+	before, err := repo.GetById(owner, itemId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added, err := repo.Assign(owner, itemId, member); err != nil || !added {
+		t.Fatalf("assign member: added %v, err %v", added, err)
+	}
+	if added, err := repo.Assign(owner, itemId, member); err != nil || added {
+		t.Errorf("assign member again: added %v, err %v", added, err)
+	}
+
+	item, err := repo.GetById(owner, itemId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(item.AssigneeIds) != 1 || item.AssigneeIds[0] != member {
+		t.Errorf("assignees = %v, want [%d]", item.AssigneeIds, member)
+	}
+	// Повторное назначение не меняет версию
+	if item.Version != before.Version+1 {
+		t.Errorf("version = %d, want %d", item.Version, before.Version+1)
+	}
+
+	items, total, err := repo.GetAllWithPagination(member, todo.ItemFilter{AssigneeId: member}, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(items) != 1 || items[0].Id != itemId {
+		t.Errorf("assigned to member: total %d, items %+v", total, items)
+	}
+
+	if err := repo.Unassign(owner, otherId, member); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("unassign not assigned: err = %v, want no rows", err)
+	}
+	if err := repo.Unassign(owner, itemId, member); err != nil {
+		t.Fatal(err)
+	}
+
+	rows := getAuditRows(t, db, todo.EntityItem, itemId)
+	var actions []string
+	for _, row := range rows {
+		actions = append(actions, row.Action)
+	}
+	if len(actions) != 3 || actions[1] != todo.EventItemAssigned || actions[2] != todo.EventItemUnassigned {
+		t.Errorf("audit actions = %v, want created, assigned, unassigned", actions)
+	}
+}
+
+func TestMoveItemDropsAssignees(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	member := createTestUser(t, db, "member")
+	sharedId := createTestList(t, db, owner, "shared")
+	privateId := createTestList(t, db, owner, "private")
+	itemId := createTestItem(t, db, sharedId, "item")
+	addTestMember(t, db, owner, sharedId, "member")
+	repo := NewTodoItemPostgres(db)
+
+	for _, userId := range []int{owner, member} {
+		if _, err := repo.Assign(owner, itemId, userId); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := repo.Bulk(owner, []todo.BulkItemOperation{{Op: todo.BulkOpMove, ItemId: itemId, ListId: privateId}}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Err != nil {
+		t.Fatal(results[0].Err)
+	}
+
+	// У участника нет доступа к новому списку, владелец остается ответственным
+	item, err := repo.GetById(owner, itemId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(item.AssigneeIds) != 1 || item.AssigneeIds[0] != owner {
+		t.Errorf("assignees after move = %v, want [%d]", item.AssigneeIds, owner)
+	}
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+)
+
+const bulkSavepoint = "bulk_operation"
+
+// Bulk выполняет пакет операций над items в одной транзакции.
+// В режиме atomic ошибка любой операции откатывает весь пакет, иначе каждая
+// операция изолирована точкой сохранения и применяется независимо от остальных
+func (r *TodoItemPostgres) Bulk(userId int, ops []todo.BulkItemOperation, atomic bool) ([]todo.BulkItemResult, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	if err := setAuditContext(tx, r.meta); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	results := make([]todo.BulkItemResult, len(ops))
+	for i, op := range ops {
+		results[i] = todo.BulkItemResult{Index: i, Op: op.Op, ItemId: op.ItemId}
+	}
+
+	for i, op := range ops {
+		if !atomic {
+			if _, err := tx.Exec("SAVEPOINT " + bulkSavepoint); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+
+		itemId, err := applyBulkOperation(tx, userId, op)
+		if err == nil {
+			results[i].ItemId = itemId
+			if !atomic {
+				if _, err := tx.Exec("RELEASE SAVEPOINT " + bulkSavepoint); err != nil {
+					tx.Rollback()
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		results[i].Err = err
+		if atomic {
+			tx.Rollback()
+			for j := range results {
+				if j == i {
+					continue
+				}
+				results[j].Err = todo.ErrBulkAborted
+				if ops[j].Op == todo.BulkOpCreate {
+					results[j].ItemId = 0
+				}
+			}
+			return results, nil
+		}
+
+		if _, err := tx.Exec("ROLLBACK TO SAVEPOINT " + bulkSavepoint); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	return results, tx.Commit()
+}
+
+// applyBulkOperation выполняет одну операцию пакета и возвращает id затронутого item.
+// Доступ проверяется для каждого item через users_lists
+func applyBulkOperation(tx *sqlx.Tx, userId int, op todo.BulkItemOperation) (int, error) {
+	switch op.Op {
+	case todo.BulkOpCreate:
+		if err := checkListAccess(tx, userId, op.ListId); err != nil {
+			return 0, err
+		}
+		return insertItem(tx, op.ListId, *op.Item)
+	case todo.BulkOpUpdate:
+		update := *op.Update
+		update.Force = op.Force
+		return op.ItemId, updateItemInTx(tx, userId, op.ItemId, op.Version, update)
+	case todo.BulkOpComplete:
+		done := true
+		return op.ItemId, updateItemInTx(tx, userId, op.ItemId, op.Version, todo.UpdateItemInput{Done: &done, Force: op.Force})
+	case todo.BulkOpArchive, todo.BulkOpDelete:
+		archived := true
+		return op.ItemId, updateItemInTx(tx, userId, op.ItemId, op.Version, todo.UpdateItemInput{Archived: &archived})
+	case todo.BulkOpMove:
+		return op.ItemId, moveItem(tx, userId, op.ItemId, op.ListId, op.Version)
+	case todo.BulkOpPurge:
+		return op.ItemId, deleteItem(tx, userId, op.ItemId, op.Version)
+	default:
+		return 0, fmt.Errorf("unsupported bulk operation %q", op.Op)
+	}
+}
+
+func updateItemInTx(tx *sqlx.Tx, userId, itemId, version int, input todo.UpdateItemInput) error {
+	updated, err := updateItem(tx, userId, itemId, version, input)
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return itemVersionConflict(tx, userId, itemId)
+	}
+	return nil
+}
+
+// moveItem переносит item в другой список; оба списка должны быть доступны пользователю
+func moveItem(tx *sqlx.Tx, userId, itemId, listId, version int) error {
+	if err := checkListAccess(tx, userId, listId); err != nil {
+		return err
+	}
+
+	item, err := getItemById(tx, userId, itemId)
+	if err != nil {
+		return err
+	}
+	if version > 0 && item.Version != version {
+		return todo.ErrVersionMismatch
+	}
+
+	moveQuery := fmt.Sprintf(`
+		UPDATE %s li SET list_id = $1 
+		FROM %s ul 
+		WHERE li.list_id = ul.list_id AND ul.user_id = $2 AND li.item_id = $3`,
+		listsItemsTable, usersListsTable)
+	if _, err := tx.Exec(moveQuery, listId, userId, itemId); err != nil {
+		return err
+	}
+
+	if _, err := removeAssigneesWithoutAccess(tx, listId, itemId); err != nil {
+		return err
+	}
+
+	// Статус заменяется статусом workflow нового списка с тем же названием,
+	// а если такого нет - первым статусом той же категории. Разделы у каждого
+	// списка свои, поэтому item попадает в конец items без раздела
+	touchQuery := fmt.Sprintf(`
+		UPDATE %s ti SET updated_at = $1, version = ti.version + 1,
+			status_id = (
+				SELECT s.id FROM %s s
+				WHERE s.list_id = $3 AND (s.category = $4) = ti.done
+				ORDER BY lower(s.name) = (SELECT lower(c.name) FROM %s c WHERE c.id = ti.status_id) DESC NULLS LAST, s.position
+				LIMIT 1),
+			section_id = NULL,
+			position = (%s)
+		WHERE ti.id = $2`,
+		todoItemsTable, listStatusesTable, listStatusesTable, fmt.Sprintf(sectionEndPosition, "$3", "NULL"))
+	if _, err := tx.Exec(touchQuery, time.Now(), itemId, listId, todo.StatusCategoryClosed); err != nil {
+		return err
+	}
+
+	return recordItemChange(tx, todo.EventItemMoved, itemId)
+}
+
+// checkListAccess возвращает sql.ErrNoRows, если список не принадлежит пользователю
+func checkListAccess(db sqlx.Queryer, userId, listId int) error {
+	var id int
+	query := fmt.Sprintf("SELECT list_id FROM %s WHERE user_id = $1 AND list_id = $2 LIMIT 1", usersListsTable)
+	return sqlx.Get(db, &id, query, userId, listId)
+}
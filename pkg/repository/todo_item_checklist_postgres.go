@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+	"github.com/lib/pq"
+)
+
+var checklistColumns = "cl.id, cl.item_id, cl.position, cl.text, cl.checked, cl.created_at, cl.updated_at"
+
+// GetChecklist возвращает пункты чек-листа item по порядку
+func (r *TodoItemPostgres) GetChecklist(userId, itemId int) ([]todo.ChecklistItem, error) {
+	if _, err := itemListId(r.db, userId, itemId); err != nil {
+		return nil, err
+	}
+
+	return getChecklist(r.db, itemId)
+}
+
+func getChecklist(db sqlx.Queryer, itemId int) ([]todo.ChecklistItem, error) {
+	entries := make([]todo.ChecklistItem, 0)
+	query := fmt.Sprintf("SELECT %s FROM %s cl WHERE cl.item_id = $1 ORDER BY cl.position", checklistColumns, checklistItemsTable)
+	err := sqlx.Select(db, &entries, query, itemId)
+
+	return entries, err
+}
+
+// AddChecklistItem добавляет пункт в чек-лист item. Позиция за пределами чек-листа
+// означает конец, пункты начиная с указанной позиции сдвигаются
+func (r *TodoItemPostgres) AddChecklistItem(userId, itemId int, input todo.ChecklistItemInput) (todo.ChecklistItem, error) {
+	var entry todo.ChecklistItem
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		if err := lockChecklistItem(tx, userId, itemId); err != nil {
+			return err
+		}
+
+		var count int
+		countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE item_id = $1", checklistItemsTable)
+		if err := tx.Get(&count, countQuery, itemId); err != nil {
+			return err
+		}
+
+		position := count
+		if input.Position != nil && *input.Position < count {
+			position = *input.Position
+			shiftQuery := fmt.Sprintf("UPDATE %s SET position = position + 1 WHERE item_id = $1 AND position >= $2", checklistItemsTable)
+			if _, err := tx.Exec(shiftQuery, itemId, position); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		query := fmt.Sprintf(`
+			INSERT INTO %s AS cl (item_id, position, text, checked, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $5)
+			RETURNING %s`, checklistItemsTable, checklistColumns)
+		if err := tx.Get(&entry, query, itemId, position, input.Text, input.Checked, now); err != nil {
+			return err
+		}
+
+		return touchChecklistItem(tx, itemId)
+	})
+
+	return entry, err
+}
+
+// UpdateChecklistItem изменяет текст или отметку пункта чек-листа
+func (r *TodoItemPostgres) UpdateChecklistItem(userId, itemId, entryId int, input todo.UpdateChecklistItemInput) (todo.ChecklistItem, error) {
+	var entry todo.ChecklistItem
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		if err := lockChecklistItem(tx, userId, itemId); err != nil {
+			return err
+		}
+
+		query := fmt.Sprintf(`
+			UPDATE %s cl SET text = coalesce($1, cl.text), checked = coalesce($2, cl.checked), updated_at = $3
+			WHERE cl.id = $4 AND cl.item_id = $5
+			RETURNING %s`, checklistItemsTable, checklistColumns)
+		if err := tx.Get(&entry, query, input.Text, input.Checked, time.Now(), entryId, itemId); err != nil {
+			return err
+		}
+
+		return touchChecklistItem(tx, itemId)
+	})
+
+	return entry, err
+}
+
+// DeleteChecklistItem удаляет пункт чек-листа; следующие пункты сдвигаются на его место
+func (r *TodoItemPostgres) DeleteChecklistItem(userId, itemId, entryId int) error {
+	return r.inTx(func(tx *sqlx.Tx) error {
+		if err := lockChecklistItem(tx, userId, itemId); err != nil {
+			return err
+		}
+
+		var position int
+		query := fmt.Sprintf("DELETE FROM %s WHERE id = $1 AND item_id = $2 RETURNING position", checklistItemsTable)
+		if err := tx.Get(&position, query, entryId, itemId); err != nil {
+			return err
+		}
+
+		shiftQuery := fmt.Sprintf("UPDATE %s SET position = position - 1 WHERE item_id = $1 AND position > $2", checklistItemsTable)
+		if _, err := tx.Exec(shiftQuery, itemId, position); err != nil {
+			return err
+		}
+
+		return touchChecklistItem(tx, itemId)
+	})
+}
+
+// ReorderChecklist расставляет пункты чек-листа в порядке ids.
+// ids должны перечислять все пункты item, иначе возвращается todo.ErrChecklistOrder
+func (r *TodoItemPostgres) ReorderChecklist(userId, itemId int, ids []int) ([]todo.ChecklistItem, error) {
+	var entries []todo.ChecklistItem
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		if err := lockChecklistItem(tx, userId, itemId); err != nil {
+			return err
+		}
+
+		var count int
+		countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE item_id = $1", checklistItemsTable)
+		if err := tx.Get(&count, countQuery, itemId); err != nil {
+			return err
+		}
+		if count != len(ids) {
+			return todo.ErrChecklistOrder
+		}
+
+		query := fmt.Sprintf(`
+			UPDATE %s cl SET position = o.ordinality - 1
+			FROM unnest($1::int[]) WITH ORDINALITY o(id, ordinality)
+			WHERE cl.id = o.id AND cl.item_id = $2`, checklistItemsTable)
+		result, err := tx.Exec(query, pq.Array(ids), itemId)
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows != int64(len(ids)) {
+			return todo.ErrChecklistOrder
+		}
+
+		if entries, err = getChecklist(tx, itemId); err != nil {
+			return err
+		}
+
+		return touchChecklistItem(tx, itemId)
+	})
+
+	return entries, err
+}
+
+// lockChecklistItem проверяет доступ к item и блокирует его строку до конца транзакции,
+// чтобы параллельные изменения чек-листа не путали позиции пунктов
+func lockChecklistItem(tx *sqlx.Tx, userId, itemId int) error {
+	if _, err := itemListId(tx, userId, itemId); err != nil {
+		return err
+	}
+
+	var id int
+	query := fmt.Sprintf("SELECT id FROM %s WHERE id = $1 FOR UPDATE", todoItemsTable)
+	return tx.Get(&id, query, itemId)
+}
+
+// touchChecklistItem увеличивает версию item после изменения чек-листа, т.к. прогресс
+// входит в представление item, и ставит в очередь webhooks. Если для item включено
+// автозавершение, отмечены все пункты и item не заблокирован, он отмечается выполненным
+func touchChecklistItem(tx *sqlx.Tx, itemId int) error {
+	// prev читается из снимка до обновления, поэтому показывает прежнее значение done
+	query := fmt.Sprintf(`
+		UPDATE %s ti SET updated_at = $1, version = ti.version + 1,
+			done = ti.done OR (ti.checklist_auto_complete
+				AND EXISTS (SELECT 1 FROM %s cl WHERE cl.item_id = ti.id)
+				AND NOT EXISTS (SELECT 1 FROM %s cl WHERE cl.item_id = ti.id AND NOT cl.checked)
+				AND NOT %s)
+		FROM %s prev
+		WHERE ti.id = $2 AND prev.id = ti.id
+		RETURNING ti.done AND NOT prev.done`,
+		todoItemsTable, checklistItemsTable, checklistItemsTable, itemBlockedCondition, todoItemsTable)
+	var completed bool
+	if err := tx.Get(&completed, query, time.Now(), itemId); err != nil {
+		return err
+	}
+
+	eventType := todo.EventItemUpdated
+	if completed {
+		eventType = todo.EventItemCompleted
+	}
+
+	return recordItemChange(tx, eventType, itemId)
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ktuty/todo-app"
+)
+
+func checklistTexts(t *testing.T, repo *TodoItemPostgres, userId, itemId int) []string {
+	t.Helper()
+
+	entries, err := repo.GetChecklist(userId, itemId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	texts := make([]string, len(entries))
+	for i, entry := range entries {
+		if entry.Position != i {
+			t.Errorf("%q at position %d, want %d", entry.Text, entry.Position, i)
+		}
+		texts[i] = entry.Text
+	}
+	return texts
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestChecklistPositions(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	listId := createTestList(t, db, owner, "list")
+	itemId := createTestItem(t, db, listId, "item")
+	repo := NewTodoItemPostgres(db)
+
+	add := func(text string, position *int) int {
+		t.Helper()
+		entry, err := repo.AddChecklistItem(owner, itemId, todo.ChecklistItemInput{Text: text, Position: position})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return entry.Id
+	}
+	zero, far := 0, 100
+	b := add("b", nil)
+	a := add("a", &zero)
+	c := add("c", &far)
+
+	if texts := checklistTexts(t, repo, owner, itemId); !equalStrings(texts, []string{"a", "b", "c"}) {
+		t.Fatalf("after add = %v, want [a b c]", texts)
+	}
+
+	entries, err := repo.ReorderChecklist(owner, itemId, []int{c, a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[0].Id != c || entries[2].Id != b {
+		t.Errorf("reordered = %+v, want [c a b]", entries)
+	}
+
+	// Порядок должен перечислять все пункты item
+	if _, err := repo.ReorderChecklist(owner, itemId, []int{c, a}); !errors.Is(err, todo.ErrChecklistOrder) {
+		t.Errorf("partial order: err = %v, want checklist order", err)
+	}
+	otherItemId := createTestItem(t, db, listId, "other")
+	other, err := repo.AddChecklistItem(owner, otherItemId, todo.ChecklistItemInput{Text: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.ReorderChecklist(owner, itemId, []int{c, a, other.Id}); !errors.Is(err, todo.ErrChecklistOrder) {
+		t.Errorf("foreign entry: err = %v, want checklist order", err)
+	}
+
+	if err := repo.DeleteChecklistItem(owner, itemId, a); err != nil {
+		t.Fatal(err)
+	}
+	if texts := checklistTexts(t, repo, owner, itemId); !equalStrings(texts, []string{"c", "b"}) {
+		t.Errorf("after delete = %v, want [c b]", texts)
+	}
+}
+
+func TestChecklistAutoComplete(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	listId := createTestList(t, db, owner, "list")
+	itemId := createTestItem(t, db, listId, "item")
+	repo := NewTodoItemPostgres(db)
+
+	autoComplete := true
+	if err := repo.Update(owner, itemId, todo.UpdateItemInput{AutoComplete: &autoComplete}); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []int
+	for _, text := range []string{"a", "b"} {
+		entry, err := repo.AddChecklistItem(owner, itemId, todo.ChecklistItemInput{Text: text})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, entry.Id)
+	}
+
+	checked := true
+	if _, err := repo.UpdateChecklistItem(owner, itemId, ids[0], todo.UpdateChecklistItemInput{Checked: &checked}); err != nil {
+		t.Fatal(err)
+	}
+	item, err := repo.GetById(owner, itemId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Done || item.ChecklistTotal != 2 || item.ChecklistChecked != 1 {
+		t.Fatalf("after one checked: done %v, progress %d/%d", item.Done, item.ChecklistChecked, item.ChecklistTotal)
+	}
+
+	if _, err := repo.UpdateChecklistItem(owner, itemId, ids[1], todo.UpdateChecklistItemInput{Checked: &checked}); err != nil {
+		t.Fatal(err)
+	}
+	if item, err = repo.GetById(owner, itemId); err != nil {
+		t.Fatal(err)
+	}
+	if !item.Done || item.ChecklistChecked != 2 {
+		t.Errorf("after all checked: done %v, progress %d/%d", item.Done, item.ChecklistChecked, item.ChecklistTotal)
+	}
+
+	rows := getAuditRows(t, db, todo.EntityItem, itemId)
+	if last := rows[len(rows)-1]; last.Action != todo.EventItemCompleted {
+		t.Errorf("last audit action = %s, want item.completed", last.Action)
+	}
+
+	// Снятая отметка не возвращает item в невыполненные
+	unchecked := false
+	if _, err := repo.UpdateChecklistItem(owner, itemId, ids[1], todo.UpdateChecklistItemInput{Checked: &unchecked}); err != nil {
+		t.Fatal(err)
+	}
+	if item, err = repo.GetById(owner, itemId); err != nil {
+		t.Fatal(err)
+	}
+	if !item.Done {
+		t.Error("unchecking an entry reopened the item")
+	}
+}
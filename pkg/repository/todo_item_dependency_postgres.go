@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+)
+
+// AddDependency отмечает, что item нельзя выполнить раньше blockedById. Оба item должны быть
+// доступны пользователю; зависимость, замыкающая цикл, отклоняется с todo.ErrDependencyCycle.
+// Возвращает false, если зависимость уже есть
+func (r *TodoItemPostgres) AddDependency(userId, itemId, blockedById int) (bool, error) {
+	if itemId == blockedById {
+		return false, todo.ErrDependencyCycle
+	}
+
+	var added bool
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		if _, err := itemListId(tx, userId, itemId); err != nil {
+			return err
+		}
+
+		_, err := itemListId(tx, userId, blockedById)
+		if errors.Is(err, sql.ErrNoRows) {
+			return todo.ErrBlockerNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		// Две встречные зависимости, добавленные параллельно, не видят друг друга при проверке,
+		// поэтому изменения графа выполняются по очереди
+		if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", itemDependenciesTable); err != nil {
+			return err
+		}
+
+		// Цикл появится, если item уже достижим из blockedById по цепочке блокирующих items
+		var cycle bool
+		cycleQuery := fmt.Sprintf(`
+			WITH RECURSIVE blockers (id) AS (
+				SELECT blocked_by_id FROM %s WHERE item_id = $1
+				UNION
+				SELECT d.blocked_by_id FROM %s d INNER JOIN blockers b ON d.item_id = b.id
+			)
+			SELECT EXISTS (SELECT 1 FROM blockers WHERE id = $2)`,
+			itemDependenciesTable, itemDependenciesTable)
+		if err := tx.Get(&cycle, cycleQuery, blockedById, itemId); err != nil {
+			return err
+		}
+		if cycle {
+			return todo.ErrDependencyCycle
+		}
+
+		query := fmt.Sprintf(`
+			INSERT INTO %s (item_id, blocked_by_id, created_by, created_at) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (item_id, blocked_by_id) DO NOTHING`, itemDependenciesTable)
+		result, err := tx.Exec(query, itemId, blockedById, userId, time.Now())
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return nil
+		}
+		added = true
+
+		return touchItem(tx, todo.EventItemUpdated, itemId)
+	})
+
+	return added, err
+}
+
+// RemoveDependency снимает зависимость item от blockedById. Блокирующий item может быть
+// уже недоступен пользователю, поэтому проверяется только доступ к item
+func (r *TodoItemPostgres) RemoveDependency(userId, itemId, blockedById int) error {
+	return r.inTx(func(tx *sqlx.Tx) error {
+		if _, err := itemListId(tx, userId, itemId); err != nil {
+			return err
+		}
+
+		query := fmt.Sprintf("DELETE FROM %s WHERE item_id = $1 AND blocked_by_id = $2", itemDependenciesTable)
+		result, err := tx.Exec(query, itemId, blockedById)
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return sql.ErrNoRows
+		}
+
+		return touchItem(tx, todo.EventItemUpdated, itemId)
+	})
+}
+
+// GetDependencyGraph возвращает доступные пользователю неархивированные items, связанные
+// зависимостями, с зависимостями между ними. listId > 0 оставляет зависимости,
+// в которых участвует хотя бы один item этого списка
+func (r *TodoItemPostgres) GetDependencyGraph(userId, listId int) ([]todo.DependencyNode, error) {
+	nodes := make([]todo.DependencyNode, 0)
+	query := fmt.Sprintf(`
+		WITH accessible AS (
+			SELECT DISTINCT ti.id, ti.title, ti.done, li.list_id
+			FROM %s ti
+			INNER JOIN %s li on li.item_id = ti.id
+			INNER JOIN %s ul on ul.list_id = li.list_id
+			WHERE ul.user_id = $1 AND ti.archived = false
+		), edges AS (
+			SELECT d.item_id, d.blocked_by_id
+			FROM %s d
+			INNER JOIN accessible a ON a.id = d.item_id
+			INNER JOIN accessible b ON b.id = d.blocked_by_id
+			WHERE $2 = 0 OR a.list_id = $2 OR b.list_id = $2
+		)
+		SELECT a.id AS item_id, a.list_id, a.title, a.done,
+			coalesce((SELECT json_agg(e.blocked_by_id ORDER BY e.blocked_by_id) FROM edges e WHERE e.item_id = a.id), '[]') AS blocked_by_ids
+		FROM accessible a
+		WHERE EXISTS (SELECT 1 FROM edges e WHERE e.item_id = a.id OR e.blocked_by_id = a.id)
+		ORDER BY a.id`,
+		todoItemsTable, listsItemsTable, usersListsTable, itemDependenciesTable)
+	err := r.db.Select(&nodes, query, userId, listId)
+
+	return nodes, err
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ktuty/todo-app"
+)
+
+func TestDependencyCycles(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	stranger := createTestUser(t, db, "stranger")
+	listId := createTestList(t, db, owner, "list")
+	a := createTestItem(t, db, listId, "a")
+	b := createTestItem(t, db, listId, "b")
+	c := createTestItem(t, db, listId, "c")
+	foreign := createTestItem(t, db, createTestList(t, db, stranger, "foreign"), "foreign")
+	repo := NewTodoItemPostgres(db)
+
+	// a блокирует b, b блокирует c
+	if added, err := repo.AddDependency(owner, b, a); err != nil || !added {
+		t.Fatalf("b after a: added %v, err %v", added, err)
+	}
+	if added, err := repo.AddDependency(owner, c, b); err != nil || !added {
+		t.Fatalf("c after b: added %v, err %v", added, err)
+	}
+	if added, err := repo.AddDependency(owner, c, b); err != nil || added {
+		t.Errorf("c after b again: added %v, err %v", added, err)
+	}
+
+	tests := []struct {
+		name              string
+		itemId, blockedBy int
+	}{
+		{"self", a, a},
+		{"direct", a, b},
+		{"transitive", a, c},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := repo.AddDependency(owner, tt.itemId, tt.blockedBy); !errors.Is(err, todo.ErrDependencyCycle) {
+				t.Errorf("err = %v, want dependency cycle", err)
+			}
+		})
+	}
+
+	// Лишняя, но не циклическая зависимость допустима
+	if _, err := repo.AddDependency(owner, c, a); err != nil {
+		t.Errorf("c after a: %s", err.Error())
+	}
+	if _, err := repo.AddDependency(owner, a, foreign); !errors.Is(err, todo.ErrBlockerNotFound) {
+		t.Errorf("foreign blocker: err = %v, want blocker not found", err)
+	}
+}
+
+func TestBlockedItems(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	listId := createTestList(t, db, owner, "list")
+	blocker := createTestItem(t, db, listId, "blocker")
+	blocked := createTestItem(t, db, listId, "blocked")
+	repo := NewTodoItemPostgres(db)
+
+	if _, err := repo.AddDependency(owner, blocked, blocker); err != nil {
+		t.Fatal(err)
+	}
+
+	items, _, err := repo.GetAllWithPagination(owner, todo.ItemFilter{ListId: listId, Blocked: "true"}, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Id != blocked || !items[0].Blocked {
+		t.Errorf("blocked items = %+v", items)
+	}
+
+	if err := repo.CompleteItem(owner, blocked, false); !errors.Is(err, todo.ErrItemBlocked) {
+		t.Errorf("complete blocked: err = %v, want item blocked", err)
+	}
+
+	// Выполненный блокирующий item больше не блокирует
+	if err := repo.CompleteItem(owner, blocker, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CompleteItem(owner, blocked, false); err != nil {
+		t.Errorf("complete after blocker: %s", err.Error())
+	}
+
+	nodes, err := repo.GetDependencyGraph(owner, listId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 2 || nodes[1].ItemId != blocked || len(nodes[1].BlockedByIds) != 1 || nodes[1].BlockedByIds[0] != blocker {
+		t.Errorf("graph = %+v", nodes)
+	}
+
+	if err := repo.RemoveDependency(owner, blocked, blocker); err != nil {
+		t.Fatal(err)
+	}
+	if nodes, err = repo.GetDependencyGraph(owner, listId); err != nil || len(nodes) != 0 {
+		t.Errorf("graph after removal = %+v, %v", nodes, err)
+	}
+}
+
+func TestForceCompleteBlocked(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	listId := createTestList(t, db, owner, "list")
+	blocker := createTestItem(t, db, listId, "blocker")
+	blocked := createTestItem(t, db, listId, "blocked")
+	repo := NewTodoItemPostgres(db)
+
+	if _, err := repo.AddDependency(owner, blocked, blocker); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CompleteItem(owner, blocked, true); err != nil {
+		t.Fatalf("forced completion: %s", err.Error())
+	}
+
+	// Автозавершение по чек-листу не обходит блокировку
+	autoComplete := true
+	if err := repo.Update(owner, blocker, todo.UpdateItemInput{AutoComplete: &autoComplete}); err != nil {
+		t.Fatal(err)
+	}
+	other := createTestItem(t, db, listId, "other")
+	if _, err := repo.AddDependency(owner, blocker, other); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.AddChecklistItem(owner, blocker, todo.ChecklistItemInput{Text: "step", Checked: true}); err != nil {
+		t.Fatal(err)
+	}
+	item, err := repo.GetById(owner, blocker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Done {
+		t.Error("blocked item was auto-completed by its checklist")
+	}
+}
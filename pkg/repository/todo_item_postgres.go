@@ -1,68 +1,153 @@
 package repository
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/ktuty/todo-app"
+	"github.com/lib/pq"
 )
 
 type TodoItemPostgres struct {
-	db *sqlx.DB
+	db   *sqlx.DB
+	meta todo.RequestMeta
 }
 
+// itemColumns - колонки выборки items (псевдоним ti); число комментариев, ответственные,
+// прогресс чек-листа, зависимости и учтенное время вычисляются подзапросами
+var itemColumns = fmt.Sprintf(`ti.id, ti.title, ti.description, ti.done, ti.archived, ti.due_at, ti.created_at, ti.updated_at, ti.version,
+		ti.checklist_auto_complete, ti.status_id, ti.priority, ti.estimate_minutes, ti.section_id, ti.position,
+		(SELECT COUNT(*) FROM %s c WHERE c.item_id = ti.id AND c.deleted_at IS NULL) AS comment_count,
+		coalesce((SELECT json_agg(a.user_id ORDER BY a.user_id) FROM %s a WHERE a.item_id = ti.id), '[]') AS assignee_ids,
+		(SELECT COUNT(*) FROM %s cl WHERE cl.item_id = ti.id) AS checklist_total,
+		(SELECT COUNT(*) FROM %s cl WHERE cl.item_id = ti.id AND cl.checked) AS checklist_checked,
+		coalesce((SELECT json_agg(d.blocked_by_id ORDER BY d.blocked_by_id) FROM %s d WHERE d.item_id = ti.id), '[]') AS blocked_by_ids,
+		%s AS blocked,
+		(SELECT %s FROM %s te WHERE te.item_id = ti.id) AS tracked_minutes`,
+	commentsTable, itemAssigneesTable, checklistItemsTable, checklistItemsTable, itemDependenciesTable, itemBlockedCondition,
+	trackedMinutes, timeEntriesTable)
+
+// itemBlockedCondition - условие "у item ti есть невыполненные блокирующие items";
+// архивированные items не блокируют
+var itemBlockedCondition = fmt.Sprintf(`EXISTS (SELECT 1 FROM %s d INNER JOIN %s b ON b.id = d.blocked_by_id
+			WHERE d.item_id = ti.id AND b.done = false AND b.archived = false)`,
+	itemDependenciesTable, todoItemsTable)
+
 func NewTodoItemPostgres(db *sqlx.DB) *TodoItemPostgres {
 	return &TodoItemPostgres{db: db}
 }
 
+// WithRequest возвращает копию репозитория, изменения через которую записываются
+// в журнал аудита от имени автора запроса meta
+func (r *TodoItemPostgres) WithRequest(meta todo.RequestMeta) TodoItem {
+	scoped := *r
+	scoped.meta = meta
+	return &scoped
+}
+
+func (r *TodoItemPostgres) inTx(fn func(tx *sqlx.Tx) error) error {
+	return inAuditedTx(r.db, r.meta, fn)
+}
+
 func (r *TodoItemPostgres) Create(listId int, item todo.TodoItem) (int, error) {
-	tx, err := r.db.Begin()
+	var itemId int
+	err := r.inTx(func(tx *sqlx.Tx) (err error) {
+		itemId, err = insertItem(tx, listId, item)
+		return err
+	})
+
+	return itemId, err
+}
+
+// insertItem создает item и связывает его со списком в рамках транзакции tx
+func insertItem(tx sqlx.Ext, listId int, item todo.TodoItem) (int, error) {
+	statusId, done, err := initialStatus(tx, listId, item.StatusId)
 	if err != nil {
 		return 0, err
 	}
+	if item.SectionId != nil {
+		if err := checkSection(tx, listId, *item.SectionId); err != nil {
+			return 0, err
+		}
+	}
 
+	// Новый item встает в конец своего раздела
 	var itemId int
 	createItemQuery := fmt.Sprintf(`
-		INSERT INTO %s (title, description, done, archived, created_at, updated_at) 
-		VALUES ($1, $2, $3, $4, $5, $6) 
-		RETURNING id`, todoItemsTable)
+		INSERT INTO %s (title, description, done, archived, due_at, checklist_auto_complete, status_id, priority, estimate_minutes,
+			section_id, position, created_at, updated_at) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, (%s), $12, $13) 
+		RETURNING id`, todoItemsTable, fmt.Sprintf(sectionEndPosition, "$11", "$10"))
 
 	now := time.Now()
-	row := tx.QueryRow(createItemQuery,
+	row := tx.QueryRowx(createItemQuery,
 		item.Title,
 		item.Description,
-		false, // done по умолчанию false
+		done,  // false, если не задан закрытый статус
 		false, // archived по умолчанию false
-		now,   // created_at
-		now)   // updated_at
-
-	err = row.Scan(&itemId)
-	if err != nil {
-		tx.Rollback()
+		item.DueAt,
+		item.AutoComplete,
+		statusId,
+		item.Priority,
+		item.EstimateMinutes,
+		item.SectionId,
+		listId,
+		now, // created_at
+		now) // updated_at
+
+	if err := row.Scan(&itemId); err != nil {
 		return 0, err
 	}
 
 	createListItemsQuery := fmt.Sprintf("INSERT INTO %s (list_id, item_id) VALUES ($1, $2)", listsItemsTable)
-	_, err = tx.Exec(createListItemsQuery, listId, itemId)
-	if err != nil {
-		tx.Rollback()
+	if _, err := tx.Exec(createListItemsQuery, listId, itemId); err != nil {
 		return 0, err
 	}
 
-	return itemId, tx.Commit()
+	return itemId, recordItemChange(tx, todo.EventItemCreated, itemId)
+}
+
+// initialStatus возвращает статус нового item списка и done по его категории. Без статуса
+// item получает первый открытый статус workflow; у списка без workflow статуса нет
+func initialStatus(db sqlx.Queryer, listId int, statusId *int) (*int, bool, error) {
+	var status struct {
+		Id     int  `db:"id"`
+		Closed bool `db:"closed"`
+	}
+	query := fmt.Sprintf(`
+		SELECT id, category = $1 AS closed
+		FROM %s
+		WHERE list_id = $2 AND ($3::int IS NULL AND category <> $1 OR id = $3)
+		ORDER BY position
+		LIMIT 1`, listStatusesTable)
+	err := sqlx.Get(db, &status, query, todo.StatusCategoryClosed, listId, statusId)
+	if errors.Is(err, sql.ErrNoRows) {
+		if statusId != nil {
+			return nil, false, todo.ErrInvalidStatus
+		}
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &status.Id, status.Closed, nil
 }
 
 func (r *TodoItemPostgres) GetAll(userId, listId int) ([]todo.TodoItem, error) {
 	var items []todo.TodoItem
 	query := fmt.Sprintf(`
-		SELECT ti.id, ti.title, ti.description, ti.done, ti.archived, ti.created_at, ti.updated_at 
+		SELECT %s 
 		FROM %s ti 
 		INNER JOIN %s li on li.item_id = ti.id
 		INNER JOIN %s ul on ul.list_id = li.list_id 
-		WHERE li.list_id = $1 AND ul.user_id = $2 AND ti.archived = false`,
-		todoItemsTable, listsItemsTable, usersListsTable)
+		WHERE li.list_id = $1 AND ul.user_id = $2 AND ti.archived = false
+		ORDER BY ti.position, ti.id`,
+		itemColumns, todoItemsTable, listsItemsTable, usersListsTable)
 	if err := r.db.Select(&items, query, listId, userId); err != nil {
 		return nil, err
 	}
@@ -71,15 +156,19 @@ func (r *TodoItemPostgres) GetAll(userId, listId int) ([]todo.TodoItem, error) {
 }
 
 func (r *TodoItemPostgres) GetById(userId, itemId int) (todo.TodoItem, error) {
+	return getItemById(r.db, userId, itemId)
+}
+
+func getItemById(db sqlx.Queryer, userId, itemId int) (todo.TodoItem, error) {
 	var item todo.TodoItem
 	query := fmt.Sprintf(`
-		SELECT ti.id, ti.title, ti.description, ti.done, ti.archived, ti.created_at, ti.updated_at 
+		SELECT %s 
 		FROM %s ti 
 		INNER JOIN %s li on li.item_id = ti.id
 		INNER JOIN %s ul on ul.list_id = li.list_id 
 		WHERE ti.id = $1 AND ul.user_id = $2`,
-		todoItemsTable, listsItemsTable, usersListsTable)
-	if err := r.db.Get(&item, query, itemId, userId); err != nil {
+		itemColumns, todoItemsTable, listsItemsTable, usersListsTable)
+	if err := sqlx.Get(db, &item, query, itemId, userId); err != nil {
 		return item, err
 	}
 
@@ -87,16 +176,53 @@ func (r *TodoItemPostgres) GetById(userId, itemId int) (todo.TodoItem, error) {
 }
 
 func (r *TodoItemPostgres) Delete(userId, itemId int) error {
-	query := fmt.Sprintf(`
-		DELETE FROM %s ti 
-		USING %s li, %s ul 
-		WHERE ti.id = li.item_id AND li.list_id = ul.list_id AND ul.user_id = $1 AND ti.id = $2`,
-		todoItemsTable, listsItemsTable, usersListsTable)
-	_, err := r.db.Exec(query, userId, itemId)
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		return deleteItem(tx, userId, itemId, 0)
+	})
+	// Удаление недоступного item не считается ошибкой
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
 	return err
 }
 
 func (r *TodoItemPostgres) Update(userId, itemId int, input todo.UpdateItemInput) error {
+	return r.inTx(func(tx *sqlx.Tx) error {
+		_, err := updateItem(tx, userId, itemId, 0, input)
+		return err
+	})
+}
+
+// UpdateIfVersion обновляет item, только если его текущая версия равна version
+func (r *TodoItemPostgres) UpdateIfVersion(userId, itemId, version int, input todo.UpdateItemInput) error {
+	return r.inTx(func(tx *sqlx.Tx) error {
+		return updateItemInTx(tx, userId, itemId, version, input)
+	})
+}
+
+// updateItem выполняет обновление и записывает событие в outbox;
+// при version > 0 добавляет условие на версию строки
+func updateItem(db sqlx.Ext, userId, itemId, version int, input todo.UpdateItemInput) (int64, error) {
+	eventType := input.EventType()
+	completing := input.Done != nil && *input.Done
+	// Статус задает done по своей категории, даже если done передан
+	if input.StatusId != nil {
+		closed, err := isClosedStatus(db, itemId, *input.StatusId)
+		if err != nil {
+			return 0, err
+		}
+		completing = closed
+		if closed && eventType == todo.EventItemUpdated {
+			eventType = todo.EventItemCompleted
+		}
+	}
+
+	if completing && !input.Force {
+		if err := checkItemNotBlocked(db, userId, itemId); err != nil {
+			return 0, err
+		}
+	}
+
 	setValues := make([]string, 0)
 	args := make([]interface{}, 0)
 	argId := 1
@@ -125,8 +251,58 @@ func (r *TodoItemPostgres) Update(userId, itemId int, input todo.UpdateItemInput
 		argId++
 	}
 
-	// Всегда обновляем updated_at
-	setValues = append(setValues, fmt.Sprintf("updated_at=$%d", argId))
+	if input.DueAt.Set {
+		setValues = append(setValues, fmt.Sprintf("due_at=$%d", argId))
+		args = append(args, input.DueAt.Time)
+		argId++
+	}
+
+	if input.AutoComplete != nil {
+		setValues = append(setValues, fmt.Sprintf("checklist_auto_complete=$%d", argId))
+		args = append(args, *input.AutoComplete)
+		argId++
+	}
+
+	if input.StatusId != nil {
+		setValues = append(setValues, fmt.Sprintf("status_id=$%d", argId))
+		args = append(args, *input.StatusId)
+		argId++
+	}
+
+	if input.Priority.Set {
+		setValues = append(setValues, fmt.Sprintf("priority=$%d", argId))
+		args = append(args, input.Priority.Value)
+		argId++
+	}
+
+	if input.EstimateMinutes.Set {
+		setValues = append(setValues, fmt.Sprintf("estimate_minutes=$%d", argId))
+		args = append(args, input.EstimateMinutes.Value)
+		argId++
+	}
+
+	// Item, перенесенный в другой раздел, встает в его конец
+	if input.SectionId.Set {
+		if v := input.SectionId.Value; v != nil {
+			listId, err := itemListId(db, userId, itemId)
+			if err != nil {
+				return 0, err
+			}
+			if err := checkSection(db, listId, *v); err != nil {
+				return 0, err
+			}
+		}
+
+		section := fmt.Sprintf("$%d::int", argId)
+		setValues = append(setValues, "section_id="+section, fmt.Sprintf(
+			"position=CASE WHEN ti.section_id IS NOT DISTINCT FROM %s THEN ti.position ELSE (%s) END",
+			section, fmt.Sprintf(sectionEndPosition, "li.list_id", section)))
+		args = append(args, input.SectionId.Value)
+		argId++
+	}
+
+	// Всегда обновляем updated_at и версию
+	setValues = append(setValues, fmt.Sprintf("updated_at=$%d", argId), "version=ti.version+1")
 	args = append(args, time.Now())
 	argId++
 
@@ -139,56 +315,139 @@ func (r *TodoItemPostgres) Update(userId, itemId int, input todo.UpdateItemInput
 		todoItemsTable, setQuery, listsItemsTable, usersListsTable, argId, argId+1)
 	args = append(args, userId, itemId)
 
-	_, err := r.db.Exec(query, args...)
-	return err
+	if version > 0 {
+		query += fmt.Sprintf(" AND ti.version = $%d", argId+2)
+		args = append(args, version)
+	}
+
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil || updated == 0 {
+		return updated, err
+	}
+
+	return updated, recordItemChange(db, eventType, itemId)
 }
 
-// ArchiveItem - мягкое удаление item
-func (r *TodoItemPostgres) ArchiveItem(userId, itemId int) error {
+// isClosedStatus сообщает, закрытый ли статус; статус должен относиться к workflow
+// списка item, иначе возвращается todo.ErrInvalidStatus
+func isClosedStatus(db sqlx.Queryer, itemId, statusId int) (bool, error) {
+	var closed bool
 	query := fmt.Sprintf(`
-		UPDATE %s ti SET archived = true, updated_at = $1 
-		FROM %s li, %s ul
-		WHERE ti.id = li.item_id AND li.list_id = ul.list_id AND ul.user_id = $2 AND ti.id = $3`,
-		todoItemsTable, listsItemsTable, usersListsTable)
+		SELECT s.category = $1
+		FROM %s s
+		INNER JOIN %s li on li.list_id = s.list_id
+		WHERE s.id = $2 AND li.item_id = $3`,
+		listStatusesTable, listsItemsTable)
+	err := sqlx.Get(db, &closed, query, todo.StatusCategoryClosed, statusId, itemId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, todo.ErrInvalidStatus
+	}
 
-	_, err := r.db.Exec(query, time.Now(), userId, itemId)
-	return err
+	return closed, err
 }
 
-// GetAllWithPagination получает items с пагинацией
-func (r *TodoItemPostgres) GetAllWithPagination(userId, listId, offset, limit int, completed string) ([]todo.TodoItem, int, error) {
-	var items []todo.TodoItem
-
-	baseQuery := fmt.Sprintf(`
-		SELECT ti.id, ti.title, ti.description, ti.done, ti.archived, ti.created_at, ti.updated_at 
-		FROM %s ti 
+// checkItemNotBlocked возвращает todo.ErrItemBlocked, если невыполненный item
+// заблокирован невыполненными items. Уже выполненный item не проверяется,
+// чтобы повторная отметка не завершалась ошибкой
+func checkItemNotBlocked(db sqlx.Queryer, userId, itemId int) error {
+	var blocked bool
+	query := fmt.Sprintf(`
+		SELECT ti.done = false AND %s
+		FROM %s ti
 		INNER JOIN %s li on li.item_id = ti.id
-		INNER JOIN %s ul on ul.list_id = li.list_id 
-		WHERE ul.user_id = $1 AND ti.archived = false`,
-		todoItemsTable, listsItemsTable, usersListsTable)
+		INNER JOIN %s ul on ul.list_id = li.list_id
+		WHERE ti.id = $1 AND ul.user_id = $2`,
+		itemBlockedCondition, todoItemsTable, listsItemsTable, usersListsTable)
+	err := sqlx.Get(db, &blocked, query, itemId, userId)
+	// Недоступный item не обновится, ошибку вернет само обновление
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return todo.ErrItemBlocked
+	}
 
-	query := baseQuery
-	args := []interface{}{userId}
-	argId := 2
+	return nil
+}
 
-	if listId > 0 {
-		query += fmt.Sprintf(" AND li.list_id = $%d", argId)
-		args = append(args, listId)
-		argId++
+// DeleteIfVersion удаляет item, только если его текущая версия равна version
+func (r *TodoItemPostgres) DeleteIfVersion(userId, itemId, version int) error {
+	return r.inTx(func(tx *sqlx.Tx) error {
+		return deleteItem(tx, userId, itemId, version)
+	})
+}
+
+// deleteItem удаляет item; при version > 0 - только если версия совпадает.
+// Событие записывается в outbox до удаления, чтобы сохранить снимок item;
+// если удаление не состоялось, транзакция откатывается вместе с ним
+func deleteItem(db sqlx.Ext, userId, itemId, version int) error {
+	if err := recordItemChange(db, todo.EventItemDeleted, itemId); err != nil {
+		return err
 	}
 
-	if completed != "" {
-		isCompleted := completed == "true"
-		query += fmt.Sprintf(" AND ti.done = $%d", argId)
-		args = append(args, isCompleted)
-		argId++
+	query := fmt.Sprintf(`
+		DELETE FROM %s ti 
+		USING %s li, %s ul 
+		WHERE ti.id = li.item_id AND li.list_id = ul.list_id AND ul.user_id = $1 AND ti.id = $2 AND ($3 = 0 OR ti.version = $3)`,
+		todoItemsTable, listsItemsTable, usersListsTable)
+	result, err := db.Exec(query, userId, itemId, version)
+	if err != nil {
+		return err
 	}
 
-	query += " ORDER BY ti.created_at DESC"
-	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", argId, argId+1)
-	args = append(args, limit, offset)
+	if deleted, err := result.RowsAffected(); err != nil || deleted > 0 {
+		return err
+	}
+
+	return itemVersionConflict(db, userId, itemId)
+}
+
+// itemVersionConflict определяет причину того, что условная операция не затронула ни одной строки:
+// item недоступен (sql.ErrNoRows) или его версия изменилась
+func itemVersionConflict(db sqlx.Queryer, userId, itemId int) error {
+	if _, err := getItemById(db, userId, itemId); err != nil {
+		return err
+	}
+
+	return todo.ErrVersionMismatch
+}
+
+// ArchiveItem - мягкое удаление item
+func (r *TodoItemPostgres) ArchiveItem(userId, itemId int) error {
+	archived := true
+	return r.inTx(func(tx *sqlx.Tx) error {
+		_, err := updateItem(tx, userId, itemId, 0, todo.UpdateItemInput{Archived: &archived})
+		return err
+	})
+}
+
+// GetAllWithPagination получает items с пагинацией и фильтрами
+func (r *TodoItemPostgres) GetAllWithPagination(userId int, filter todo.ItemFilter, offset, limit int) ([]todo.TodoItem, int, error) {
+	items := make([]todo.TodoItem, 0)
 
-	err := r.db.Select(&items, query, args...)
+	conditions, args := itemFilterConditions(filter, 2)
+	args = append([]interface{}{userId}, args...)
+	argId := len(args) + 1
+
+	query := fmt.Sprintf(`
+		SELECT %s 
+		FROM %s ti 
+		INNER JOIN %s li on li.item_id = ti.id
+		INNER JOIN %s ul on ul.list_id = li.list_id 
+		WHERE ul.user_id = $1 AND ti.archived = false%s
+		ORDER BY ti.created_at DESC
+		LIMIT $%d OFFSET $%d`,
+		itemColumns, todoItemsTable, listsItemsTable, usersListsTable, conditions, argId, argId+1)
+
+	err := r.db.Select(&items, query, append(args, limit, offset)...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -199,42 +458,87 @@ func (r *TodoItemPostgres) GetAllWithPagination(userId, listId, offset, limit in
 		FROM %s ti 
 		INNER JOIN %s li on li.item_id = ti.id
 		INNER JOIN %s ul on ul.list_id = li.list_id 
-		WHERE ul.user_id = $1 AND ti.archived = false`,
-		todoItemsTable, listsItemsTable, usersListsTable)
+		WHERE ul.user_id = $1 AND ti.archived = false%s`,
+		todoItemsTable, listsItemsTable, usersListsTable, conditions)
+
+	var total int
+	err = r.db.Get(&total, countQuery, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return items, total, nil
+}
 
-	countArgs := []interface{}{userId}
-	countArgId := 2
+// itemFilterConditions возвращает условия фильтра, дописываемые к WHERE через AND,
+// и их аргументы; номера параметров начинаются с argId
+func itemFilterConditions(filter todo.ItemFilter, argId int) (string, []interface{}) {
+	var conditions strings.Builder
+	args := make([]interface{}, 0)
 
-	if listId > 0 {
-		countQuery += fmt.Sprintf(" AND li.list_id = $%d", countArgId)
-		countArgs = append(countArgs, listId)
-		countArgId++
+	if filter.ListId > 0 {
+		fmt.Fprintf(&conditions, " AND li.list_id = $%d", argId)
+		args = append(args, filter.ListId)
+		argId++
 	}
 
-	if completed != "" {
-		isCompleted := completed == "true"
-		countQuery += fmt.Sprintf(" AND ti.done = $%d", countArgId)
-		countArgs = append(countArgs, isCompleted)
-		countArgId++
+	if filter.Completed != "" {
+		fmt.Fprintf(&conditions, " AND ti.done = $%d", argId)
+		args = append(args, filter.Completed == "true")
+		argId++
 	}
 
-	var total int
-	err = r.db.Get(&total, countQuery, countArgs...)
-	if err != nil {
-		return nil, 0, err
+	if filter.AssigneeId > 0 {
+		fmt.Fprintf(&conditions, " AND EXISTS (SELECT 1 FROM %s a WHERE a.item_id = ti.id AND a.user_id = $%d)", itemAssigneesTable, argId)
+		args = append(args, filter.AssigneeId)
+		argId++
 	}
 
-	return items, total, nil
+	if filter.Blocked != "" {
+		conditions.WriteString(" AND " + blockedFilter(filter.Blocked))
+	}
+
+	if len(filter.StatusIds) > 0 {
+		fmt.Fprintf(&conditions, " AND ti.status_id = ANY($%d)", argId)
+		args = append(args, pq.Array(filter.StatusIds))
+		argId++
+	}
+
+	return conditions.String(), args
+}
+
+// blockedFilter возвращает условие фильтра items по наличию невыполненных блокирующих items
+func blockedFilter(blocked string) string {
+	if blocked == "true" {
+		return itemBlockedCondition
+	}
+	return "NOT " + itemBlockedCondition
 }
 
-// CompleteItem - отмечает item как выполненный
-func (r *TodoItemPostgres) CompleteItem(userId, itemId int) error {
+// CompleteItem - отмечает item как выполненный; force разрешает выполнить заблокированный item
+func (r *TodoItemPostgres) CompleteItem(userId, itemId int, force bool) error {
+	done := true
+	return r.inTx(func(tx *sqlx.Tx) error {
+		_, err := updateItem(tx, userId, itemId, 0, todo.UpdateItemInput{Done: &done, Force: force})
+		return err
+	})
+}
+
+// GetListId возвращает id списка, в котором находится item
+func (r *TodoItemPostgres) GetListId(userId, itemId int) (int, error) {
+	return itemListId(r.db, userId, itemId)
+}
+
+func itemListId(db sqlx.Queryer, userId, itemId int) (int, error) {
+	var listId int
 	query := fmt.Sprintf(`
-		UPDATE %s ti SET done = true, updated_at = $1 
-		FROM %s li, %s ul
-		WHERE ti.id = li.item_id AND li.list_id = ul.list_id AND ul.user_id = $2 AND ti.id = $3`,
-		todoItemsTable, listsItemsTable, usersListsTable)
+		SELECT li.list_id 
+		FROM %s li 
+		INNER JOIN %s ul on ul.list_id = li.list_id 
+		WHERE li.item_id = $1 AND ul.user_id = $2 
+		LIMIT 1`,
+		listsItemsTable, usersListsTable)
+	err := sqlx.Get(db, &listId, query, itemId, userId)
 
-	_, err := r.db.Exec(query, time.Now(), userId, itemId)
-	return err
+	return listId, err
 }
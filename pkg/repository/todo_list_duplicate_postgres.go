@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+)
+
+// Duplicate копирует список пользователя в одной транзакции и возвращает копию.
+// Items копируются набором запросов без обхода по одному: соответствие исходных
+// items и копий хранится во временной таблице до конца транзакции
+func (r *TodoListPostgres) Duplicate(userId, listId int, input todo.DuplicateListInput) (int, error) {
+	var copyId int
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		// Блокировка исходного списка не дает изменить его разделы и workflow во время копирования
+		if err := lockList(tx, userId, listId); err != nil {
+			return err
+		}
+
+		var source todo.TodoList
+		sourceQuery := fmt.Sprintf("SELECT title, description, color, priority FROM %s WHERE id = $1", todoListsTable)
+		if err := tx.Get(&source, sourceQuery, listId); err != nil {
+			return err
+		}
+		if input.Title != nil {
+			source.Title = *input.Title
+		}
+
+		var err error
+		if copyId, err = insertList(tx, userId, source); err != nil {
+			return err
+		}
+
+		if input.IncludeMembers {
+			membersQuery := fmt.Sprintf(`
+				INSERT INTO %s (user_id, list_id)
+				SELECT DISTINCT ul.user_id, $1::int FROM %s ul WHERE ul.list_id = $2 AND ul.user_id <> $3`,
+				usersListsTable, usersListsTable)
+			if _, err := tx.Exec(membersQuery, copyId, listId, userId); err != nil {
+				return err
+			}
+		}
+
+		// Статусы и разделы уникальны по позиции в списке, по ней копии и сопоставляются с исходными
+		now := time.Now()
+		statusesQuery := fmt.Sprintf(`
+			INSERT INTO %s (list_id, name, category, position)
+			SELECT $1, name, category, position FROM %s WHERE list_id = $2`,
+			listStatusesTable, listStatusesTable)
+		if _, err := tx.Exec(statusesQuery, copyId, listId); err != nil {
+			return err
+		}
+
+		sectionsQuery := fmt.Sprintf(`
+			INSERT INTO %s (list_id, name, position, created_at, updated_at)
+			SELECT $1, name, position, $3, $3 FROM %s WHERE list_id = $2`,
+			listSectionsTable, listSectionsTable)
+		if _, err := tx.Exec(sectionsQuery, copyId, listId, now); err != nil {
+			return err
+		}
+
+		mapQuery := fmt.Sprintf(`
+			CREATE TEMPORARY TABLE item_copies ON COMMIT DROP AS
+			SELECT ti.id AS source_id, nextval(pg_get_serial_sequence('%s', 'id'))::int AS id
+			FROM %s ti
+			INNER JOIN %s li on li.item_id = ti.id
+			WHERE li.list_id = $1 AND ($2 OR NOT ti.done) AND ($3 OR NOT ti.archived)`,
+			todoItemsTable, todoItemsTable, listsItemsTable)
+		if _, err := tx.Exec(mapQuery, listId, input.IncludeCompleted, input.IncludeArchived); err != nil {
+			return err
+		}
+
+		// При сбросе выполнения item закрытого статуса получает первый открытый статус копии
+		itemsQuery := fmt.Sprintf(`
+			INSERT INTO %s (id, title, description, done, archived, due_at, checklist_auto_complete, status_id,
+				priority, estimate_minutes, section_id, position, created_at, updated_at)
+			SELECT c.id, ti.title, ti.description, ti.done AND NOT $3, ti.archived, ti.due_at, ti.checklist_auto_complete,
+				CASE WHEN $3 AND os.category = $4 THEN (
+					SELECT s.id FROM %s s WHERE s.list_id = $1 AND s.category <> $4 ORDER BY s.position LIMIT 1)
+				ELSE ns.id END,
+				ti.priority, ti.estimate_minutes, nsec.id, ti.position, $2, $2
+			FROM item_copies c
+			INNER JOIN %s ti on ti.id = c.source_id
+			LEFT JOIN %s os on os.id = ti.status_id
+			LEFT JOIN %s ns on ns.list_id = $1 AND ns.position = os.position
+			LEFT JOIN %s osec on osec.id = ti.section_id
+			LEFT JOIN %s nsec on nsec.list_id = $1 AND nsec.position = osec.position`,
+			todoItemsTable, listStatusesTable, todoItemsTable, listStatusesTable, listStatusesTable,
+			listSectionsTable, listSectionsTable)
+		if _, err := tx.Exec(itemsQuery, copyId, now, input.ResetDone, todo.StatusCategoryClosed); err != nil {
+			return err
+		}
+
+		listItemsQuery := fmt.Sprintf("INSERT INTO %s (list_id, item_id) SELECT $1, id FROM item_copies", listsItemsTable)
+		if _, err := tx.Exec(listItemsQuery, copyId); err != nil {
+			return err
+		}
+
+		checklistQuery := fmt.Sprintf(`
+			INSERT INTO %s (item_id, position, text, checked, created_at, updated_at)
+			SELECT c.id, cl.position, cl.text, cl.checked AND NOT $1, $2, $2
+			FROM %s cl
+			INNER JOIN item_copies c on c.source_id = cl.item_id`,
+			checklistItemsTable, checklistItemsTable)
+		if _, err := tx.Exec(checklistQuery, input.ResetDone, now); err != nil {
+			return err
+		}
+
+		// Зависимость копируется, только если скопированы оба item
+		dependenciesQuery := fmt.Sprintf(`
+			INSERT INTO %s (item_id, blocked_by_id, created_by, created_at)
+			SELECT ci.id, cb.id, $1, $2
+			FROM %s d
+			INNER JOIN item_copies ci on ci.source_id = d.item_id
+			INNER JOIN item_copies cb on cb.source_id = d.blocked_by_id`,
+			itemDependenciesTable, itemDependenciesTable)
+		if _, err := tx.Exec(dependenciesQuery, userId, now); err != nil {
+			return err
+		}
+
+		// Ответственным остается только пользователь с доступом к копии
+		assigneesQuery := fmt.Sprintf(`
+			INSERT INTO %s (item_id, user_id, assigned_by, assigned_at)
+			SELECT c.id, a.user_id, a.assigned_by, a.assigned_at
+			FROM %s a
+			INNER JOIN item_copies c on c.source_id = a.item_id
+			WHERE EXISTS (SELECT 1 FROM %s ul WHERE ul.list_id = $1 AND ul.user_id = a.user_id)`,
+			itemAssigneesTable, itemAssigneesTable, usersListsTable)
+		if _, err := tx.Exec(assigneesQuery, copyId); err != nil {
+			return err
+		}
+
+		itemIds := make([]int, 0)
+		if err := tx.Select(&itemIds, "SELECT id FROM item_copies"); err != nil {
+			return err
+		}
+
+		return recordItemsChange(tx, todo.EventItemCreated, itemIds)
+	})
+
+	return copyId, err
+}
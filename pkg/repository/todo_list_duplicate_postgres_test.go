@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/ktuty/todo-app"
+)
+
+func TestDuplicateList(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	member := createTestUser(t, db, "member")
+	listId := createTestList(t, db, owner, "source")
+	open := createTestItem(t, db, listId, "open")
+	done := createTestItem(t, db, listId, "done")
+	archived := createTestItem(t, db, listId, "archived")
+	addTestMember(t, db, owner, listId, "member")
+	lists := NewTodoListPostgres(db)
+	items := NewTodoItemPostgres(db)
+
+	statuses, err := lists.SetWorkflow(owner, listId, []todo.StatusInput{
+		{Name: "Todo", Category: todo.StatusCategoryOpen},
+		{Name: "Done", Category: todo.StatusCategoryClosed},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	section, err := lists.CreateSection(owner, listId, todo.SectionInput{Name: "Section"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := items.MoveItems(owner, listId, &section.Id, []int{done}); err != nil {
+		t.Fatal(err)
+	}
+	if err := items.CompleteItem(owner, done, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := items.ArchiveItem(owner, archived); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := items.AddChecklistItem(owner, done, todo.ChecklistItemInput{Text: "step", Checked: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := items.AddDependency(owner, open, done); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := items.AddDependency(owner, open, archived); err != nil {
+		t.Fatal(err)
+	}
+	for _, userId := range []int{owner, member} {
+		if _, err := items.Assign(owner, open, userId); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	webhookId, err := NewWebhookPostgres(db).Create(owner, todo.Webhook{
+		URL:    "https://example.com/hook",
+		Secret: "secret-secret-secret",
+		Events: []string{todo.EventItemCreated},
+		Active: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	title := "copy"
+	input := todo.DuplicateListInput{Title: &title, IncludeCompleted: true, ResetDone: true}
+	copyId, err := lists.WithRequest(todo.RequestMeta{ActorId: owner}).Duplicate(owner, listId, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copied, err := items.GetAll(owner, copyId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byTitle := make(map[string]todo.TodoItem, len(copied))
+	for _, item := range copied {
+		byTitle[item.Title] = item
+	}
+	if len(copied) != 2 || byTitle["archived"].Id != 0 {
+		t.Fatalf("copied items = %+v, want open and done without archived", copied)
+	}
+
+	copyStatuses, err := lists.GetWorkflow(owner, copyId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copySections, err := lists.GetSections(owner, copyId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(copyStatuses) != len(statuses) || len(copySections) != 1 {
+		t.Fatalf("copy statuses %+v, sections %+v", copyStatuses, copySections)
+	}
+
+	// Сброс выполнения переводит item в первый открытый статус копии и снимает отметки чек-листа
+	doneCopy := byTitle["done"]
+	if doneCopy.Done || doneCopy.StatusId == nil || *doneCopy.StatusId != copyStatuses[0].Id {
+		t.Errorf("done copy: done %v, status %v", doneCopy.Done, doneCopy.StatusId)
+	}
+	if doneCopy.SectionId == nil || *doneCopy.SectionId != copySections[0].Id {
+		t.Errorf("done copy section = %v, want %d", doneCopy.SectionId, copySections[0].Id)
+	}
+	if doneCopy.ChecklistTotal != 1 || doneCopy.ChecklistChecked != 0 {
+		t.Errorf("done copy checklist = %d/%d", doneCopy.ChecklistChecked, doneCopy.ChecklistTotal)
+	}
+
+	// Зависимость от нескопированного item и ответственный без доступа к копии не переносятся
+	openCopy := byTitle["open"]
+	if len(openCopy.BlockedByIds) != 1 || openCopy.BlockedByIds[0] != doneCopy.Id {
+		t.Errorf("open copy blocked by %v, want [%d]", openCopy.BlockedByIds, doneCopy.Id)
+	}
+	if len(openCopy.AssigneeIds) != 1 || openCopy.AssigneeIds[0] != owner {
+		t.Errorf("open copy assignees = %v, want [%d]", openCopy.AssigneeIds, owner)
+	}
+
+	// Аудит и webhooks item.created пишутся в транзакции копирования
+	if rows := getAuditRows(t, db, todo.EntityItem, openCopy.Id); len(rows) != 1 || rows[0].Action != todo.EventItemCreated || rows[0].ActorId != owner {
+		t.Errorf("open copy audit = %+v", rows)
+	}
+	if count := countDeliveries(t, db, webhookId); count != 2 {
+		t.Errorf("item.created deliveries = %d, want 2", count)
+	}
+
+	// Исходный список не меняется
+	source, err := items.GetById(owner, done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !source.Done || source.ChecklistChecked != 1 {
+		t.Errorf("source item changed: done %v, checklist %d", source.Done, source.ChecklistChecked)
+	}
+}
+
+func TestDuplicateListMembers(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	member := createTestUser(t, db, "member")
+	listId := createTestList(t, db, owner, "source")
+	itemId := createTestItem(t, db, listId, "item")
+	addTestMember(t, db, owner, listId, "member")
+	lists := NewTodoListPostgres(db)
+	items := NewTodoItemPostgres(db)
+
+	if _, err := items.Assign(owner, itemId, member); err != nil {
+		t.Fatal(err)
+	}
+
+	copyId, err := lists.Duplicate(owner, listId, todo.DuplicateListInput{IncludeCompleted: true, IncludeMembers: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	duplicate, err := lists.GetById(member, copyId)
+	if err != nil {
+		t.Fatalf("member has no access to the copy: %s", err.Error())
+	}
+	if duplicate.Title != "source" {
+		t.Errorf("copy title = %q, want source", duplicate.Title)
+	}
+
+	copied, err := items.GetAll(member, copyId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(copied) != 1 || len(copied[0].AssigneeIds) != 1 || copied[0].AssigneeIds[0] != member {
+		t.Errorf("copied items = %+v, want the member assigned", copied)
+	}
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+)
+
+// Merge переносит в список items списка-источника в одной транзакции и возвращает
+// перенесенные items. Участники источника получают доступ к списку, разделы источника
+// присоединяются к разделам списка с тем же названием или добавляются в конец, а items
+// сохраняют порядок после items списка. Источник затем архивируется или удаляется
+func (r *TodoListPostgres) Merge(userId, listId, sourceId int, deleteSource bool) ([]int, error) {
+	itemIds := make([]int, 0)
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		// Списки блокируются в порядке id, чтобы встречные слияния не ждали друг друга бесконечно
+		first, second := listId, sourceId
+		if first > second {
+			first, second = second, first
+		}
+		if err := lockList(tx, userId, first); err != nil {
+			return err
+		}
+		if err := lockList(tx, userId, second); err != nil {
+			return err
+		}
+
+		membersQuery := fmt.Sprintf(`
+			INSERT INTO %s (user_id, list_id)
+			SELECT DISTINCT ul.user_id, $1::int FROM %s ul
+			WHERE ul.list_id = $2 AND NOT EXISTS (SELECT 1 FROM %s t WHERE t.list_id = $1 AND t.user_id = ul.user_id)`,
+			usersListsTable, usersListsTable, usersListsTable)
+		if _, err := tx.Exec(membersQuery, listId, sourceId); err != nil {
+			return err
+		}
+
+		// Раздел источника сопоставляется с разделом списка по названию без учета регистра
+		now := time.Now()
+		mapQuery := fmt.Sprintf(`
+			CREATE TEMPORARY TABLE section_merges ON COMMIT DROP AS
+			SELECT s.id AS source_id, s.position, (
+				SELECT t.id FROM %s t WHERE t.list_id = $1 AND lower(t.name) = lower(s.name) ORDER BY t.position LIMIT 1
+			) AS target_id
+			FROM %s s
+			WHERE s.list_id = $2`,
+			listSectionsTable, listSectionsTable)
+		if _, err := tx.Exec(mapQuery, listId, sourceId); err != nil {
+			return err
+		}
+
+		moveSectionsQuery := fmt.Sprintf(`
+			UPDATE %s s SET list_id = $1, position = o.position, updated_at = $2
+			FROM (
+				SELECT m.source_id, (SELECT coalesce(max(t.position) + 1, 0) FROM %s t WHERE t.list_id = $1)
+					+ row_number() OVER (ORDER BY m.position) - 1 AS position
+				FROM section_merges m
+				WHERE m.target_id IS NULL
+			) o
+			WHERE s.id = o.source_id`,
+			listSectionsTable, listSectionsTable)
+		if _, err := tx.Exec(moveSectionsQuery, listId, now); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE section_merges SET target_id = source_id WHERE target_id IS NULL"); err != nil {
+			return err
+		}
+
+		// Items раздела встают после items того же раздела списка и нумеруются подряд:
+		// сначала по порядку разделов источника, затем по позиции в разделе. Статус
+		// выбирается так же, как при переносе одного item. Запрос видит items источника
+		// еще в источнике, поэтому конец раздела считается только по items списка
+		itemsQuery := fmt.Sprintf(`
+			UPDATE %s ti SET updated_at = $2, version = ti.version + 1,
+				status_id = (
+					SELECT s.id FROM %s s
+					WHERE s.list_id = $1 AND (s.category = $4) = ti.done
+					ORDER BY lower(s.name) = (SELECT lower(c.name) FROM %s c WHERE c.id = ti.status_id) DESC NULLS LAST, s.position
+					LIMIT 1),
+				section_id = o.section_id,
+				position = o.position
+			FROM (
+				SELECT i.id, m.target_id AS section_id, (%s)
+					+ row_number() OVER (PARTITION BY m.target_id ORDER BY m.position NULLS FIRST, i.position, i.id) - 1 AS position
+				FROM %s i
+				INNER JOIN %s li ON li.item_id = i.id
+				LEFT JOIN section_merges m ON m.source_id = i.section_id
+				WHERE li.list_id = $3
+			) o
+			WHERE ti.id = o.id
+			RETURNING ti.id`,
+			todoItemsTable, listStatusesTable, listStatusesTable,
+			fmt.Sprintf(sectionEndPosition, "$1", "m.target_id"),
+			todoItemsTable, listsItemsTable)
+		if err := tx.Select(&itemIds, itemsQuery, listId, now, sourceId, todo.StatusCategoryClosed); err != nil {
+			return err
+		}
+
+		listItemsQuery := fmt.Sprintf("UPDATE %s SET list_id = $1 WHERE list_id = $2", listsItemsTable)
+		if _, err := tx.Exec(listItemsQuery, listId, sourceId); err != nil {
+			return err
+		}
+
+		// Присоединенные разделы источника опустели
+		sectionsQuery := fmt.Sprintf("DELETE FROM %s WHERE list_id = $1", listSectionsTable)
+		if _, err := tx.Exec(sectionsQuery, sourceId); err != nil {
+			return err
+		}
+
+		// Слияние записывается в журнал аудита как list.merged с перенесенными items,
+		// webhooks списка получают list.updated
+		if err := bumpListVersion(tx, listId); err != nil {
+			return err
+		}
+		details := map[string]interface{}{"source_list_id": sourceId, "item_ids": itemIds}
+		if err := auditList(tx, todo.EventListMerged, listId, details); err != nil {
+			return err
+		}
+		if err := enqueueListWebhooks(tx, todo.EventListUpdated, listId); err != nil {
+			return err
+		}
+		if err := recordItemsChange(tx, todo.EventItemMoved, itemIds); err != nil {
+			return err
+		}
+
+		if deleteSource {
+			_, err := deleteList(tx, userId, sourceId, 0)
+			return err
+		}
+
+		archived := true
+		_, err := updateList(tx, userId, sourceId, 0, todo.UpdateListInput{Archived: &archived})
+		return err
+	})
+
+	return itemIds, err
+}
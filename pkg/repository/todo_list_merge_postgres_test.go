@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+)
+
+// getTestItemPositions возвращает позиции items раздела списка по порядку
+func getTestItemPositions(t *testing.T, db *sqlx.DB, listId, sectionId int) []int {
+	t.Helper()
+
+	positions := make([]int, 0)
+	query := `
+		SELECT ti.position FROM todo_items ti INNER JOIN lists_items li on li.item_id = ti.id
+		WHERE li.list_id = $1 AND coalesce(ti.section_id, 0) = $2
+		ORDER BY ti.position, ti.id`
+	if err := db.Select(&positions, query, listId, sectionId); err != nil {
+		t.Fatal(err)
+	}
+	return positions
+}
+
+func TestMergeList(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	member := createTestUser(t, db, "member")
+	listId := createTestList(t, db, owner, "target")
+	sourceId := createTestList(t, db, owner, "source")
+	addTestMember(t, db, owner, sourceId, "member")
+	lists := NewTodoListPostgres(db)
+	items := NewTodoItemPostgres(db)
+
+	createSection := func(listId int, name string) int {
+		t.Helper()
+		section, err := lists.CreateSection(owner, listId, todo.SectionInput{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return section.Id
+	}
+	moveItems := func(listId, sectionId int, ids ...int) {
+		t.Helper()
+		if _, err := items.MoveItems(owner, listId, &sectionId, ids); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loose := createTestItem(t, db, listId, "loose")
+	targetNow := createSection(listId, "Now")
+	current := createTestItem(t, db, listId, "current")
+	moveItems(listId, targetNow, current)
+
+	// Два раздела источника с одним названием присоединяются к одному разделу списка
+	sourceNow := createSection(sourceId, "now")
+	sourceLater := createSection(sourceId, "Later")
+	sourceNOW := createSection(sourceId, "NOW")
+	first := createTestItem(t, db, sourceId, "first")
+	second := createTestItem(t, db, sourceId, "second")
+	third := createTestItem(t, db, sourceId, "third")
+	later := createTestItem(t, db, sourceId, "later")
+	looseFirst := createTestItem(t, db, sourceId, "loose first")
+	looseSecond := createTestItem(t, db, sourceId, "loose second")
+	moveItems(sourceId, sourceNow, first, second)
+	moveItems(sourceId, sourceNOW, third)
+	moveItems(sourceId, sourceLater, later)
+
+	webhookId, err := NewWebhookPostgres(db).Create(owner, todo.Webhook{
+		ListId: &listId,
+		URL:    "https://example.com/hook",
+		Secret: "secret-secret-secret",
+		Events: []string{todo.EventItemMoved},
+		Active: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	itemIds, err := lists.WithRequest(todo.RequestMeta{ActorId: owner}).Merge(owner, listId, sourceId, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(itemIds) != 6 {
+		t.Fatalf("moved %v, want 6 items", itemIds)
+	}
+
+	// Items встают после items раздела списка по порядку разделов источника, позиции идут подряд
+	if got := getTestSectionItems(t, db, listId, targetNow); !reflect.DeepEqual(got, []int{current, first, second, third}) {
+		t.Errorf("Now = %v, want [%d %d %d %d]", got, current, first, second, third)
+	}
+	if got := getTestItemPositions(t, db, listId, targetNow); !reflect.DeepEqual(got, []int{0, 1, 2, 3}) {
+		t.Errorf("Now positions = %v", got)
+	}
+	if got := getTestSectionItems(t, db, listId, 0); !reflect.DeepEqual(got, []int{loose, looseFirst, looseSecond}) {
+		t.Errorf("without section = %v, want [%d %d %d]", got, loose, looseFirst, looseSecond)
+	}
+	if got := getTestItemPositions(t, db, listId, 0); !reflect.DeepEqual(got, []int{0, 1, 2}) {
+		t.Errorf("positions without section = %v", got)
+	}
+
+	// Раздел без пары переносится в конец разделов списка, присоединенные удаляются
+	sections, err := lists.GetSections(owner, listId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 2 || sections[0].Id != targetNow || sections[1].Id != sourceLater || sections[1].Position != 1 {
+		t.Fatalf("sections = %+v, want Now, Later", sections)
+	}
+	if got := getTestSectionItems(t, db, listId, sourceLater); !reflect.DeepEqual(got, []int{later}) {
+		t.Errorf("Later = %v, want [%d]", got, later)
+	}
+
+	// Участник источника получает доступ к списку, источник архивируется
+	if _, err := lists.GetById(member, listId); err != nil {
+		t.Errorf("member: %v", err)
+	}
+	source, err := lists.GetById(owner, sourceId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !source.Archived {
+		t.Error("source is not archived")
+	}
+
+	rows := getAuditRows(t, db, todo.EntityItem, third)
+	if moved := rows[len(rows)-1]; moved.Action != todo.EventItemMoved || moved.ListId != listId {
+		t.Errorf("item row: action %s, list %d", moved.Action, moved.ListId)
+	}
+	if count := countDeliveries(t, db, webhookId); count != len(itemIds) {
+		t.Errorf("deliveries = %d, want %d", count, len(itemIds))
+	}
+
+	// С delete_source источник удаляется
+	otherId := createTestList(t, db, owner, "other")
+	createTestItem(t, db, otherId, "other")
+	if _, err := lists.Merge(owner, listId, otherId, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lists.GetById(owner, otherId); err == nil {
+		t.Error("source was not deleted")
+	}
+	if got := getTestItemPositions(t, db, listId, 0); !reflect.DeepEqual(got, []int{0, 1, 2, 3}) {
+		t.Errorf("positions after second merge = %v", got)
+	}
+}
+
+func TestMergeListAccess(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	outsider := createTestUser(t, db, "outsider")
+	listId := createTestList(t, db, owner, "target")
+	foreignId := createTestList(t, db, outsider, "foreign")
+	itemId := createTestItem(t, db, foreignId, "foreign")
+
+	if _, err := NewTodoListPostgres(db).Merge(owner, listId, foreignId, false); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("foreign source: err = %v, want no rows", err)
+	}
+	var gotListId int
+	if err := db.Get(&gotListId, "SELECT list_id FROM lists_items WHERE item_id = $1", itemId); err != nil {
+		t.Fatal(err)
+	}
+	if gotListId != foreignId {
+		t.Errorf("item moved to list %d", gotListId)
+	}
+}
+
+func TestMergeListOverlappingMembers(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	alice := createTestUser(t, db, "alice")
+	bob := createTestUser(t, db, "bob")
+	carol := createTestUser(t, db, "carol")
+	listId := createTestList(t, db, owner, "target")
+	sourceId := createTestList(t, db, owner, "source")
+	addTestMember(t, db, owner, listId, "alice")
+	addTestMember(t, db, owner, listId, "carol")
+	addTestMember(t, db, owner, sourceId, "alice")
+	addTestMember(t, db, owner, sourceId, "bob")
+	first := createTestItem(t, db, listId, "first")
+	second := createTestItem(t, db, listId, "second")
+	third := createTestItem(t, db, sourceId, "third")
+	fourth := createTestItem(t, db, sourceId, "fourth")
+	lists := NewTodoListPostgres(db)
+
+	if _, err := lists.Merge(owner, listId, sourceId, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Общие участники не получают второй доступ, участники только источника добавляются
+	var rows []struct {
+		UserId int `db:"user_id"`
+		Count  int `db:"count"`
+	}
+	query := "SELECT user_id, COUNT(*) AS count FROM users_lists WHERE list_id = $1 GROUP BY user_id ORDER BY user_id"
+	if err := db.Select(&rows, query, listId); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("members = %+v, want owner, alice, bob and carol", rows)
+	}
+	for _, row := range rows {
+		if row.Count != 1 {
+			t.Errorf("user %d has %d users_lists rows, want 1", row.UserId, row.Count)
+		}
+	}
+
+	// Создателем списка остается его создатель: участники источника получают доступ позже
+	if creatorId, err := listCreator(db, listId); err != nil || creatorId != owner {
+		t.Errorf("creator = %d, err = %v, want %d", creatorId, err, owner)
+	}
+	for _, userId := range []int{alice, bob, carol} {
+		if _, err := lists.GetById(userId, listId); err != nil {
+			t.Errorf("user %d: %v", userId, err)
+		}
+	}
+
+	if got := getTestSectionItems(t, db, listId, 0); !reflect.DeepEqual(got, []int{first, second, third, fourth}) {
+		t.Errorf("items = %v, want [%d %d %d %d]", got, first, second, third, fourth)
+	}
+	if got := getTestItemPositions(t, db, listId, 0); !reflect.DeepEqual(got, []int{0, 1, 2, 3}) {
+		t.Errorf("positions = %v", got)
+	}
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -11,19 +13,46 @@ import (
 )
 
 type TodoListPostgres struct {
-	db *sqlx.DB
+	db   *sqlx.DB
+	meta todo.RequestMeta
 }
 
+// listColumns - колонки выборки списков (псевдоним tl) с папкой из строки доступа пользователя ul;
+// учтенное время по items вычисляется подзапросом
+var listColumns = fmt.Sprintf(`tl.id, tl.title, tl.description, tl.archived, tl.created_at, tl.updated_at, tl.color, tl.priority, tl.version,
+		ul.folder_id,
+		(SELECT %s FROM %s te INNER JOIN %s li on li.item_id = te.item_id WHERE li.list_id = tl.id) AS tracked_minutes`,
+	trackedMinutes, timeEntriesTable, listsItemsTable)
+
 func NewTodoListPostgres(db *sqlx.DB) *TodoListPostgres {
 	return &TodoListPostgres{db: db}
 }
 
+// WithRequest возвращает копию репозитория, изменения через которую записываются
+// в журнал аудита от имени автора запроса meta
+func (r *TodoListPostgres) WithRequest(meta todo.RequestMeta) TodoList {
+	scoped := *r
+	scoped.meta = meta
+	return &scoped
+}
+
+func (r *TodoListPostgres) inTx(fn func(tx *sqlx.Tx) error) error {
+	return inAuditedTx(r.db, r.meta, fn)
+}
+
 func (r *TodoListPostgres) Create(userId int, list todo.TodoList) (int, error) {
-	tx, err := r.db.Begin()
-	if err != nil {
-		return 0, err
-	}
+	var id int
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		var err error
+		id, err = insertList(tx, userId, list)
+		return err
+	})
+
+	return id, err
+}
 
+// insertList создает список и дает к нему доступ пользователю в рамках транзакции tx
+func insertList(tx *sqlx.Tx, userId int, list todo.TodoList) (int, error) {
 	var id int
 	createListQuery := fmt.Sprintf(`
 		INSERT INTO %s (title, description, archived, created_at, updated_at, color, priority) 
@@ -41,29 +70,26 @@ func (r *TodoListPostgres) Create(userId int, list todo.TodoList) (int, error) {
 		list.Priority)
 
 	if err := row.Scan(&id); err != nil {
-		tx.Rollback()
 		return 0, err
 	}
 
 	createUsersListQuery := fmt.Sprintf("INSERT INTO %s (user_id, list_id) VALUES ($1, $2)", usersListsTable)
-	_, err = tx.Exec(createUsersListQuery, userId, id)
-	if err != nil {
-		tx.Rollback()
+	if _, err := tx.Exec(createUsersListQuery, userId, id); err != nil {
 		return 0, err
 	}
 
-	return id, tx.Commit()
+	return id, auditList(tx, todo.EventListCreated, id, nil)
 }
 
 func (r *TodoListPostgres) GetAll(userId int) ([]todo.TodoList, error) {
 	var lists []todo.TodoList
 
 	query := fmt.Sprintf(`
-		SELECT tl.id, tl.title, tl.description, tl.archived, tl.created_at, tl.updated_at, tl.color, tl.priority 
+		SELECT %s
 		FROM %s tl 
 		INNER JOIN %s ul on tl.id = ul.list_id 
 		WHERE ul.user_id = $1 AND tl.archived = false`,
-		todoListsTable, usersListsTable)
+		listColumns, todoListsTable, usersListsTable)
 	err := r.db.Select(&lists, query, userId)
 
 	return lists, err
@@ -73,28 +99,50 @@ func (r *TodoListPostgres) GetById(userId, listId int) (todo.TodoList, error) {
 	var list todo.TodoList
 
 	query := fmt.Sprintf(`
-		SELECT tl.id, tl.title, tl.description, tl.archived, tl.created_at, tl.updated_at, tl.color, tl.priority 
+		SELECT %s
 		FROM %s tl
 		INNER JOIN %s ul on tl.id = ul.list_id 
 		WHERE ul.user_id = $1 AND ul.list_id = $2`,
-		todoListsTable, usersListsTable)
+		listColumns, todoListsTable, usersListsTable)
 	err := r.db.Get(&list, query, userId, listId)
 
 	return list, err
 }
 
 func (r *TodoListPostgres) Delete(userId, listId int) error {
-	query := fmt.Sprintf(`
-		DELETE FROM %s tl 
-		USING %s ul 
-		WHERE tl.id = ul.list_id AND ul.user_id=$1 AND ul.list_id=$2`,
-		todoListsTable, usersListsTable)
-	_, err := r.db.Exec(query, userId, listId)
-
-	return err
+	return r.inTx(func(tx *sqlx.Tx) error {
+		_, err := deleteList(tx, userId, listId, 0)
+		return err
+	})
 }
 
 func (r *TodoListPostgres) Update(userId, listId int, input todo.UpdateListInput) error {
+	return r.inTx(func(tx *sqlx.Tx) error {
+		_, err := updateList(tx, userId, listId, 0, input)
+		return err
+	})
+}
+
+// UpdateIfVersion обновляет список, только если его текущая версия равна version
+func (r *TodoListPostgres) UpdateIfVersion(userId, listId, version int, input todo.UpdateListInput) error {
+	var updated int64
+	err := r.inTx(func(tx *sqlx.Tx) (err error) {
+		updated, err = updateList(tx, userId, listId, version, input)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return r.versionConflict(userId, listId)
+	}
+
+	return nil
+}
+
+// updateList выполняет обновление и записывает событие в outbox;
+// при version > 0 добавляет условие на версию строки
+func updateList(db sqlx.Execer, userId, listId, version int, input todo.UpdateListInput) (int64, error) {
 	setValues := make([]string, 0)
 	args := make([]interface{}, 0)
 	argId := 1
@@ -129,8 +177,8 @@ func (r *TodoListPostgres) Update(userId, listId int, input todo.UpdateListInput
 		argId++
 	}
 
-	// Всегда обновляем updated_at
-	setValues = append(setValues, fmt.Sprintf("updated_at=$%d", argId))
+	// Всегда обновляем updated_at и версию
+	setValues = append(setValues, fmt.Sprintf("updated_at=$%d", argId), "version=tl.version+1")
 	args = append(args, time.Now())
 	argId++
 
@@ -143,23 +191,90 @@ func (r *TodoListPostgres) Update(userId, listId int, input todo.UpdateListInput
 		todoListsTable, setQuery, usersListsTable, argId, argId+1)
 	args = append(args, listId, userId)
 
+	if version > 0 {
+		query += fmt.Sprintf(" AND tl.version=$%d", argId+2)
+		args = append(args, version)
+	}
+
 	logrus.Debugf("updateQuery: %s", query)
 	logrus.Debugf("args: %s", args)
 
-	_, err := r.db.Exec(query, args...)
-	return err
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil || updated == 0 {
+		return updated, err
+	}
+
+	return updated, recordListChange(db, input.EventType(), listId)
 }
 
-// ArchiveList - мягкое удаление списка
-func (r *TodoListPostgres) ArchiveList(userId, listId int) error {
-	query := fmt.Sprintf(`
-		UPDATE %s tl SET archived = true, updated_at = $1 
-		FROM %s ul 
-		WHERE tl.id = ul.list_id AND ul.user_id=$2 AND ul.list_id=$3`,
+// DeleteIfVersion удаляет список, только если его текущая версия равна version
+func (r *TodoListPostgres) DeleteIfVersion(userId, listId, version int) error {
+	var deleted int64
+	err := r.inTx(func(tx *sqlx.Tx) (err error) {
+		deleted, err = deleteList(tx, userId, listId, version)
+		return err
+	})
+	if err != nil || deleted > 0 {
+		return err
+	}
+
+	return r.versionConflict(userId, listId)
+}
+
+// deleteList удаляет список; при version > 0 - только если версия совпадает.
+// Событие записывается в outbox до удаления, чтобы сохранить снимок списка,
+// поэтому строка списка сначала блокируется с проверкой доступа и версии
+func deleteList(tx *sqlx.Tx, userId, listId, version int) (int64, error) {
+	var id int
+	lockQuery := fmt.Sprintf(`
+		SELECT tl.id FROM %s tl 
+		INNER JOIN %s ul on tl.id = ul.list_id 
+		WHERE ul.user_id=$1 AND ul.list_id=$2 AND ($3 = 0 OR tl.version=$3) 
+		LIMIT 1 
+		FOR UPDATE OF tl`,
 		todoListsTable, usersListsTable)
+	if err := tx.Get(&id, lockQuery, userId, listId, version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	if err := recordListChange(tx, todo.EventListDeleted, listId); err != nil {
+		return 0, err
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", todoListsTable)
+	result, err := tx.Exec(query, listId)
+	if err != nil {
+		return 0, err
+	}
 
-	_, err := r.db.Exec(query, time.Now(), userId, listId)
-	return err
+	return result.RowsAffected()
+}
+
+// versionConflict определяет причину того, что условная операция не затронула ни одной строки:
+// список недоступен (sql.ErrNoRows) или его версия изменилась
+func (r *TodoListPostgres) versionConflict(userId, listId int) error {
+	if _, err := r.GetById(userId, listId); err != nil {
+		return err
+	}
+
+	return todo.ErrVersionMismatch
+}
+
+// ArchiveList - мягкое удаление списка
+func (r *TodoListPostgres) ArchiveList(userId, listId int) error {
+	archived := true
+	return r.inTx(func(tx *sqlx.Tx) error {
+		_, err := updateList(tx, userId, listId, 0, todo.UpdateListInput{Archived: &archived})
+		return err
+	})
 }
 
 // GetAllWithPagination получает списки с пагинацией
@@ -168,11 +283,11 @@ func (r *TodoListPostgres) GetAllWithPagination(userId, offset, limit int, archi
 
 	// Базовый запрос
 	baseQuery := fmt.Sprintf(`
-		SELECT tl.id, tl.title, tl.description, tl.archived, tl.created_at, tl.updated_at, tl.color, tl.priority 
+		SELECT %s
 		FROM %s tl 
 		INNER JOIN %s ul on tl.id = ul.list_id 
 		WHERE ul.user_id = $1`,
-		todoListsTable, usersListsTable)
+		listColumns, todoListsTable, usersListsTable)
 
 	// Добавляем фильтр по archived если указан
 	query := baseQuery
@@ -0,0 +1,270 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+	"github.com/lib/pq"
+)
+
+var sectionColumns = "ls.id, ls.list_id, ls.name, ls.position, ls.created_at, ls.updated_at"
+
+// sectionEndPosition - подзапрос позиции в конце раздела: первый аргумент - список,
+// второй - раздел (NULL - items без раздела)
+var sectionEndPosition = fmt.Sprintf(`SELECT coalesce(max(o.position) + 1, 0) FROM %s o INNER JOIN %s ol ON ol.item_id = o.id
+			WHERE ol.list_id = %%s AND o.section_id IS NOT DISTINCT FROM %%s`,
+	todoItemsTable, listsItemsTable)
+
+// GetSections возвращает разделы списка по порядку
+func (r *TodoListPostgres) GetSections(userId, listId int) ([]todo.ListSection, error) {
+	if err := checkListAccess(r.db, userId, listId); err != nil {
+		return nil, err
+	}
+
+	return getSections(r.db, listId)
+}
+
+func getSections(db sqlx.Queryer, listId int) ([]todo.ListSection, error) {
+	sections := make([]todo.ListSection, 0)
+	query := fmt.Sprintf("SELECT %s FROM %s ls WHERE ls.list_id = $1 ORDER BY ls.position", sectionColumns, listSectionsTable)
+	err := sqlx.Select(db, &sections, query, listId)
+
+	return sections, err
+}
+
+// CreateSection добавляет раздел в список. Позиция за пределами списка разделов
+// означает конец, разделы начиная с указанной позиции сдвигаются
+func (r *TodoListPostgres) CreateSection(userId, listId int, input todo.SectionInput) (todo.ListSection, error) {
+	var section todo.ListSection
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		if err := lockList(tx, userId, listId); err != nil {
+			return err
+		}
+
+		var count int
+		countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE list_id = $1", listSectionsTable)
+		if err := tx.Get(&count, countQuery, listId); err != nil {
+			return err
+		}
+
+		position := count
+		if input.Position != nil && *input.Position < count {
+			position = *input.Position
+			shiftQuery := fmt.Sprintf("UPDATE %s SET position = position + 1 WHERE list_id = $1 AND position >= $2", listSectionsTable)
+			if _, err := tx.Exec(shiftQuery, listId, position); err != nil {
+				return err
+			}
+		}
+
+		query := fmt.Sprintf(`
+			INSERT INTO %s AS ls (list_id, name, position, created_at, updated_at) VALUES ($1, $2, $3, $4, $4)
+			RETURNING %s`, listSectionsTable, sectionColumns)
+		if err := tx.Get(&section, query, listId, input.Name, position, time.Now()); err != nil {
+			return err
+		}
+
+		return touchList(tx, listId)
+	})
+
+	return section, err
+}
+
+// RenameSection переименовывает раздел списка
+func (r *TodoListPostgres) RenameSection(userId, listId, sectionId int, name string) (todo.ListSection, error) {
+	var section todo.ListSection
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		if err := lockList(tx, userId, listId); err != nil {
+			return err
+		}
+
+		query := fmt.Sprintf(`
+			UPDATE %s ls SET name = $1, updated_at = $2
+			WHERE ls.id = $3 AND ls.list_id = $4
+			RETURNING %s`, listSectionsTable, sectionColumns)
+		if err := tx.Get(&section, query, name, time.Now(), sectionId, listId); err != nil {
+			return err
+		}
+
+		return touchList(tx, listId)
+	})
+
+	return section, err
+}
+
+// ReorderSections расставляет разделы списка в порядке ids.
+// ids должны перечислять все разделы списка, иначе возвращается todo.ErrSectionOrder
+func (r *TodoListPostgres) ReorderSections(userId, listId int, ids []int) ([]todo.ListSection, error) {
+	var sections []todo.ListSection
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		if err := lockList(tx, userId, listId); err != nil {
+			return err
+		}
+
+		var count int
+		countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE list_id = $1", listSectionsTable)
+		if err := tx.Get(&count, countQuery, listId); err != nil {
+			return err
+		}
+		if count != len(ids) {
+			return todo.ErrSectionOrder
+		}
+
+		query := fmt.Sprintf(`
+			UPDATE %s ls SET position = o.ordinality - 1, updated_at = $3
+			FROM unnest($1::int[]) WITH ORDINALITY o(id, ordinality)
+			WHERE ls.id = o.id AND ls.list_id = $2`, listSectionsTable)
+		result, err := tx.Exec(query, pq.Array(ids), listId, time.Now())
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows != int64(len(ids)) {
+			return todo.ErrSectionOrder
+		}
+
+		if sections, err = getSections(tx, listId); err != nil {
+			return err
+		}
+
+		return touchList(tx, listId)
+	})
+
+	return sections, err
+}
+
+// DeleteSection удаляет раздел списка. Items раздела в прежнем порядке переходят
+// в конец items без раздела, следующие разделы сдвигаются на его место
+func (r *TodoListPostgres) DeleteSection(userId, listId, sectionId int) error {
+	return r.inTx(func(tx *sqlx.Tx) error {
+		if err := lockList(tx, userId, listId); err != nil {
+			return err
+		}
+
+		var position int
+		query := fmt.Sprintf("SELECT position FROM %s WHERE id = $1 AND list_id = $2", listSectionsTable)
+		if err := tx.Get(&position, query, sectionId, listId); err != nil {
+			return err
+		}
+
+		// Items переносятся до удаления раздела: иначе внешний ключ сбросит раздел без позиций и версий
+		itemIds := make([]int, 0)
+		itemsQuery := fmt.Sprintf(`
+			UPDATE %s ti SET section_id = NULL, updated_at = $1, version = ti.version + 1,
+				position = (%s) + o.row_number - 1
+			FROM (SELECT id, row_number() OVER (ORDER BY position, id) FROM %s WHERE section_id = $3) o
+			WHERE ti.id = o.id
+			RETURNING ti.id`,
+			todoItemsTable, fmt.Sprintf(sectionEndPosition, "$2", "NULL"), todoItemsTable)
+		if err := tx.Select(&itemIds, itemsQuery, time.Now(), listId, sectionId); err != nil {
+			return err
+		}
+		for _, itemId := range itemIds {
+			if err := recordItemChange(tx, todo.EventItemUpdated, itemId); err != nil {
+				return err
+			}
+		}
+
+		deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE id = $1", listSectionsTable)
+		if _, err := tx.Exec(deleteQuery, sectionId); err != nil {
+			return err
+		}
+
+		shiftQuery := fmt.Sprintf("UPDATE %s SET position = position - 1 WHERE list_id = $1 AND position > $2", listSectionsTable)
+		if _, err := tx.Exec(shiftQuery, listId, position); err != nil {
+			return err
+		}
+
+		return touchList(tx, listId)
+	})
+}
+
+// MoveItems переносит items списка в раздел sectionId (nil - без раздела) и ставит их
+// в его начало в порядке ids; остальные items раздела сохраняют порядок после них.
+// Возвращает items, у которых изменились раздел или позиция
+func (r *TodoItemPostgres) MoveItems(userId, listId int, sectionId *int, ids []int) ([]int, error) {
+	itemIds := make([]int, 0)
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		// Блокировка списка упорядочивает параллельные перестановки его items
+		if err := lockList(tx, userId, listId); err != nil {
+			return err
+		}
+		if sectionId != nil {
+			if err := checkSection(tx, listId, *sectionId); err != nil {
+				return err
+			}
+		}
+
+		var count int
+		countQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE list_id = $1 AND item_id = ANY($2)", listsItemsTable)
+		if err := tx.Get(&count, countQuery, listId, pq.Array(ids)); err != nil {
+			return err
+		}
+		if count != len(ids) {
+			return sql.ErrNoRows
+		}
+
+		query := fmt.Sprintf(`
+			WITH target AS (
+				SELECT o.id, o.ordinality - 1 AS position
+				FROM unnest($1::int[]) WITH ORDINALITY o(id, ordinality)
+				UNION ALL
+				SELECT ti.id, $2 + row_number() OVER (ORDER BY ti.position, ti.id) - 1
+				FROM %s ti
+				INNER JOIN %s li on li.item_id = ti.id
+				WHERE li.list_id = $3 AND ti.section_id IS NOT DISTINCT FROM $4::int AND NOT (ti.id = ANY($1))
+			)
+			UPDATE %s ti SET section_id = $4::int, position = t.position, updated_at = $5, version = ti.version + 1
+			FROM target t
+			WHERE ti.id = t.id AND (ti.section_id IS DISTINCT FROM $4::int OR ti.position <> t.position)
+			RETURNING ti.id`,
+			todoItemsTable, listsItemsTable, todoItemsTable)
+		if err := tx.Select(&itemIds, query, pq.Array(ids), len(ids), listId, sectionId, time.Now()); err != nil {
+			return err
+		}
+
+		for _, itemId := range itemIds {
+			if err := recordItemChange(tx, todo.EventItemUpdated, itemId); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return itemIds, err
+}
+
+// checkSection возвращает todo.ErrInvalidSection, если раздел не относится к списку
+func checkSection(db sqlx.Queryer, listId, sectionId int) error {
+	var id int
+	query := fmt.Sprintf("SELECT id FROM %s WHERE id = $1 AND list_id = $2", listSectionsTable)
+	err := sqlx.Get(db, &id, query, sectionId, listId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return todo.ErrInvalidSection
+	}
+
+	return err
+}
+
+// touchList увеличивает версию списка после изменения его разделов, записывает аудит
+// и ставит в очередь webhooks
+func touchList(tx *sqlx.Tx, listId int) error {
+	if err := bumpListVersion(tx, listId); err != nil {
+		return err
+	}
+
+	return recordListChange(tx, todo.EventListUpdated, listId)
+}
+
+func bumpListVersion(tx *sqlx.Tx, listId int) error {
+	query := fmt.Sprintf("UPDATE %s SET updated_at = $1, version = version + 1 WHERE id = $2", todoListsTable)
+	_, err := tx.Exec(query, time.Now(), listId)
+	return err
+}
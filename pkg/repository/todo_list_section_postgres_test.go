@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+)
+
+// getTestSectionItems возвращает items раздела списка (sectionId = 0 - без раздела) по порядку
+func getTestSectionItems(t *testing.T, db *sqlx.DB, listId, sectionId int) []int {
+	t.Helper()
+
+	ids := make([]int, 0)
+	query := `
+		SELECT ti.id FROM todo_items ti INNER JOIN lists_items li on li.item_id = ti.id
+		WHERE li.list_id = $1 AND coalesce(ti.section_id, 0) = $2
+		ORDER BY ti.position, ti.id`
+	if err := db.Select(&ids, query, listId, sectionId); err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func TestSectionOrder(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	listId := createTestList(t, db, owner, "list")
+	repo := NewTodoListPostgres(db)
+
+	create := func(name string, position *int) int {
+		t.Helper()
+		section, err := repo.CreateSection(owner, listId, todo.SectionInput{Name: name, Position: position})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return section.Id
+	}
+	zero := 0
+	later := create("Later", nil)
+	now := create("Now", &zero)
+	next := create("Next", nil)
+
+	sections, err := repo.GetSections(owner, listId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 3 || sections[0].Id != now || sections[1].Id != later || sections[2].Id != next {
+		t.Fatalf("sections = %+v, want Now, Later, Next", sections)
+	}
+
+	if sections, err = repo.ReorderSections(owner, listId, []int{now, next, later}); err != nil {
+		t.Fatal(err)
+	}
+	if sections[1].Id != next || sections[1].Position != 1 {
+		t.Errorf("reordered = %+v", sections)
+	}
+	if _, err := repo.ReorderSections(owner, listId, []int{now, next}); !errors.Is(err, todo.ErrSectionOrder) {
+		t.Errorf("partial order: err = %v, want section order", err)
+	}
+
+	if err := repo.DeleteSection(owner, listId, now); err != nil {
+		t.Fatal(err)
+	}
+	if sections, err = repo.GetSections(owner, listId); err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 2 || sections[0].Id != next || sections[0].Position != 0 || sections[1].Position != 1 {
+		t.Errorf("after delete = %+v", sections)
+	}
+}
+
+func TestMoveItemsBetweenSections(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	listId := createTestList(t, db, owner, "list")
+	a := createTestItem(t, db, listId, "a")
+	b := createTestItem(t, db, listId, "b")
+	c := createTestItem(t, db, listId, "c")
+	d := createTestItem(t, db, listId, "d")
+	lists := NewTodoListPostgres(db)
+	items := NewTodoItemPostgres(db)
+
+	section, err := lists.CreateSection(owner, listId, todo.SectionInput{Name: "Section"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := items.MoveItems(owner, listId, &section.Id, []int{c, a}); err != nil {
+		t.Fatal(err)
+	}
+	// Перенесенные items встают в начало раздела, остальные сохраняют порядок после них
+	if _, err := items.MoveItems(owner, listId, &section.Id, []int{b}); err != nil {
+		t.Fatal(err)
+	}
+	if got := getTestSectionItems(t, db, listId, section.Id); !reflect.DeepEqual(got, []int{b, c, a}) {
+		t.Errorf("section = %v, want [%d %d %d]", got, b, c, a)
+	}
+
+	// Item из другого списка и раздел другого списка отклоняются
+	otherList := createTestList(t, db, owner, "other")
+	foreignItem := createTestItem(t, db, otherList, "foreign")
+	if _, err := items.MoveItems(owner, listId, &section.Id, []int{foreignItem}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("foreign item: err = %v, want no rows", err)
+	}
+	if _, err := items.MoveItems(owner, otherList, &section.Id, []int{foreignItem}); !errors.Is(err, todo.ErrInvalidSection) {
+		t.Errorf("foreign section: err = %v, want invalid section", err)
+	}
+
+	// Через обновление item переходит в конец раздела
+	if err := items.Update(owner, d, todo.UpdateItemInput{SectionId: todo.NewOptionalInt(&section.Id)}); err != nil {
+		t.Fatal(err)
+	}
+	if got := getTestSectionItems(t, db, listId, section.Id); !reflect.DeepEqual(got, []int{b, c, a, d}) {
+		t.Errorf("section after update = %v", got)
+	}
+
+	// Items удаленного раздела в прежнем порядке переходят в конец items без раздела
+	if _, err := items.MoveItems(owner, listId, nil, []int{c}); err != nil {
+		t.Fatal(err)
+	}
+	if err := lists.DeleteSection(owner, listId, section.Id); err != nil {
+		t.Fatal(err)
+	}
+	if got := getTestSectionItems(t, db, listId, 0); !reflect.DeepEqual(got, []int{c, b, a, d}) {
+		t.Errorf("without section = %v, want [%d %d %d %d]", got, c, b, a, d)
+	}
+}
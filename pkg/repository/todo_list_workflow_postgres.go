@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+	"github.com/lib/pq"
+)
+
+var statusColumns = "s.id, s.list_id, s.name, s.category, s.position"
+
+// GetWorkflow возвращает статусы workflow списка по порядку
+func (r *TodoListPostgres) GetWorkflow(userId, listId int) ([]todo.ListStatus, error) {
+	if err := checkListAccess(r.db, userId, listId); err != nil {
+		return nil, err
+	}
+
+	return getStatuses(r.db, listId)
+}
+
+func getStatuses(db sqlx.Queryer, listId int) ([]todo.ListStatus, error) {
+	statuses := make([]todo.ListStatus, 0)
+	query := fmt.Sprintf("SELECT %s FROM %s s WHERE s.list_id = $1 ORDER BY s.position", statusColumns, listStatusesTable)
+	err := sqlx.Select(db, &statuses, query, listId)
+
+	return statuses, err
+}
+
+// SetWorkflow заменяет workflow списка. Статусы с id сохраняются с новыми названием, категорией
+// и позицией, без id - создаются, отсутствующие - удаляются. Items удаленных статусов и items
+// без статуса переходят в первый статус подходящей по done категории; при смене категории
+// статуса его items меняют done. Пустой набор статусов отключает workflow
+func (r *TodoListPostgres) SetWorkflow(userId, listId int, statuses []todo.StatusInput) ([]todo.ListStatus, error) {
+	var result []todo.ListStatus
+	err := r.inTx(func(tx *sqlx.Tx) error {
+		// Блокировка списка упорядочивает параллельные изменения workflow
+		if err := lockList(tx, userId, listId); err != nil {
+			return err
+		}
+
+		current, err := getStatuses(tx, listId)
+		if err != nil {
+			return err
+		}
+
+		removed := make(map[int]bool, len(current))
+		for _, status := range current {
+			removed[status.Id] = true
+		}
+		for _, status := range statuses {
+			if status.Id == 0 {
+				continue
+			}
+			if !removed[status.Id] {
+				return todo.ErrInvalidStatus
+			}
+			delete(removed, status.Id)
+		}
+
+		for position, status := range statuses {
+			if status.Id > 0 {
+				query := fmt.Sprintf("UPDATE %s SET name = $1, category = $2, position = $3 WHERE id = $4", listStatusesTable)
+				if _, err := tx.Exec(query, status.Name, status.Category, position, status.Id); err != nil {
+					return err
+				}
+				continue
+			}
+
+			query := fmt.Sprintf("INSERT INTO %s (list_id, name, category, position) VALUES ($1, $2, $3, $4)", listStatusesTable)
+			if _, err := tx.Exec(query, listId, status.Name, status.Category, position); err != nil {
+				return err
+			}
+		}
+
+		removedIds := make([]int, 0, len(removed))
+		for id := range removed {
+			removedIds = append(removedIds, id)
+		}
+
+		itemIds, err := restatusItems(tx, listId, removedIds, len(statuses) > 0)
+		if err != nil {
+			return err
+		}
+
+		deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE id = ANY($1)", listStatusesTable)
+		if _, err := tx.Exec(deleteQuery, pq.Array(removedIds)); err != nil {
+			return err
+		}
+
+		for _, itemId := range itemIds {
+			if err := recordItemChange(tx, todo.EventItemUpdated, itemId); err != nil {
+				return err
+			}
+		}
+
+		touchQuery := fmt.Sprintf("UPDATE %s SET updated_at = $1, version = version + 1 WHERE id = $2", todoListsTable)
+		if _, err := tx.Exec(touchQuery, time.Now(), listId); err != nil {
+			return err
+		}
+		if err := recordListChange(tx, todo.EventListUpdated, listId); err != nil {
+			return err
+		}
+
+		result, err = getStatuses(tx, listId)
+		return err
+	})
+
+	return result, err
+}
+
+// restatusItems приводит статусы items списка в соответствие с новым workflow и возвращает
+// затронутые items. Без workflow статусы снимаются, done сохраняется
+func restatusItems(tx *sqlx.Tx, listId int, removedIds []int, enabled bool) ([]int, error) {
+	itemIds := make([]int, 0)
+	if !enabled {
+		query := fmt.Sprintf(`
+			UPDATE %s ti SET status_id = NULL, updated_at = $1, version = ti.version + 1
+			FROM %s li
+			WHERE li.item_id = ti.id AND li.list_id = $2 AND ti.status_id IS NOT NULL
+			RETURNING ti.id`,
+			todoItemsTable, listsItemsTable)
+		err := tx.Select(&itemIds, query, time.Now(), listId)
+		return itemIds, err
+	}
+
+	// Сначала done следует за сменившейся категорией статуса, затем items без статуса
+	// и из удаляемых статусов получают первый статус своей категории
+	categoryQuery := fmt.Sprintf(`
+		UPDATE %s ti SET done = (s.category = $1), updated_at = $2, version = ti.version + 1
+		FROM %s s
+		WHERE s.id = ti.status_id AND s.list_id = $3 AND NOT (s.id = ANY($4)) AND ti.done <> (s.category = $1)
+		RETURNING ti.id`,
+		todoItemsTable, listStatusesTable)
+	if err := tx.Select(&itemIds, categoryQuery, todo.StatusCategoryClosed, time.Now(), listId, pq.Array(removedIds)); err != nil {
+		return nil, err
+	}
+
+	var moved []int
+	moveQuery := fmt.Sprintf(`
+		UPDATE %s ti SET updated_at = $2, version = ti.version + 1,
+			status_id = (
+				SELECT s.id FROM %s s
+				WHERE s.list_id = $3 AND NOT (s.id = ANY($4)) AND (s.category = $1) = ti.done
+				ORDER BY s.position
+				LIMIT 1)
+		FROM %s li
+		WHERE li.item_id = ti.id AND li.list_id = $3 AND (ti.status_id IS NULL OR ti.status_id = ANY($4))
+		RETURNING ti.id`,
+		todoItemsTable, listStatusesTable, listsItemsTable)
+	if err := tx.Select(&moved, moveQuery, todo.StatusCategoryClosed, time.Now(), listId, pq.Array(removedIds)); err != nil {
+		return nil, err
+	}
+
+	return append(itemIds, moved...), nil
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ktuty/todo-app"
+)
+
+func getTestItemStatus(t *testing.T, repo *TodoItemPostgres, userId, itemId int) (*int, bool) {
+	t.Helper()
+
+	item, err := repo.GetById(userId, itemId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return item.StatusId, item.Done
+}
+
+func TestWorkflowStatusSync(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	listId := createTestList(t, db, owner, "list")
+	openId := createTestItem(t, db, listId, "open")
+	doneId := createTestItem(t, db, listId, "done")
+	lists := NewTodoListPostgres(db)
+	items := NewTodoItemPostgres(db)
+
+	if err := items.CompleteItem(owner, doneId, false); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := lists.SetWorkflow(owner, listId, []todo.StatusInput{
+		{Name: "Todo", Category: todo.StatusCategoryOpen},
+		{Name: "Doing", Category: todo.StatusCategoryOpen},
+		{Name: "Done", Category: todo.StatusCategoryClosed},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 3 {
+		t.Fatalf("statuses = %+v", statuses)
+	}
+	todoStatus, doing, done := statuses[0].Id, statuses[1].Id, statuses[2].Id
+
+	// Items без статуса получают первый статус своей категории
+	if status, _ := getTestItemStatus(t, items, owner, openId); status == nil || *status != todoStatus {
+		t.Errorf("open item status = %v, want %d", status, todoStatus)
+	}
+	if status, _ := getTestItemStatus(t, items, owner, doneId); status == nil || *status != done {
+		t.Errorf("done item status = %v, want %d", status, done)
+	}
+
+	// Смена статуса меняет done по категории, смена done - статус
+	if err := items.Update(owner, openId, todo.UpdateItemInput{StatusId: &done}); err != nil {
+		t.Fatal(err)
+	}
+	if _, isDone := getTestItemStatus(t, items, owner, openId); !isDone {
+		t.Error("item in a closed status is not done")
+	}
+	reopen := false
+	if err := items.Update(owner, openId, todo.UpdateItemInput{Done: &reopen}); err != nil {
+		t.Fatal(err)
+	}
+	if status, isDone := getTestItemStatus(t, items, owner, openId); isDone || status == nil || *status != todoStatus {
+		t.Errorf("reopened item: status %v, done %v", status, isDone)
+	}
+
+	// Статус чужого списка не подходит
+	otherList := createTestList(t, db, owner, "other")
+	other, err := lists.SetWorkflow(owner, otherList, []todo.StatusInput{
+		{Name: "Open", Category: todo.StatusCategoryOpen},
+		{Name: "Closed", Category: todo.StatusCategoryClosed},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := items.Update(owner, openId, todo.UpdateItemInput{StatusId: &other[0].Id}); !errors.Is(err, todo.ErrInvalidStatus) {
+		t.Errorf("foreign status: err = %v, want invalid status", err)
+	}
+
+	if err := items.Update(owner, openId, todo.UpdateItemInput{StatusId: &doing}); err != nil {
+		t.Fatal(err)
+	}
+	filtered, total, err := items.GetAllWithPagination(owner, todo.ItemFilter{ListId: listId, StatusIds: []int{doing}}, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || filtered[0].Id != openId {
+		t.Errorf("items in Doing: total %d, items %+v", total, filtered)
+	}
+}
+
+func TestSetWorkflowRestatusesItems(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	listId := createTestList(t, db, owner, "list")
+	itemId := createTestItem(t, db, listId, "item")
+	lists := NewTodoListPostgres(db)
+	items := NewTodoItemPostgres(db)
+
+	statuses, err := lists.SetWorkflow(owner, listId, []todo.StatusInput{
+		{Name: "Todo", Category: todo.StatusCategoryOpen},
+		{Name: "Review", Category: todo.StatusCategoryOpen},
+		{Name: "Done", Category: todo.StatusCategoryClosed},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	review := statuses[1].Id
+	if err := items.Update(owner, itemId, todo.UpdateItemInput{StatusId: &review}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Review становится закрытым: его items становятся выполненными
+	statuses, err = lists.SetWorkflow(owner, listId, []todo.StatusInput{
+		{Id: statuses[0].Id, Name: "Todo", Category: todo.StatusCategoryOpen},
+		{Id: review, Name: "Review", Category: todo.StatusCategoryClosed},
+		{Id: statuses[2].Id, Name: "Done", Category: todo.StatusCategoryClosed},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, done := getTestItemStatus(t, items, owner, itemId); !done || status == nil || *status != review {
+		t.Errorf("after category change: status %v, done %v", status, done)
+	}
+
+	// Удаленный статус заменяется первым статусом той же категории
+	statuses, err = lists.SetWorkflow(owner, listId, []todo.StatusInput{
+		{Id: statuses[0].Id, Name: "Todo", Category: todo.StatusCategoryOpen},
+		{Id: statuses[2].Id, Name: "Done", Category: todo.StatusCategoryClosed},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, done := getTestItemStatus(t, items, owner, itemId); !done || status == nil || *status != statuses[1].Id {
+		t.Errorf("after removal: status %v, done %v, want %d", status, done, statuses[1].Id)
+	}
+
+	// Удаленный статус нельзя сохранить по id
+	if _, err := lists.SetWorkflow(owner, listId, []todo.StatusInput{{Id: review, Name: "Review", Category: todo.StatusCategoryOpen}}); !errors.Is(err, todo.ErrInvalidStatus) {
+		t.Errorf("removed status id: err = %v, want invalid status", err)
+	}
+
+	// Без workflow статусы снимаются, done сохраняется
+	if _, err := lists.SetWorkflow(owner, listId, nil); err != nil {
+		t.Fatal(err)
+	}
+	if status, done := getTestItemStatus(t, items, owner, itemId); !done || status != nil {
+		t.Errorf("after disabling: status %v, done %v", status, done)
+	}
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+	"github.com/lib/pq"
+)
+
+type WebhookPostgres struct {
+	db *sqlx.DB
+}
+
+func NewWebhookPostgres(db *sqlx.DB) *WebhookPostgres {
+	return &WebhookPostgres{db: db}
+}
+
+type webhookRow struct {
+	todo.Webhook
+	Events pq.StringArray `db:"events"`
+}
+
+func (r webhookRow) toWebhook() todo.Webhook {
+	webhook := r.Webhook
+	webhook.Events = []string(r.Events)
+	return webhook
+}
+
+const webhookColumns = "id, list_id, url, events, active, created_at"
+
+const deliveryColumns = `d.id, d.webhook_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at,
+		d.last_status_code, d.last_error, d.redelivery_of, d.created_at, d.delivered_at`
+
+func (r *WebhookPostgres) Create(userId int, webhook todo.Webhook) (int, error) {
+	if webhook.ListId != nil {
+		if err := checkListAccess(r.db, userId, *webhook.ListId); err != nil {
+			return 0, err
+		}
+	}
+
+	var id int
+	query := fmt.Sprintf(`
+		INSERT INTO %s (user_id, list_id, url, secret, events, active, created_at) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7) 
+		RETURNING id`, webhooksTable)
+	err := r.db.Get(&id, query,
+		userId, webhook.ListId, webhook.URL, webhook.Secret, pq.StringArray(webhook.Events), webhook.Active, time.Now())
+
+	return id, err
+}
+
+func (r *WebhookPostgres) GetAll(userId int) ([]todo.Webhook, error) {
+	var rows []webhookRow
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE user_id = $1 ORDER BY id", webhookColumns, webhooksTable)
+	if err := r.db.Select(&rows, query, userId); err != nil {
+		return nil, err
+	}
+
+	webhooks := make([]todo.Webhook, len(rows))
+	for i, row := range rows {
+		webhooks[i] = row.toWebhook()
+	}
+	return webhooks, nil
+}
+
+func (r *WebhookPostgres) GetById(userId, webhookId int) (todo.Webhook, error) {
+	var row webhookRow
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE id = $1 AND user_id = $2", webhookColumns, webhooksTable)
+	if err := r.db.Get(&row, query, webhookId, userId); err != nil {
+		return todo.Webhook{}, err
+	}
+
+	return row.toWebhook(), nil
+}
+
+func (r *WebhookPostgres) Delete(userId, webhookId int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1 AND user_id = $2", webhooksTable)
+	result, err := r.db.Exec(query, webhookId, userId)
+	if err != nil {
+		return err
+	}
+
+	if deleted, err := result.RowsAffected(); err != nil || deleted > 0 {
+		return err
+	}
+	return sql.ErrNoRows
+}
+
+// GetDeliveries возвращает журнал доставок webhook, новые первыми
+func (r *WebhookPostgres) GetDeliveries(userId, webhookId int, status string, limit int) ([]todo.WebhookDelivery, error) {
+	deliveries := make([]todo.WebhookDelivery, 0)
+	query := fmt.Sprintf(`
+		SELECT %s 
+		FROM %s d 
+		INNER JOIN %s w on w.id = d.webhook_id 
+		WHERE d.webhook_id = $1 AND w.user_id = $2 AND ($3 = '' OR d.status = $3) 
+		ORDER BY d.id DESC 
+		LIMIT $4`,
+		deliveryColumns, webhookDeliveriesTable, webhooksTable)
+	err := r.db.Select(&deliveries, query, webhookId, userId, status, limit)
+
+	return deliveries, err
+}
+
+func (r *WebhookPostgres) GetDelivery(userId int, deliveryId int64) (todo.WebhookDelivery, error) {
+	var delivery todo.WebhookDelivery
+	query := fmt.Sprintf(`
+		SELECT %s 
+		FROM %s d 
+		INNER JOIN %s w on w.id = d.webhook_id 
+		WHERE d.id = $1 AND w.user_id = $2`,
+		deliveryColumns, webhookDeliveriesTable, webhooksTable)
+	err := r.db.Get(&delivery, query, deliveryId, userId)
+
+	return delivery, err
+}
+
+// Redeliver ставит в очередь копию доставки; исходная запись журнала не меняется
+func (r *WebhookPostgres) Redeliver(userId int, deliveryId int64) (int64, error) {
+	var id int64
+	query := fmt.Sprintf(`
+		INSERT INTO %s (webhook_id, event_type, payload, redelivery_of) 
+		SELECT d.webhook_id, d.event_type, d.payload, d.id 
+		FROM %s d 
+		INNER JOIN %s w on w.id = d.webhook_id 
+		WHERE d.id = $1 AND w.user_id = $2 
+		RETURNING id`,
+		webhookDeliveriesTable, webhookDeliveriesTable, webhooksTable)
+	err := r.db.Get(&id, query, deliveryId, userId)
+
+	return id, err
+}
+
+// ClaimDue выбирает доставки, время которых подошло, и откладывает их на lease:
+// параллельные воркеры их пропускают, а если воркер упадет, доставка будет повторена
+func (r *WebhookPostgres) ClaimDue(limit int, lease time.Duration) ([]todo.WebhookDelivery, error) {
+	deliveries := make([]todo.WebhookDelivery, 0)
+	query := fmt.Sprintf(`
+		WITH due AS (
+			SELECT d.id FROM %[1]s d 
+			INNER JOIN %[2]s w on w.id = d.webhook_id 
+			WHERE d.status = $1 AND d.next_attempt_at <= now() AND w.active 
+			ORDER BY d.next_attempt_at 
+			LIMIT $2 
+			FOR UPDATE OF d SKIP LOCKED
+		), d AS (
+			UPDATE %[1]s wd SET attempts = wd.attempts + 1, next_attempt_at = $3 
+			FROM due 
+			WHERE wd.id = due.id 
+			RETURNING wd.*
+		)
+		SELECT %[3]s, w.url, w.secret 
+		FROM d 
+		INNER JOIN %[2]s w on w.id = d.webhook_id`,
+		webhookDeliveriesTable, webhooksTable, deliveryColumns)
+	err := r.db.Select(&deliveries, query, todo.WebhookDeliveryPending, limit, time.Now().Add(lease))
+
+	return deliveries, err
+}
+
+func (r *WebhookPostgres) MarkDelivered(deliveryId int64, statusCode int) error {
+	query := fmt.Sprintf(`
+		UPDATE %s SET status = $1, last_status_code = $2, last_error = NULL, delivered_at = $3 
+		WHERE id = $4`, webhookDeliveriesTable)
+	_, err := r.db.Exec(query, todo.WebhookDeliveryDelivered, statusCode, time.Now(), deliveryId)
+	return err
+}
+
+// MarkFailed фиксирует неудачную попытку; при dead доставка больше не повторяется
+func (r *WebhookPostgres) MarkFailed(deliveryId int64, statusCode int, reason string, nextAttemptAt time.Time, dead bool) error {
+	status := todo.WebhookDeliveryPending
+	if dead {
+		status = todo.WebhookDeliveryDead
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE %s SET status = $1, last_status_code = NULLIF($2, 0), last_error = $3, next_attempt_at = $4 
+		WHERE id = $5`, webhookDeliveriesTable)
+	_, err := r.db.Exec(query, status, statusCode, reason, nextAttemptAt, deliveryId)
+	return err
+}
+
+// enqueueItemsWebhooks записывает в outbox доставки события об items для всех подходящих
+// webhooks. Вызывается в транзакции изменения, поэтому доставки появляются тогда и только тогда,
+// когда изменение зафиксировано. Для удаления вызывается до удаления строки, чтобы сохранить снимок.
+// Владелец webhook, в том числе webhook одного списка, должен оставаться участником списка
+func enqueueItemsWebhooks(db sqlx.Execer, eventType string, itemIds []int) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s (webhook_id, event_type, payload) 
+		SELECT w.id, $1, jsonb_build_object('event', $1::text, 'list_id', li.list_id, 'item', to_jsonb(ti) - 'change_seq' - 'change_xid', 'occurred_at', now()) 
+		FROM %s w, %s li, %s ti 
+		WHERE li.item_id = ANY($2) AND ti.id = li.item_id AND w.active AND $1 = ANY(w.events) 
+			AND (w.list_id IS NULL OR w.list_id = li.list_id)
+			AND EXISTS (SELECT 1 FROM %s ul WHERE ul.list_id = li.list_id AND ul.user_id = w.user_id)
+		ORDER BY li.item_id`,
+		webhookDeliveriesTable, webhooksTable, listsItemsTable, todoItemsTable, usersListsTable)
+	_, err := db.Exec(query, eventType, pq.Array(itemIds))
+	return err
+}
+
+// enqueueListWebhooks - то же, что enqueueItemsWebhooks, для событий списка.
+// Webhooks одного списка удаляются вместе с ним, поэтому list.deleted получают только
+// webhooks уровня пользователя
+func enqueueListWebhooks(db sqlx.Execer, eventType string, listId int) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s (webhook_id, event_type, payload) 
+		SELECT w.id, $1, jsonb_build_object('event', $1::text, 'list_id', tl.id, 'list', to_jsonb(tl) - 'change_seq' - 'change_xid', 'occurred_at', now()) 
+		FROM %s w, %s tl 
+		WHERE tl.id = $2 AND w.active AND $1 = ANY(w.events) 
+			AND (w.list_id IS NULL OR w.list_id = tl.id)
+			AND EXISTS (SELECT 1 FROM %s ul WHERE ul.list_id = tl.id AND ul.user_id = w.user_id)`,
+		webhookDeliveriesTable, webhooksTable, todoListsTable, usersListsTable)
+	_, err := db.Exec(query, eventType, listId)
+	return err
+}
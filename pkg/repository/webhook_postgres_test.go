@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ktuty/todo-app"
+	"github.com/lib/pq"
+)
+
+func createTestWebhook(t *testing.T, db *sqlx.DB, userId int, listId *int) int {
+	t.Helper()
+
+	id, err := NewWebhookPostgres(db).Create(userId, todo.Webhook{
+		ListId: listId,
+		URL:    "https://example.com/hook",
+		Secret: "secret-secret-secret",
+		Events: []string{todo.EventItemUpdated},
+		Active: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func countDeliveries(t *testing.T, db *sqlx.DB, webhookId int) int {
+	t.Helper()
+
+	var count int
+	if err := db.Get(&count, "SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = $1", webhookId); err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestWebhooksRequireListMembership(t *testing.T) {
+	db := newTestDB(t)
+	owner := createTestUser(t, db, "owner")
+	member := createTestUser(t, db, "member")
+	outsider := createTestUser(t, db, "outsider")
+
+	listId := createTestList(t, db, owner, "list")
+	itemId := createTestItem(t, db, listId, "item")
+	addTestMember(t, db, owner, listId, "member")
+
+	ownerHook := createTestWebhook(t, db, owner, &listId)
+	memberListHook := createTestWebhook(t, db, member, &listId)
+	memberUserHook := createTestWebhook(t, db, member, nil)
+
+	// Webhook списка, владелец которого не участник: например, созданный до исправления
+	var outsiderHook int
+	err := db.Get(&outsiderHook, `
+		INSERT INTO webhooks (user_id, list_id, url, secret, events) VALUES ($1, $2, 'https://example.com/hook', 's', $3)
+		RETURNING id`, outsider, listId, pq.StringArray{todo.EventItemUpdated})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewListMemberPostgres(db).Remove(owner, listId, member); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewWebhookPostgres(db).GetById(member, memberListHook); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("list webhook of removed member: err = %v, want %v", err, sql.ErrNoRows)
+	}
+	if _, err := NewWebhookPostgres(db).GetById(member, memberUserHook); err != nil {
+		t.Errorf("user webhook of removed member was deleted: %v", err)
+	}
+
+	title := "renamed"
+	if err := NewTodoItemPostgres(db).Update(owner, itemId, todo.UpdateItemInput{Title: &title}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name      string
+		webhookId int
+		want      int
+	}{
+		{"owner list webhook", ownerHook, 1},
+		{"removed member user webhook", memberUserHook, 0},
+		{"outsider list webhook", outsiderHook, 0},
+	} {
+		if got := countDeliveries(t, db, tt.webhookId); got != tt.want {
+			t.Errorf("%s: %d deliveries, want %d", tt.name, got, tt.want)
+		}
+	}
+}
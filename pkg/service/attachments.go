@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/repository"
+	"github.com/ktuty/todo-app/pkg/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// AttachmentConfig - настройки вложений
+type AttachmentConfig struct {
+	SigningKey []byte        // Ключ подписи ссылок на скачивание
+	ReserveFor time.Duration // Сколько ждать сохранения метаданных после начала загрузки, прежде чем удалить объект
+}
+
+// AttachmentCleanerConfig - настройки удаления содержимого вложений из хранилища
+type AttachmentCleanerConfig struct {
+	PollInterval time.Duration // Период проверки очереди удаления
+	BatchSize    int           // Число объектов за одну проверку
+	Timeout      time.Duration // Таймаут удаления одного объекта
+	RetryDelay   time.Duration // Задержка перед повторной попыткой после ошибки
+}
+
+func (c AttachmentCleanerConfig) withDefaults() AttachmentCleanerConfig {
+	if c.PollInterval <= 0 {
+		c.PollInterval = time.Minute
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 30 * time.Second
+	}
+	if c.RetryDelay <= 0 {
+		c.RetryDelay = 10 * time.Minute
+	}
+	return c
+}
+
+type AttachmentService struct {
+	repo   repository.Attachments
+	items  repository.TodoItem
+	store  storage.BlobStore
+	config AttachmentConfig
+}
+
+func NewAttachmentService(repo repository.Attachments, items repository.TodoItem, store storage.BlobStore, config AttachmentConfig) *AttachmentService {
+	if config.ReserveFor <= 0 {
+		config.ReserveFor = time.Hour
+	}
+	return &AttachmentService{repo: repo, items: items, store: store, config: config}
+}
+
+// Create сохраняет содержимое в хранилище, а затем метаданные. Ключ заранее ставится
+// в отложенную очередь удаления: если метаданные не сохранятся, объект будет удален
+func (s *AttachmentService) Create(ctx context.Context, userId, itemId int, upload todo.AttachmentUpload) (todo.Attachment, error) {
+	if _, err := s.items.GetListId(userId, itemId); err != nil {
+		return todo.Attachment{}, err
+	}
+
+	suffix := make([]byte, 16)
+	if _, err := rand.Read(suffix); err != nil {
+		return todo.Attachment{}, err
+	}
+	key := fmt.Sprintf("attachments/%d/%s", itemId, hex.EncodeToString(suffix))
+
+	if err := s.repo.Reserve(key, time.Now().Add(s.config.ReserveFor)); err != nil {
+		return todo.Attachment{}, err
+	}
+
+	if err := s.store.Put(ctx, key, upload.Body, upload.Size, upload.ContentType); err != nil {
+		return todo.Attachment{}, err
+	}
+
+	id, err := s.repo.Create(userId, itemId, todo.Attachment{
+		Filename:    upload.Filename,
+		ContentType: upload.ContentType,
+		Size:        upload.Size,
+		Checksum:    upload.Checksum,
+		StorageKey:  key,
+	})
+	if err != nil {
+		return todo.Attachment{}, err
+	}
+
+	return s.repo.GetById(userId, id)
+}
+
+func (s *AttachmentService) GetAll(userId, itemId int) ([]todo.Attachment, error) {
+	return s.repo.GetAll(userId, itemId)
+}
+
+func (s *AttachmentService) GetById(userId, attachmentId int) (todo.Attachment, error) {
+	return s.repo.GetById(userId, attachmentId)
+}
+
+func (s *AttachmentService) Delete(userId, attachmentId int) error {
+	return s.repo.Delete(userId, attachmentId)
+}
+
+// Open возвращает вложение и его содержимое; читатель нужно закрыть
+func (s *AttachmentService) Open(ctx context.Context, userId, attachmentId int) (todo.Attachment, io.ReadCloser, error) {
+	attachment, err := s.repo.GetById(userId, attachmentId)
+	if err != nil {
+		return todo.Attachment{}, nil, err
+	}
+
+	body, err := s.store.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		return todo.Attachment{}, nil, err
+	}
+	return attachment, body, nil
+}
+
+// SignURL возвращает путь и срок действия ссылки на скачивание вложения без авторизации
+func (s *AttachmentService) SignURL(userId, attachmentId int, ttl time.Duration) (string, time.Time, error) {
+	if _, err := s.repo.GetById(userId, attachmentId); err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	expires := expiresAt.Unix()
+	path := fmt.Sprintf("/files/attachments/%d?expires=%d&signature=%s",
+		attachmentId, expires, s.signature(attachmentId, expires))
+
+	return path, expiresAt, nil
+}
+
+// OpenSigned - то же, что Open, для ссылки из SignURL. Истекшая или поддельная
+// подпись - todo.ErrForbidden
+func (s *AttachmentService) OpenSigned(ctx context.Context, attachmentId int, expires int64, signature string) (todo.Attachment, io.ReadCloser, error) {
+	expected := s.signature(attachmentId, expires)
+	if time.Now().Unix() > expires || !hmac.Equal([]byte(signature), []byte(expected)) {
+		return todo.Attachment{}, nil, todo.ErrForbidden
+	}
+
+	attachment, err := s.repo.GetByIdUnchecked(attachmentId)
+	if err != nil {
+		return todo.Attachment{}, nil, err
+	}
+
+	body, err := s.store.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		return todo.Attachment{}, nil, err
+	}
+	return attachment, body, nil
+}
+
+func (s *AttachmentService) signature(attachmentId int, expires int64) string {
+	mac := hmac.New(sha256.New, s.config.SigningKey)
+	mac.Write([]byte(strconv.Itoa(attachmentId) + ":" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RunCleaner удаляет из хранилища содержимое удаленных вложений до отмены ctx
+func (s *AttachmentService) RunCleaner(ctx context.Context, config AttachmentCleanerConfig) {
+	config = config.withDefaults()
+
+	ticker := time.NewTicker(config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for s.cleanup(ctx, config) == config.BatchSize {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// cleanup удаляет одну порцию объектов и возвращает их число
+func (s *AttachmentService) cleanup(ctx context.Context, config AttachmentCleanerConfig) int {
+	if ctx.Err() != nil {
+		return 0
+	}
+
+	lease := config.Timeout*time.Duration(config.BatchSize) + config.PollInterval
+	deletions, err := s.repo.ClaimDeletions(config.BatchSize, lease)
+	if err != nil {
+		logrus.Errorf("attachments: failed to claim blob deletions: %s", err.Error())
+		return 0
+	}
+
+	for _, deletion := range deletions {
+		s.deleteBlob(ctx, config, deletion)
+	}
+	return len(deletions)
+}
+
+func (s *AttachmentService) deleteBlob(ctx context.Context, config AttachmentCleanerConfig, deletion todo.BlobDeletion) {
+	deleteCtx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	if err := s.store.Delete(deleteCtx, deletion.StorageKey); err != nil {
+		if err := s.repo.MarkDeletionFailed(deletion.Id, err.Error(), time.Now().Add(config.RetryDelay)); err != nil {
+			logrus.Errorf("attachments: failed to mark blob deletion %d failed: %s", deletion.Id, err.Error())
+		}
+		return
+	}
+
+	if err := s.repo.MarkDeleted(deletion.Id); err != nil {
+		logrus.Errorf("attachments: failed to mark blob deletion %d done: %s", deletion.Id, err.Error())
+	}
+}
@@ -0,0 +1,22 @@
+package service
+
+import (
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/repository"
+)
+
+type AuditService struct {
+	repo repository.Audit
+}
+
+func NewAuditService(repo repository.Audit) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+func (s *AuditService) GetListActivity(userId, listId int, filter todo.AuditFilter) ([]todo.AuditEntry, int, error) {
+	return s.repo.GetByList(userId, listId, filter)
+}
+
+func (s *AuditService) GetFeed(userId int, filter todo.AuditFilter) ([]todo.AuditEntry, int, error) {
+	return s.repo.GetFeed(userId, filter)
+}
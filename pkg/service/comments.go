@@ -0,0 +1,116 @@
+package service
+
+import (
+	"regexp"
+
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/repository"
+)
+
+// maxMentions ограничивает число упоминаний в одном комментарии
+const maxMentions = 50
+
+var (
+	// @username в начале текста или после символа, который не может входить в email или username
+	mentionPattern = regexp.MustCompile(`(?:^|[^\w@.\-])@([A-Za-z0-9_](?:[A-Za-z0-9_.\-]*[A-Za-z0-9_])?)`)
+	// Блоки и фрагменты кода Markdown: упоминания внутри них не учитываются
+	codeBlockPattern = regexp.MustCompile("(?s)```.*?(```|$)|~~~.*?(~~~|$)")
+	codeSpanPattern  = regexp.MustCompile("`[^`\n]+`")
+)
+
+type CommentService struct {
+	repo   repository.Comments
+	items  repository.TodoItem
+	events EventPublisher
+}
+
+func NewCommentService(repo repository.Comments, items repository.TodoItem, events EventPublisher) *CommentService {
+	return &CommentService{repo: repo, items: items, events: events}
+}
+
+func (s *CommentService) Create(userId, itemId int, input todo.CommentInput) (todo.Comment, error) {
+	id, mentioned, err := s.repo.Create(userId, itemId, input, parseMentions(input.Body))
+	if err != nil {
+		return todo.Comment{}, err
+	}
+
+	comment, err := s.repo.GetById(userId, id)
+	if err != nil {
+		return comment, err
+	}
+
+	s.publish(todo.EventCommentCreated, userId, comment, mentioned)
+	return comment, nil
+}
+
+func (s *CommentService) GetAll(userId, itemId, offset, limit int) ([]todo.Comment, int, error) {
+	return s.repo.GetAll(userId, itemId, offset, limit)
+}
+
+func (s *CommentService) GetById(userId, commentId int) (todo.Comment, error) {
+	return s.repo.GetById(userId, commentId)
+}
+
+func (s *CommentService) Update(userId, commentId int, input todo.UpdateCommentInput) (todo.Comment, error) {
+	mentioned, err := s.repo.Update(userId, commentId, input.Body, parseMentions(input.Body))
+	if err != nil {
+		return todo.Comment{}, err
+	}
+
+	comment, err := s.repo.GetById(userId, commentId)
+	if err != nil {
+		return comment, err
+	}
+
+	s.publish(todo.EventCommentUpdated, userId, comment, mentioned)
+	return comment, nil
+}
+
+func (s *CommentService) Delete(userId, commentId int) error {
+	if err := s.repo.Delete(userId, commentId); err != nil {
+		return err
+	}
+
+	// Удаленный комментарий остается в ветке, поэтому его можно прочитать для события
+	if comment, err := s.repo.GetById(userId, commentId); err == nil {
+		s.publish(todo.EventCommentDeleted, userId, comment, nil)
+	}
+	return nil
+}
+
+func (s *CommentService) GetEdits(userId, commentId int) ([]todo.CommentEdit, error) {
+	return s.repo.GetEdits(userId, commentId)
+}
+
+// publish публикует событие о комментарии; по упоминаниям в нем создаются уведомления
+func (s *CommentService) publish(eventType string, userId int, comment todo.Comment, mentioned []int) {
+	listId, err := s.items.GetListId(userId, comment.ItemId)
+	if err != nil {
+		return
+	}
+
+	data := todo.CommentEventData{Comment: comment, Mentioned: mentioned}
+	s.events.Publish(newEvent(eventType, userId, listId, comment.ItemId, data))
+}
+
+// parseMentions возвращает уникальные usernames, упомянутые в тексте вне кода
+func parseMentions(body string) []string {
+	body = codeBlockPattern.ReplaceAllString(body, " ")
+	body = codeSpanPattern.ReplaceAllString(body, " ")
+
+	seen := make(map[string]bool)
+	mentions := make([]string, 0)
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		username := match[1]
+		if seen[username] {
+			continue
+		}
+		seen[username] = true
+		mentions = append(mentions, username)
+		if len(mentions) == maxMentions {
+			break
+		}
+	}
+
+	return mentions
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"start of text", "@alice look", []string{"alice"}},
+		{"after punctuation", "thanks,@bob! and (@carol)", []string{"bob", "carol"}},
+		{"dots and dashes inside", "@first.last-name.", []string{"first.last-name"}},
+		{"duplicates", "@alice @alice", []string{"alice"}},
+		{"email", "mail me at user@example.com", []string{}},
+		{"code span", "`@alice` and @bob", []string{"bob"}},
+		{"code block", "```\n@alice\n```\n@bob", []string{"bob"}},
+		{"unclosed code block", "@bob\n~~~\n@alice", []string{"bob"}},
+		{"bare at", "@ alone", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseMentions(tt.body); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMentions(%q) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMentionsLimit(t *testing.T) {
+	var body strings.Builder
+	for i := 0; i < maxMentions+10; i++ {
+		body.WriteString(" @user")
+		body.WriteByte(byte('a' + i%26))
+		body.WriteByte(byte('a' + i/26))
+	}
+
+	if got := parseMentions(body.String()); len(got) != maxMentions {
+		t.Errorf("got %d mentions, want %d", len(got), maxMentions)
+	}
+}
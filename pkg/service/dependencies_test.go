@@ -0,0 +1,30 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ktuty/todo-app"
+)
+
+func TestSortDependencies(t *testing.T) {
+	// 4 блокирует 2 и 3, оба блокируют 1; 5 блокирует item вне графа
+	nodes := []todo.DependencyNode{
+		{ItemId: 1, BlockedByIds: todo.IdList{2, 3}},
+		{ItemId: 2, BlockedByIds: todo.IdList{4}},
+		{ItemId: 3, BlockedByIds: todo.IdList{4}},
+		{ItemId: 4},
+		{ItemId: 5, BlockedByIds: todo.IdList{99}},
+	}
+
+	sorted := sortDependencies(nodes)
+	ids := make([]int, len(sorted))
+	for i, node := range sorted {
+		ids[i] = node.ItemId
+	}
+
+	// При равных условиях items идут по id
+	if want := []int{4, 2, 3, 1, 5}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("order = %v, want %v", ids, want)
+	}
+}
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/mailer"
+	"github.com/ktuty/todo-app/pkg/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// Максимальное число items в одном разделе дайджеста
+const digestSectionLimit = 50
+
+//go:embed templates/digest.html templates/digest.txt
+var digestTemplates embed.FS
+
+// Текстовая версия письма собирается text/template: html/template экранировал бы
+// в ней символы как в HTML
+var (
+	digestHTML = htmltemplate.Must(htmltemplate.ParseFS(digestTemplates, "templates/digest.html"))
+	digestText = texttemplate.Must(texttemplate.ParseFS(digestTemplates, "templates/digest.txt"))
+)
+
+// DigestSchedulerConfig - настройки планировщика дайджестов
+type DigestSchedulerConfig struct {
+	PollInterval time.Duration // Период поиска пользователей, которым пора отправить дайджест
+	BatchSize    int           // Число дайджестов за один опрос
+	Timeout      time.Duration // Таймаут сборки и отправки одного дайджеста
+	RetryDelay   time.Duration // Задержка перед повторной отправкой после ошибки
+	MaxLateness  time.Duration // Насколько позже времени отправки дайджест еще отправляется
+}
+
+func (c DigestSchedulerConfig) withDefaults() DigestSchedulerConfig {
+	if c.PollInterval <= 0 {
+		c.PollInterval = time.Minute
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 20
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 30 * time.Second
+	}
+	if c.RetryDelay <= 0 {
+		c.RetryDelay = 10 * time.Minute
+	}
+	if c.MaxLateness <= 0 {
+		c.MaxLateness = 4 * time.Hour
+	}
+	return c
+}
+
+type DigestService struct {
+	repo   repository.Digests
+	mailer mailer.Mailer
+}
+
+func NewDigestService(repo repository.Digests, mailer mailer.Mailer) *DigestService {
+	return &DigestService{repo: repo, mailer: mailer}
+}
+
+func (s *DigestService) GetPreferences(userId int) (todo.DigestPreferences, error) {
+	return s.repo.GetPreferences(userId)
+}
+
+func (s *DigestService) UpdatePreferences(userId int, input todo.UpdateDigestPreferencesInput) (todo.DigestPreferences, error) {
+	if err := input.Validate(); err != nil {
+		return todo.DigestPreferences{}, err
+	}
+
+	if err := s.repo.UpdatePreferences(userId, input); err != nil {
+		return todo.DigestPreferences{}, err
+	}
+
+	return s.repo.GetPreferences(userId)
+}
+
+// Build собирает дайджест пользователя на текущий момент. Пустая frequency означает
+// периодичность из настроек пользователя
+func (s *DigestService) Build(userId int, frequency string) (todo.Digest, error) {
+	preferences, err := s.repo.GetPreferences(userId)
+	if err != nil {
+		return todo.Digest{}, err
+	}
+	if frequency == "" {
+		frequency = preferences.Frequency
+	}
+
+	return s.build(userId, frequency, preferences.TimeZone, time.Now())
+}
+
+// RenderHTML возвращает HTML-версию письма с дайджестом
+func (s *DigestService) RenderHTML(digest todo.Digest) (string, error) {
+	var buf bytes.Buffer
+	if err := digestHTML.Execute(&buf, newDigestView("", digest)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// build собирает дайджест на местную дату момента now. Daily показывает items со сроком
+// сегодня и выполненные вчера, weekly - со сроком в ближайшие 7 дней и выполненные за 7 дней
+func (s *DigestService) build(userId int, frequency, timeZone string, now time.Time) (todo.Digest, error) {
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	local := now.In(location)
+	dayStart := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+	days := 1
+	if frequency == todo.DigestWeekly {
+		days = 7
+	}
+
+	digest := todo.Digest{
+		Frequency: frequency,
+		Date:      dayStart.Format("2006-01-02"),
+		TimeZone:  location.String(),
+	}
+
+	if digest.Due, err = s.repo.GetDue(userId, dayStart, dayStart.AddDate(0, 0, days), digestSectionLimit); err != nil {
+		return todo.Digest{}, err
+	}
+	if digest.Overdue, err = s.repo.GetDue(userId, time.Time{}, dayStart, digestSectionLimit); err != nil {
+		return todo.Digest{}, err
+	}
+	if digest.Completed, err = s.repo.GetCompleted(userId, dayStart.AddDate(0, 0, -days), dayStart, digestSectionLimit); err != nil {
+		return todo.Digest{}, err
+	}
+
+	return digest, nil
+}
+
+// RunScheduler отправляет дайджесты до отмены ctx. Несколько реплик могут работать
+// одновременно: дайджест пользователя за дату захватывается одной из них
+func (s *DigestService) RunScheduler(ctx context.Context, config DigestSchedulerConfig) {
+	config = config.withDefaults()
+
+	ticker := time.NewTicker(config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for s.sendDue(ctx, config) == config.BatchSize {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendDue отправляет одну порцию дайджестов и возвращает их число
+func (s *DigestService) sendDue(ctx context.Context, config DigestSchedulerConfig) int {
+	if ctx.Err() != nil {
+		return 0
+	}
+
+	lease := config.Timeout*time.Duration(config.BatchSize) + config.PollInterval
+	deliveries, err := s.repo.ClaimDue(config.BatchSize, lease, config.MaxLateness)
+	if err != nil {
+		logrus.Errorf("digests: failed to claim digests: %s", err.Error())
+		return 0
+	}
+
+	for _, delivery := range deliveries {
+		s.send(ctx, config, delivery)
+	}
+	return len(deliveries)
+}
+
+// send собирает и отправляет дайджест. Пустой дайджест не отправляется,
+// но отмечается отправленным, чтобы не собирать его повторно в этот день
+func (s *DigestService) send(ctx context.Context, config DigestSchedulerConfig, delivery todo.DigestDelivery) {
+	sendCtx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	if err := s.deliver(sendCtx, delivery); err != nil {
+		if err := s.repo.MarkFailed(delivery.UserId, err.Error(), time.Now().Add(config.RetryDelay)); err != nil {
+			logrus.Errorf("digests: failed to mark digest for user %d failed: %s", delivery.UserId, err.Error())
+		}
+		return
+	}
+
+	if err := s.repo.MarkSent(delivery.UserId, delivery.Date); err != nil {
+		logrus.Errorf("digests: failed to mark digest for user %d sent: %s", delivery.UserId, err.Error())
+	}
+}
+
+func (s *DigestService) deliver(ctx context.Context, delivery todo.DigestDelivery) error {
+	digest, err := s.build(delivery.UserId, delivery.Frequency, delivery.TimeZone, time.Now())
+	if err != nil {
+		return err
+	}
+	if digest.IsEmpty() {
+		return nil
+	}
+
+	msg, err := digestMessage(delivery, digest)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, msg)
+}
+
+// digestMessage собирает письмо с дайджестом. Message-ID постоянен для пользователя
+// и даты, поэтому повторная отправка после сбоя распознается как то же письмо
+func digestMessage(delivery todo.DigestDelivery, digest todo.Digest) (mailer.Message, error) {
+	view := newDigestView(delivery.Name, digest)
+
+	var html, text bytes.Buffer
+	if err := digestHTML.Execute(&html, view); err != nil {
+		return mailer.Message{}, err
+	}
+	if err := digestText.Execute(&text, view); err != nil {
+		return mailer.Message{}, err
+	}
+
+	return mailer.Message{
+		To:      []string{delivery.Email},
+		Subject: view.Subject,
+		Text:    text.String(),
+		HTML:    html.String(),
+		Headers: map[string]string{
+			"Message-ID": fmt.Sprintf("<digest-%d-%s@todo-app>", delivery.UserId, digest.Date),
+		},
+	}, nil
+}
+
+// digestView - данные шаблонов дайджеста со временем в часовом поясе пользователя
+type digestView struct {
+	Subject   string
+	Name      string
+	Frequency string
+	Date      string
+	TimeZone  string
+	Sections  []digestSection
+}
+
+type digestSection struct {
+	Title string
+	Items []digestViewItem
+}
+
+type digestViewItem struct {
+	Title       string
+	ListTitle   string
+	Due         string
+	CompletedBy string
+	Completed   string
+}
+
+func newDigestView(name string, digest todo.Digest) digestView {
+	location, err := time.LoadLocation(digest.TimeZone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	dueTitle := "Due today"
+	completedTitle := "Completed yesterday"
+	if digest.Frequency == todo.DigestWeekly {
+		dueTitle = "Due this week"
+		completedTitle = "Completed last week"
+	}
+
+	view := digestView{
+		Subject:   "Your " + digest.Frequency + " digest for " + digest.Date,
+		Name:      name,
+		Frequency: digest.Frequency,
+		Date:      digest.Date,
+		TimeZone:  digest.TimeZone,
+	}
+	for _, section := range []struct {
+		title string
+		items []todo.DigestItem
+	}{
+		{"Overdue", digest.Overdue},
+		{dueTitle, digest.Due},
+		{completedTitle, digest.Completed},
+	} {
+		if len(section.items) == 0 {
+			continue
+		}
+
+		items := make([]digestViewItem, 0, len(section.items))
+		for _, item := range section.items {
+			items = append(items, digestViewItem{
+				Title:       item.Title,
+				ListTitle:   item.ListTitle,
+				Due:         formatDigestTime(item.DueAt, location),
+				CompletedBy: item.CompletedBy,
+				Completed:   formatDigestTime(item.CompletedAt, location),
+			})
+		}
+		view.Sections = append(view.Sections, digestSection{Title: section.title, Items: items})
+	}
+
+	return view
+}
+
+func formatDigestTime(t *time.Time, location *time.Location) string {
+	if t == nil {
+		return ""
+	}
+	return t.In(location).Format("Mon, 02 Jan 15:04")
+}
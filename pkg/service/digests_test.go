@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/mailer"
+	"github.com/ktuty/todo-app/pkg/repository"
+)
+
+// digestWindow - период выборки, запрошенный у репозитория
+type digestWindow struct {
+	from, to time.Time
+}
+
+// digestRepo запоминает периоды выборок и результат отправки
+type digestRepo struct {
+	repository.Digests
+	due       []digestWindow
+	completed []digestWindow
+	items     []todo.DigestItem
+	sent      []int
+	failed    []string
+}
+
+func (r *digestRepo) GetDue(userId int, from, to time.Time, limit int) ([]todo.DigestItem, error) {
+	r.due = append(r.due, digestWindow{from, to})
+	return r.items, nil
+}
+
+func (r *digestRepo) GetCompleted(userId int, from, to time.Time, limit int) ([]todo.DigestItem, error) {
+	r.completed = append(r.completed, digestWindow{from, to})
+	return nil, nil
+}
+
+func (r *digestRepo) MarkSent(userId int, date time.Time) error {
+	r.sent = append(r.sent, userId)
+	return nil
+}
+
+func (r *digestRepo) MarkFailed(userId int, reason string, retryAt time.Time) error {
+	r.failed = append(r.failed, reason)
+	return nil
+}
+
+// recordedMailer запоминает отправленные письма
+type recordedMailer struct {
+	messages []mailer.Message
+}
+
+func (m *recordedMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+func TestDigestWindows(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// В Нью-Йорке еще 1 марта
+	now := time.Date(2026, 3, 2, 1, 30, 0, 0, time.UTC)
+	dayStart := time.Date(2026, 3, 1, 0, 0, 0, 0, newYork)
+
+	tests := []struct {
+		frequency string
+		days      int
+	}{
+		{todo.DigestDaily, 1},
+		{todo.DigestWeekly, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.frequency, func(t *testing.T) {
+			repo := &digestRepo{}
+			s := NewDigestService(repo, nil)
+
+			digest, err := s.build(1, tt.frequency, "America/New_York", now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if digest.Date != "2026-03-01" {
+				t.Errorf("date = %s, want 2026-03-01", digest.Date)
+			}
+
+			if len(repo.due) != 2 || len(repo.completed) != 1 {
+				t.Fatalf("due queries = %d, completed queries = %d", len(repo.due), len(repo.completed))
+			}
+			if due := repo.due[0]; !due.from.Equal(dayStart) || !due.to.Equal(dayStart.AddDate(0, 0, tt.days)) {
+				t.Errorf("due window = %v - %v", due.from, due.to)
+			}
+			if overdue := repo.due[1]; !overdue.from.IsZero() || !overdue.to.Equal(dayStart) {
+				t.Errorf("overdue window = %v - %v", overdue.from, overdue.to)
+			}
+			if completed := repo.completed[0]; !completed.from.Equal(dayStart.AddDate(0, 0, -tt.days)) || !completed.to.Equal(dayStart) {
+				t.Errorf("completed window = %v - %v", completed.from, completed.to)
+			}
+		})
+	}
+}
+
+func TestDigestMessage(t *testing.T) {
+	due := time.Date(2026, 3, 1, 15, 0, 0, 0, time.UTC)
+	delivery := todo.DigestDelivery{UserId: 3, Name: "Alice", Email: "alice@example.com", Frequency: todo.DigestDaily}
+	digest := todo.Digest{
+		Frequency: todo.DigestDaily,
+		Date:      "2026-03-01",
+		TimeZone:  "Europe/Moscow",
+		Due:       []todo.DigestItem{{ItemId: 1, Title: "<b>Ship</b> & test", ListTitle: "Work", DueAt: &due}},
+	}
+
+	msg, err := digestMessage(delivery, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Subject != "Your daily digest for 2026-03-01" {
+		t.Errorf("subject = %q", msg.Subject)
+	}
+	if msg.Headers["Message-ID"] != "<digest-3-2026-03-01@todo-app>" {
+		t.Errorf("Message-ID = %q", msg.Headers["Message-ID"])
+	}
+
+	// Время показывается в часовом поясе пользователя; HTML экранируется только в HTML-версии
+	if want := "  - <b>Ship</b> & test [Work], due Sun, 01 Mar 18:00"; !strings.Contains(msg.Text, want) {
+		t.Errorf("text = %q, want it to contain %q", msg.Text, want)
+	}
+	if want := "<strong>&lt;b&gt;Ship&lt;/b&gt; &amp; test</strong>"; !strings.Contains(msg.HTML, want) {
+		t.Errorf("html = %q, want it to contain %q", msg.HTML, want)
+	}
+	if !strings.Contains(msg.Text, "Due today (1):") {
+		t.Errorf("text = %q, want the due section", msg.Text)
+	}
+}
+
+func TestEmptyDigestNotSent(t *testing.T) {
+	repo := &digestRepo{}
+	mail := &recordedMailer{}
+	s := NewDigestService(repo, mail)
+
+	s.send(context.Background(), DigestSchedulerConfig{}.withDefaults(), todo.DigestDelivery{
+		UserId: 3, Email: "alice@example.com", TimeZone: "UTC", Frequency: todo.DigestDaily,
+	})
+
+	// Пустой дайджест отмечается отправленным, чтобы не собирать его снова в этот день
+	if len(mail.messages) != 0 {
+		t.Errorf("sent %d messages for an empty digest", len(mail.messages))
+	}
+	if len(repo.sent) != 1 || len(repo.failed) != 0 {
+		t.Errorf("sent = %v, failed = %v", repo.sent, repo.failed)
+	}
+}
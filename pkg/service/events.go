@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// subscriberBuffer - размер буфера подписчика; отстающий подписчик отключается
+// и догоняет пропущенное по Last-Event-ID после переподключения
+const subscriberBuffer = 64
+
+// EventRetentionConfig - настройки удаления старых событий
+type EventRetentionConfig struct {
+	Retention    time.Duration // Срок хранения события
+	PollInterval time.Duration // Период проверки
+	BatchSize    int           // Число событий, удаляемых одним запросом
+}
+
+func (c EventRetentionConfig) withDefaults() EventRetentionConfig {
+	if c.Retention <= 0 {
+		c.Retention = 7 * 24 * time.Hour
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = time.Hour
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 1000
+	}
+	return c
+}
+
+// EventService сохраняет доменные события и раздает их подписчикам потока.
+// Доставка подписчикам идет через LISTEN/NOTIFY, поэтому каждая реплика
+// получает события, опубликованные любой другой
+type EventService struct {
+	repo repository.Events
+
+	handlers []EventHandler
+
+	mu          sync.RWMutex
+	subscribers map[int]map[chan todo.Event]struct{}
+}
+
+func NewEventService(repo repository.Events) *EventService {
+	return &EventService{
+		repo:        repo,
+		subscribers: make(map[int]map[chan todo.Event]struct{}),
+	}
+}
+
+// Publish сохраняет событие. Ошибки публикации не отменяют уже выполненное изменение,
+// поэтому только логируются
+func (s *EventService) Publish(event todo.Event) {
+	if event.Audience == nil && event.ListId > 0 {
+		audience, err := s.repo.GetListAudience(event.ListId)
+		if err != nil {
+			logrus.Errorf("failed to resolve audience for %s: %s", event.Type, err.Error())
+			return
+		}
+		event.Audience = audience
+	}
+
+	id, err := s.repo.Create(event)
+	if err != nil {
+		logrus.Errorf("failed to publish %s: %s", event.Type, err.Error())
+		return
+	}
+	event.Id = id
+
+	for _, handler := range s.handlers {
+		handler.HandleEvent(event)
+	}
+}
+
+// AddHandler регистрирует обработчик событий. Обработчики вызываются только на реплике,
+// опубликовавшей событие, поэтому каждое событие обрабатывается один раз.
+// Регистрировать обработчики нужно до начала работы сервиса
+func (s *EventService) AddHandler(handler EventHandler) {
+	s.handlers = append(s.handlers, handler)
+}
+
+// ListAudience возвращает пользователей с доступом к списку; нужен, чтобы
+// зафиксировать аудиторию события до удаления списка
+func (s *EventService) ListAudience(listId int) ([]int, error) {
+	return s.repo.GetListAudience(listId)
+}
+
+func (s *EventService) GetSince(userId int, afterId int64, limit int) ([]todo.Event, error) {
+	return s.repo.GetSince(userId, afterId, limit)
+}
+
+// RunRetention удаляет события старше срока хранения до отмены ctx
+func (s *EventService) RunRetention(ctx context.Context, config EventRetentionConfig) {
+	config = config.withDefaults()
+
+	ticker := time.NewTicker(config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for s.deleteExpired(ctx, config) == int64(config.BatchSize) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deleteExpired удаляет одну порцию устаревших событий и возвращает их число
+func (s *EventService) deleteExpired(ctx context.Context, config EventRetentionConfig) int64 {
+	if ctx.Err() != nil {
+		return 0
+	}
+
+	deleted, err := s.repo.DeleteBefore(time.Now().Add(-config.Retention), config.BatchSize)
+	if err != nil {
+		logrus.Errorf("events: failed to delete expired events: %s", err.Error())
+		return 0
+	}
+	return deleted
+}
+
+// Subscribe регистрирует подписчика на события пользователя.
+// Канал закрывается при отписке или если подписчик не успевает читать события
+func (s *EventService) Subscribe(userId int) (<-chan todo.Event, func()) {
+	ch := make(chan todo.Event, subscriberBuffer)
+
+	s.mu.Lock()
+	if s.subscribers[userId] == nil {
+		s.subscribers[userId] = make(map[chan todo.Event]struct{})
+	}
+	s.subscribers[userId][ch] = struct{}{}
+	s.mu.Unlock()
+
+	return ch, func() { s.unsubscribe(userId, ch) }
+}
+
+func (s *EventService) unsubscribe(userId int, ch chan todo.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subscribers[userId][ch]; !ok {
+		return
+	}
+	delete(s.subscribers[userId], ch)
+	if len(s.subscribers[userId]) == 0 {
+		delete(s.subscribers, userId)
+	}
+	close(ch)
+}
+
+// Dispatch читает id событий из LISTEN/NOTIFY и рассылает их подписчикам
+// этой реплики. Работает до закрытия канала ids
+func (s *EventService) Dispatch(ids <-chan int64) {
+	for id := range ids {
+		event, err := s.repo.GetById(id)
+		if err != nil {
+			logrus.Errorf("failed to load event %d: %s", id, err.Error())
+			continue
+		}
+		s.deliver(event)
+	}
+}
+
+func (s *EventService) deliver(event todo.Event) {
+	var slow []func()
+
+	s.mu.RLock()
+	for _, userId := range event.Audience {
+		for ch := range s.subscribers[userId] {
+			select {
+			case ch <- event:
+			default:
+				userId, ch := userId, ch
+				slow = append(slow, func() { s.unsubscribe(userId, ch) })
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, drop := range slow {
+		drop()
+	}
+}
+
+// newEvent собирает событие со снимком ресурса в data
+func newEvent(eventType string, actorId, listId, itemId int, data interface{}) todo.Event {
+	event := todo.Event{
+		Type:    eventType,
+		ActorId: actorId,
+		ListId:  listId,
+		ItemId:  itemId,
+	}
+
+	if data != nil {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			logrus.Errorf("failed to marshal %s payload: %s", eventType, err.Error())
+		} else {
+			event.Data = payload
+		}
+	}
+
+	return event
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/repository"
+)
+
+// expiringEventRepo удаляет события порциями из remaining
+type expiringEventRepo struct {
+	repository.Events
+	remaining int64
+	before    []time.Time
+}
+
+func (r *expiringEventRepo) DeleteBefore(before time.Time, limit int) (int64, error) {
+	r.before = append(r.before, before)
+	deleted := r.remaining
+	if deleted > int64(limit) {
+		deleted = int64(limit)
+	}
+	r.remaining -= deleted
+	return deleted, nil
+}
+
+func TestEventRetention(t *testing.T) {
+	repo := &expiringEventRepo{remaining: 5}
+	s := NewEventService(repo)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.RunRetention(ctx, EventRetentionConfig{Retention: time.Hour, PollInterval: time.Hour, BatchSize: 2})
+		close(done)
+	}()
+
+	// Порции 2, 2 и неполная 1 удаляются подряд, без ожидания следующего тика
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RunRetention did not stop")
+	}
+
+	if repo.remaining != 0 || len(repo.before) != 3 {
+		t.Fatalf("remaining = %d after %d calls", repo.remaining, len(repo.before))
+	}
+	if cutoff := time.Since(repo.before[0]); cutoff < time.Hour || cutoff > time.Hour+time.Minute {
+		t.Errorf("cutoff = now - %s, want now - 1h", cutoff)
+	}
+}
+
+func TestEventServiceDeliver(t *testing.T) {
+	s := NewEventService(nil)
+	first, unsubscribe := s.Subscribe(1)
+	defer unsubscribe()
+	other, unsubscribeOther := s.Subscribe(2)
+	defer unsubscribeOther()
+
+	s.deliver(todo.Event{Id: 1, Audience: []int{1}})
+	if event := <-first; event.Id != 1 {
+		t.Errorf("event = %+v", event)
+	}
+	select {
+	case event := <-other:
+		t.Errorf("user outside audience got %+v", event)
+	default:
+	}
+
+	// Отстающий подписчик отключается, а не блокирует рассылку
+	for i := 0; i <= subscriberBuffer; i++ {
+		s.deliver(todo.Event{Id: int64(i + 2), Audience: []int{1}})
+	}
+	received := 0
+	for range first {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("received %d events before disconnect, want %d", received, subscriberBuffer)
+	}
+}
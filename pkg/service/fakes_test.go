@@ -0,0 +1,27 @@
+package service
+
+import (
+	"github.com/ktuty/todo-app"
+)
+
+// recordedEvents запоминает опубликованные события вместо рассылки
+type recordedEvents struct {
+	events   []todo.Event
+	audience []int
+}
+
+func (e *recordedEvents) Publish(event todo.Event) {
+	e.events = append(e.events, event)
+}
+
+func (e *recordedEvents) ListAudience(listId int) ([]int, error) {
+	return e.audience, nil
+}
+
+func (e *recordedEvents) types() []string {
+	types := make([]string, len(e.events))
+	for i, event := range e.events {
+		types[i] = event.Type
+	}
+	return types
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/repository"
+)
+
+type FolderService struct {
+	repo  repository.Folders
+	lists TodoList
+}
+
+func NewFolderService(repo repository.Folders, lists TodoList) *FolderService {
+	return &FolderService{repo: repo, lists: lists}
+}
+
+func (s *FolderService) Create(userId int, input todo.FolderInput) (todo.Folder, error) {
+	id, err := s.repo.Create(userId, input)
+	if err != nil {
+		return todo.Folder{}, err
+	}
+
+	return s.repo.GetById(userId, id)
+}
+
+func (s *FolderService) GetById(userId, folderId int) (todo.Folder, error) {
+	return s.repo.GetById(userId, folderId)
+}
+
+func (s *FolderService) Update(userId, folderId int, input todo.UpdateFolderInput) (todo.Folder, error) {
+	if err := input.Validate(); err != nil {
+		return todo.Folder{}, err
+	}
+
+	if err := s.repo.Update(userId, folderId, input); err != nil {
+		return todo.Folder{}, err
+	}
+
+	return s.repo.GetById(userId, folderId)
+}
+
+// Delete удаляет папку. В режиме cascade списки папки и вложенных папок удаляются
+// по одному, как при DELETE /lists/:id, с событиями и аудитом; список, к которому
+// уже нет доступа, пропускается. Если удаление прервется, папка останется на месте
+// с еще не удаленными списками, и его можно повторить
+func (s *FolderService) Delete(userId, folderId int, mode string) error {
+	cascade := mode == todo.FolderDeleteCascade
+	if cascade {
+		listIds, err := s.repo.GetListIds(userId, folderId)
+		if err != nil {
+			return err
+		}
+
+		for _, listId := range listIds {
+			if err := s.lists.Delete(userId, listId); err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+		}
+	}
+
+	return s.repo.Delete(userId, folderId, cascade)
+}
+
+// GetTree собирает дерево папок и списков пользователя с числом items в каждой папке
+func (s *FolderService) GetTree(userId int) (todo.FolderTree, error) {
+	rows, err := s.repo.GetTree(userId)
+	if err != nil {
+		return todo.FolderTree{}, err
+	}
+
+	folders := make(map[int]todo.FolderTreeRow)
+	children := make(map[int][]int) // папка -> вложенные папки, 0 - корень
+	lists := make(map[int][]todo.FolderListNode)
+	for _, row := range rows {
+		parentId := 0
+		if row.ParentId != nil {
+			parentId = *row.ParentId
+		}
+
+		switch row.Kind {
+		case todo.FolderTreeFolder:
+			folders[row.Id] = row
+			children[parentId] = append(children[parentId], row.Id)
+		case todo.FolderTreeList:
+			lists[parentId] = append(lists[parentId], todo.FolderListNode{Id: row.Id, Title: row.Name, ItemCount: row.ItemCount})
+		}
+	}
+
+	var build func(folderId int) ([]todo.FolderNode, []todo.FolderListNode, int)
+	build = func(folderId int) ([]todo.FolderNode, []todo.FolderListNode, int) {
+		nodes := make([]todo.FolderNode, 0, len(children[folderId]))
+		total := 0
+		for _, childId := range children[folderId] {
+			node := todo.FolderNode{Id: childId, Name: folders[childId].Name}
+			node.Folders, node.Lists, node.ItemCount = build(childId)
+			nodes = append(nodes, node)
+			total += node.ItemCount
+		}
+
+		folderLists := lists[folderId]
+		if folderLists == nil {
+			folderLists = make([]todo.FolderListNode, 0)
+		}
+		for _, list := range folderLists {
+			total += list.ItemCount
+		}
+
+		return nodes, folderLists, total
+	}
+
+	var tree todo.FolderTree
+	tree.Folders, tree.Lists, tree.ItemCount = build(0)
+	return tree, nil
+}
+
+// MoveList кладет список в папку текущего пользователя и возвращает список
+func (s *FolderService) MoveList(userId, listId int, folderId *int) (todo.TodoList, error) {
+	if err := s.repo.MoveList(userId, listId, folderId); err != nil {
+		return todo.TodoList{}, err
+	}
+
+	return s.lists.GetById(userId, listId)
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/repository"
+)
+
+// folderRepo отдает заранее заданные строки дерева и списки папки и запоминает удаление
+type folderRepo struct {
+	repository.Folders
+	rows    []todo.FolderTreeRow
+	listIds []int
+	deleted []bool // cascade каждого удаления
+}
+
+func (r *folderRepo) GetTree(userId int) ([]todo.FolderTreeRow, error) {
+	return r.rows, nil
+}
+
+func (r *folderRepo) GetListIds(userId, folderId int) ([]int, error) {
+	return r.listIds, nil
+}
+
+func (r *folderRepo) Delete(userId, folderId int, cascade bool) error {
+	r.deleted = append(r.deleted, cascade)
+	return nil
+}
+
+// deletedLists запоминает удаленные списки; списки из missing уже недоступны
+type deletedLists struct {
+	TodoList
+	missing map[int]bool
+	deleted []int
+}
+
+func (l *deletedLists) Delete(userId, listId int) error {
+	if l.missing[listId] {
+		return sql.ErrNoRows
+	}
+	l.deleted = append(l.deleted, listId)
+	return nil
+}
+
+func TestFolderTree(t *testing.T) {
+	work, projects := 1, 2
+	repo := &folderRepo{rows: []todo.FolderTreeRow{
+		{Kind: todo.FolderTreeFolder, Id: projects, ParentId: &work, Name: "Projects"},
+		{Kind: todo.FolderTreeFolder, Id: work, Name: "Work"},
+		{Kind: todo.FolderTreeFolder, Id: 3, Name: "Empty"},
+		{Kind: todo.FolderTreeList, Id: 10, ParentId: &projects, Name: "Launch", ItemCount: 4},
+		{Kind: todo.FolderTreeList, Id: 11, ParentId: &work, Name: "Inbox", ItemCount: 2},
+		{Kind: todo.FolderTreeList, Id: 12, Name: "Home", ItemCount: 1},
+	}}
+	s := NewFolderService(repo, nil)
+
+	tree, err := s.GetTree(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Число items папки включает вложенные папки
+	if tree.ItemCount != 7 || len(tree.Lists) != 1 || tree.Lists[0].Id != 12 {
+		t.Fatalf("root: count %d, lists %+v", tree.ItemCount, tree.Lists)
+	}
+	if len(tree.Folders) != 2 {
+		t.Fatalf("root folders = %+v", tree.Folders)
+	}
+	workNode := tree.Folders[0]
+	if workNode.Id != work || workNode.ItemCount != 6 || len(workNode.Lists) != 1 || len(workNode.Folders) != 1 {
+		t.Errorf("work folder = %+v", workNode)
+	}
+	if projectsNode := workNode.Folders[0]; projectsNode.Id != projects || projectsNode.ItemCount != 4 {
+		t.Errorf("projects folder = %+v", projectsNode)
+	}
+
+	// Пустые папки отдаются с пустыми массивами, а не null
+	empty := tree.Folders[1]
+	if empty.ItemCount != 0 || empty.Folders == nil || empty.Lists == nil {
+		t.Errorf("empty folder = %+v", empty)
+	}
+}
+
+func TestDeleteFolderCascade(t *testing.T) {
+	repo := &folderRepo{listIds: []int{10, 11, 12}}
+	lists := &deletedLists{missing: map[int]bool{11: true}}
+	s := NewFolderService(repo, lists)
+
+	if err := s.Delete(1, 5, todo.FolderDeleteCascade); err != nil {
+		t.Fatal(err)
+	}
+
+	// Недоступный список пропускается
+	if !reflect.DeepEqual(lists.deleted, []int{10, 12}) {
+		t.Errorf("deleted lists = %v, want [10 12]", lists.deleted)
+	}
+	if !reflect.DeepEqual(repo.deleted, []bool{true}) {
+		t.Errorf("folder deletes = %v", repo.deleted)
+	}
+}
+
+func TestDeleteFolderReparent(t *testing.T) {
+	repo := &folderRepo{listIds: []int{10}}
+	lists := &deletedLists{}
+	s := NewFolderService(repo, lists)
+
+	if err := s.Delete(1, 5, todo.FolderDeleteReparent); err != nil {
+		t.Fatal(err)
+	}
+	if len(lists.deleted) != 0 || !reflect.DeepEqual(repo.deleted, []bool{false}) {
+		t.Errorf("deleted lists = %v, folder deletes = %v", lists.deleted, repo.deleted)
+	}
+}
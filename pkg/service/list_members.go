@@ -0,0 +1,76 @@
+package service
+
+import (
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/repository"
+)
+
+type ListMemberService struct {
+	repo   repository.ListMembers
+	events EventPublisher
+}
+
+func NewListMemberService(repo repository.ListMembers, events EventPublisher) *ListMemberService {
+	return &ListMemberService{repo: repo, events: events}
+}
+
+// withRequest возвращает копию сервиса, изменения через которую записываются в журнал
+// аудита от имени автора запроса
+func (s *ListMemberService) withRequest(meta todo.RequestMeta) *ListMemberService {
+	scoped := *s
+	scoped.repo = s.repo.WithRequest(meta)
+	return &scoped
+}
+
+func (s *ListMemberService) GetAll(userId, listId int) ([]todo.ListMember, error) {
+	return s.repo.GetAll(userId, listId)
+}
+
+// Add открывает доступ к списку; событие list.shared публикуется, только если доступа не было
+func (s *ListMemberService) Add(userId, listId int, username string) (todo.ListMember, bool, error) {
+	member, added, err := s.repo.Add(userId, listId, username)
+	if err != nil || !added {
+		return member, added, err
+	}
+
+	s.events.Publish(newEvent(todo.EventListShared, userId, listId, 0, member))
+	return member, true, nil
+}
+
+// Remove закрывает доступ к списку. Аудитория событий фиксируется до удаления,
+// чтобы о потере доступа и снятии с items узнал и сам удаленный участник
+func (s *ListMemberService) Remove(userId, listId, memberId int) error {
+	members, err := s.repo.GetAll(userId, listId)
+	if err != nil {
+		return err
+	}
+
+	audience, err := s.events.ListAudience(listId)
+	if err != nil {
+		return err
+	}
+
+	items, err := s.repo.Remove(userId, listId, memberId)
+	if err != nil {
+		return err
+	}
+
+	member := todo.ListMember{UserId: memberId}
+	for _, m := range members {
+		if m.UserId == memberId {
+			member = m
+		}
+	}
+
+	event := newEvent(todo.EventListUnshared, userId, listId, 0, member)
+	event.Audience = audience
+	s.events.Publish(event)
+
+	for _, item := range items {
+		data := todo.AssignmentEventData{TodoItem: item, AssigneeId: memberId}
+		event := newEvent(todo.EventItemUnassigned, userId, listId, item.Id, data)
+		event.Audience = audience
+		s.events.Publish(event)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/repository"
+)
+
+// removeMemberRepo снимает удаленного участника с одного item
+type removeMemberRepo struct {
+	repository.ListMembers
+}
+
+func (r *removeMemberRepo) GetAll(userId, listId int) ([]todo.ListMember, error) {
+	return []todo.ListMember{{UserId: 1, Username: "owner"}, {UserId: 2, Username: "bob"}}, nil
+}
+
+func (r *removeMemberRepo) Remove(userId, listId, memberId int) ([]todo.TodoItem, error) {
+	return []todo.TodoItem{{Id: 5}}, nil
+}
+
+func TestRemoveListMember(t *testing.T) {
+	events := &recordedEvents{audience: []int{1, 2}}
+	s := NewListMemberService(&removeMemberRepo{}, events)
+
+	if err := s.Remove(1, 3, 2); err != nil {
+		t.Fatal(err)
+	}
+	if types := events.types(); !reflect.DeepEqual(types, []string{todo.EventListUnshared, todo.EventItemUnassigned}) {
+		t.Fatalf("events = %v", types)
+	}
+
+	// Удаленный участник видит снятие с item и получает уведомление unassigned
+	unassigned := events.events[1]
+	if unassigned.ItemId != 5 || unassigned.ListId != 3 || !unassigned.VisibleTo(2) {
+		t.Errorf("item.unassigned = %+v", unassigned)
+	}
+	notifications := notificationsFor(unassigned)
+	if len(notifications) != 1 || notifications[0].UserId != 2 || notifications[0].Type != todo.NotificationUnassigned {
+		t.Errorf("notifications = %+v", notifications)
+	}
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"encoding/json"
+
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// NotificationService ведет входящие уведомления пользователей. Уведомления создаются
+// из доменных событий, которые публикуют остальные сервисы
+type NotificationService struct {
+	repo repository.Notifications
+}
+
+func NewNotificationService(repo repository.Notifications) *NotificationService {
+	return &NotificationService{repo: repo}
+}
+
+// HandleEvent создает уведомления по событию. Ошибка не отменяет уже выполненное
+// изменение, поэтому только логируется
+func (s *NotificationService) HandleEvent(event todo.Event) {
+	notifications := notificationsFor(event)
+	if len(notifications) == 0 {
+		return
+	}
+
+	if err := s.repo.Create(notifications); err != nil {
+		logrus.Errorf("failed to create notifications for %s: %s", event.Type, err.Error())
+	}
+}
+
+func (s *NotificationService) GetAll(userId int, unreadOnly bool, offset, limit int) ([]todo.Notification, int, error) {
+	return s.repo.GetAll(userId, unreadOnly, offset, limit)
+}
+
+func (s *NotificationService) CountUnread(userId int) (int, error) {
+	return s.repo.CountUnread(userId)
+}
+
+func (s *NotificationService) MarkRead(userId int, notificationId int64) error {
+	return s.repo.MarkRead(userId, notificationId)
+}
+
+func (s *NotificationService) MarkAllRead(userId int) (int64, error) {
+	return s.repo.MarkAllRead(userId)
+}
+
+// GetPreferences возвращает настройки по всем типам уведомлений; по умолчанию типы включены
+func (s *NotificationService) GetPreferences(userId int) (map[string]bool, error) {
+	saved, err := s.repo.GetPreferences(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	preferences := make(map[string]bool, len(todo.NotificationTypes))
+	for _, notificationType := range todo.NotificationTypes {
+		enabled, ok := saved[notificationType]
+		preferences[notificationType] = enabled || !ok
+	}
+	return preferences, nil
+}
+
+// SetPreferences сохраняет переданные настройки, не трогая остальные типы
+func (s *NotificationService) SetPreferences(userId int, preferences map[string]bool) (map[string]bool, error) {
+	if err := s.repo.SetPreferences(userId, preferences); err != nil {
+		return nil, err
+	}
+
+	return s.GetPreferences(userId)
+}
+
+// notificationsFor возвращает уведомления, которые порождает событие.
+// Пользователь не получает уведомлений о собственных действиях
+func notificationsFor(event todo.Event) []todo.Notification {
+	var notifications []todo.Notification
+	notify := func(userId int, notificationType string, commentId int) {
+		if userId == event.ActorId {
+			return
+		}
+		notifications = append(notifications, todo.Notification{
+			UserId:    userId,
+			Type:      notificationType,
+			ActorId:   event.ActorId,
+			ListId:    event.ListId,
+			ItemId:    event.ItemId,
+			CommentId: commentId,
+		})
+	}
+
+	switch event.Type {
+	case todo.EventCommentCreated, todo.EventCommentUpdated:
+		var data todo.CommentEventData
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			return nil
+		}
+		for _, userId := range data.Mentioned {
+			notify(userId, todo.NotificationMention, data.Id)
+		}
+	case todo.EventListShared:
+		var member todo.ListMember
+		if err := json.Unmarshal(event.Data, &member); err != nil {
+			return nil
+		}
+		notify(member.UserId, todo.NotificationListShared, 0)
+	case todo.EventItemAssigned, todo.EventItemUnassigned:
+		var data todo.AssignmentEventData
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			return nil
+		}
+		notificationType := todo.NotificationAssigned
+		if event.Type == todo.EventItemUnassigned {
+			notificationType = todo.NotificationUnassigned
+		}
+		notify(data.AssigneeId, notificationType, 0)
+	case todo.EventItemDueSoon:
+		var data todo.DueSoonEventData
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			return nil
+		}
+		notify(data.UserId, todo.NotificationDueSoon, 0)
+	}
+
+	return notifications
+}
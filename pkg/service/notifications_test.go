@@ -0,0 +1,57 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ktuty/todo-app"
+)
+
+func TestAssignmentNotifications(t *testing.T) {
+	tests := []struct {
+		name       string
+		eventType  string
+		assigneeId int
+		want       string
+	}{
+		{"assigned", todo.EventItemAssigned, 2, todo.NotificationAssigned},
+		{"unassigned", todo.EventItemUnassigned, 2, todo.NotificationUnassigned},
+		{"self", todo.EventItemAssigned, 1, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := todo.AssignmentEventData{TodoItem: todo.TodoItem{Id: 5}, AssigneeId: tt.assigneeId}
+			notifications := notificationsFor(newEvent(tt.eventType, 1, 3, 5, data))
+
+			// Пользователь не получает уведомлений о собственных действиях
+			if tt.want == "" {
+				if len(notifications) != 0 {
+					t.Errorf("notifications = %+v, want none", notifications)
+				}
+				return
+			}
+			if len(notifications) != 1 {
+				t.Fatalf("notifications = %+v, want one", notifications)
+			}
+			if n := notifications[0]; n.UserId != tt.assigneeId || n.Type != tt.want || n.ActorId != 1 || n.ListId != 3 || n.ItemId != 5 {
+				t.Errorf("notification = %+v", n)
+			}
+		})
+	}
+}
+
+func TestDueSoonNotification(t *testing.T) {
+	data := todo.DueSoonEventData{UserId: 2, DueAt: time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)}
+	notifications := notificationsFor(newEvent(todo.EventItemDueSoon, 0, 3, 5, data))
+
+	if len(notifications) != 1 {
+		t.Fatalf("notifications = %+v, want one", notifications)
+	}
+	if n := notifications[0]; n.UserId != 2 || n.Type != todo.NotificationDueSoon || n.ActorId != 0 || n.ListId != 3 || n.ItemId != 5 {
+		t.Errorf("notification = %+v", n)
+	}
+	if !todo.IsNotificationType(todo.NotificationDueSoon) {
+		t.Error("due_soon can't be turned off")
+	}
+}
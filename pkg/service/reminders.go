@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/mailer"
+	"github.com/ktuty/todo-app/pkg/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// ReminderSchedulerConfig - настройки планировщика напоминаний
+type ReminderSchedulerConfig struct {
+	PollInterval time.Duration // Период поиска сработавших напоминаний
+	BatchSize    int           // Число напоминаний за один опрос
+	Timeout      time.Duration // Таймаут отправки одного письма
+	RetryDelay   time.Duration // Задержка перед повторной отправкой после ошибки
+	MaxLateness  time.Duration // Напоминания, опоздавшие сильнее (например, после простоя), не отправляются
+}
+
+func (c ReminderSchedulerConfig) withDefaults() ReminderSchedulerConfig {
+	if c.PollInterval <= 0 {
+		c.PollInterval = 30 * time.Second
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 50
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 30 * time.Second
+	}
+	if c.RetryDelay <= 0 {
+		c.RetryDelay = 5 * time.Minute
+	}
+	if c.MaxLateness <= 0 {
+		c.MaxLateness = 6 * time.Hour
+	}
+	return c
+}
+
+type ReminderService struct {
+	repo   repository.Reminders
+	mailer mailer.Mailer
+	events EventPublisher
+}
+
+func NewReminderService(repo repository.Reminders, mailer mailer.Mailer, events EventPublisher) *ReminderService {
+	return &ReminderService{repo: repo, mailer: mailer, events: events}
+}
+
+func (s *ReminderService) Create(userId, itemId int, input todo.ReminderInput) (todo.Reminder, error) {
+	id, err := s.repo.Create(userId, itemId, input)
+	if err != nil {
+		return todo.Reminder{}, err
+	}
+
+	return s.repo.GetById(userId, id)
+}
+
+func (s *ReminderService) GetAll(userId, itemId int) ([]todo.Reminder, error) {
+	return s.repo.GetAll(userId, itemId)
+}
+
+func (s *ReminderService) Delete(userId, reminderId int) error {
+	return s.repo.Delete(userId, reminderId)
+}
+
+// RunScheduler отправляет сработавшие напоминания до отмены ctx. Несколько реплик могут
+// работать одновременно: каждое напоминание захватывается одной из них
+func (s *ReminderService) RunScheduler(ctx context.Context, config ReminderSchedulerConfig) {
+	config = config.withDefaults()
+
+	ticker := time.NewTicker(config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for s.sendDue(ctx, config) == config.BatchSize {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendDue отправляет одну порцию напоминаний и возвращает их число
+func (s *ReminderService) sendDue(ctx context.Context, config ReminderSchedulerConfig) int {
+	if ctx.Err() != nil {
+		return 0
+	}
+
+	lease := config.Timeout*time.Duration(config.BatchSize) + config.PollInterval
+	deliveries, err := s.repo.ClaimDue(config.BatchSize, lease, config.MaxLateness)
+	if err != nil {
+		logrus.Errorf("reminders: failed to claim reminders: %s", err.Error())
+		return 0
+	}
+
+	for _, delivery := range deliveries {
+		s.send(ctx, config, delivery)
+	}
+	return len(deliveries)
+}
+
+// send отправляет письмо напоминания и публикует item.due_soon, по которому пользователь
+// получает уведомление. Без email отправляется только уведомление; после ошибки письма
+// напоминание повторяется, а уведомление создается после успешной отправки
+func (s *ReminderService) send(ctx context.Context, config ReminderSchedulerConfig, delivery todo.ReminderDelivery) {
+	if delivery.Email != "" {
+		if err := s.mail(ctx, config, delivery); err != nil {
+			if err := s.repo.MarkFailed(delivery.ReminderId, err.Error(), time.Now().Add(config.RetryDelay)); err != nil {
+				logrus.Errorf("reminders: failed to mark reminder %d failed: %s", delivery.ReminderId, err.Error())
+			}
+			return
+		}
+	}
+
+	if err := s.repo.MarkSent(delivery.ReminderId, delivery.FireAt); err != nil {
+		logrus.Errorf("reminders: failed to mark reminder %d sent: %s", delivery.ReminderId, err.Error())
+		return
+	}
+
+	// Событие адресовано только владельцу напоминания
+	event := newEvent(todo.EventItemDueSoon, 0, delivery.ListId, delivery.ItemId,
+		todo.DueSoonEventData{UserId: delivery.UserId, DueAt: delivery.DueAt})
+	event.Audience = []int{delivery.UserId}
+	s.events.Publish(event)
+}
+
+func (s *ReminderService) mail(ctx context.Context, config ReminderSchedulerConfig, delivery todo.ReminderDelivery) error {
+	sendCtx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	return s.mailer.Send(sendCtx, reminderMessage(delivery))
+}
+
+// reminderMessage собирает письмо напоминания; срок показывается в часовом поясе пользователя.
+// Message-ID постоянен для одного срабатывания, поэтому повторная отправка после сбоя
+// распознается почтовым клиентом как то же письмо
+func reminderMessage(delivery todo.ReminderDelivery) mailer.Message {
+	location, err := time.LoadLocation(delivery.TimeZone)
+	if err != nil {
+		location = time.UTC
+	}
+	due := delivery.DueAt.In(location).Format("Mon, 02 Jan 2006 15:04 MST")
+
+	return mailer.Message{
+		To:      []string{delivery.Email},
+		Subject: fmt.Sprintf("Reminder: %s is due %s", delivery.ItemTitle, due),
+		Text: fmt.Sprintf("Hi %s,\n\n\"%s\" in list \"%s\" is due %s.\n",
+			delivery.Name, delivery.ItemTitle, delivery.ListTitle, due),
+		Headers: map[string]string{
+			"Message-ID": fmt.Sprintf("<reminder-%d-%d@todo-app>", delivery.ReminderId, delivery.FireAt.Unix()),
+		},
+	}
+}
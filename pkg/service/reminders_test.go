@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata" // Часовой пояс пользователя не должен зависеть от системной базы
+
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/mailer"
+	"github.com/ktuty/todo-app/pkg/repository"
+)
+
+// smtpMessage - письмо, принятое тестовым SMTP-сервером
+type smtpMessage struct {
+	from string
+	to   []string
+	data []byte
+}
+
+// fakeSMTPServer принимает письма по минимальному подмножеству SMTP без STARTTLS
+// и аутентификации и передает их в канал
+type fakeSMTPServer struct {
+	listener net.Listener
+	messages chan smtpMessage
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeSMTPServer{listener: listener, messages: make(chan smtpMessage, 10)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+
+	var msg smtpMessage
+	text.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			text.PrintfLine("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			msg = smtpMessage{from: smtpAddress(line)}
+			text.PrintfLine("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			msg.to = append(msg.to, smtpAddress(line))
+			text.PrintfLine("250 OK")
+		case command == "DATA":
+			text.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.data = data
+			s.messages <- msg
+			text.PrintfLine("250 OK")
+		case command == "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) port() string {
+	return strconv.Itoa(s.listener.Addr().(*net.TCPAddr).Port)
+}
+
+func smtpAddress(line string) string {
+	start, end := strings.Index(line, "<"), strings.LastIndex(line, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
+
+// dueReminderRepo отдает одно сработавшее напоминание и запоминает результат отправки
+type dueReminderRepo struct {
+	repository.Reminders
+	delivery todo.ReminderDelivery
+	claimed  bool
+	sent     chan int
+	failed   chan string
+}
+
+func (r *dueReminderRepo) ClaimDue(limit int, lease, maxLateness time.Duration) ([]todo.ReminderDelivery, error) {
+	if r.claimed {
+		return nil, nil
+	}
+	r.claimed = true
+	return []todo.ReminderDelivery{r.delivery}, nil
+}
+
+func (r *dueReminderRepo) MarkSent(reminderId int, fireAt time.Time) error {
+	r.sent <- reminderId
+	return nil
+}
+
+func (r *dueReminderRepo) MarkFailed(reminderId int, reason string, retryAt time.Time) error {
+	r.failed <- reason
+	return nil
+}
+
+func TestReminderSentOverSMTP(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	from, err := mail.ParseAddress("Todo <todo@example.com>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := &dueReminderRepo{
+		delivery: todo.ReminderDelivery{
+			ReminderId: 7,
+			FireAt:     time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC),
+			Name:       "Alice",
+			Email:      "Alice <alice@example.com>",
+			TimeZone:   "Europe/Moscow",
+			ItemTitle:  "Купить молоко",
+			ListTitle:  "Дом",
+			DueAt:      time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC),
+		},
+		sent:   make(chan int, 1),
+		failed: make(chan string, 1),
+	}
+	s := NewReminderService(repo, mailer.NewSMTPMailer("127.0.0.1", server.port(), "", "", from), &recordedEvents{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.RunScheduler(ctx, ReminderSchedulerConfig{PollInterval: time.Hour, Timeout: 5 * time.Second})
+
+	var msg smtpMessage
+	select {
+	case msg = <-server.messages:
+	case reason := <-repo.failed:
+		t.Fatalf("send failed: %s", reason)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+
+	select {
+	case id := <-repo.sent:
+		if id != 7 {
+			t.Errorf("marked reminder %d sent, want 7", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reminder was not marked sent")
+	}
+
+	if msg.from != "todo@example.com" {
+		t.Errorf("MAIL FROM = %q", msg.from)
+	}
+	if len(msg.to) != 1 || msg.to[0] != "alice@example.com" {
+		t.Errorf("RCPT TO = %v, want [alice@example.com]", msg.to)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(string(msg.data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Срок показывается в часовом поясе пользователя
+	if want := "Reminder: Купить молоко is due Sun, 01 Mar 2026 13:00 MSK"; subject != want {
+		t.Errorf("subject = %q, want %q", subject, want)
+	}
+	if id := parsed.Header.Get("Message-ID"); id != "<reminder-7-1772355600@todo-app>" {
+		t.Errorf("Message-ID = %q", id)
+	}
+
+	body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Hi Alice,\n\n\"Купить молоко\" in list \"Дом\" is due Sun, 01 Mar 2026 13:00 MSK."; !strings.Contains(string(body), want) {
+		t.Errorf("body = %q, want it to contain %q", body, want)
+	}
+}
+
+func TestReminderWithoutEmail(t *testing.T) {
+	repo := &dueReminderRepo{
+		delivery: todo.ReminderDelivery{
+			ReminderId: 7,
+			FireAt:     time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC),
+			UserId:     2,
+			ItemId:     5,
+			ListId:     3,
+			DueAt:      time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC),
+		},
+		sent:   make(chan int, 1),
+		failed: make(chan string, 1),
+	}
+	events := &recordedEvents{}
+	// Без email mailer не вызывается
+	s := NewReminderService(repo, nil, events)
+
+	config := ReminderSchedulerConfig{}.withDefaults()
+	if n := s.sendDue(context.Background(), config); n != 1 {
+		t.Fatalf("sent %d reminders, want 1", n)
+	}
+	if id := <-repo.sent; id != 7 {
+		t.Errorf("marked reminder %d sent, want 7", id)
+	}
+
+	// Владелец напоминания получает item.due_soon, по которому создается уведомление
+	if len(events.events) != 1 {
+		t.Fatalf("events = %v", events.types())
+	}
+	event := events.events[0]
+	if event.Type != todo.EventItemDueSoon || event.ListId != 3 || event.ItemId != 5 || !event.VisibleTo(2) || len(event.Audience) != 1 {
+		t.Errorf("event = %+v", event)
+	}
+	if notifications := notificationsFor(event); len(notifications) != 1 || notifications[0].Type != todo.NotificationDueSoon {
+		t.Errorf("notifications = %+v", notifications)
+	}
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/repository"
+)
+
+type RevisionService struct {
+	repo  repository.Revisions
+	lists TodoList
+	items TodoItem
+}
+
+func NewRevisionService(repo repository.Revisions, lists TodoList, items TodoItem) *RevisionService {
+	return &RevisionService{repo: repo, lists: lists, items: items}
+}
+
+func (s *RevisionService) GetAll(userId int, entity string, entityId, offset, limit int) ([]todo.Revision, int, error) {
+	if _, err := s.currentVersion(userId, entity, entityId); err != nil {
+		return nil, 0, err
+	}
+
+	return s.repo.GetAll(entity, entityId, offset, limit)
+}
+
+func (s *RevisionService) GetByVersion(userId int, entity string, entityId, version int) (todo.Revision, error) {
+	if _, err := s.currentVersion(userId, entity, entityId); err != nil {
+		return todo.Revision{}, err
+	}
+
+	return s.repo.GetByVersion(entity, entityId, version)
+}
+
+// Diff возвращает изменения полей между ревизиями from и to
+func (s *RevisionService) Diff(userId int, entity string, entityId, from, to int) (todo.RevisionDiff, error) {
+	fromRevision, err := s.GetByVersion(userId, entity, entityId, from)
+	if err != nil {
+		return todo.RevisionDiff{}, err
+	}
+
+	toRevision, err := s.repo.GetByVersion(entity, entityId, to)
+	if err != nil {
+		return todo.RevisionDiff{}, err
+	}
+
+	var before, after map[string]interface{}
+	if err := json.Unmarshal(fromRevision.Snapshot, &before); err != nil {
+		return todo.RevisionDiff{}, err
+	}
+	if err := json.Unmarshal(toRevision.Snapshot, &after); err != nil {
+		return todo.RevisionDiff{}, err
+	}
+
+	return todo.RevisionDiff{
+		Entity:   entity,
+		EntityId: entityId,
+		From:     from,
+		To:       to,
+		Changes:  fieldDiff(before, after),
+	}, nil
+}
+
+// Revert восстанавливает содержимое ревизии version. История не переписывается:
+// восстановление - обычное изменение, создающее новую ревизию, которая и возвращается.
+// При expectedVersion > 0 изменение применяется, только если текущая версия совпадает
+func (s *RevisionService) Revert(userId int, entity string, entityId, version, expectedVersion int) (todo.Revision, error) {
+	current, err := s.currentVersion(userId, entity, entityId)
+	if err != nil {
+		return todo.Revision{}, err
+	}
+	if expectedVersion == 0 {
+		expectedVersion = current
+	}
+
+	revision, err := s.repo.GetByVersion(entity, entityId, version)
+	if err != nil {
+		return todo.Revision{}, err
+	}
+
+	switch entity {
+	case todo.EntityList:
+		var list todo.TodoList
+		if err := json.Unmarshal(revision.Snapshot, &list); err != nil {
+			return todo.Revision{}, err
+		}
+		input := todo.UpdateListInput{
+			Title:       &list.Title,
+			Description: &list.Description,
+			Archived:    &list.Archived,
+			Priority:    &list.Priority,
+		}
+		// color может быть NULL в старых строках
+		if list.Color != "" {
+			input.Color = &list.Color
+		}
+		err = s.lists.UpdateIfVersion(userId, entityId, expectedVersion, input)
+	case todo.EntityItem:
+		var item todo.TodoItem
+		if err := json.Unmarshal(revision.Snapshot, &item); err != nil {
+			return todo.Revision{}, err
+		}
+		err = s.items.UpdateIfVersion(userId, entityId, expectedVersion, todo.UpdateItemInput{
+			Title:           &item.Title,
+			Description:     &item.Description,
+			Done:            &item.Done,
+			Archived:        &item.Archived,
+			DueAt:           todo.NewOptionalTime(item.DueAt),
+			AutoComplete:    &item.AutoComplete,
+			Priority:        todo.NewOptionalInt(item.Priority),
+			EstimateMinutes: todo.NewOptionalInt(item.EstimateMinutes),
+		})
+	}
+	if err != nil {
+		return todo.Revision{}, err
+	}
+
+	// Ревизия записывается триггером в той же транзакции, что и изменение
+	return s.repo.GetByVersion(entity, entityId, expectedVersion+1)
+}
+
+// currentVersion проверяет доступ пользователя к ресурсу и возвращает его текущую версию
+func (s *RevisionService) currentVersion(userId int, entity string, entityId int) (int, error) {
+	switch entity {
+	case todo.EntityList:
+		list, err := s.lists.GetById(userId, entityId)
+		return list.Version, err
+	case todo.EntityItem:
+		item, err := s.items.GetById(userId, entityId)
+		return item.Version, err
+	default:
+		return 0, fmt.Errorf("unsupported revision entity %q", entity)
+	}
+}
+
+// diffIgnoredFields меняются при каждом изменении и не несут смысла в diff
+var diffIgnoredFields = map[string]bool{"updated_at": true, "version": true}
+
+// fieldDiff возвращает поля, значения которых различаются в двух снимках
+func fieldDiff(before, after map[string]interface{}) map[string]todo.FieldChange {
+	diff := make(map[string]todo.FieldChange)
+	for field := range mergeKeys(before, after) {
+		if diffIgnoredFields[field] || reflect.DeepEqual(before[field], after[field]) {
+			continue
+		}
+		diff[field] = todo.FieldChange{From: before[field], To: after[field]}
+	}
+	return diff
+}
+
+func mergeKeys(a, b map[string]interface{}) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	return keys
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/repository"
+)
+
+// revisionRepo хранит ревизии в памяти по версиям
+type revisionRepo struct {
+	repository.Revisions
+	snapshots map[int]string
+}
+
+func (r *revisionRepo) GetByVersion(entity string, entityId, version int) (todo.Revision, error) {
+	return todo.Revision{Entity: entity, EntityId: entityId, Version: version, Snapshot: []byte(r.snapshots[version])}, nil
+}
+
+// revertItems запоминает изменение, которым восстанавливается ревизия
+type revertItems struct {
+	TodoItem
+	version  int
+	expected int
+	input    todo.UpdateItemInput
+}
+
+func (s *revertItems) GetById(userId, itemId int) (todo.TodoItem, error) {
+	return todo.TodoItem{Id: itemId, Version: s.version}, nil
+}
+
+func (s *revertItems) UpdateIfVersion(userId, itemId, version int, input todo.UpdateItemInput) error {
+	s.expected, s.input = version, input
+	return nil
+}
+
+func TestRevisionDiff(t *testing.T) {
+	repo := &revisionRepo{snapshots: map[int]string{
+		1: `{"id": 7, "title": "item", "done": false, "version": 1, "updated_at": "2026-01-01T00:00:00Z"}`,
+		2: `{"id": 7, "title": "renamed", "done": false, "priority": 1, "version": 2, "updated_at": "2026-01-02T00:00:00Z"}`,
+	}}
+	s := NewRevisionService(repo, nil, &revertItems{version: 2})
+
+	diff, err := s.Diff(1, todo.EntityItem, 7, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]todo.FieldChange{
+		"title":    {From: "item", To: "renamed"},
+		"priority": {From: nil, To: float64(1)},
+	}
+	if !reflect.DeepEqual(diff.Changes, want) {
+		t.Errorf("changes = %v, want %v", diff.Changes, want)
+	}
+}
+
+func TestRevertItem(t *testing.T) {
+	repo := &revisionRepo{snapshots: map[int]string{
+		1: `{"id": 7, "title": "item", "description": null, "done": true, "archived": false,
+			"due_at": "2026-03-01T10:00:00Z", "checklist_auto_complete": true, "priority": 2, "estimate_minutes": null}`,
+	}}
+	items := &revertItems{version: 5}
+	s := NewRevisionService(repo, nil, items)
+
+	revision, err := s.Revert(1, todo.EntityItem, 7, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Без ожидаемой версии изменение применяется к текущей, результат - следующая ревизия
+	if items.expected != 5 || revision.Version != 6 {
+		t.Errorf("expected version = %d, revision = %d", items.expected, revision.Version)
+	}
+
+	input := items.input
+	if *input.Title != "item" || *input.Description != "" || !*input.Done || *input.Archived || !*input.AutoComplete {
+		t.Errorf("input = %+v", input)
+	}
+	if !input.Priority.Set || input.Priority.Value == nil || *input.Priority.Value != 2 {
+		t.Errorf("priority = %+v, want 2", input.Priority)
+	}
+	if !input.EstimateMinutes.Set || input.EstimateMinutes.Value != nil {
+		t.Errorf("estimate = %+v, want explicit null", input.EstimateMinutes)
+	}
+	if !input.DueAt.Set || input.DueAt.Time == nil || input.DueAt.Time.Day() != 1 {
+		t.Errorf("due_at = %+v", input.DueAt)
+	}
+}
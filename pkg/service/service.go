@@ -1,10 +1,14 @@
 package service
 
 import (
+	"context"
+	"io"
 	"time"
 
 	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/mailer"
 	"github.com/ktuty/todo-app/pkg/repository"
+	"github.com/ktuty/todo-app/pkg/storage"
 )
 
 type Authorization interface {
@@ -19,10 +23,26 @@ type TodoList interface {
 	GetById(userId, listId int) (todo.TodoList, error)
 	Delete(userId, listId int) error
 	Update(userId, listId int, input todo.UpdateListInput) error
+	// Условные операции для If-Match: выполняются, только если версия совпадает
+	UpdateIfVersion(userId, listId, version int, input todo.UpdateListInput) error
+	DeleteIfVersion(userId, listId, version int) error
 	// V2 методы
 	GetAllWithPagination(userId, offset, limit int, archived string) ([]todo.TodoList, int, error)
 	GetItemCount(userId, listId int) (int, error)
 	ArchiveList(userId, listId int) error
+	// Workflow: статусы items списка, каждый открытый или закрытый
+	GetWorkflow(userId, listId int) (todo.Workflow, error)
+	SetWorkflow(userId, listId int, input todo.WorkflowInput) (todo.Workflow, error)
+	// Разделы: именованные группы items списка с ручным порядком
+	CreateSection(userId, listId int, input todo.SectionInput) (todo.ListSection, error)
+	RenameSection(userId, listId, sectionId int, input todo.UpdateSectionInput) (todo.ListSection, error)
+	ReorderSections(userId, listId int, input todo.ReorderSectionsInput) ([]todo.ListSection, error)
+	// DeleteSection удаляет раздел; его items переходят в конец items без раздела
+	DeleteSection(userId, listId, sectionId int) error
+	// Duplicate копирует список с items в одной транзакции и возвращает копию
+	Duplicate(userId, listId int, input todo.DuplicateListInput) (todo.TodoList, error)
+	// Merge переносит в список items и участников другого списка в одной транзакции
+	Merge(userId, listId int, input todo.MergeListInput) (todo.TodoList, error)
 }
 
 type TodoItem interface {
@@ -31,10 +51,167 @@ type TodoItem interface {
 	GetById(userId, itemId int) (todo.TodoItem, error)
 	Delete(userId, itemId int) error
 	Update(userId, itemId int, input todo.UpdateItemInput) error
+	// Условные операции для If-Match: выполняются, только если версия совпадает
+	UpdateIfVersion(userId, itemId, version int, input todo.UpdateItemInput) error
+	DeleteIfVersion(userId, itemId, version int) error
 	// V2 методы
-	GetAllWithPagination(userId, listId, offset, limit int, completed string) ([]todo.TodoItem, int, error)
+	GetAllWithPagination(userId int, filter todo.ItemFilter, offset, limit int) ([]todo.TodoItem, int, error)
+	// GetBoard возвращает items списка по колонкам статусов, не больше limit в колонке
+	GetBoard(userId, listId, limit int) (todo.Board, error)
 	ArchiveItem(userId, itemId int) error
-	CompleteItem(userId, itemId int) error
+	CompleteItem(userId, itemId int, force bool) error
+	Bulk(userId int, ops []todo.BulkItemOperation, atomic bool) ([]todo.BulkItemResult, error)
+	// Ответственным можно назначить только пользователя с доступом к списку item
+	Assign(userId, itemId, assigneeId int) (bool, error)
+	Unassign(userId, itemId, assigneeId int) error
+	// Чек-лист; при включенном автозавершении item отмечается выполненным, когда отмечены все пункты
+	GetChecklist(userId, itemId int) ([]todo.ChecklistItem, error)
+	AddChecklistItem(userId, itemId int, input todo.ChecklistItemInput) (todo.ChecklistItem, error)
+	UpdateChecklistItem(userId, itemId, entryId int, input todo.UpdateChecklistItemInput) (todo.ChecklistItem, error)
+	DeleteChecklistItem(userId, itemId, entryId int) error
+	ReorderChecklist(userId, itemId int, input todo.ReorderChecklistInput) ([]todo.ChecklistItem, error)
+	// Зависимости: item нельзя выполнить без force, пока не выполнены блокирующие его items
+	AddDependency(userId, itemId, blockedById int) (bool, error)
+	RemoveDependency(userId, itemId, blockedById int) error
+	GetDependencyGraph(userId, listId int) (todo.DependencyGraph, error)
+	// Разделы: items списка по разделам и перенос items в раздел в заданном порядке
+	GetSectionItems(userId, listId int) (todo.ListSections, error)
+	MoveItems(userId, listId int, input todo.MoveItemsInput) error
+}
+
+// EventPublisher публикует доменные события об изменениях списков и items
+type EventPublisher interface {
+	Publish(event todo.Event)
+	ListAudience(listId int) ([]int, error)
+}
+
+// EventHandler обрабатывает опубликованные доменные события
+type EventHandler interface {
+	HandleEvent(event todo.Event)
+}
+
+type Events interface {
+	EventPublisher
+	GetSince(userId int, afterId int64, limit int) ([]todo.Event, error)
+	Subscribe(userId int) (<-chan todo.Event, func())
+	Dispatch(ids <-chan int64)
+	RunRetention(ctx context.Context, config EventRetentionConfig)
+}
+
+type Webhooks interface {
+	Create(userId int, webhook todo.Webhook) (todo.Webhook, error)
+	GetAll(userId int) ([]todo.Webhook, error)
+	GetById(userId, webhookId int) (todo.Webhook, error)
+	Delete(userId, webhookId int) error
+	GetDeliveries(userId, webhookId int, status string, limit int) ([]todo.WebhookDelivery, error)
+	GetDelivery(userId int, deliveryId int64) (todo.WebhookDelivery, error)
+	Redeliver(userId int, deliveryId int64) (todo.WebhookDelivery, error)
+	RunWorker(ctx context.Context, config WebhookWorkerConfig)
+}
+
+type Sync interface {
+	GetChanges(userId int, token string, limit int) ([]todo.SyncChange, string, bool, error)
+	Apply(userId int, changes []todo.SyncClientChange) []todo.SyncChangeResult
+}
+
+type Audit interface {
+	GetListActivity(userId, listId int, filter todo.AuditFilter) ([]todo.AuditEntry, int, error)
+	GetFeed(userId int, filter todo.AuditFilter) ([]todo.AuditEntry, int, error)
+}
+
+type Revisions interface {
+	GetAll(userId int, entity string, entityId, offset, limit int) ([]todo.Revision, int, error)
+	GetByVersion(userId int, entity string, entityId, version int) (todo.Revision, error)
+	Diff(userId int, entity string, entityId, from, to int) (todo.RevisionDiff, error)
+	Revert(userId int, entity string, entityId, version, expectedVersion int) (todo.Revision, error)
+}
+
+type Comments interface {
+	Create(userId, itemId int, input todo.CommentInput) (todo.Comment, error)
+	GetAll(userId, itemId, offset, limit int) ([]todo.Comment, int, error)
+	GetById(userId, commentId int) (todo.Comment, error)
+	// Изменять и удалять комментарий может только автор
+	Update(userId, commentId int, input todo.UpdateCommentInput) (todo.Comment, error)
+	Delete(userId, commentId int) error
+	GetEdits(userId, commentId int) ([]todo.CommentEdit, error)
+}
+
+type Notifications interface {
+	EventHandler
+	GetAll(userId int, unreadOnly bool, offset, limit int) ([]todo.Notification, int, error)
+	CountUnread(userId int) (int, error)
+	MarkRead(userId int, notificationId int64) error
+	MarkAllRead(userId int) (int64, error)
+	GetPreferences(userId int) (map[string]bool, error)
+	SetPreferences(userId int, preferences map[string]bool) (map[string]bool, error)
+}
+
+type ListMembers interface {
+	GetAll(userId, listId int) ([]todo.ListMember, error)
+	Add(userId, listId int, username string) (todo.ListMember, bool, error)
+	Remove(userId, listId, memberId int) error
+}
+
+type Settings interface {
+	Get(userId int) (todo.UserSettings, error)
+	Update(userId int, input todo.UpdateSettingsInput) (todo.UserSettings, error)
+}
+
+type Reminders interface {
+	Create(userId, itemId int, input todo.ReminderInput) (todo.Reminder, error)
+	GetAll(userId, itemId int) ([]todo.Reminder, error)
+	Delete(userId, reminderId int) error
+	RunScheduler(ctx context.Context, config ReminderSchedulerConfig)
+}
+
+type Digests interface {
+	GetPreferences(userId int) (todo.DigestPreferences, error)
+	UpdatePreferences(userId int, input todo.UpdateDigestPreferencesInput) (todo.DigestPreferences, error)
+	Build(userId int, frequency string) (todo.Digest, error)
+	RenderHTML(digest todo.Digest) (string, error)
+	RunScheduler(ctx context.Context, config DigestSchedulerConfig)
+}
+
+type Attachments interface {
+	Create(ctx context.Context, userId, itemId int, upload todo.AttachmentUpload) (todo.Attachment, error)
+	GetAll(userId, itemId int) ([]todo.Attachment, error)
+	GetById(userId, attachmentId int) (todo.Attachment, error)
+	Delete(userId, attachmentId int) error
+	Open(ctx context.Context, userId, attachmentId int) (todo.Attachment, io.ReadCloser, error)
+	SignURL(userId, attachmentId int, ttl time.Duration) (string, time.Time, error)
+	OpenSigned(ctx context.Context, attachmentId int, expires int64, signature string) (todo.Attachment, io.ReadCloser, error)
+	RunCleaner(ctx context.Context, config AttachmentCleanerConfig)
+}
+
+type Folders interface {
+	Create(userId int, input todo.FolderInput) (todo.Folder, error)
+	GetById(userId, folderId int) (todo.Folder, error)
+	Update(userId, folderId int, input todo.UpdateFolderInput) (todo.Folder, error)
+	Delete(userId, folderId int, mode string) error
+	GetTree(userId int) (todo.FolderTree, error)
+	MoveList(userId, listId int, folderId *int) (todo.TodoList, error)
+}
+
+// Templates - шаблоны списков пользователя
+type Templates interface {
+	Create(userId int, input todo.TemplateInput) (todo.ListTemplate, error)
+	GetAll(userId int) ([]todo.ListTemplate, error)
+	GetById(userId, templateId int) (todo.ListTemplate, error)
+	Update(userId, templateId int, input todo.UpdateTemplateInput) (todo.ListTemplate, error)
+	Delete(userId, templateId int) error
+	// SaveList сохраняет список шаблоном; Instantiate создает по шаблону новый список
+	SaveList(userId, listId int, input todo.SaveTemplateInput) (todo.ListTemplate, error)
+	Instantiate(userId, templateId int, input todo.InstantiateTemplateInput) (todo.TodoList, error)
+}
+
+type TimeEntries interface {
+	StartTimer(userId, itemId int, input todo.StartTimerInput) (todo.TimeEntry, error)
+	StopTimer(userId int) (todo.TimeEntry, error)
+	GetRunning(userId int) (todo.TimeEntry, error)
+	Create(userId, itemId int, input todo.TimeEntryInput) (todo.TimeEntry, error)
+	GetAll(userId, itemId int) ([]todo.TimeEntry, error)
+	Delete(userId, entryId int) error
+	GetReport(userId int, filter todo.TimeReportFilter) ([]todo.TimeReportRow, error)
 }
 
 type Idempotency interface {
@@ -47,13 +224,96 @@ type Service struct {
 	TodoList
 	TodoItem
 	Idempotency
+	Events
+	Webhooks
+	Sync
+	Audit
+	Revisions
+	Comments
+	Notifications
+	ListMembers
+	Settings
+	Reminders
+	Digests
+	Attachments
+	TimeEntries
+	Folders
+	Templates
+
+	// Конкретные реализации для привязки к запросу в WithRequest
+	lists       *TodoListService
+	items       *TodoItemService
+	sync        *SyncService
+	revisions   *RevisionService
+	folders     *FolderService
+	templates   *TemplateService
+	timeEntries *TimeEntryService
+	listMembers *ListMemberService
 }
 
-func NewService(repos *repository.Repository) *Service {
+func NewService(repos *repository.Repository, mailer mailer.Mailer, store storage.BlobStore, attachments AttachmentConfig) *Service {
+	events := NewEventService(repos.Events)
+	lists := NewTodoListService(repos.TodoList, repos.TodoItem, events)
+	items := NewTodoItemService(repos.TodoItem, repos.TodoList, events)
+	sync := NewSyncService(repos.Sync, lists, items)
+	revisions := NewRevisionService(repos.Revisions, lists, items)
+	folders := NewFolderService(repos.Folders, lists)
+	templates := NewTemplateService(repos.Templates, lists, items)
+	timeEntries := NewTimeEntryService(repos.TimeEntries)
+	notifications := NewNotificationService(repos.Notifications)
+	listMembers := NewListMemberService(repos.ListMembers, events)
+	events.AddHandler(notifications)
+
 	return &Service{
 		Authorization: NewAuthService(repos.Authorization),
-		TodoList:      NewTodoListService(repos.TodoList),
-		TodoItem:      NewTodoItemService(repos.TodoItem, repos.TodoList),
+		TodoList:      lists,
+		TodoItem:      items,
 		Idempotency:   NewIdempotencyService(), // Добавляем сервис идемпотентности
+		Events:        events,
+		Webhooks:      NewWebhookService(repos.Webhooks),
+		Sync:          sync,
+		Audit:         NewAuditService(repos.Audit),
+		Revisions:     revisions,
+		Comments:      NewCommentService(repos.Comments, repos.TodoItem, events),
+		Notifications: notifications,
+		ListMembers:   listMembers,
+		Settings:      NewSettingsService(repos.Settings),
+		Reminders:     NewReminderService(repos.Reminders, mailer, events),
+		Digests:       NewDigestService(repos.Digests, mailer),
+		Attachments:   NewAttachmentService(repos.Attachments, repos.TodoItem, store, attachments),
+		TimeEntries:   timeEntries,
+		Folders:       folders,
+		Templates:     templates,
+		lists:         lists,
+		items:         items,
+		sync:          sync,
+		revisions:     revisions,
+		folders:       folders,
+		templates:     templates,
+		timeEntries:   timeEntries,
+		listMembers:   listMembers,
 	}
 }
+
+// WithRequest возвращает набор сервисов, изменения через который записываются
+// в журнал аудита от имени автора запроса и со сведениями о запросе
+func (s *Service) WithRequest(meta todo.RequestMeta) *Service {
+	scoped := *s
+	scoped.lists = s.lists.withRequest(meta)
+	scoped.items = s.items.withRequest(meta)
+	scoped.sync = NewSyncService(s.sync.repo, scoped.lists, scoped.items)
+	scoped.revisions = NewRevisionService(s.revisions.repo, scoped.lists, scoped.items)
+	scoped.folders = NewFolderService(s.folders.repo, scoped.lists)
+	scoped.templates = NewTemplateService(s.templates.repo.WithRequest(meta), scoped.lists, scoped.items)
+	scoped.timeEntries = NewTimeEntryService(s.timeEntries.repo.WithRequest(meta))
+	scoped.listMembers = s.listMembers.withRequest(meta)
+	scoped.TodoList = scoped.lists
+	scoped.TodoItem = scoped.items
+	scoped.Sync = scoped.sync
+	scoped.Revisions = scoped.revisions
+	scoped.Folders = scoped.folders
+	scoped.Templates = scoped.templates
+	scoped.TimeEntries = scoped.timeEntries
+	scoped.ListMembers = scoped.listMembers
+	return &scoped
+}
@@ -0,0 +1,30 @@
+package service
+
+import (
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/repository"
+)
+
+type SettingsService struct {
+	repo repository.Settings
+}
+
+func NewSettingsService(repo repository.Settings) *SettingsService {
+	return &SettingsService{repo: repo}
+}
+
+func (s *SettingsService) Get(userId int) (todo.UserSettings, error) {
+	return s.repo.Get(userId)
+}
+
+func (s *SettingsService) Update(userId int, input todo.UpdateSettingsInput) (todo.UserSettings, error) {
+	if err := input.Validate(); err != nil {
+		return todo.UserSettings{}, err
+	}
+
+	if err := s.repo.Update(userId, input); err != nil {
+		return todo.UserSettings{}, err
+	}
+
+	return s.repo.Get(userId)
+}
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/repository"
+)
+
+// snapshotPattern - текстовое представление pg_snapshot: xmin:xmax:xip_list
+var snapshotPattern = regexp.MustCompile(`^\d+:\d+:(\d+(,\d+)*)?$`)
+
+// syncToken - состояние синхронизации клиента, передается ему в непрозрачном виде
+type syncToken struct {
+	UserId   int    `json:"u"`
+	Since    string `json:"s,omitempty"` // Снимок предыдущей завершенной синхронизации
+	Until    string `json:"t,omitempty"` // Снимок, которым завершится текущая (при постраничной выдаче)
+	AfterSeq int64  `json:"a,omitempty"` // Курсор внутри текущей синхронизации
+}
+
+func (t syncToken) encode() string {
+	data, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSyncToken(userId int, value string) (syncToken, error) {
+	token := syncToken{UserId: userId}
+	if value == "" {
+		return token, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return token, todo.ErrInvalidSyncToken
+	}
+	if err := json.Unmarshal(data, &token); err != nil || token.UserId != userId || token.AfterSeq < 0 {
+		return token, todo.ErrInvalidSyncToken
+	}
+	for _, snapshot := range []string{token.Since, token.Until} {
+		if snapshot != "" && !snapshotPattern.MatchString(snapshot) {
+			return token, todo.ErrInvalidSyncToken
+		}
+	}
+
+	return token, nil
+}
+
+type SyncService struct {
+	repo  repository.Sync
+	lists TodoList
+	items TodoItem
+}
+
+func NewSyncService(repo repository.Sync, lists TodoList, items TodoItem) *SyncService {
+	return &SyncService{repo: repo, lists: lists, items: items}
+}
+
+// GetChanges возвращает порцию изменений после token и токен для следующего запроса.
+// Пока hasMore = true, клиент должен запрашивать следующие страницы с новым токеном
+func (s *SyncService) GetChanges(userId int, token string, limit int) ([]todo.SyncChange, string, bool, error) {
+	current, err := decodeSyncToken(userId, token)
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	changes, until, err := s.repo.GetChanges(userId, current.Since, current.Until, current.AfterSeq, limit+1)
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	hasMore := len(changes) > limit
+	next := syncToken{UserId: userId, Since: until}
+	if hasMore {
+		changes = changes[:limit]
+		next = syncToken{
+			UserId:   userId,
+			Since:    current.Since,
+			Until:    until,
+			AfterSeq: changes[len(changes)-1].Seq,
+		}
+	}
+
+	return changes, next.encode(), hasMore, nil
+}
+
+// Apply применяет изменения клиента по порядку, каждое независимо от остальных.
+// Конфликт разрешается по версии: если клиент изменял текущую версию, изменение применяется.
+// Иначе побеждает более позднее изменение по client_updated_at и updated_at сервера;
+// если сервер новее, изменение отклоняется и в результате возвращается состояние сервера
+func (s *SyncService) Apply(userId int, changes []todo.SyncClientChange) []todo.SyncChangeResult {
+	results := make([]todo.SyncChangeResult, len(changes))
+	listIds := make(map[string]int) // client_id -> id списков, созданных в этом пакете
+
+	for i, change := range changes {
+		result := todo.SyncChangeResult{Index: i, ClientId: change.ClientId, Kind: change.Kind, Id: change.Id}
+
+		var err error
+		switch change.Kind {
+		case todo.SyncKindList:
+			err = s.applyList(userId, change, &result)
+			if err == nil && change.Op == "create" {
+				listIds[change.ClientId] = result.Id
+			}
+		case todo.SyncKindItem:
+			if change.ListId == 0 && change.ListClientId != "" {
+				change.ListId = listIds[change.ListClientId]
+			}
+			err = s.applyItem(userId, change, &result)
+		}
+
+		switch {
+		case err == nil:
+			if result.Status == "" {
+				result.Status = todo.SyncApplied
+			}
+		case errors.Is(err, sql.ErrNoRows):
+			result.Status = todo.SyncNotFound
+		case errors.As(err, new(syncInvalidError)), errors.Is(err, todo.ErrItemBlocked),
+			errors.Is(err, todo.ErrInvalidStatus), errors.Is(err, todo.ErrInvalidSection), errors.Is(err, todo.ErrInvalidUpdate):
+			result.Status = todo.SyncInvalid
+			result.Error = err.Error()
+		default:
+			result.Status = todo.SyncFailed
+			result.Error = err.Error()
+		}
+		results[i] = result
+	}
+
+	return results
+}
+
+// syncInvalidError - изменение клиента не может быть применено в переданном виде
+type syncInvalidError string
+
+func (e syncInvalidError) Error() string { return string(e) }
+
+func (s *SyncService) applyList(userId int, change todo.SyncClientChange, result *todo.SyncChangeResult) error {
+	if change.Op == "create" {
+		if change.List == nil || change.List.Title == nil {
+			return syncInvalidError("list.title is required to create a list")
+		}
+		list := todo.TodoList{Title: *change.List.Title}
+		if change.List.Description != nil {
+			list.Description = *change.List.Description
+		}
+		if change.List.Color != nil {
+			list.Color = *change.List.Color
+		}
+		if change.List.Priority != nil {
+			list.Priority = *change.List.Priority
+		}
+
+		id, err := s.lists.Create(userId, list)
+		if err != nil {
+			return err
+		}
+		result.Id = id
+
+		if change.List.Archived != nil && *change.List.Archived {
+			return s.lists.ArchiveList(userId, id)
+		}
+		return nil
+	}
+
+	current, err := s.lists.GetById(userId, change.Id)
+	if err != nil {
+		return err
+	}
+	if !clientWins(change, current.Version, current.UpdatedAt) {
+		result.Status = todo.SyncConflict
+		result.Server = current
+		return nil
+	}
+
+	switch change.Op {
+	case "update":
+		if change.List == nil {
+			return syncInvalidError("list is required to update a list")
+		}
+		err = s.lists.UpdateIfVersion(userId, change.Id, current.Version, *change.List)
+	case "delete":
+		// Как и DELETE /api/v2/lists/{id}, удаление архивирует список; purge удаляет безвозвратно
+		archived := true
+		err = s.lists.UpdateIfVersion(userId, change.Id, current.Version, todo.UpdateListInput{Archived: &archived})
+	case "purge":
+		err = s.lists.DeleteIfVersion(userId, change.Id, current.Version)
+	}
+
+	// Список изменился между чтением и записью: отдаем клиенту актуальное состояние
+	if errors.Is(err, todo.ErrVersionMismatch) {
+		if latest, getErr := s.lists.GetById(userId, change.Id); getErr == nil {
+			result.Status = todo.SyncConflict
+			result.Server = latest
+			return nil
+		}
+	}
+	return err
+}
+
+func (s *SyncService) applyItem(userId int, change todo.SyncClientChange, result *todo.SyncChangeResult) error {
+	if change.Op == "create" {
+		if change.Item == nil || change.Item.Title == nil {
+			return syncInvalidError("item.title is required to create an item")
+		}
+		if change.ListId == 0 {
+			return syncInvalidError("list_id or list_client_id of a created list is required to create an item")
+		}
+		if err := change.Item.Validate(); err != nil {
+			return syncInvalidError(err.Error())
+		}
+		item := todo.TodoItem{
+			Title:           *change.Item.Title,
+			Priority:        change.Item.Priority.Value,
+			EstimateMinutes: change.Item.EstimateMinutes.Value,
+			SectionId:       change.Item.SectionId.Value,
+		}
+		if change.Item.Description != nil {
+			item.Description = *change.Item.Description
+		}
+		if change.Item.DueAt.Set {
+			item.DueAt = change.Item.DueAt.Time
+		}
+
+		id, err := s.items.Create(userId, change.ListId, item)
+		if err != nil {
+			return err
+		}
+		result.Id = id
+
+		// Состояние done/archived задается отдельным обновлением: при создании item всегда активен
+		if change.Item.Done != nil || change.Item.Archived != nil {
+			return s.items.Update(userId, id, todo.UpdateItemInput{Done: change.Item.Done, Archived: change.Item.Archived})
+		}
+		return nil
+	}
+
+	current, err := s.items.GetById(userId, change.Id)
+	if err != nil {
+		return err
+	}
+	if !clientWins(change, current.Version, current.UpdatedAt) {
+		result.Status = todo.SyncConflict
+		result.Server = current
+		return nil
+	}
+
+	switch change.Op {
+	case "update":
+		if change.Item == nil {
+			return syncInvalidError("item is required to update an item")
+		}
+		err = s.items.UpdateIfVersion(userId, change.Id, current.Version, *change.Item)
+	case "delete":
+		// Как и DELETE /api/v2/items/{id}, удаление архивирует item; purge удаляет безвозвратно
+		archived := true
+		err = s.items.UpdateIfVersion(userId, change.Id, current.Version, todo.UpdateItemInput{Archived: &archived})
+	case "purge":
+		err = s.items.DeleteIfVersion(userId, change.Id, current.Version)
+	}
+
+	// Item изменился между чтением и записью: отдаем клиенту актуальное состояние
+	if errors.Is(err, todo.ErrVersionMismatch) {
+		if latest, getErr := s.items.GetById(userId, change.Id); getErr == nil {
+			result.Status = todo.SyncConflict
+			result.Server = latest
+			return nil
+		}
+	}
+	return err
+}
+
+// clientWins решает, применять ли изменение клиента к ресурсу с текущими version и updatedAt
+func clientWins(change todo.SyncClientChange, version int, updatedAt time.Time) bool {
+	if change.BaseVersion == 0 || change.BaseVersion == version {
+		return true
+	}
+	return change.ClientUpdatedAt != nil && change.ClientUpdatedAt.After(updatedAt)
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/repository"
+)
+
+func TestDecodeSyncToken(t *testing.T) {
+	valid := syncToken{UserId: 1, Since: "10:20:12,15", Until: "10:25:", AfterSeq: 7}
+
+	tests := []struct {
+		name  string
+		value string
+		ok    bool
+	}{
+		{"empty", "", true},
+		{"valid", valid.encode(), true},
+		{"other user", syncToken{UserId: 2}.encode(), false},
+		{"invalid snapshot", syncToken{UserId: 1, Since: "10:20:x"}.encode(), false},
+		{"negative cursor", syncToken{UserId: 1, AfterSeq: -1}.encode(), false},
+		{"not base64", "!!!", false},
+		{"not json", "bm90IGpzb24", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := decodeSyncToken(1, tt.value)
+			if tt.ok && err != nil {
+				t.Fatalf("decodeSyncToken() error = %v", err)
+			}
+			if !tt.ok && !errors.Is(err, todo.ErrInvalidSyncToken) {
+				t.Fatalf("decodeSyncToken() error = %v, want %v", err, todo.ErrInvalidSyncToken)
+			}
+			if tt.value == valid.encode() && token != valid {
+				t.Errorf("token = %+v, want %+v", token, valid)
+			}
+		})
+	}
+}
+
+// pagedSyncRepo отдает changes постранично и запоминает аргументы запросов
+type pagedSyncRepo struct {
+	repository.Sync
+	changes []todo.SyncChange
+	calls   []syncToken
+}
+
+func (r *pagedSyncRepo) GetChanges(userId int, since, until string, afterSeq int64, limit int) ([]todo.SyncChange, string, error) {
+	r.calls = append(r.calls, syncToken{UserId: userId, Since: since, Until: until, AfterSeq: afterSeq})
+	if until == "" {
+		until = "5:9:"
+	}
+
+	var page []todo.SyncChange
+	for _, change := range r.changes {
+		if change.Seq > afterSeq && len(page) < limit {
+			page = append(page, change)
+		}
+	}
+	return page, until, nil
+}
+
+func TestSyncGetChangesPages(t *testing.T) {
+	repo := &pagedSyncRepo{changes: []todo.SyncChange{{Seq: 1}, {Seq: 2}, {Seq: 3}}}
+	s := NewSyncService(repo, nil, nil)
+
+	first := syncToken{UserId: 1, Since: "1:4:"}.encode()
+	changes, next, hasMore, err := s.GetChanges(1, first, 2)
+	if err != nil || len(changes) != 2 || !hasMore {
+		t.Fatalf("first page = %d changes, hasMore = %t, err = %v", len(changes), hasMore, err)
+	}
+
+	changes, final, hasMore, err := s.GetChanges(1, next, 2)
+	if err != nil || len(changes) != 1 || hasMore {
+		t.Fatalf("last page = %d changes, hasMore = %t, err = %v", len(changes), hasMore, err)
+	}
+
+	// Страницы одной синхронизации используют ее снимки, следующая начинается со снимка until
+	want := []syncToken{
+		{UserId: 1, Since: "1:4:"},
+		{UserId: 1, Since: "1:4:", Until: "5:9:", AfterSeq: 2},
+	}
+	for i, call := range repo.calls {
+		if call != want[i] {
+			t.Errorf("call %d = %+v, want %+v", i, call, want[i])
+		}
+	}
+	if token, _ := decodeSyncToken(1, final); token != (syncToken{UserId: 1, Since: "5:9:"}) {
+		t.Errorf("final token = %+v", token)
+	}
+}
+
+func TestClientWins(t *testing.T) {
+	serverTime := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	later := serverTime.Add(time.Minute)
+	earlier := serverTime.Add(-time.Minute)
+
+	tests := []struct {
+		name   string
+		change todo.SyncClientChange
+		wins   bool
+	}{
+		{"no base version", todo.SyncClientChange{}, true},
+		{"current version", todo.SyncClientChange{BaseVersion: 3}, true},
+		{"stale without time", todo.SyncClientChange{BaseVersion: 2}, false},
+		{"stale but newer", todo.SyncClientChange{BaseVersion: 2, ClientUpdatedAt: &later}, true},
+		{"stale and older", todo.SyncClientChange{BaseVersion: 2, ClientUpdatedAt: &earlier}, false},
+	}
+	for _, tt := range tests {
+		if wins := clientWins(tt.change, 3, serverTime); wins != tt.wins {
+			t.Errorf("%s: clientWins() = %t, want %t", tt.name, wins, tt.wins)
+		}
+	}
+}
+
+func TestSyncApplyInvalidUpdate(t *testing.T) {
+	events := &recordedEvents{}
+	lists := NewTodoListService(&archiveListRepo{}, nil, events)
+	items := NewTodoItemService(&mergeItemRepo{}, nil, events)
+	s := NewSyncService(nil, lists, items)
+
+	priority := todo.MaxItemPriority + 1
+	results := s.Apply(1, []todo.SyncClientChange{
+		{Op: "update", Kind: todo.SyncKindList, Id: 10, List: &todo.UpdateListInput{}},
+		{Op: "update", Kind: todo.SyncKindItem, Id: 7, Item: &todo.UpdateItemInput{Priority: todo.NewOptionalInt(&priority)}},
+	})
+
+	// Изменения проверяются сервисами списков и items и отклоняются как неверные
+	for _, result := range results {
+		if result.Status != todo.SyncInvalid || result.Error == "" {
+			t.Errorf("change %d: status %s, error %q", result.Index, result.Status, result.Error)
+		}
+	}
+	if len(events.events) != 0 {
+		t.Errorf("events = %v", events.types())
+	}
+}
+
+// syncItemRepo запоминает архивирование и удаление items
+type syncItemRepo struct {
+	repository.TodoItem
+	archived []int
+	deleted  []int
+}
+
+func (r *syncItemRepo) GetById(userId, itemId int) (todo.TodoItem, error) {
+	return todo.TodoItem{Id: itemId, Version: 1}, nil
+}
+
+func (r *syncItemRepo) GetListId(userId, itemId int) (int, error) {
+	return 10, nil
+}
+
+func (r *syncItemRepo) UpdateIfVersion(userId, itemId, version int, input todo.UpdateItemInput) error {
+	if input.Archived != nil && *input.Archived {
+		r.archived = append(r.archived, itemId)
+	}
+	return nil
+}
+
+func (r *syncItemRepo) DeleteIfVersion(userId, itemId, version int) error {
+	r.deleted = append(r.deleted, itemId)
+	return nil
+}
+
+func TestSyncApplyDelete(t *testing.T) {
+	repo := &syncItemRepo{}
+	events := &recordedEvents{}
+	s := NewSyncService(nil, nil, NewTodoItemService(repo, nil, events))
+
+	results := s.Apply(1, []todo.SyncClientChange{
+		{Op: "delete", Kind: todo.SyncKindItem, Id: 7, BaseVersion: 1},
+		{Op: "purge", Kind: todo.SyncKindItem, Id: 8, BaseVersion: 1},
+	})
+	for _, result := range results {
+		if result.Status != todo.SyncApplied {
+			t.Errorf("change %d: status %s, error %q", result.Index, result.Status, result.Error)
+		}
+	}
+
+	// Удаление архивирует item, как DELETE в v2, а purge удаляет безвозвратно
+	if !reflect.DeepEqual(repo.archived, []int{7}) || !reflect.DeepEqual(repo.deleted, []int{8}) {
+		t.Errorf("archived %v, deleted %v", repo.archived, repo.deleted)
+	}
+	if types := events.types(); !reflect.DeepEqual(types, []string{todo.EventItemArchived, todo.EventItemDeleted}) {
+		t.Errorf("events = %v", types)
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"time"
+
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/repository"
+)
+
+type TemplateService struct {
+	repo  repository.Templates
+	lists *TodoListService
+	items *TodoItemService
+}
+
+func NewTemplateService(repo repository.Templates, lists *TodoListService, items *TodoItemService) *TemplateService {
+	return &TemplateService{repo: repo, lists: lists, items: items}
+}
+
+func (s *TemplateService) Create(userId int, input todo.TemplateInput) (todo.ListTemplate, error) {
+	if err := input.Validate(); err != nil {
+		return todo.ListTemplate{}, err
+	}
+
+	id, err := s.repo.Create(userId, input)
+	if err != nil {
+		return todo.ListTemplate{}, err
+	}
+
+	return s.GetById(userId, id)
+}
+
+func (s *TemplateService) GetAll(userId int) ([]todo.ListTemplate, error) {
+	templates, err := s.repo.GetAll(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range templates {
+		templates[i].Variables = templates[i].Content.Variables()
+	}
+	return templates, nil
+}
+
+func (s *TemplateService) GetById(userId, templateId int) (todo.ListTemplate, error) {
+	template, err := s.repo.GetById(userId, templateId)
+	if err != nil {
+		return todo.ListTemplate{}, err
+	}
+
+	template.Variables = template.Content.Variables()
+	return template, nil
+}
+
+func (s *TemplateService) Update(userId, templateId int, input todo.UpdateTemplateInput) (todo.ListTemplate, error) {
+	if err := input.Validate(); err != nil {
+		return todo.ListTemplate{}, err
+	}
+
+	if err := s.repo.Update(userId, templateId, input); err != nil {
+		return todo.ListTemplate{}, err
+	}
+
+	return s.GetById(userId, templateId)
+}
+
+func (s *TemplateService) Delete(userId, templateId int) error {
+	return s.repo.Delete(userId, templateId)
+}
+
+// SaveList сохраняет шаблоном неархивированные items списка с разделами и пунктами
+// чек-листов. Выполнение items, отметки пунктов и статусы workflow не сохраняются
+func (s *TemplateService) SaveList(userId, listId int, input todo.SaveTemplateInput) (todo.ListTemplate, error) {
+	if err := input.Validate(); err != nil {
+		return todo.ListTemplate{}, err
+	}
+
+	list, err := s.lists.GetById(userId, listId)
+	if err != nil {
+		return todo.ListTemplate{}, err
+	}
+
+	sections, err := s.lists.repo.GetSections(userId, listId)
+	if err != nil {
+		return todo.ListTemplate{}, err
+	}
+
+	items, err := s.items.GetAll(userId, listId)
+	if err != nil {
+		return todo.ListTemplate{}, err
+	}
+
+	anchor := list.CreatedAt
+	if input.Anchor != nil {
+		anchor = *input.Anchor
+	}
+
+	content := todo.TemplateContent{
+		Title:       list.Title,
+		Description: list.Description,
+		Color:       list.Color,
+		Priority:    list.Priority,
+		Sections:    make([]string, 0, len(sections)),
+		Items:       make([]todo.TemplateItem, 0, len(items)),
+	}
+	sectionIndex := make(map[int]int, len(sections))
+	for i, section := range sections {
+		sectionIndex[section.Id] = i
+		content.Sections = append(content.Sections, section.Name)
+	}
+
+	for _, item := range items {
+		templateItem := todo.TemplateItem{
+			Title:           item.Title,
+			Description:     item.Description,
+			Priority:        item.Priority,
+			EstimateMinutes: item.EstimateMinutes,
+			AutoComplete:    item.AutoComplete,
+			Checklist:       make([]string, 0),
+		}
+		if item.SectionId != nil {
+			if i, ok := sectionIndex[*item.SectionId]; ok {
+				templateItem.Section = &i
+			}
+		}
+		if item.DueAt != nil {
+			offset := int(item.DueAt.Sub(anchor).Round(time.Minute) / time.Minute)
+			templateItem.DueOffsetMinutes = &offset
+		}
+
+		entries, err := s.items.GetChecklist(userId, item.Id)
+		if err != nil {
+			return todo.ListTemplate{}, err
+		}
+		for _, entry := range entries {
+			templateItem.Checklist = append(templateItem.Checklist, entry.Text)
+		}
+
+		content.Items = append(content.Items, templateItem)
+	}
+
+	return s.Create(userId, todo.TemplateInput{Name: input.Name, Description: input.Description, Content: content})
+}
+
+// Instantiate создает список из шаблона и публикует события о создании списка и его items
+func (s *TemplateService) Instantiate(userId, templateId int, input todo.InstantiateTemplateInput) (todo.TodoList, error) {
+	template, err := s.repo.GetById(userId, templateId)
+	if err != nil {
+		return todo.TodoList{}, err
+	}
+
+	content, err := template.Content.Render(input.Variables)
+	if err != nil {
+		return todo.TodoList{}, err
+	}
+
+	listId, itemIds, err := s.repo.Instantiate(userId, content, input.Anchor)
+	if err != nil {
+		return todo.TodoList{}, err
+	}
+
+	s.lists.publish(todo.EventListCreated, userId, listId)
+	for _, itemId := range itemIds {
+		s.items.publish(todo.EventItemCreated, userId, itemId)
+	}
+
+	return s.lists.GetById(userId, listId)
+}
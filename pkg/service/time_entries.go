@@ -0,0 +1,62 @@
+package service
+
+import (
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/repository"
+)
+
+type TimeEntryService struct {
+	repo repository.TimeEntries
+}
+
+func NewTimeEntryService(repo repository.TimeEntries) *TimeEntryService {
+	return &TimeEntryService{repo: repo}
+}
+
+// StartTimer запускает таймер по item, останавливая уже запущенный таймер пользователя
+func (s *TimeEntryService) StartTimer(userId, itemId int, input todo.StartTimerInput) (todo.TimeEntry, error) {
+	id, err := s.repo.StartTimer(userId, itemId, input.Note)
+	if err != nil {
+		return todo.TimeEntry{}, err
+	}
+
+	return s.repo.GetById(userId, id)
+}
+
+func (s *TimeEntryService) StopTimer(userId int) (todo.TimeEntry, error) {
+	id, err := s.repo.StopTimer(userId)
+	if err != nil {
+		return todo.TimeEntry{}, err
+	}
+
+	return s.repo.GetById(userId, id)
+}
+
+func (s *TimeEntryService) GetRunning(userId int) (todo.TimeEntry, error) {
+	return s.repo.GetRunning(userId)
+}
+
+func (s *TimeEntryService) Create(userId, itemId int, input todo.TimeEntryInput) (todo.TimeEntry, error) {
+	if err := input.Validate(); err != nil {
+		return todo.TimeEntry{}, err
+	}
+
+	id, err := s.repo.Create(userId, itemId, input)
+	if err != nil {
+		return todo.TimeEntry{}, err
+	}
+
+	return s.repo.GetById(userId, id)
+}
+
+func (s *TimeEntryService) GetAll(userId, itemId int) ([]todo.TimeEntry, error) {
+	return s.repo.GetAll(userId, itemId)
+}
+
+func (s *TimeEntryService) Delete(userId, entryId int) error {
+	return s.repo.Delete(userId, entryId)
+}
+
+func (s *TimeEntryService) GetReport(userId int, filter todo.TimeReportFilter) ([]todo.TimeReportRow, error) {
+	return s.repo.GetReport(userId, filter)
+}
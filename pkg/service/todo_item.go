@@ -1,6 +1,9 @@
 package service
 
 import (
+	"sort"
+	"strconv"
+
 	"github.com/ktuty/todo-app"
 	"github.com/ktuty/todo-app/pkg/repository"
 )
@@ -8,10 +11,20 @@ import (
 type TodoItemService struct {
 	repo     repository.TodoItem
 	listRepo repository.TodoList
+	events   EventPublisher
+}
+
+func NewTodoItemService(repo repository.TodoItem, listRepo repository.TodoList, events EventPublisher) *TodoItemService {
+	return &TodoItemService{repo: repo, listRepo: listRepo, events: events}
 }
 
-func NewTodoItemService(repo repository.TodoItem, listRepo repository.TodoList) *TodoItemService {
-	return &TodoItemService{repo: repo, listRepo: listRepo}
+// withRequest возвращает копию сервиса, изменения через которую записываются в журнал
+// аудита от имени автора запроса
+func (s *TodoItemService) withRequest(meta todo.RequestMeta) *TodoItemService {
+	scoped := *s
+	scoped.repo = s.repo.WithRequest(meta)
+	scoped.listRepo = s.listRepo.WithRequest(meta)
+	return &scoped
 }
 
 func (s *TodoItemService) Create(userId, listId int, item todo.TodoItem) (int, error) {
@@ -21,7 +34,13 @@ func (s *TodoItemService) Create(userId, listId int, item todo.TodoItem) (int, e
 		return 0, err
 	}
 
-	return s.repo.Create(listId, item)
+	id, err := s.repo.Create(listId, item)
+	if err != nil {
+		return 0, err
+	}
+
+	s.publish(todo.EventItemCreated, userId, id)
+	return id, nil
 }
 
 func (s *TodoItemService) GetAll(userId, listId int) ([]todo.TodoItem, error) {
@@ -33,49 +52,442 @@ func (s *TodoItemService) GetById(userId, itemId int) (todo.TodoItem, error) {
 }
 
 func (s *TodoItemService) Delete(userId, itemId int) error {
-	return s.repo.Delete(userId, itemId)
+	return s.delete(userId, itemId, func() error {
+		return s.repo.Delete(userId, itemId)
+	})
 }
 
 func (s *TodoItemService) Update(userId, itemId int, input todo.UpdateItemInput) error {
-	return s.repo.Update(userId, itemId, input)
+	if err := input.Validate(); err != nil {
+		return err
+	}
+
+	before := s.snapshot(userId, itemId)
+	if err := s.repo.Update(userId, itemId, input); err != nil {
+		return err
+	}
+
+	s.publishChange(input.EventType(), userId, itemId, before)
+	return nil
+}
+
+func (s *TodoItemService) UpdateIfVersion(userId, itemId, version int, input todo.UpdateItemInput) error {
+	if err := input.Validate(); err != nil {
+		return err
+	}
+
+	before := s.snapshot(userId, itemId)
+	if err := s.repo.UpdateIfVersion(userId, itemId, version, input); err != nil {
+		return err
+	}
+
+	s.publishChange(input.EventType(), userId, itemId, before)
+	return nil
+}
+
+func (s *TodoItemService) DeleteIfVersion(userId, itemId, version int) error {
+	return s.delete(userId, itemId, func() error {
+		return s.repo.DeleteIfVersion(userId, itemId, version)
+	})
+}
+
+// delete запоминает список и снимок item до удаления, чтобы опубликовать item.deleted
+func (s *TodoItemService) delete(userId, itemId int, del func() error) error {
+	item, err := s.repo.GetById(userId, itemId)
+	if err != nil {
+		return err
+	}
+
+	listId, err := s.repo.GetListId(userId, itemId)
+	if err != nil {
+		return err
+	}
+
+	if err := del(); err != nil {
+		return err
+	}
+
+	s.events.Publish(newEvent(todo.EventItemDeleted, userId, listId, itemId, item))
+	return nil
+}
+
+// publish публикует событие со снимком текущего состояния item.
+// Если item недоступен пользователю, изменение не состоялось
+func (s *TodoItemService) publish(eventType string, userId, itemId int) {
+	item, err := s.repo.GetById(userId, itemId)
+	if err != nil {
+		return
+	}
+
+	listId, err := s.repo.GetListId(userId, itemId)
+	if err != nil {
+		return
+	}
+
+	s.events.Publish(newEvent(eventType, userId, listId, itemId, item))
+}
+
+// snapshot возвращает состояние item до изменения для событий
+func (s *TodoItemService) snapshot(userId, itemId int) *todo.TodoItem {
+	item, err := s.repo.GetById(userId, itemId)
+	if err != nil {
+		return nil
+	}
+	return &item
 }
 
 // V2 методы
 
-func (s *TodoItemService) GetAllWithPagination(userId, listId, offset, limit int, completed string) ([]todo.TodoItem, int, error) {
-	// Временная реализация
-	items, err := s.repo.GetAll(userId, listId)
+func (s *TodoItemService) GetAllWithPagination(userId int, filter todo.ItemFilter, offset, limit int) ([]todo.TodoItem, int, error) {
+	return s.repo.GetAllWithPagination(userId, filter, offset, limit)
+}
+
+// GetBoard раскладывает items списка по колонкам статусов workflow. У списка без workflow
+// колонки две: открытые и выполненные items
+func (s *TodoItemService) GetBoard(userId, listId, limit int) (todo.Board, error) {
+	statuses, err := s.listRepo.GetWorkflow(userId, listId)
 	if err != nil {
-		return nil, 0, err
+		return todo.Board{}, err
 	}
 
-	// Применяем пагинацию
-	start := offset
-	if start > len(items) {
-		start = len(items)
+	columns := make([]todo.BoardColumn, 0, len(statuses))
+	for i := range statuses {
+		columns = append(columns, todo.BoardColumn{
+			StatusId: &statuses[i].Id,
+			Name:     statuses[i].Name,
+			Category: statuses[i].Category,
+		})
 	}
-	end := start + limit
-	if end > len(items) {
-		end = len(items)
+	if len(columns) == 0 {
+		columns = append(columns,
+			todo.BoardColumn{Name: "Open", Category: todo.StatusCategoryOpen},
+			todo.BoardColumn{Name: "Done", Category: todo.StatusCategoryClosed})
 	}
 
-	if start >= len(items) {
-		return []todo.TodoItem{}, len(items), nil
+	for i, column := range columns {
+		filter := todo.ItemFilter{ListId: listId}
+		if column.StatusId != nil {
+			filter.StatusIds = []int{*column.StatusId}
+		} else {
+			filter.Completed = strconv.FormatBool(column.Category == todo.StatusCategoryClosed)
+		}
+
+		items, total, err := s.repo.GetAllWithPagination(userId, filter, 0, limit)
+		if err != nil {
+			return todo.Board{}, err
+		}
+		columns[i].Items, columns[i].Total = items, total
 	}
 
-	return items[start:end], len(items), nil
+	return todo.Board{ListId: listId, Columns: columns}, nil
 }
 
 func (s *TodoItemService) ArchiveItem(userId, itemId int) error {
-	// Временная реализация
-	return s.repo.Delete(userId, itemId)
+	if err := s.repo.ArchiveItem(userId, itemId); err != nil {
+		return err
+	}
+
+	s.publish(todo.EventItemArchived, userId, itemId)
+	return nil
+}
+
+func (s *TodoItemService) CompleteItem(userId, itemId int, force bool) error {
+	if err := s.repo.CompleteItem(userId, itemId, force); err != nil {
+		return err
+	}
+
+	s.publish(todo.EventItemCompleted, userId, itemId)
+	return nil
+}
+
+// Bulk выполняет пакет операций; права доступа проверяются для каждого item отдельно
+func (s *TodoItemService) Bulk(userId int, ops []todo.BulkItemOperation, atomic bool) ([]todo.BulkItemResult, error) {
+	// Снимки удаляемых items нужны для события item.deleted
+	before := make(map[int]*todo.TodoItem)
+	listIds := make(map[int]int)
+	for _, op := range ops {
+		if op.Op != todo.BulkOpPurge {
+			continue
+		}
+		if _, ok := before[op.ItemId]; ok {
+			continue
+		}
+		before[op.ItemId] = s.snapshot(userId, op.ItemId)
+		if listId, err := s.repo.GetListId(userId, op.ItemId); err == nil {
+			listIds[op.ItemId] = listId
+		}
+	}
+
+	results, err := s.repo.Bulk(userId, ops, atomic)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, result := range results {
+		if result.Err != nil {
+			continue
+		}
+
+		switch op := ops[i]; op.Op {
+		case todo.BulkOpCreate:
+			s.publish(todo.EventItemCreated, userId, result.ItemId)
+		case todo.BulkOpUpdate:
+			s.publish(op.Update.EventType(), userId, result.ItemId)
+		case todo.BulkOpComplete:
+			s.publish(todo.EventItemCompleted, userId, result.ItemId)
+		case todo.BulkOpArchive, todo.BulkOpDelete:
+			s.publish(todo.EventItemArchived, userId, result.ItemId)
+		case todo.BulkOpMove:
+			s.publish(todo.EventItemMoved, userId, result.ItemId)
+		case todo.BulkOpPurge:
+			item, listId := before[result.ItemId], listIds[result.ItemId]
+			if item == nil {
+				continue
+			}
+			s.events.Publish(newEvent(todo.EventItemDeleted, userId, listId, result.ItemId, *item))
+		}
+	}
+
+	return results, nil
+}
+
+// Assign назначает ответственного за item. Возвращает false, если пользователь уже назначен
+func (s *TodoItemService) Assign(userId, itemId, assigneeId int) (bool, error) {
+	added, err := s.repo.Assign(userId, itemId, assigneeId)
+	if err != nil || !added {
+		return added, err
+	}
+
+	s.publishAssignment(todo.EventItemAssigned, userId, itemId, assigneeId)
+	return true, nil
+}
+
+func (s *TodoItemService) Unassign(userId, itemId, assigneeId int) error {
+	if err := s.repo.Unassign(userId, itemId, assigneeId); err != nil {
+		return err
+	}
+
+	s.publishAssignment(todo.EventItemUnassigned, userId, itemId, assigneeId)
+	return nil
+}
+
+// publishAssignment - то же, что publish, для изменения ответственных: в данных события
+// вместе со снимком item передается назначенный или снятый пользователь
+func (s *TodoItemService) publishAssignment(eventType string, userId, itemId, assigneeId int) {
+	item, err := s.repo.GetById(userId, itemId)
+	if err != nil {
+		return
+	}
+
+	listId, err := s.repo.GetListId(userId, itemId)
+	if err != nil {
+		return
+	}
+
+	data := todo.AssignmentEventData{TodoItem: item, AssigneeId: assigneeId}
+	s.events.Publish(newEvent(eventType, userId, listId, itemId, data))
+}
+
+func (s *TodoItemService) GetChecklist(userId, itemId int) ([]todo.ChecklistItem, error) {
+	return s.repo.GetChecklist(userId, itemId)
+}
+
+func (s *TodoItemService) AddChecklistItem(userId, itemId int, input todo.ChecklistItemInput) (todo.ChecklistItem, error) {
+	before := s.snapshot(userId, itemId)
+	entry, err := s.repo.AddChecklistItem(userId, itemId, input)
+	if err != nil {
+		return entry, err
+	}
+
+	s.publishChange(todo.EventItemUpdated, userId, itemId, before)
+	return entry, nil
+}
+
+func (s *TodoItemService) UpdateChecklistItem(userId, itemId, entryId int, input todo.UpdateChecklistItemInput) (todo.ChecklistItem, error) {
+	if err := input.Validate(); err != nil {
+		return todo.ChecklistItem{}, err
+	}
+
+	before := s.snapshot(userId, itemId)
+	entry, err := s.repo.UpdateChecklistItem(userId, itemId, entryId, input)
+	if err != nil {
+		return entry, err
+	}
+
+	s.publishChange(todo.EventItemUpdated, userId, itemId, before)
+	return entry, nil
+}
+
+func (s *TodoItemService) DeleteChecklistItem(userId, itemId, entryId int) error {
+	before := s.snapshot(userId, itemId)
+	if err := s.repo.DeleteChecklistItem(userId, itemId, entryId); err != nil {
+		return err
+	}
+
+	s.publishChange(todo.EventItemUpdated, userId, itemId, before)
+	return nil
+}
+
+func (s *TodoItemService) ReorderChecklist(userId, itemId int, input todo.ReorderChecklistInput) ([]todo.ChecklistItem, error) {
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+
+	before := s.snapshot(userId, itemId)
+	entries, err := s.repo.ReorderChecklist(userId, itemId, input.Ids)
+	if err != nil {
+		return nil, err
+	}
+
+	s.publishChange(todo.EventItemUpdated, userId, itemId, before)
+	return entries, nil
 }
 
-func (s *TodoItemService) CompleteItem(userId, itemId int) error {
-	// Временная реализация
-	done := true
-	updateInput := todo.UpdateItemInput{
-		Done: &done,
+// publishChange - то же, что publish, но item.updated заменяется на item.completed, если
+// изменение отметило item выполненным: по чек-листу или по категории нового статуса
+func (s *TodoItemService) publishChange(eventType string, userId, itemId int, before *todo.TodoItem) {
+	if eventType == todo.EventItemUpdated && before != nil && !before.Done {
+		if item, err := s.repo.GetById(userId, itemId); err == nil && item.Done {
+			eventType = todo.EventItemCompleted
+		}
+	}
+
+	s.publish(eventType, userId, itemId)
+}
+
+// AddDependency отмечает, что item нельзя выполнить раньше blockedById.
+// Возвращает false, если зависимость уже есть
+func (s *TodoItemService) AddDependency(userId, itemId, blockedById int) (bool, error) {
+	added, err := s.repo.AddDependency(userId, itemId, blockedById)
+	if err != nil || !added {
+		return added, err
+	}
+
+	s.publish(todo.EventItemUpdated, userId, itemId)
+	return true, nil
+}
+
+func (s *TodoItemService) RemoveDependency(userId, itemId, blockedById int) error {
+	if err := s.repo.RemoveDependency(userId, itemId, blockedById); err != nil {
+		return err
+	}
+
+	s.publish(todo.EventItemUpdated, userId, itemId)
+	return nil
+}
+
+// GetDependencyGraph возвращает граф зависимостей в топологическом порядке; при равных
+// условиях items упорядочены по id, чтобы порядок не менялся от запроса к запросу
+func (s *TodoItemService) GetDependencyGraph(userId, listId int) (todo.DependencyGraph, error) {
+	nodes, err := s.repo.GetDependencyGraph(userId, listId)
+	if err != nil {
+		return todo.DependencyGraph{}, err
+	}
+
+	return todo.DependencyGraph{Nodes: sortDependencies(nodes)}, nil
+}
+
+// sortDependencies упорядочивает items алгоритмом Кана: item попадает в результат,
+// когда в нем уже есть все блокирующие его items. nodes должны быть отсортированы по id
+func sortDependencies(nodes []todo.DependencyNode) []todo.DependencyNode {
+	index := make(map[int]int, len(nodes))
+	for i, node := range nodes {
+		index[node.ItemId] = i
+	}
+
+	pending := make([]int, len(nodes))
+	dependents := make([][]int, len(nodes))
+	for i, node := range nodes {
+		for _, blockerId := range node.BlockedByIds {
+			if j, ok := index[blockerId]; ok {
+				pending[i]++
+				dependents[j] = append(dependents[j], i)
+			}
+		}
+	}
+
+	ready := make([]int, 0)
+	for i := range nodes {
+		if pending[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	sorted := make([]todo.DependencyNode, 0, len(nodes))
+	for len(ready) > 0 {
+		// ready держится отсортированным по индексу, т.е. по id
+		i := ready[0]
+		ready = ready[1:]
+		sorted = append(sorted, nodes[i])
+
+		for _, j := range dependents[i] {
+			pending[j]--
+			if pending[j] == 0 {
+				ready = insertSorted(ready, j)
+			}
+		}
+	}
+
+	return sorted
+}
+
+func insertSorted(values []int, value int) []int {
+	i := sort.SearchInts(values, value)
+	values = append(values, 0)
+	copy(values[i+1:], values[i:])
+	values[i] = value
+	return values
+}
+
+// GetSectionItems раскладывает неархивированные items списка по разделам в их порядке
+func (s *TodoItemService) GetSectionItems(userId, listId int) (todo.ListSections, error) {
+	sections, err := s.listRepo.GetSections(userId, listId)
+	if err != nil {
+		return todo.ListSections{}, err
+	}
+
+	items, err := s.repo.GetAll(userId, listId)
+	if err != nil {
+		return todo.ListSections{}, err
+	}
+
+	result := todo.ListSections{
+		ListId:      listId,
+		Unsectioned: make([]todo.TodoItem, 0),
+		Sections:    make([]todo.SectionItems, 0, len(sections)),
+	}
+	index := make(map[int]int, len(sections))
+	for i, section := range sections {
+		index[section.Id] = i
+		result.Sections = append(result.Sections, todo.SectionItems{ListSection: section, Items: make([]todo.TodoItem, 0)})
+	}
+	for _, item := range items {
+		if item.SectionId != nil {
+			if i, ok := index[*item.SectionId]; ok {
+				result.Sections[i].Items = append(result.Sections[i].Items, item)
+				continue
+			}
+		}
+		result.Unsectioned = append(result.Unsectioned, item)
+	}
+
+	return result, nil
+}
+
+// MoveItems переносит items в раздел в заданном порядке; событие item.updated публикуется
+// для каждого item, у которого изменились раздел или позиция
+func (s *TodoItemService) MoveItems(userId, listId int, input todo.MoveItemsInput) error {
+	if err := input.Validate(); err != nil {
+		return err
+	}
+
+	itemIds, err := s.repo.MoveItems(userId, listId, input.SectionId, input.Ids)
+	if err != nil {
+		return err
+	}
+
+	for _, itemId := range itemIds {
+		s.publish(todo.EventItemUpdated, userId, itemId)
 	}
-	return s.repo.Update(userId, itemId, updateInput)
+	return nil
 }
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/repository"
+)
+
+// bulkItemRepo возвращает заранее заданные результаты пакета; items с id из deleted
+// после пакета считаются удаленными
+type bulkItemRepo struct {
+	repository.TodoItem
+	results []todo.BulkItemResult
+	deleted map[int]bool
+	applied bool
+}
+
+func (r *bulkItemRepo) Bulk(userId int, ops []todo.BulkItemOperation, atomic bool) ([]todo.BulkItemResult, error) {
+	r.applied = true
+	return r.results, nil
+}
+
+func (r *bulkItemRepo) GetById(userId, itemId int) (todo.TodoItem, error) {
+	if r.applied && r.deleted[itemId] {
+		return todo.TodoItem{}, sql.ErrNoRows
+	}
+	return todo.TodoItem{Id: itemId, Title: "item"}, nil
+}
+
+func (r *bulkItemRepo) GetListId(userId, itemId int) (int, error) {
+	if r.applied && r.deleted[itemId] {
+		return 0, sql.ErrNoRows
+	}
+	return 10, nil
+}
+
+func TestBulkEvents(t *testing.T) {
+	ops := []todo.BulkItemOperation{
+		{Op: todo.BulkOpCreate, ListId: 10, Item: &todo.TodoItem{Title: "new"}},
+		{Op: todo.BulkOpComplete, ItemId: 2},
+		{Op: todo.BulkOpArchive, ItemId: 3},
+		{Op: todo.BulkOpDelete, ItemId: 4},
+		{Op: todo.BulkOpPurge, ItemId: 5},
+		{Op: todo.BulkOpMove, ItemId: 6, ListId: 11},
+	}
+	repo := &bulkItemRepo{deleted: map[int]bool{5: true}}
+	for i, op := range ops {
+		itemId := op.ItemId
+		if op.Op == todo.BulkOpCreate {
+			itemId = 1
+		}
+		repo.results = append(repo.results, todo.BulkItemResult{Index: i, Op: op.Op, ItemId: itemId})
+	}
+	// Неудачная операция событий не публикует
+	ops = append(ops, todo.BulkItemOperation{Op: todo.BulkOpComplete, ItemId: 7})
+	repo.results = append(repo.results, todo.BulkItemResult{Index: 6, Op: todo.BulkOpComplete, ItemId: 7, Err: sql.ErrNoRows})
+
+	events := &recordedEvents{}
+	s := NewTodoItemService(repo, nil, events)
+	if _, err := s.Bulk(1, ops, false); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		todo.EventItemCreated, todo.EventItemCompleted, todo.EventItemArchived,
+		todo.EventItemArchived, todo.EventItemDeleted, todo.EventItemMoved,
+	}
+	if got := events.types(); !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+
+	// Событие о безвозвратном удалении несет снимок item до пакета
+	purged := events.events[4]
+	if purged.ItemId != 5 || purged.ListId != 10 || len(purged.Data) == 0 {
+		t.Errorf("item.deleted = %+v", purged)
+	}
+}
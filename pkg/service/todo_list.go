@@ -6,15 +6,32 @@ import (
 )
 
 type TodoListService struct {
-	repo repository.TodoList
+	repo     repository.TodoList
+	itemRepo repository.TodoItem
+	events   EventPublisher
 }
 
-func NewTodoListService(repo repository.TodoList) *TodoListService {
-	return &TodoListService{repo: repo}
+func NewTodoListService(repo repository.TodoList, itemRepo repository.TodoItem, events EventPublisher) *TodoListService {
+	return &TodoListService{repo: repo, itemRepo: itemRepo, events: events}
+}
+
+// withRequest возвращает копию сервиса, изменения через которую записываются в журнал
+// аудита от имени автора запроса
+func (s *TodoListService) withRequest(meta todo.RequestMeta) *TodoListService {
+	scoped := *s
+	scoped.repo = s.repo.WithRequest(meta)
+	scoped.itemRepo = s.itemRepo.WithRequest(meta)
+	return &scoped
 }
 
 func (s *TodoListService) Create(userId int, list todo.TodoList) (int, error) {
-	return s.repo.Create(userId, list)
+	id, err := s.repo.Create(userId, list)
+	if err != nil {
+		return 0, err
+	}
+
+	s.publish(todo.EventListCreated, userId, id)
+	return id, nil
 }
 
 func (s *TodoListService) GetAll(userId int) ([]todo.TodoList, error) {
@@ -26,7 +43,9 @@ func (s *TodoListService) GetById(userId, listId int) (todo.TodoList, error) {
 }
 
 func (s *TodoListService) Delete(userId, listId int) error {
-	return s.repo.Delete(userId, listId)
+	return s.delete(userId, listId, func() error {
+		return s.repo.Delete(userId, listId)
+	})
 }
 
 func (s *TodoListService) Update(userId, listId int, input todo.UpdateListInput) error {
@@ -34,7 +53,65 @@ func (s *TodoListService) Update(userId, listId int, input todo.UpdateListInput)
 		return err
 	}
 
-	return s.repo.Update(userId, listId, input)
+	if err := s.repo.Update(userId, listId, input); err != nil {
+		return err
+	}
+
+	s.publish(input.EventType(), userId, listId)
+	return nil
+}
+
+func (s *TodoListService) UpdateIfVersion(userId, listId, version int, input todo.UpdateListInput) error {
+	if err := input.Validate(); err != nil {
+		return err
+	}
+
+	if err := s.repo.UpdateIfVersion(userId, listId, version, input); err != nil {
+		return err
+	}
+
+	s.publish(input.EventType(), userId, listId)
+	return nil
+}
+
+func (s *TodoListService) DeleteIfVersion(userId, listId, version int) error {
+	return s.delete(userId, listId, func() error {
+		return s.repo.DeleteIfVersion(userId, listId, version)
+	})
+}
+
+// delete проверяет доступ к списку, фиксирует аудиторию события до удаления
+// (строки users_lists удаляются каскадно) и публикует list.deleted
+func (s *TodoListService) delete(userId, listId int, del func() error) error {
+	list, err := s.repo.GetById(userId, listId)
+	if err != nil {
+		return err
+	}
+
+	audience, err := s.events.ListAudience(listId)
+	if err != nil {
+		return err
+	}
+
+	if err := del(); err != nil {
+		return err
+	}
+
+	event := newEvent(todo.EventListDeleted, userId, listId, 0, list)
+	event.Audience = audience
+	s.events.Publish(event)
+	return nil
+}
+
+// publish публикует событие со снимком текущего состояния списка.
+// Если список недоступен пользователю, изменение не состоялось
+func (s *TodoListService) publish(eventType string, userId, listId int) {
+	list, err := s.repo.GetById(userId, listId)
+	if err != nil {
+		return
+	}
+
+	s.events.Publish(newEvent(eventType, userId, listId, 0, list))
 }
 
 // V2 методы
@@ -71,7 +148,147 @@ func (s *TodoListService) GetItemCount(userId, listId int) (int, error) {
 }
 
 func (s *TodoListService) ArchiveList(userId, listId int) error {
-	// Временная реализация - используем обычное удаление
-	// В реальной реализации нужно добавить поле archived в базу
-	return s.repo.Delete(userId, listId)
+	if err := s.repo.ArchiveList(userId, listId); err != nil {
+		return err
+	}
+
+	s.publish(todo.EventListArchived, userId, listId)
+	return nil
+}
+
+func (s *TodoListService) GetWorkflow(userId, listId int) (todo.Workflow, error) {
+	statuses, err := s.repo.GetWorkflow(userId, listId)
+	if err != nil {
+		return todo.Workflow{}, err
+	}
+
+	return todo.Workflow{ListId: listId, Statuses: statuses}, nil
+}
+
+// SetWorkflow заменяет статусы списка; items удаленных статусов переходят в оставшиеся
+func (s *TodoListService) SetWorkflow(userId, listId int, input todo.WorkflowInput) (todo.Workflow, error) {
+	if err := input.Validate(); err != nil {
+		return todo.Workflow{}, err
+	}
+
+	statuses, err := s.repo.SetWorkflow(userId, listId, input.Statuses)
+	if err != nil {
+		return todo.Workflow{}, err
+	}
+
+	s.publish(todo.EventListUpdated, userId, listId)
+	return todo.Workflow{ListId: listId, Statuses: statuses}, nil
+}
+
+func (s *TodoListService) CreateSection(userId, listId int, input todo.SectionInput) (todo.ListSection, error) {
+	if err := input.Validate(); err != nil {
+		return todo.ListSection{}, err
+	}
+
+	section, err := s.repo.CreateSection(userId, listId, input)
+	if err != nil {
+		return section, err
+	}
+
+	s.publish(todo.EventListUpdated, userId, listId)
+	return section, nil
+}
+
+func (s *TodoListService) RenameSection(userId, listId, sectionId int, input todo.UpdateSectionInput) (todo.ListSection, error) {
+	if err := input.Validate(); err != nil {
+		return todo.ListSection{}, err
+	}
+
+	section, err := s.repo.RenameSection(userId, listId, sectionId, input.Name)
+	if err != nil {
+		return section, err
+	}
+
+	s.publish(todo.EventListUpdated, userId, listId)
+	return section, nil
+}
+
+func (s *TodoListService) ReorderSections(userId, listId int, input todo.ReorderSectionsInput) ([]todo.ListSection, error) {
+	if err := input.Validate(); err != nil {
+		return nil, err
+	}
+
+	sections, err := s.repo.ReorderSections(userId, listId, input.Ids)
+	if err != nil {
+		return nil, err
+	}
+
+	s.publish(todo.EventListUpdated, userId, listId)
+	return sections, nil
+}
+
+func (s *TodoListService) DeleteSection(userId, listId, sectionId int) error {
+	if err := s.repo.DeleteSection(userId, listId, sectionId); err != nil {
+		return err
+	}
+
+	s.publish(todo.EventListUpdated, userId, listId)
+	return nil
+}
+
+// Duplicate копирует список. Публикуется только list.created: события о каждом
+// скопированном item для списков из тысяч items были бы слишком дорогими. Аудит
+// и webhooks item.created по копиям записывает репозиторий через recordItemsChange
+// в транзакции копирования
+func (s *TodoListService) Duplicate(userId, listId int, input todo.DuplicateListInput) (todo.TodoList, error) {
+	input.Normalize()
+	if err := input.Validate(); err != nil {
+		return todo.TodoList{}, err
+	}
+
+	copyId, err := s.repo.Duplicate(userId, listId, input)
+	if err != nil {
+		return todo.TodoList{}, err
+	}
+
+	s.publish(todo.EventListCreated, userId, copyId)
+	return s.repo.GetById(userId, copyId)
+}
+
+// Merge переносит в список items и участников списка-источника. В журнал списка
+// записывается list.merged с перенесенными items, журнал и webhooks item.moved
+// пишутся в транзакции слияния, а подписчики списка получают item.moved о каждом item
+func (s *TodoListService) Merge(userId, listId int, input todo.MergeListInput) (todo.TodoList, error) {
+	if input.SourceListId == listId {
+		return todo.TodoList{}, todo.ErrMergeIntoItself
+	}
+
+	var itemIds []int
+	merge := func() (err error) {
+		itemIds, err = s.repo.Merge(userId, listId, input.SourceListId, input.DeleteSource)
+		return err
+	}
+
+	if input.DeleteSource {
+		if err := s.delete(userId, input.SourceListId, merge); err != nil {
+			return todo.TodoList{}, err
+		}
+	} else {
+		if err := merge(); err != nil {
+			return todo.TodoList{}, err
+		}
+		s.publish(todo.EventListArchived, userId, input.SourceListId)
+	}
+
+	list, err := s.repo.GetById(userId, listId)
+	if err != nil {
+		return todo.TodoList{}, err
+	}
+
+	for _, itemId := range itemIds {
+		item, err := s.itemRepo.GetById(userId, itemId)
+		if err != nil {
+			continue
+		}
+		s.events.Publish(newEvent(todo.EventItemMoved, userId, listId, itemId, item))
+	}
+
+	merged := todo.ListMergeEventData{TodoList: list, SourceListId: input.SourceListId, ItemIds: itemIds}
+	s.events.Publish(newEvent(todo.EventListMerged, userId, listId, 0, merged))
+	return list, nil
 }
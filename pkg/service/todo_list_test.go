@@ -0,0 +1,141 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/repository"
+)
+
+// archiveListRepo отмечает архивирование и удаление списка
+type archiveListRepo struct {
+	repository.TodoList
+	archived bool
+	deleted  bool
+}
+
+func (r *archiveListRepo) GetById(userId, listId int) (todo.TodoList, error) {
+	return todo.TodoList{Id: listId, Title: "list", Archived: r.archived}, nil
+}
+
+func (r *archiveListRepo) ArchiveList(userId, listId int) error {
+	r.archived = true
+	return nil
+}
+
+func (r *archiveListRepo) Delete(userId, listId int) error {
+	r.deleted = true
+	return nil
+}
+
+func TestArchiveList(t *testing.T) {
+	repo := &archiveListRepo{}
+	events := &recordedEvents{}
+	s := NewTodoListService(repo, nil, events)
+
+	if err := s.ArchiveList(1, 10); err != nil {
+		t.Fatal(err)
+	}
+	if !repo.archived || repo.deleted {
+		t.Errorf("archived = %t, deleted = %t", repo.archived, repo.deleted)
+	}
+	if types := events.types(); !reflect.DeepEqual(types, []string{todo.EventListArchived}) {
+		t.Errorf("events = %v", types)
+	}
+}
+
+// duplicateListRepo запоминает параметры копирования
+type duplicateListRepo struct {
+	repository.TodoList
+	inputs []todo.DuplicateListInput
+}
+
+func (r *duplicateListRepo) Duplicate(userId, listId int, input todo.DuplicateListInput) (int, error) {
+	r.inputs = append(r.inputs, input)
+	return 20, nil
+}
+
+func (r *duplicateListRepo) GetById(userId, listId int) (todo.TodoList, error) {
+	return todo.TodoList{Id: listId}, nil
+}
+
+func TestDuplicateList(t *testing.T) {
+	repo := &duplicateListRepo{}
+	events := &recordedEvents{}
+	s := NewTodoListService(repo, nil, events)
+
+	blank := "   "
+	if _, err := s.Duplicate(1, 10, todo.DuplicateListInput{Title: &blank}); !errors.Is(err, todo.ErrEmptyTitle) {
+		t.Fatalf("blank title: err = %v, want empty title", err)
+	}
+	if len(repo.inputs) != 0 {
+		t.Fatal("list with a blank title was copied")
+	}
+
+	title := "  Copy "
+	list, err := s.Duplicate(1, 10, todo.DuplicateListInput{Title: &title, IncludeCompleted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Id != 20 || *repo.inputs[0].Title != "Copy" {
+		t.Errorf("list %d, title %q", list.Id, *repo.inputs[0].Title)
+	}
+
+	// События о скопированных items не публикуются
+	if types := events.types(); !reflect.DeepEqual(types, []string{todo.EventListCreated}) {
+		t.Errorf("events = %v", types)
+	}
+}
+
+// mergeListRepo запоминает слияния и возвращает перенесенные items
+type mergeListRepo struct {
+	archiveListRepo
+	merges int
+}
+
+func (r *mergeListRepo) Merge(userId, listId, sourceId int, deleteSource bool) ([]int, error) {
+	r.merges++
+	if deleteSource {
+		r.deleted = true
+	} else {
+		r.archived = true
+	}
+	return []int{7, 8}, nil
+}
+
+// mergeItemRepo возвращает снимок перенесенного item
+type mergeItemRepo struct {
+	repository.TodoItem
+}
+
+func (r *mergeItemRepo) GetById(userId, itemId int) (todo.TodoItem, error) {
+	return todo.TodoItem{Id: itemId}, nil
+}
+
+func TestMergeList(t *testing.T) {
+	repo := &mergeListRepo{}
+	events := &recordedEvents{}
+	s := NewTodoListService(repo, &mergeItemRepo{}, events)
+
+	if _, err := s.Merge(1, 10, todo.MergeListInput{SourceListId: 10}); !errors.Is(err, todo.ErrMergeIntoItself) {
+		t.Fatalf("self merge: err = %v, want merge into itself", err)
+	}
+	if repo.merges != 0 || len(events.events) != 0 {
+		t.Fatal("list was merged into itself")
+	}
+
+	if _, err := s.Merge(1, 10, todo.MergeListInput{SourceListId: 11}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{todo.EventListArchived, todo.EventItemMoved, todo.EventItemMoved, todo.EventListMerged}
+	if types := events.types(); !reflect.DeepEqual(types, want) {
+		t.Fatalf("events = %v, want %v", types, want)
+	}
+	for _, event := range events.events[1:3] {
+		if event.ListId != 10 || event.ItemId == 0 {
+			t.Errorf("item.moved: list %d, item %d", event.ListId, event.ItemId)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// Заголовки исходящего запроса webhook
+const (
+	WebhookIdHeader        = "X-Webhook-Id"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// maxWebhookErrorLength ограничивает сохраняемый в журнал ответ получателя
+const maxWebhookErrorLength = 1024
+
+// WebhookWorkerConfig - настройки воркера доставки webhooks
+type WebhookWorkerConfig struct {
+	PollInterval time.Duration // Период опроса outbox
+	BatchSize    int           // Число доставок за один опрос
+	Timeout      time.Duration // Таймаут запроса к получателю
+	MaxAttempts  int           // После исчерпания попыток доставка переходит в dead
+	BaseDelay    time.Duration // Задержка перед второй попыткой, далее удваивается
+	MaxDelay     time.Duration // Верхняя граница задержки между попытками
+}
+
+func (c WebhookWorkerConfig) withDefaults() WebhookWorkerConfig {
+	if c.PollInterval <= 0 {
+		c.PollInterval = 2 * time.Second
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 50
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 8
+	}
+	if c.BaseDelay <= 0 {
+		c.BaseDelay = 30 * time.Second
+	}
+	if c.MaxDelay <= 0 {
+		c.MaxDelay = 6 * time.Hour
+	}
+	return c
+}
+
+// backoff возвращает задержку перед следующей попыткой после attempts неудачных
+func (c WebhookWorkerConfig) backoff(attempts int) time.Duration {
+	delay := c.BaseDelay
+	for i := 1; i < attempts && delay < c.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > c.MaxDelay {
+		delay = c.MaxDelay
+	}
+	return delay
+}
+
+type WebhookService struct {
+	repo repository.Webhooks
+}
+
+func NewWebhookService(repo repository.Webhooks) *WebhookService {
+	return &WebhookService{repo: repo}
+}
+
+// Create регистрирует webhook. Если секрет не передан, он генерируется;
+// секрет возвращается только в ответе на создание
+func (s *WebhookService) Create(userId int, webhook todo.Webhook) (todo.Webhook, error) {
+	if err := checkWebhookURL(context.Background(), webhook.URL); err != nil {
+		return todo.Webhook{}, err
+	}
+
+	if webhook.Secret == "" {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			return todo.Webhook{}, err
+		}
+		webhook.Secret = secret
+	}
+	webhook.Active = true
+
+	id, err := s.repo.Create(userId, webhook)
+	if err != nil {
+		return todo.Webhook{}, err
+	}
+
+	created, err := s.repo.GetById(userId, id)
+	if err != nil {
+		return todo.Webhook{}, err
+	}
+	created.Secret = webhook.Secret
+
+	return created, nil
+}
+
+func (s *WebhookService) GetAll(userId int) ([]todo.Webhook, error) {
+	return s.repo.GetAll(userId)
+}
+
+func (s *WebhookService) GetById(userId, webhookId int) (todo.Webhook, error) {
+	return s.repo.GetById(userId, webhookId)
+}
+
+func (s *WebhookService) Delete(userId, webhookId int) error {
+	return s.repo.Delete(userId, webhookId)
+}
+
+// GetDeliveries возвращает журнал доставок webhook; webhook должен принадлежать пользователю
+func (s *WebhookService) GetDeliveries(userId, webhookId int, status string, limit int) ([]todo.WebhookDelivery, error) {
+	if _, err := s.repo.GetById(userId, webhookId); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetDeliveries(userId, webhookId, status, limit)
+}
+
+func (s *WebhookService) GetDelivery(userId int, deliveryId int64) (todo.WebhookDelivery, error) {
+	return s.repo.GetDelivery(userId, deliveryId)
+}
+
+// Redeliver ставит доставку в очередь повторно и возвращает новую запись журнала
+func (s *WebhookService) Redeliver(userId int, deliveryId int64) (todo.WebhookDelivery, error) {
+	id, err := s.repo.Redeliver(userId, deliveryId)
+	if err != nil {
+		return todo.WebhookDelivery{}, err
+	}
+
+	return s.repo.GetDelivery(userId, id)
+}
+
+// RunWorker отправляет доставки из outbox до отмены ctx
+func (s *WebhookService) RunWorker(ctx context.Context, config WebhookWorkerConfig) {
+	config = config.withDefaults()
+	client := newWebhookClient(config.Timeout)
+
+	ticker := time.NewTicker(config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Пока outbox не пуст, забираем следующую порцию без ожидания
+		for s.deliverDue(ctx, client, config) == config.BatchSize {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverDue отправляет одну порцию доставок и возвращает их число
+func (s *WebhookService) deliverDue(ctx context.Context, client *http.Client, config WebhookWorkerConfig) int {
+	if ctx.Err() != nil {
+		return 0
+	}
+
+	// Доставка не будет выбрана повторно, пока идут попытки отправки всей порции
+	lease := config.Timeout*time.Duration(config.BatchSize) + config.PollInterval
+	deliveries, err := s.repo.ClaimDue(config.BatchSize, lease)
+	if err != nil {
+		logrus.Errorf("webhooks: failed to claim deliveries: %s", err.Error())
+		return 0
+	}
+
+	for _, delivery := range deliveries {
+		s.deliver(ctx, client, config, delivery)
+	}
+	return len(deliveries)
+}
+
+func (s *WebhookService) deliver(ctx context.Context, client *http.Client, config WebhookWorkerConfig, delivery todo.WebhookDelivery) {
+	statusCode, err := sendWebhook(ctx, client, delivery)
+	if err == nil {
+		if err := s.repo.MarkDelivered(delivery.Id, statusCode); err != nil {
+			logrus.Errorf("webhooks: failed to mark delivery %d delivered: %s", delivery.Id, err.Error())
+		}
+		return
+	}
+
+	// attempts уже увеличен при выборе доставки
+	dead := delivery.Attempts >= config.MaxAttempts
+	nextAttemptAt := time.Now().Add(config.backoff(delivery.Attempts))
+	if err := s.repo.MarkFailed(delivery.Id, statusCode, err.Error(), nextAttemptAt, dead); err != nil {
+		logrus.Errorf("webhooks: failed to mark delivery %d failed: %s", delivery.Id, err.Error())
+	}
+
+	if dead {
+		logrus.Warnf("webhooks: delivery %d moved to dead letter after %d attempts", delivery.Id, delivery.Attempts)
+	}
+}
+
+// sendWebhook отправляет доставку получателю. Успехом считается любой ответ 2xx
+func sendWebhook(ctx context.Context, client *http.Client, delivery todo.WebhookDelivery) (int, error) {
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "todo-app-webhooks/1.0")
+	req.Header.Set(WebhookIdHeader, strconv.Itoa(delivery.WebhookId))
+	req.Header.Set(WebhookEventHeader, delivery.EventType)
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatInt(delivery.Id, 10))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxWebhookErrorLength))
+		return resp.StatusCode, nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxWebhookErrorLength))
+	return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+}
+
+// newWebhookClient возвращает клиент для отправки webhooks. Адрес проверяется при каждом
+// подключении: DNS получателя мог измениться после регистрации webhook.
+// Прокси из окружения не используется, иначе проверялся бы адрес прокси, а не получателя
+func newWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("%w: %s", todo.ErrWebhookURLNotAllowed, host)
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConnsPerHost: 2,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		// Редиректы не выполняем: получатель должен отвечать по зарегистрированному url
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// checkWebhookURL проверяет, что все адреса хоста url публичные
+func checkWebhookURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return fmt.Errorf("%w: invalid url", todo.ErrWebhookURLNotAllowed)
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("%w: can't resolve %s", todo.ErrWebhookURLNotAllowed, u.Hostname())
+	}
+	for _, addr := range addrs {
+		if !isPublicIP(addr.IP) {
+			return fmt.Errorf("%w: %s resolves to %s", todo.ErrWebhookURLNotAllowed, u.Hostname(), addr.IP)
+		}
+	}
+	return nil
+}
+
+// isPublicIP сообщает, можно ли отправлять webhooks на адрес. Запрещены loopback,
+// link-local (в том числе metadata-сервис облака 169.254.169.254), частные сети RFC 1918
+// и fc00::/7, а также unspecified и multicast
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsUnspecified() && !ip.IsMulticast()
+}
+
+// SignWebhookPayload вычисляет подпись запроса webhook: HMAC-SHA256 от "<timestamp>.<body>".
+// Получатель проверяет подпись, пересчитывая ее с тем же секретом и заголовком X-Webhook-Timestamp
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func generateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
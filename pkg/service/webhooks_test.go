@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ktuty/todo-app"
+)
+
+func TestSignWebhookPayload(t *testing.T) {
+	body := []byte(`{"event":"item.created"}`)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got := SignWebhookPayload("secret", 1700000000, body); got != want {
+		t.Errorf("signature = %s, want %s", got, want)
+	}
+	if SignWebhookPayload("secret", 1700000001, body) == want {
+		t.Error("signature does not depend on timestamp")
+	}
+	if SignWebhookPayload("other", 1700000000, body) == want {
+		t.Error("signature does not depend on secret")
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	config := WebhookWorkerConfig{BaseDelay: 30 * time.Second, MaxDelay: 5 * time.Minute}
+
+	tests := []struct {
+		attempts int
+		delay    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{4, 4 * time.Minute},
+		{5, 5 * time.Minute},
+		{100, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if delay := config.backoff(tt.attempts); delay != tt.delay {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, delay, tt.delay)
+		}
+	}
+}
+
+func newTestDelivery(url string) todo.WebhookDelivery {
+	return todo.WebhookDelivery{
+		Id:        7,
+		WebhookId: 3,
+		EventType: todo.EventItemCreated,
+		Payload:   []byte(`{"event":"item.created"}`),
+		URL:       url,
+		Secret:    "secret",
+	}
+}
+
+func TestSendWebhookDelivered(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(WebhookTimestampHeader), 10, 64)
+		if r.Header.Get(WebhookSignatureHeader) != SignWebhookPayload("secret", timestamp, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get(WebhookEventHeader) != todo.EventItemCreated || r.Header.Get(WebhookDeliveryHeader) != "7" ||
+			r.Header.Get(WebhookIdHeader) != "3" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	status, err := sendWebhook(context.Background(), server.Client(), newTestDelivery(server.URL))
+	if err != nil || status != http.StatusAccepted {
+		t.Errorf("sendWebhook() = %d, %v", status, err)
+	}
+}
+
+func TestSendWebhookFailedTruncatesBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		io.WriteString(w, strings.Repeat("z", 4*maxWebhookErrorLength))
+	}))
+	defer server.Close()
+
+	status, err := sendWebhook(context.Background(), server.Client(), newTestDelivery(server.URL))
+	if status != http.StatusBadGateway || err == nil {
+		t.Fatalf("sendWebhook() = %d, %v", status, err)
+	}
+	if n := strings.Count(err.Error(), "z"); n != maxWebhookErrorLength {
+		t.Errorf("error keeps %d bytes of body, want %d", n, maxWebhookErrorLength)
+	}
+}
+
+func TestSendWebhookDoesNotFollowRedirects(t *testing.T) {
+	followed := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followed = true
+	}))
+	defer target.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	client := newWebhookClient(time.Second)
+	// Тестовые серверы слушают loopback, поэтому проверка адреса здесь отключена
+	client.Transport = server.Client().Transport
+
+	status, err := sendWebhook(context.Background(), client, newTestDelivery(server.URL))
+	if status != http.StatusTemporaryRedirect || err == nil {
+		t.Errorf("sendWebhook() = %d, %v", status, err)
+	}
+	if followed {
+		t.Error("redirect was followed")
+	}
+}
+
+func TestWebhookClientRefusesPrivateAddresses(t *testing.T) {
+	reached := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer server.Close()
+
+	_, err := sendWebhook(context.Background(), newWebhookClient(time.Second), newTestDelivery(server.URL))
+	if !errors.Is(err, todo.ErrWebhookURLNotAllowed) {
+		t.Errorf("sendWebhook() error = %v, want %v", err, todo.ErrWebhookURLNotAllowed)
+	}
+	if reached {
+		t.Error("request reached loopback server")
+	}
+}
+
+func TestCheckWebhookURL(t *testing.T) {
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"https://93.184.216.34/hook", true},
+		{"https://[2606:2800:220:1:248:1893:25c8:1946]/hook", true},
+		{"http://127.0.0.1:8000/hook", false},
+		{"http://localhost/hook", false},
+		{"http://[::1]/hook", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://10.0.0.5/hook", false},
+		{"http://172.16.3.4/hook", false},
+		{"http://192.168.1.10/hook", false},
+		{"http://[fd00::1]/hook", false},
+		{"http://0.0.0.0/hook", false},
+		{"http://[::ffff:127.0.0.1]/hook", false},
+	}
+	for _, tt := range tests {
+		err := checkWebhookURL(context.Background(), tt.url)
+		if (err == nil) != tt.ok {
+			t.Errorf("checkWebhookURL(%s) = %v, want ok = %t", tt.url, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, todo.ErrWebhookURLNotAllowed) {
+			t.Errorf("checkWebhookURL(%s) error = %v", tt.url, err)
+		}
+	}
+}
+
+func TestCreateWebhookRejectsPrivateURL(t *testing.T) {
+	s := NewWebhookService(nil)
+
+	_, err := s.Create(1, todo.Webhook{URL: "http://169.254.169.254/", Events: []string{todo.EventItemCreated}})
+	if !errors.Is(err, todo.ErrWebhookURLNotAllowed) {
+		t.Errorf("Create() error = %v, want %v", err, todo.ErrWebhookURLNotAllowed)
+	}
+}
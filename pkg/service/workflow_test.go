@@ -0,0 +1,72 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/ktuty/todo-app"
+	"github.com/ktuty/todo-app/pkg/repository"
+)
+
+// workflowListRepo отдает заранее заданный workflow
+type workflowListRepo struct {
+	repository.TodoList
+	statuses []todo.ListStatus
+}
+
+func (r *workflowListRepo) GetWorkflow(userId, listId int) ([]todo.ListStatus, error) {
+	return r.statuses, nil
+}
+
+// boardItemRepo запоминает фильтры выборок колонок
+type boardItemRepo struct {
+	repository.TodoItem
+	filters []todo.ItemFilter
+}
+
+func (r *boardItemRepo) GetAllWithPagination(userId int, filter todo.ItemFilter, offset, limit int) ([]todo.TodoItem, int, error) {
+	r.filters = append(r.filters, filter)
+	return []todo.TodoItem{{Id: len(r.filters)}}, 5, nil
+}
+
+func TestGetBoard(t *testing.T) {
+	statuses := []todo.ListStatus{
+		{Id: 7, Name: "Todo", Category: todo.StatusCategoryOpen},
+		{Id: 9, Name: "Done", Category: todo.StatusCategoryClosed},
+	}
+	repo := &boardItemRepo{}
+	s := NewTodoItemService(repo, &workflowListRepo{statuses: statuses}, &recordedEvents{})
+
+	board, err := s.GetBoard(1, 3, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(board.Columns) != 2 || board.Columns[1].Name != "Done" || *board.Columns[1].StatusId != 9 {
+		t.Fatalf("columns = %+v", board.Columns)
+	}
+	if board.Columns[0].Total != 5 || len(board.Columns[0].Items) != 1 {
+		t.Errorf("first column: total %d, items %d", board.Columns[0].Total, len(board.Columns[0].Items))
+	}
+	for i, filter := range repo.filters {
+		if filter.ListId != 3 || len(filter.StatusIds) != 1 || filter.StatusIds[0] != statuses[i].Id || filter.Completed != "" {
+			t.Errorf("column %d filter = %+v", i, filter)
+		}
+	}
+}
+
+func TestGetBoardWithoutWorkflow(t *testing.T) {
+	repo := &boardItemRepo{}
+	s := NewTodoItemService(repo, &workflowListRepo{}, &recordedEvents{})
+
+	board, err := s.GetBoard(1, 3, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Без workflow колонки соответствуют категориям
+	if len(board.Columns) != 2 || board.Columns[0].StatusId != nil || board.Columns[1].Category != todo.StatusCategoryClosed {
+		t.Fatalf("columns = %+v", board.Columns)
+	}
+	if repo.filters[0].Completed != "false" || repo.filters[1].Completed != "true" {
+		t.Errorf("filters = %+v", repo.filters)
+	}
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	s := NewLocalStore(dir)
+	ctx := context.Background()
+	key := "attachments/1/report.txt"
+
+	if err := s.Put(ctx, key, strings.NewReader("hello"), 5, "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "attachments", "1", "report.txt")); err != nil {
+		t.Errorf("object file: %s", err.Error())
+	}
+
+	body, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("body = %q, want hello", data)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get after delete: err = %v, want not found", err)
+	}
+	// Удаление отсутствующего объекта не ошибка
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("delete missing: %s", err.Error())
+	}
+}
+
+func TestLocalStoreSizeMismatch(t *testing.T) {
+	dir := t.TempDir()
+	s := NewLocalStore(dir)
+	ctx := context.Background()
+
+	if err := s.Put(ctx, "blob", strings.NewReader("hello"), 10, ""); err == nil {
+		t.Fatal("expected a size mismatch error")
+	}
+
+	// Недописанный объект и временный файл не остаются в каталоге
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("directory entries = %v, want none", entries)
+	}
+}
+
+func TestLocalStoreRejectsEscapingKeys(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "blobs")
+	s := NewLocalStore(dir)
+	ctx := context.Background()
+
+	for _, key := range []string{"", "..", "../outside", "a/../../outside", "/etc/passwd"} {
+		t.Run(key, func(t *testing.T) {
+			if err := s.Put(ctx, key, strings.NewReader("x"), 1, ""); err == nil {
+				t.Error("put: expected an error")
+			}
+			if _, err := s.Get(ctx, key); err == nil || errors.Is(err, ErrNotFound) {
+				t.Errorf("get: err = %v, want invalid key", err)
+			}
+			if err := s.Delete(ctx, key); err == nil {
+				t.Error("delete: expected an error")
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "outside")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("file written outside the storage directory: %v", err)
+	}
+}
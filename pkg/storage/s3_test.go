@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 хранит объекты в памяти и проверяет подпись каждого запроса по тому,
+// что дошло до сервера: так ловятся расхождения в кодировании пути
+type fakeS3 struct {
+	secretKey string
+	mu        sync.Mutex
+	objects   map[string]string
+	types     map[string]string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.signatureValid(r) {
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := r.URL.EscapedPath()
+	switch r.Method {
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil || int64(len(body)) != r.ContentLength {
+			http.Error(w, "IncompleteBody", http.StatusBadRequest)
+			return
+		}
+		f.objects[path] = string(body)
+		f.types[path] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		body, ok := f.objects[path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, body)
+	case http.MethodDelete:
+		if _, ok := f.objects[path]; !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		delete(f.objects, path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeS3) signatureValid(r *http.Request) bool {
+	amzDate := r.Header.Get("X-Amz-Date")
+	if len(amzDate) != len("20060102T150405Z") || r.Header.Get("X-Amz-Content-Sha256") != unsignedPayload {
+		return false
+	}
+	scope := amzDate[:8] + "/us-east-1/s3/aws4_request"
+
+	canonicalRequest := strings.Join([]string{
+		r.Method, r.URL.EscapedPath(), r.URL.RawQuery,
+		"host:" + r.Host, "x-amz-content-sha256:" + unsignedPayload, "x-amz-date:" + amzDate, "",
+		"host;x-amz-content-sha256;x-amz-date", unsignedPayload,
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + f.secretKey)
+	for _, part := range []string{amzDate[:8], "us-east-1", "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	want := "AWS4-HMAC-SHA256 Credential=AKID/" + scope +
+		", SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=" + hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	return r.Header.Get("Authorization") == want
+}
+
+func newTestS3Store(t *testing.T, secretKey string) (*S3Store, *fakeS3) {
+	t.Helper()
+
+	fake := &fakeS3{secretKey: "SECRET", objects: map[string]string{}, types: map[string]string{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	s, err := NewS3Store(server.URL, "", "bucket", "AKID", secretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC) }
+	return s, fake
+}
+
+func TestS3StoreRoundTrip(t *testing.T) {
+	s, fake := newTestS3Store(t, "SECRET")
+	ctx := context.Background()
+	key := "attachments/1/отчет за март.txt"
+
+	if err := s.Put(ctx, key, strings.NewReader("hello"), 5, "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	// Путь кодируется по правилам S3: пробел - %20, а не "+"
+	path := "/bucket/attachments/1/%D0%BE%D1%82%D1%87%D0%B5%D1%82%20%D0%B7%D0%B0%20%D0%BC%D0%B0%D1%80%D1%82.txt"
+	if fake.objects[path] != "hello" || fake.types[path] != "text/plain" {
+		t.Fatalf("stored objects = %v, types = %v", fake.objects, fake.types)
+	}
+
+	body, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("body = %q, want hello", data)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get after delete: err = %v, want not found", err)
+	}
+	// Удаление отсутствующего объекта не ошибка
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("delete missing: %s", err.Error())
+	}
+}
+
+func TestS3StoreError(t *testing.T) {
+	s, _ := newTestS3Store(t, "WRONG")
+
+	err := s.Put(context.Background(), "blob", strings.NewReader("x"), 1, "")
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("err = %v, want a signature error", err)
+	}
+	if !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Errorf("err = %s, want the status and the response body", err.Error())
+	}
+}
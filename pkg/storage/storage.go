@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNotFound возвращается, если объекта с указанным ключом нет в хранилище
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore хранит содержимое файлов по ключу. Ключи - пути через "/",
+// их формирует приложение, а не клиент
+type BlobStore interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete не считает ошибкой отсутствие объекта
+	Delete(ctx context.Context, key string) error
+}
+
+// Драйверы хранилища
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+type Config struct {
+	Driver string
+	Dir    string // Каталог драйвера local
+	// Настройки S3-совместимого хранилища
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+// New создает BlobStore по настройкам; драйвер по умолчанию - local
+func New(cfg Config) (BlobStore, error) {
+	switch cfg.Driver {
+	case DriverLocal, "":
+		if cfg.Dir == "" {
+			return nil, errors.New("storage directory is required")
+		}
+		return NewLocalStore(cfg.Dir), nil
+	case DriverS3:
+		if cfg.Endpoint == "" || cfg.Bucket == "" {
+			return nil, errors.New("s3 endpoint and bucket are required")
+		}
+		return NewS3Store(cfg.Endpoint, cfg.Region, cfg.Bucket, cfg.AccessKey, cfg.SecretKey)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
@@ -0,0 +1,6 @@
+-- Откат изменений
+ALTER TABLE todo_lists
+DROP COLUMN IF EXISTS version;
+
+ALTER TABLE todo_items
+DROP COLUMN IF EXISTS version;
@@ -0,0 +1,6 @@
+-- Версия строки для оптимистичной блокировки (ETag / If-Match)
+ALTER TABLE todo_lists
+    ADD COLUMN IF NOT EXISTS version integer not null default 1;
+
+ALTER TABLE todo_items
+    ADD COLUMN IF NOT EXISTS version integer not null default 1;
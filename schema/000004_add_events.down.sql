@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_events_created_at;
+DROP INDEX IF EXISTS idx_events_audience;
+
+DROP TABLE events;
@@ -0,0 +1,16 @@
+-- Журнал доменных событий для real-time потока (SSE/WebSocket)
+CREATE TABLE events (
+                        id bigserial not null unique,
+                        type varchar(64) not null,
+                        actor_id int references users (id) on delete set null,
+                        list_id int,
+                        item_id int,
+                        audience int[] not null default '{}',
+                        payload jsonb,
+                        created_at timestamp with time zone not null default current_timestamp
+);
+
+-- Пользователи, которым доступно событие, сохраняются на момент публикации,
+-- поэтому события об удалении доставляются и после удаления строк users_lists
+CREATE INDEX IF NOT EXISTS idx_events_audience ON events USING gin (audience);
+CREATE INDEX IF NOT EXISTS idx_events_created_at ON events(created_at);
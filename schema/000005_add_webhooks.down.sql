@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_webhook_deliveries_due;
+DROP INDEX IF EXISTS idx_webhook_deliveries_webhook_id;
+DROP INDEX IF EXISTS idx_webhooks_list_id;
+DROP INDEX IF EXISTS idx_webhooks_user_id;
+
+DROP TABLE webhook_deliveries;
+DROP TABLE webhooks;
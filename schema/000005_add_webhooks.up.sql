@@ -0,0 +1,33 @@
+-- Подписки на исходящие webhooks: на все списки пользователя (list_id is null) или на один список
+CREATE TABLE webhooks (
+                          id serial not null unique,
+                          user_id int references users (id) on delete cascade not null,
+                          list_id int references todo_lists (id) on delete cascade,
+                          url varchar(2048) not null,
+                          secret varchar(255) not null,
+                          events text[] not null,
+                          active boolean not null default true,
+                          created_at timestamp with time zone not null default current_timestamp
+);
+
+-- Transactional outbox: доставка создается в той же транзакции, что и изменение,
+-- и отправляется воркером. Журнал доставок хранится в этой же таблице
+CREATE TABLE webhook_deliveries (
+                                    id bigserial not null unique,
+                                    webhook_id int references webhooks (id) on delete cascade not null,
+                                    event_type varchar(64) not null,
+                                    payload jsonb not null,
+                                    status varchar(16) not null default 'pending',
+                                    attempts int not null default 0,
+                                    next_attempt_at timestamp with time zone not null default current_timestamp,
+                                    last_status_code int,
+                                    last_error text,
+                                    redelivery_of bigint references webhook_deliveries (id) on delete set null,
+                                    created_at timestamp with time zone not null default current_timestamp,
+                                    delivered_at timestamp with time zone
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_user_id ON webhooks(user_id);
+CREATE INDEX IF NOT EXISTS idx_webhooks_list_id ON webhooks(list_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
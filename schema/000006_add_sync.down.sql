@@ -0,0 +1,22 @@
+DROP TRIGGER IF EXISTS lists_items_sync_moved ON lists_items;
+DROP TRIGGER IF EXISTS todo_items_sync_tombstone ON todo_items;
+DROP TRIGGER IF EXISTS todo_lists_sync_tombstone ON todo_lists;
+DROP TRIGGER IF EXISTS todo_items_sync_touch ON todo_items;
+DROP TRIGGER IF EXISTS todo_lists_sync_touch ON todo_lists;
+
+DROP FUNCTION IF EXISTS sync_item_moved();
+DROP FUNCTION IF EXISTS sync_item_tombstone();
+DROP FUNCTION IF EXISTS sync_list_tombstone();
+DROP FUNCTION IF EXISTS sync_touch();
+
+DROP TABLE sync_tombstones;
+
+ALTER TABLE todo_items
+    DROP COLUMN IF EXISTS change_xid,
+    DROP COLUMN IF EXISTS change_seq;
+
+ALTER TABLE todo_lists
+    DROP COLUMN IF EXISTS change_xid,
+    DROP COLUMN IF EXISTS change_seq;
+
+DROP SEQUENCE IF EXISTS sync_seq;
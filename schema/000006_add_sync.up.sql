@@ -0,0 +1,83 @@
+-- Delta sync: каждое изменение строки получает номер из общей последовательности
+-- и id транзакции. По id транзакции и снимку (pg_snapshot), сохраненному в токене клиента,
+-- определяется, какие изменения клиент еще не видел, в том числе зафиксированные позже
+-- транзакциями с меньшим номером
+CREATE SEQUENCE IF NOT EXISTS sync_seq;
+
+ALTER TABLE todo_lists
+    ADD COLUMN IF NOT EXISTS change_seq bigint not null default nextval('sync_seq'),
+    ADD COLUMN IF NOT EXISTS change_xid xid8 not null default pg_current_xact_id();
+
+ALTER TABLE todo_items
+    ADD COLUMN IF NOT EXISTS change_seq bigint not null default nextval('sync_seq'),
+    ADD COLUMN IF NOT EXISTS change_xid xid8 not null default pg_current_xact_id();
+
+-- Tombstones удаленных ресурсов. Аудитория фиксируется на момент удаления
+CREATE TABLE sync_tombstones (
+                                 id bigserial not null unique,
+                                 kind varchar(16) not null,
+                                 resource_id int not null,
+                                 list_id int,
+                                 audience int[] not null default '{}',
+                                 change_seq bigint not null default nextval('sync_seq'),
+                                 change_xid xid8 not null default pg_current_xact_id(),
+                                 deleted_at timestamp with time zone not null default current_timestamp
+);
+
+CREATE OR REPLACE FUNCTION sync_touch() RETURNS trigger AS $$
+BEGIN
+    NEW.change_seq := nextval('sync_seq');
+    NEW.change_xid := pg_current_xact_id();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION sync_list_tombstone() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO sync_tombstones (kind, resource_id, list_id, audience)
+    SELECT 'list', OLD.id, OLD.id, coalesce(array_agg(DISTINCT ul.user_id), '{}')
+    FROM users_lists ul WHERE ul.list_id = OLD.id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+-- BEFORE DELETE: строки lists_items удаляются каскадно позже, поэтому список item еще известен
+CREATE OR REPLACE FUNCTION sync_item_tombstone() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO sync_tombstones (kind, resource_id, list_id, audience)
+    SELECT 'item', OLD.id, min(li.list_id), coalesce(array_agg(DISTINCT ul.user_id), '{}')
+    FROM lists_items li
+    INNER JOIN users_lists ul on ul.list_id = li.list_id
+    WHERE li.item_id = OLD.id;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+-- При переносе item пользователи, у которых нет доступа к новому списку, получают tombstone
+CREATE OR REPLACE FUNCTION sync_item_moved() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO sync_tombstones (kind, resource_id, list_id, audience)
+    SELECT 'item', OLD.item_id, OLD.list_id, array_agg(DISTINCT ul.user_id)
+    FROM users_lists ul
+    WHERE ul.list_id = OLD.list_id
+      AND ul.user_id NOT IN (SELECT user_id FROM users_lists WHERE list_id = NEW.list_id)
+    HAVING count(*) > 0;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER todo_lists_sync_touch BEFORE UPDATE ON todo_lists
+    FOR EACH ROW EXECUTE FUNCTION sync_touch();
+CREATE TRIGGER todo_items_sync_touch BEFORE UPDATE ON todo_items
+    FOR EACH ROW EXECUTE FUNCTION sync_touch();
+CREATE TRIGGER todo_lists_sync_tombstone BEFORE DELETE ON todo_lists
+    FOR EACH ROW EXECUTE FUNCTION sync_list_tombstone();
+CREATE TRIGGER todo_items_sync_tombstone BEFORE DELETE ON todo_items
+    FOR EACH ROW EXECUTE FUNCTION sync_item_tombstone();
+CREATE TRIGGER lists_items_sync_moved AFTER UPDATE OF list_id ON lists_items
+    FOR EACH ROW WHEN (OLD.list_id <> NEW.list_id) EXECUTE FUNCTION sync_item_moved();
+
+CREATE INDEX IF NOT EXISTS idx_todo_lists_change_seq ON todo_lists(change_seq);
+CREATE INDEX IF NOT EXISTS idx_todo_items_change_seq ON todo_items(change_seq);
+CREATE INDEX IF NOT EXISTS idx_sync_tombstones_audience ON sync_tombstones USING gin (audience);
+CREATE INDEX IF NOT EXISTS idx_sync_tombstones_change_seq ON sync_tombstones(change_seq);
@@ -0,0 +1,31 @@
+-- Журнал аудита изменений списков и items. Ссылок на users и todo_lists нет намеренно:
+-- записи должны пережить удаление пользователя или списка
+CREATE TABLE audit_log (
+                           id bigserial not null unique,
+                           actor_id int not null,
+                           action varchar(64) not null,
+                           entity varchar(16) not null,
+                           entity_id int not null,
+                           list_id int,
+                           before jsonb,
+                           after jsonb,
+                           diff jsonb,
+                           request_id varchar(128),
+                           ip varchar(64),
+                           user_agent text,
+                           created_at timestamp with time zone not null default current_timestamp
+);
+
+-- Журнал только дополняется
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_list_id ON audit_log(list_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor_id ON audit_log(actor_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_action ON audit_log(action);
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS todo_items_revision ON todo_items;
+DROP TRIGGER IF EXISTS todo_lists_revision ON todo_lists;
+DROP FUNCTION IF EXISTS record_revision();
+
+DROP TABLE revisions;
@@ -0,0 +1,31 @@
+-- Полные снимки каждой версии списков и items. Снимок пишется триггером при любой вставке
+-- или изменении строки, поэтому история не зависит от того, какой код изменил ресурс
+CREATE TABLE revisions (
+                           id bigserial not null unique,
+                           entity varchar(16) not null,
+                           entity_id int not null,
+                           version int not null,
+                           snapshot jsonb not null,
+                           created_at timestamp with time zone not null default current_timestamp,
+                           unique (entity, entity_id, version)
+);
+
+CREATE OR REPLACE FUNCTION record_revision() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO revisions (entity, entity_id, version, snapshot)
+    VALUES (TG_ARGV[0], NEW.id, NEW.version, to_jsonb(NEW) - 'change_seq' - 'change_xid')
+    ON CONFLICT (entity, entity_id, version) DO NOTHING;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER todo_lists_revision AFTER INSERT OR UPDATE ON todo_lists
+    FOR EACH ROW EXECUTE FUNCTION record_revision('list');
+CREATE TRIGGER todo_items_revision AFTER INSERT OR UPDATE ON todo_items
+    FOR EACH ROW EXECUTE FUNCTION record_revision('item');
+
+-- Текущие версии существующих строк становятся первыми ревизиями
+INSERT INTO revisions (entity, entity_id, version, snapshot)
+SELECT 'list', id, version, to_jsonb(tl) - 'change_seq' - 'change_xid' FROM todo_lists tl;
+INSERT INTO revisions (entity, entity_id, version, snapshot)
+SELECT 'item', id, version, to_jsonb(ti) - 'change_seq' - 'change_xid' FROM todo_items ti;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_notifications_unread;
+DROP INDEX IF EXISTS idx_notifications_user_id;
+DROP INDEX IF EXISTS idx_comment_edits_comment_id;
+DROP INDEX IF EXISTS idx_comments_item_id;
+
+DROP TABLE notifications;
+DROP TABLE comment_mentions;
+DROP TABLE comment_edits;
+DROP TABLE comments;
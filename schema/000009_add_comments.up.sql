@@ -0,0 +1,45 @@
+-- Комментарии к items. Текст хранится в Markdown и отдается клиенту как есть.
+-- Удаленный комментарий остается в ветке обсуждения без текста, чтобы не терять ответы на него
+CREATE TABLE comments (
+                          id serial not null unique,
+                          item_id int references todo_items (id) on delete cascade not null,
+                          parent_id int references comments (id) on delete cascade,
+                          author_id int references users (id) on delete set null,
+                          body text not null,
+                          created_at timestamp with time zone not null default current_timestamp,
+                          updated_at timestamp with time zone not null default current_timestamp,
+                          edited_at timestamp with time zone,
+                          deleted_at timestamp with time zone
+);
+
+-- Предыдущие версии текста комментария
+CREATE TABLE comment_edits (
+                               id serial not null unique,
+                               comment_id int references comments (id) on delete cascade not null,
+                               body text not null,
+                               edited_at timestamp with time zone not null default current_timestamp
+);
+
+CREATE TABLE comment_mentions (
+                                  comment_id int references comments (id) on delete cascade not null,
+                                  user_id int references users (id) on delete cascade not null,
+                                  primary key (comment_id, user_id)
+);
+
+-- Входящие уведомления пользователя
+CREATE TABLE notifications (
+                               id bigserial not null unique,
+                               user_id int references users (id) on delete cascade not null,
+                               type varchar(64) not null,
+                               actor_id int references users (id) on delete set null,
+                               list_id int references todo_lists (id) on delete cascade,
+                               item_id int references todo_items (id) on delete cascade,
+                               comment_id int references comments (id) on delete cascade,
+                               read_at timestamp with time zone,
+                               created_at timestamp with time zone not null default current_timestamp
+);
+
+CREATE INDEX IF NOT EXISTS idx_comments_item_id ON comments(item_id, id);
+CREATE INDEX IF NOT EXISTS idx_comment_edits_comment_id ON comment_edits(comment_id, id);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id, id);
+CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications(user_id) WHERE read_at IS NULL;
@@ -0,0 +1,7 @@
+-- Отключенные пользователем типы уведомлений; отсутствие строки означает, что тип включен
+CREATE TABLE notification_preferences (
+                                          user_id int references users (id) on delete cascade not null,
+                                          type varchar(64) not null,
+                                          enabled boolean not null,
+                                          primary key (user_id, type)
+);
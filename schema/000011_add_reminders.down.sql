@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_reminders_user_id;
+DROP INDEX IF EXISTS idx_reminders_item_id;
+DROP INDEX IF EXISTS idx_todo_items_due_at;
+
+DROP TABLE reminders;
+
+ALTER TABLE users DROP COLUMN time_zone;
+ALTER TABLE users DROP COLUMN email;
+
+ALTER TABLE todo_items DROP COLUMN due_at;
@@ -0,0 +1,30 @@
+ALTER TABLE todo_items ADD COLUMN due_at timestamp with time zone;
+
+-- Адрес для писем и часовой пояс пользователя (имя из базы IANA)
+ALTER TABLE users ADD COLUMN email varchar(255);
+ALTER TABLE users ADD COLUMN time_zone varchar(64) not null default 'UTC';
+
+-- Напоминания о сроке item. Задается либо offset_minutes (за сколько минут до срока),
+-- либо at_time (местное время пользователя за days_before дней до дня срока).
+-- Время срабатывания вычисляется из текущего срока item, поэтому при его изменении
+-- напоминание переносится. sent_for - время срабатывания, для которого письмо уже отправлено:
+-- напоминание не отправляется повторно после перезапуска или на другой реплике
+CREATE TABLE reminders (
+                           id serial not null unique,
+                           user_id int references users (id) on delete cascade not null,
+                           item_id int references todo_items (id) on delete cascade not null,
+                           offset_minutes int,
+                           days_before int not null default 0,
+                           at_time time,
+                           sent_for timestamp with time zone,
+                           sent_at timestamp with time zone,
+                           claimed_until timestamp with time zone,
+                           attempts int not null default 0,
+                           last_error text,
+                           created_at timestamp with time zone not null default current_timestamp,
+                           check ((offset_minutes IS NULL) <> (at_time IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_todo_items_due_at ON todo_items(due_at) WHERE due_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_reminders_item_id ON reminders(item_id);
+CREATE INDEX IF NOT EXISTS idx_reminders_user_id ON reminders(user_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_audit_log_completed;
+DROP INDEX IF EXISTS idx_digest_preferences_enabled;
+
+DROP TABLE digest_preferences;
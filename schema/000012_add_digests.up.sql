@@ -0,0 +1,20 @@
+-- Настройки писем-дайджестов. Рассылка включается пользователем явно; время отправки
+-- send_at и день недели weekday (1 - понедельник, для weekly) отсчитываются в часовом поясе
+-- пользователя. sent_for - местная дата, за которую дайджест уже отправлен
+CREATE TABLE digest_preferences (
+                           user_id int references users (id) on delete cascade not null unique,
+                           enabled boolean not null default false,
+                           frequency varchar(16) not null default 'daily',
+                           send_at time not null default '08:00',
+                           weekday smallint not null default 1,
+                           sent_for date,
+                           sent_at timestamp with time zone,
+                           claimed_until timestamp with time zone,
+                           last_error text,
+                           updated_at timestamp with time zone not null default current_timestamp,
+                           check (frequency IN ('daily', 'weekly')),
+                           check (weekday BETWEEN 1 AND 7)
+);
+
+CREATE INDEX IF NOT EXISTS idx_digest_preferences_enabled ON digest_preferences(user_id) WHERE enabled;
+CREATE INDEX IF NOT EXISTS idx_audit_log_completed ON audit_log(list_id, created_at) WHERE action = 'item.completed';
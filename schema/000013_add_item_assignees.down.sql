@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_item_assignees_user_id;
+
+DROP TABLE item_assignees;
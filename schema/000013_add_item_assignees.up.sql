@@ -0,0 +1,11 @@
+-- Ответственные за item. Назначить можно только пользователя с доступом к списку item;
+-- при закрытии доступа или переносе item в другой список лишние назначения удаляются
+CREATE TABLE item_assignees (
+                           item_id int references todo_items (id) on delete cascade not null,
+                           user_id int references users (id) on delete cascade not null,
+                           assigned_by int references users (id) on delete set null,
+                           assigned_at timestamp with time zone not null default current_timestamp,
+                           primary key (item_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_item_assignees_user_id ON item_assignees(user_id);
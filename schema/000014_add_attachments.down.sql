@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_blob_deletions_storage_key;
+DROP INDEX IF EXISTS idx_attachments_item_id;
+
+DROP TRIGGER IF EXISTS attachments_blob_deletion ON attachments;
+DROP FUNCTION IF EXISTS queue_blob_deletion();
+
+DROP TABLE blob_deletions;
+DROP TABLE attachments;
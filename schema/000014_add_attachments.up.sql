@@ -0,0 +1,37 @@
+-- Метаданные файлов, прикрепленных к items. Содержимое хранится в BlobStore по storage_key
+CREATE TABLE attachments (
+                           id serial not null unique,
+                           item_id int references todo_items (id) on delete cascade not null,
+                           uploaded_by int references users (id) on delete set null,
+                           filename varchar(255) not null,
+                           content_type varchar(127) not null,
+                           size bigint not null,
+                           checksum char(64) not null,
+                           storage_key varchar(255) not null unique,
+                           created_at timestamp with time zone not null default current_timestamp
+);
+
+-- Очередь удаления содержимого из BlobStore. Запись добавляется триггером при удалении
+-- метаданных, в том числе каскадном вместе с item, а также перед загрузкой файла: если
+-- метаданные так и не были сохранены, недозагруженный объект будет удален после claimed_until
+CREATE TABLE blob_deletions (
+                           id bigserial not null unique,
+                           storage_key varchar(255) not null,
+                           claimed_until timestamp with time zone,
+                           attempts int not null default 0,
+                           last_error text,
+                           created_at timestamp with time zone not null default current_timestamp
+);
+
+CREATE OR REPLACE FUNCTION queue_blob_deletion() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO blob_deletions (storage_key) VALUES (OLD.storage_key);
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER attachments_blob_deletion AFTER DELETE ON attachments
+    FOR EACH ROW EXECUTE FUNCTION queue_blob_deletion();
+
+CREATE INDEX IF NOT EXISTS idx_attachments_item_id ON attachments(item_id);
+CREATE INDEX IF NOT EXISTS idx_blob_deletions_storage_key ON blob_deletions(storage_key);
@@ -0,0 +1,3 @@
+ALTER TABLE todo_items DROP COLUMN IF EXISTS checklist_auto_complete;
+
+DROP TABLE checklist_items;
@@ -0,0 +1,15 @@
+-- Чек-лист item: упорядоченные пункты без собственных сроков и ответственных.
+-- Позиции пунктов item идут подряд с нуля; уникальность проверяется в конце транзакции,
+-- чтобы вставка и перестановка могли сдвигать позиции
+CREATE TABLE checklist_items (
+                           id serial not null unique,
+                           item_id int references todo_items (id) on delete cascade not null,
+                           position int not null,
+                           text varchar(255) not null,
+                           checked boolean not null default false,
+                           created_at timestamp with time zone not null default current_timestamp,
+                           updated_at timestamp with time zone not null default current_timestamp,
+                           constraint checklist_items_position_key unique (item_id, position) deferrable initially deferred
+);
+
+ALTER TABLE todo_items ADD COLUMN checklist_auto_complete boolean not null default false;
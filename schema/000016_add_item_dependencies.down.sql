@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_item_dependencies_blocked_by_id;
+
+DROP TABLE item_dependencies;
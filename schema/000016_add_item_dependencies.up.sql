@@ -0,0 +1,12 @@
+-- Зависимости между items: item_id нельзя выполнить, пока не выполнен blocked_by_id.
+-- Items могут быть в разных списках; циклы отклоняются при добавлении зависимости
+CREATE TABLE item_dependencies (
+                           item_id int references todo_items (id) on delete cascade not null,
+                           blocked_by_id int references todo_items (id) on delete cascade not null,
+                           created_by int references users (id) on delete set null,
+                           created_at timestamp with time zone not null default current_timestamp,
+                           primary key (item_id, blocked_by_id),
+                           check (item_id <> blocked_by_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_item_dependencies_blocked_by_id ON item_dependencies(blocked_by_id);
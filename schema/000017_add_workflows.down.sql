@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS todo_items_status ON todo_items;
+DROP FUNCTION IF EXISTS sync_item_status();
+
+DROP INDEX IF EXISTS idx_todo_items_status_id;
+ALTER TABLE todo_items DROP COLUMN IF EXISTS status_id;
+
+DROP TABLE list_statuses;
@@ -0,0 +1,38 @@
+-- Workflow списка: упорядоченные статусы items, каждый открытый или закрытый.
+-- У списка без статусов workflow нет, и items описываются только флагом done
+CREATE TABLE list_statuses (
+                           id serial not null unique,
+                           list_id int references todo_lists (id) on delete cascade not null,
+                           name varchar(50) not null,
+                           category varchar(16) not null check (category in ('open', 'closed')),
+                           position int not null,
+                           created_at timestamp with time zone not null default current_timestamp,
+                           constraint list_statuses_position_key unique (list_id, position) deferrable initially deferred
+);
+
+ALTER TABLE todo_items ADD COLUMN status_id int references list_statuses (id) on delete set null;
+
+CREATE INDEX IF NOT EXISTS idx_todo_items_status_id ON todo_items(status_id);
+
+-- done остается источником истины для v1 и выборок по выполненности, поэтому триггер
+-- согласует его со статусом при любом изменении: смена статуса меняет done по категории,
+-- а смена done переводит item в первый статус подходящей категории
+CREATE OR REPLACE FUNCTION sync_item_status() RETURNS trigger AS $$
+BEGIN
+    IF NEW.status_id IS NOT NULL AND NEW.status_id IS DISTINCT FROM OLD.status_id THEN
+        SELECT category = 'closed' INTO NEW.done FROM list_statuses WHERE id = NEW.status_id;
+    ELSIF NEW.done IS DISTINCT FROM OLD.done AND NEW.status_id IS NOT NULL
+        AND NOT EXISTS (SELECT 1 FROM list_statuses WHERE id = NEW.status_id AND (category = 'closed') = NEW.done) THEN
+        NEW.status_id := (
+            SELECT s.id FROM list_statuses s
+            WHERE s.list_id = (SELECT list_id FROM list_statuses WHERE id = NEW.status_id)
+                AND (s.category = 'closed') = NEW.done
+            ORDER BY s.position
+            LIMIT 1);
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER todo_items_status BEFORE UPDATE ON todo_items
+    FOR EACH ROW EXECUTE FUNCTION sync_item_status();
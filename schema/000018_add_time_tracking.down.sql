@@ -0,0 +1,4 @@
+DROP TABLE time_entries;
+
+ALTER TABLE todo_items DROP COLUMN IF EXISTS estimate_minutes;
+ALTER TABLE todo_items DROP COLUMN IF EXISTS priority;
@@ -0,0 +1,19 @@
+-- Приоритет item от 0 (P0, наивысший) до 3 (P3) и оценка в минутах; NULL - не заданы
+ALTER TABLE todo_items ADD COLUMN priority smallint check (priority between 0 and 3);
+ALTER TABLE todo_items ADD COLUMN estimate_minutes int check (estimate_minutes > 0);
+
+-- Учтенное время по item. Запись без ended_at - запущенный таймер; у пользователя
+-- одновременно может идти только один таймер
+CREATE TABLE time_entries (
+                           id serial not null unique,
+                           item_id int references todo_items (id) on delete cascade not null,
+                           user_id int references users (id) on delete cascade not null,
+                           started_at timestamp with time zone not null,
+                           ended_at timestamp with time zone,
+                           note varchar(255) not null default '',
+                           created_at timestamp with time zone not null default current_timestamp,
+                           check (ended_at >= started_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_time_entries_item_id ON time_entries(item_id, started_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_time_entries_running ON time_entries(user_id) WHERE ended_at IS NULL;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_users_lists_folder_id;
+ALTER TABLE users_lists DROP COLUMN IF EXISTS folder_id;
+
+DROP TABLE folders;
@@ -0,0 +1,19 @@
+-- Папки пользователя для группировки списков. Папки вкладываются друг в друга;
+-- parent_id = NULL - папка в корне
+CREATE TABLE folders (
+                           id serial not null unique,
+                           user_id int references users (id) on delete cascade not null,
+                           parent_id int references folders (id) on delete cascade,
+                           name varchar(255) not null,
+                           created_at timestamp with time zone not null default current_timestamp,
+                           updated_at timestamp with time zone not null default current_timestamp,
+                           check (parent_id <> id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_folders_user_id ON folders(user_id, parent_id);
+
+-- Общий список каждый участник раскладывает по своим папкам, поэтому папка
+-- хранится в строке доступа пользователя к списку
+ALTER TABLE users_lists ADD COLUMN folder_id int references folders (id) on delete set null;
+
+CREATE INDEX IF NOT EXISTS idx_users_lists_folder_id ON users_lists(folder_id) WHERE folder_id IS NOT NULL;
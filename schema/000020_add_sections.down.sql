@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_todo_items_section_id;
+ALTER TABLE todo_items DROP COLUMN IF EXISTS position;
+ALTER TABLE todo_items DROP COLUMN IF EXISTS section_id;
+
+DROP TABLE list_sections;
@@ -0,0 +1,22 @@
+-- Разделы внутри списка с ручным порядком
+CREATE TABLE list_sections (
+                           id serial not null unique,
+                           list_id int references todo_lists (id) on delete cascade not null,
+                           name varchar(100) not null,
+                           position int not null,
+                           created_at timestamp with time zone not null default current_timestamp,
+                           updated_at timestamp with time zone not null default current_timestamp,
+                           constraint list_sections_position_key unique (list_id, position) deferrable initially deferred
+);
+
+-- Item принадлежит необязательному разделу своего списка; position - порядок
+-- среди items того же раздела (или items без раздела)
+ALTER TABLE todo_items ADD COLUMN section_id int references list_sections (id) on delete set null;
+ALTER TABLE todo_items ADD COLUMN position int not null default 0;
+
+-- Существующие items сохраняют порядок создания
+UPDATE todo_items ti SET position = o.position
+FROM (SELECT item_id, row_number() OVER (PARTITION BY list_id ORDER BY item_id) - 1 AS position FROM lists_items) o
+WHERE o.item_id = ti.id;
+
+CREATE INDEX IF NOT EXISTS idx_todo_items_section_id ON todo_items(section_id) WHERE section_id IS NOT NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_list_templates_user_id;
+
+DROP TABLE list_templates;
@@ -0,0 +1,13 @@
+-- Шаблоны списков пользователя. Содержимое хранится снимком и не зависит
+-- от списка, из которого шаблон был сохранен
+CREATE TABLE list_templates (
+                           id serial not null unique,
+                           user_id int references users (id) on delete cascade not null,
+                           name varchar(255) not null,
+                           description varchar(255) not null default '',
+                           content jsonb not null,
+                           created_at timestamp with time zone not null default current_timestamp,
+                           updated_at timestamp with time zone not null default current_timestamp
+);
+
+CREATE INDEX IF NOT EXISTS idx_list_templates_user_id ON list_templates(user_id);
@@ -11,12 +11,15 @@ type Server struct {
 }
 
 func (s *Server) Run(port string, handler http.Handler) error {
+	// ReadTimeout и WriteTimeout продлевают маршруты загрузки и скачивания файлов,
+	// поэтому чтение заголовков ограничено отдельно
 	s.httpServer = &http.Server{
-		Addr:           ":" + port,
-		Handler:        handler,
-		MaxHeaderBytes: 1 << 20, // 1MB
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
+		Addr:              ":" + port,
+		Handler:           handler,
+		MaxHeaderBytes:    1 << 20, // 1MB
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      10 * time.Second,
 	}
 
 	return s.httpServer.ListenAndServe()
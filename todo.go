@@ -1,19 +1,44 @@
 package todo
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
+// ListColorPalette - именованные цвета, допустимые наравне с hex-значениями (#rgb, #rrggbb)
+var ListColorPalette = []string{
+	"red", "orange", "yellow", "green", "teal", "blue", "purple", "pink", "gray", "black", "white",
+}
+
 type TodoList struct {
 	Id          int       `json:"id" db:"id"`
-	Title       string    `json:"title" db:"title" binding:"required"`
-	Description string    `json:"description" db:"description"`
-	Archived    bool      `json:"archived" db:"archived"`     // Новое поле для v2
-	CreatedAt   time.Time `json:"created_at" db:"created_at"` // Новое поле для v2
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"` // Новое поле для v2
-	Color       string    `json:"color,omitempty" db:"color"` // Новое поле в v2
-	Priority    int       `json:"priority" db:"priority"`     // Новое поле в v2
+	Title       string    `json:"title" db:"title" binding:"required,max=255"`
+	Description string    `json:"description" db:"description" binding:"max=255"`
+	Archived    bool      `json:"archived" db:"archived"`                                           // Новое поле для v2
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`                                       // Новое поле для v2
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`                                       // Новое поле для v2
+	Color       string    `json:"color,omitempty" db:"color" binding:"omitempty,max=50,list_color"` // Новое поле в v2
+	Priority    int       `json:"priority" db:"priority" binding:"gte=0,lte=5"`                     // Новое поле в v2
+	Version     int       `json:"version" db:"version"`                                             // Увеличивается при каждом изменении
+
+	// TrackedMinutes - учтенное время по items списка, вычисляется при чтении
+	TrackedMinutes int `json:"tracked_minutes" db:"tracked_minutes"`
+	// FolderId - папка, в которую список положил текущий пользователь; nil - корень
+	FolderId *int `json:"folder_id" db:"folder_id"`
+}
+
+// Normalize убирает пробельные символы по краям строковых полей
+func (l *TodoList) Normalize() {
+	l.Title = strings.TrimSpace(l.Title)
+	l.Description = strings.TrimSpace(l.Description)
+	l.Color = strings.ToLower(strings.TrimSpace(l.Color))
 }
 
 type UsersList struct {
@@ -23,13 +48,60 @@ type UsersList struct {
 }
 
 type TodoItem struct {
-	Id          int       `json:"id" db:"id"`
-	Title       string    `json:"title" db:"title" binding:"required"`
-	Description string    `json:"description" db:"description"`
-	Done        bool      `json:"done" db:"done"`
-	Archived    bool      `json:"archived" db:"archived"`     // Новое поле для v2
-	CreatedAt   time.Time `json:"created_at" db:"created_at"` // Новое поле для v2
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"` // Новое поле для v2
+	Id           int        `json:"id" db:"id"`
+	Title        string     `json:"title" db:"title" binding:"required,max=255"`
+	Description  string     `json:"description" db:"description" binding:"max=255"`
+	Done         bool       `json:"done" db:"done"`
+	Archived     bool       `json:"archived" db:"archived"`           // Новое поле для v2
+	DueAt        *time.Time `json:"due_at" db:"due_at"`               // Срок выполнения, nil - без срока
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`       // Новое поле для v2
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`       // Новое поле для v2
+	Version      int        `json:"version" db:"version"`             // Увеличивается при каждом изменении
+	CommentCount int        `json:"comment_count" db:"comment_count"` // Вычисляется при чтении
+	AssigneeIds  IdList     `json:"assignee_ids" db:"assignee_ids"`   // Ответственные, вычисляется при чтении
+
+	// AutoComplete - отметить item выполненным, когда отмечены все пункты чек-листа
+	AutoComplete bool `json:"checklist_auto_complete" db:"checklist_auto_complete"`
+	// Прогресс чек-листа: всего пунктов и отмеченных; вычисляется при чтении
+	ChecklistTotal   int `json:"checklist_total" db:"checklist_total"`
+	ChecklistChecked int `json:"checklist_checked" db:"checklist_checked"`
+	// BlockedByIds - items, которые нужно выполнить раньше этого; Blocked - среди них есть невыполненные
+	BlockedByIds IdList `json:"blocked_by_ids" db:"blocked_by_ids"`
+	Blocked      bool   `json:"blocked" db:"blocked"`
+	// StatusId - статус из workflow списка, nil - у списка нет workflow. Done следует из категории статуса
+	StatusId *int `json:"status_id" db:"status_id"`
+	// Priority - приоритет от 0 (P0, наивысший) до 3 (P3), nil - без приоритета
+	Priority *int `json:"priority" db:"priority" binding:"omitempty,gte=0,lte=3"`
+	// EstimateMinutes - оценка в минутах; TrackedMinutes - учтенное время с учетом запущенных таймеров,
+	// вычисляется при чтении
+	EstimateMinutes *int `json:"estimate_minutes" db:"estimate_minutes" binding:"omitempty,gt=0,lte=525600"`
+	TrackedMinutes  int  `json:"tracked_minutes" db:"tracked_minutes"`
+	// SectionId - раздел списка, nil - без раздела; Position - порядок среди items того же раздела
+	SectionId *int `json:"section_id" db:"section_id" binding:"omitempty,gt=0"`
+	Position  int  `json:"position" db:"position"`
+}
+
+// IdList - список идентификаторов, который читается из json-массива в выборке
+type IdList []int
+
+func (l *IdList) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*l = IdList{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, (*[]int)(l))
+	case string:
+		return json.Unmarshal([]byte(v), (*[]int)(l))
+	default:
+		return errors.New("unsupported type for IdList")
+	}
+}
+
+// Normalize убирает пробельные символы по краям строковых полей
+func (i *TodoItem) Normalize() {
+	i.Title = strings.TrimSpace(i.Title)
+	i.Description = strings.TrimSpace(i.Description)
 }
 
 type ListsItem struct {
@@ -38,6 +110,52 @@ type ListsItem struct {
 	ItemId int `db:"item_id"`
 }
 
+// ErrVersionMismatch возвращается, если ресурс был изменен после чтения клиентом
+var ErrVersionMismatch = errors.New("resource version mismatch")
+
+// ErrBulkAborted - операция пакета не применена, т.к. атомарный пакет был откачен
+var ErrBulkAborted = errors.New("operation not applied: batch was rolled back")
+
+// Операции пакетной обработки items
+const (
+	BulkOpCreate   = "create"
+	BulkOpUpdate   = "update"
+	BulkOpComplete = "complete"
+	BulkOpArchive  = "archive"
+	BulkOpMove     = "move"
+	BulkOpDelete   = "delete" // Архивирует item, как DELETE /api/v2/items/{id}
+	BulkOpPurge    = "purge"  // Удаляет item безвозвратно
+)
+
+// BulkItemOperation - одна операция пакетного запроса над items
+type BulkItemOperation struct {
+	Op      string           `json:"op" binding:"required,oneof=create update complete archive move delete purge"`
+	ItemId  int              `json:"item_id,omitempty" binding:"required_unless=Op create,gte=0"`
+	ListId  int              `json:"list_id,omitempty" binding:"required_if=Op create,required_if=Op move,gte=0"` // Список для create, целевой список для move
+	Item    *TodoItem        `json:"item,omitempty" binding:"required_if=Op create"`
+	Update  *UpdateItemInput `json:"update,omitempty" binding:"required_if=Op update"`
+	Version int              `json:"version,omitempty" binding:"gte=0"` // Ожидаемая версия item, 0 - без проверки
+	Force   bool             `json:"force,omitempty"`                   // Выполнить item, несмотря на блокирующие items
+}
+
+// Normalize приводит вложенные данные операции к каноничному виду
+func (o *BulkItemOperation) Normalize() {
+	if o.Item != nil {
+		o.Item.Normalize()
+	}
+	if o.Update != nil {
+		o.Update.Normalize()
+	}
+}
+
+// BulkItemResult - результат выполнения одной операции пакета
+type BulkItemResult struct {
+	Index  int
+	Op     string
+	ItemId int
+	Err    error
+}
+
 // ListV2 - алиас для обратной совместимости, используйте TodoList вместо этого
 type ListV2 struct {
 	ID          int       `json:"id"`
@@ -59,37 +177,1548 @@ type PaginatedResponse struct {
 }
 
 type UpdateListInput struct {
-	Title       *string `json:"title"`
-	Description *string `json:"description"`
-	Archived    *bool   `json:"archived"` // Добавлено для v2
-	Color       *string `json:"color"`    // Добавлено для v2
-	Priority    *int    `json:"priority"` // Добавлено для v2
+	Title       *string `json:"title" binding:"omitempty,min=1,max=255"`
+	Description *string `json:"description" binding:"omitempty,max=255"`
+	Archived    *bool   `json:"archived"`                                    // Добавлено для v2
+	Color       *string `json:"color" binding:"omitempty,max=50,list_color"` // Добавлено для v2
+	Priority    *int    `json:"priority" binding:"omitempty,gte=0,lte=5"`    // Добавлено для v2
 }
 
+// Normalize убирает пробельные символы по краям переданных строковых полей
+func (i *UpdateListInput) Normalize() {
+	trimPtr(i.Title)
+	trimPtr(i.Description)
+	if i.Color != nil {
+		*i.Color = strings.ToLower(strings.TrimSpace(*i.Color))
+	}
+}
+
+// EventType возвращает тип события, которым сопровождается обновление списка
+func (i UpdateListInput) EventType() string {
+	if i.Archived != nil && *i.Archived {
+		return EventListArchived
+	}
+	return EventListUpdated
+}
+
+// ErrInvalidUpdate возвращается, если переданное обновление списка или item неверно
+var ErrInvalidUpdate = errors.New("invalid update")
+
 func (i *UpdateListInput) Validate() error {
 	if i.Title == nil && i.Description == nil && i.Archived == nil && i.Color == nil && i.Priority == nil {
-		return errors.New("update structure has no values")
+		return fmt.Errorf("%w: update structure has no values", ErrInvalidUpdate)
 	}
 	return nil
 }
 
 type UpdateItemInput struct {
-	Title       *string `json:"title"`
-	Description *string `json:"description"`
-	Done        *bool   `json:"done"`
-	Archived    *bool   `json:"archived"` // Добавлено для v2
+	Title        *string      `json:"title" binding:"omitempty,min=1,max=255"`
+	Description  *string      `json:"description" binding:"omitempty,max=255"`
+	Done         *bool        `json:"done"`
+	Archived     *bool        `json:"archived"`                                       // Добавлено для v2
+	DueAt        OptionalTime `json:"due_at" swaggertype:"string" format:"date-time"` // null снимает срок
+	AutoComplete *bool        `json:"checklist_auto_complete"`
+	StatusId     *int         `json:"status_id" binding:"omitempty,gt=0"` // Статус из workflow списка, меняет done
+	// Priority и EstimateMinutes: null снимает значение
+	Priority        OptionalInt `json:"priority" swaggertype:"integer"`
+	EstimateMinutes OptionalInt `json:"estimate_minutes" swaggertype:"integer"`
+	// SectionId переносит item в конец раздела своего списка; null - в конец items без раздела
+	SectionId OptionalInt `json:"section_id" swaggertype:"integer"`
+	// Force разрешает отметить выполненным item с невыполненными блокирующими items
+	Force bool `json:"-"`
+}
+
+// Normalize убирает пробельные символы по краям переданных строковых полей
+func (i *UpdateItemInput) Normalize() {
+	trimPtr(i.Title)
+	trimPtr(i.Description)
+}
+
+// EventType возвращает тип события, которым сопровождается обновление item
+func (i UpdateItemInput) EventType() string {
+	switch {
+	case i.Archived != nil && *i.Archived:
+		return EventItemArchived
+	case i.Done != nil && *i.Done:
+		return EventItemCompleted
+	default:
+		return EventItemUpdated
+	}
 }
 
 func (i *UpdateItemInput) Validate() error {
-	if i.Title == nil && i.Description == nil && i.Done == nil && i.Archived == nil {
+	if i.Title == nil && i.Description == nil && i.Done == nil && i.Archived == nil && !i.DueAt.Set && i.AutoComplete == nil &&
+		i.StatusId == nil && !i.Priority.Set && !i.EstimateMinutes.Set && !i.SectionId.Set {
+		return fmt.Errorf("%w: update structure has no values", ErrInvalidUpdate)
+	}
+	if v := i.Priority.Value; v != nil && (*v < MinItemPriority || *v > MaxItemPriority) {
+		return fmt.Errorf("%w: priority must be between %d and %d", ErrInvalidUpdate, MinItemPriority, MaxItemPriority)
+	}
+	if v := i.EstimateMinutes.Value; v != nil && (*v < 1 || *v > MaxEstimateMinutes) {
+		return fmt.Errorf("%w: estimate_minutes must be between 1 and %d", ErrInvalidUpdate, MaxEstimateMinutes)
+	}
+	if v := i.SectionId.Value; v != nil && *v < 1 {
+		return fmt.Errorf("%w: section_id must be positive", ErrInvalidUpdate)
+	}
+	return nil
+}
+
+// Допустимые приоритет и оценка item; совпадают с ограничениями binding у TodoItem
+const (
+	MinItemPriority    = 0
+	MaxItemPriority    = 3
+	MaxEstimateMinutes = 525600
+)
+
+// OptionalTime - время в частичном обновлении, где нужно отличать отсутствующее поле от null:
+// Set = поле передано, Time = nil - значение нужно очистить
+type OptionalTime struct {
+	Set  bool
+	Time *time.Time
+}
+
+// NewOptionalTime возвращает переданное значение времени; nil означает очистку
+func NewOptionalTime(t *time.Time) OptionalTime {
+	return OptionalTime{Set: true, Time: t}
+}
+
+func (t *OptionalTime) UnmarshalJSON(data []byte) error {
+	t.Set = true
+	if string(data) == "null" {
+		t.Time = nil
+		return nil
+	}
+
+	var value time.Time
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	t.Time = &value
+	return nil
+}
+
+func (t OptionalTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Time)
+}
+
+// OptionalInt - число в частичном обновлении: Set = поле передано, Value = nil - значение нужно очистить
+type OptionalInt struct {
+	Set   bool
+	Value *int
+}
+
+// NewOptionalInt возвращает переданное значение; nil означает очистку
+func NewOptionalInt(v *int) OptionalInt {
+	return OptionalInt{Set: true, Value: v}
+}
+
+func (i *OptionalInt) UnmarshalJSON(data []byte) error {
+	i.Set = true
+	if string(data) == "null" {
+		i.Value = nil
+		return nil
+	}
+
+	var value int
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	i.Value = &value
+	return nil
+}
+
+func (i OptionalInt) MarshalJSON() ([]byte, error) {
+	return json.Marshal(i.Value)
+}
+
+// IsListColor проверяет, что цвет задан в hex-формате (#rgb, #rrggbb) или входит в палитру
+func IsListColor(color string) bool {
+	if strings.HasPrefix(color, "#") {
+		hex := color[1:]
+		if len(hex) != 3 && len(hex) != 6 {
+			return false
+		}
+		for _, r := range hex {
+			if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, named := range ListColorPalette {
+		if color == named {
+			return true
+		}
+	}
+	return false
+}
+
+func trimPtr(s *string) {
+	if s != nil {
+		*s = strings.TrimSpace(*s)
+	}
+}
+
+// Типы доменных событий
+const (
+	EventListCreated   = "list.created"
+	EventListUpdated   = "list.updated"
+	EventListArchived  = "list.archived"
+	EventListDeleted   = "list.deleted"
+	EventItemCreated   = "item.created"
+	EventItemUpdated   = "item.updated"
+	EventItemCompleted = "item.completed"
+	EventItemArchived  = "item.archived"
+	EventItemMoved     = "item.moved"
+	EventItemDeleted   = "item.deleted"
+
+	EventItemAssigned   = "item.assigned"
+	EventItemUnassigned = "item.unassigned"
+	EventItemDueSoon    = "item.due_soon" // Сработало напоминание пользователя о сроке item
+
+	EventListShared   = "list.shared"
+	EventListUnshared = "list.unshared"
+	EventListMerged   = "list.merged"
+
+	EventCommentCreated = "comment.created"
+	EventCommentUpdated = "comment.updated"
+	EventCommentDeleted = "comment.deleted"
+)
+
+// Event - доменное событие об изменении списка или item
+type Event struct {
+	Id        int64           `json:"id" db:"id"`
+	Type      string          `json:"type" db:"type"`
+	ActorId   int             `json:"actor_id" db:"actor_id"`
+	ListId    int             `json:"list_id,omitempty" db:"list_id"`
+	ItemId    int             `json:"item_id,omitempty" db:"item_id"`
+	Audience  []int           `json:"-" db:"-"` // Пользователи, имеющие доступ к ресурсу события
+	Data      json.RawMessage `json:"data,omitempty" db:"-"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+// VisibleTo проверяет, входит ли пользователь в аудиторию события
+func (e Event) VisibleTo(userId int) bool {
+	for _, id := range e.Audience {
+		if id == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEvents - события, на которые можно подписать webhook
+var WebhookEvents = []string{
+	EventListUpdated, EventListArchived, EventListDeleted,
+	EventItemCreated, EventItemUpdated, EventItemCompleted, EventItemArchived, EventItemMoved, EventItemDeleted,
+	EventItemAssigned, EventItemUnassigned,
+}
+
+// Статусы доставки webhook
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryDead      = "dead" // Попытки исчерпаны
+)
+
+// Webhook - подписка на события всех списков пользователя или одного списка
+type Webhook struct {
+	Id        int       `json:"id" db:"id"`
+	ListId    *int      `json:"list_id,omitempty" db:"list_id" binding:"omitempty,gt=0"`
+	URL       string    `json:"url" db:"url" binding:"required,http_url,max=2048"`
+	Secret    string    `json:"secret,omitempty" db:"secret" binding:"omitempty,min=16,max=255"` // Возвращается только при создании
+	Events    []string  `json:"events" db:"-" binding:"required,min=1,dive,webhook_event"`
+	Active    bool      `json:"active" db:"active"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// Normalize убирает пробельные символы по краям url
+func (w *Webhook) Normalize() {
+	w.URL = strings.TrimSpace(w.URL)
+}
+
+// ErrWebhookURLNotAllowed возвращается, если url webhook ведет на внутренний адрес:
+// loopback, link-local или частную сеть
+var ErrWebhookURLNotAllowed = errors.New("webhook url must point to a public address")
+
+// IsWebhookEvent проверяет, можно ли подписать webhook на событие
+func IsWebhookEvent(eventType string) bool {
+	for _, e := range WebhookEvents {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery - запись журнала доставок webhook
+type WebhookDelivery struct {
+	Id             int64           `json:"id" db:"id"`
+	WebhookId      int             `json:"webhook_id" db:"webhook_id"`
+	EventType      string          `json:"event_type" db:"event_type"`
+	Payload        json.RawMessage `json:"payload" db:"payload"`
+	Status         string          `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at" db:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code,omitempty" db:"last_status_code"`
+	LastError      *string         `json:"last_error,omitempty" db:"last_error"`
+	RedeliveryOf   *int64          `json:"redelivery_of,omitempty" db:"redelivery_of"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty" db:"delivered_at"`
+
+	// Заполняются только для отправки воркером
+	URL    string `json:"-" db:"url"`
+	Secret string `json:"-" db:"secret"`
+}
+
+// Типы сущностей в событиях, журнале аудита и delta sync
+const (
+	EntityList      = "list"
+	EntityItem      = "item"
+	EntityTimeEntry = "time_entry" // Только в журнале аудита
+)
+
+// Виды ресурсов delta sync
+const (
+	SyncKindList = EntityList
+	SyncKindItem = EntityItem
+)
+
+// Результаты применения изменения клиента
+const (
+	SyncApplied  = "applied"
+	SyncConflict = "conflict" // Версия на сервере новее, изменение клиента отклонено
+	SyncNotFound = "not_found"
+	SyncInvalid  = "invalid"
+	SyncFailed   = "failed"
+)
+
+// ErrInvalidSyncToken возвращается для поврежденного или чужого токена синхронизации
+var ErrInvalidSyncToken = errors.New("invalid sync token")
+
+// SyncChange - изменение ресурса в дельте; для удаленных ресурсов Deleted = true и Data пуст
+type SyncChange struct {
+	Kind    string          `json:"kind" db:"kind"`
+	Id      int             `json:"id" db:"id"`
+	ListId  int             `json:"list_id,omitempty" db:"list_id"`
+	Seq     int64           `json:"seq" db:"seq"`
+	Deleted bool            `json:"deleted,omitempty" db:"deleted"`
+	Data    json.RawMessage `json:"data,omitempty" db:"data"`
+}
+
+// SyncClientChange - изменение, сделанное клиентом офлайн. Delete архивирует ресурс,
+// как DELETE в v2, purge удаляет его безвозвратно.
+// Ресурсы, созданные в том же пакете, адресуются по client_id (list_client_id для items)
+type SyncClientChange struct {
+	Op              string           `json:"op" binding:"required,oneof=create update delete purge"`
+	Kind            string           `json:"kind" binding:"required,oneof=list item"`
+	ClientId        string           `json:"client_id,omitempty" binding:"required_if=Op create,max=64"`
+	Id              int              `json:"id,omitempty" binding:"required_unless=Op create,gte=0"`
+	ListId          int              `json:"list_id,omitempty" binding:"gte=0"`
+	ListClientId    string           `json:"list_client_id,omitempty" binding:"max=64"`
+	BaseVersion     int              `json:"base_version,omitempty" binding:"gte=0"` // Версия, от которой клиент вносил изменение
+	ClientUpdatedAt *time.Time       `json:"client_updated_at,omitempty"`            // Время изменения на клиенте
+	List            *UpdateListInput `json:"list,omitempty" binding:"omitempty"`
+	Item            *UpdateItemInput `json:"item,omitempty" binding:"omitempty"`
+}
+
+// Normalize приводит вложенные данные изменения к каноничному виду
+func (c *SyncClientChange) Normalize() {
+	if c.List != nil {
+		c.List.Normalize()
+	}
+	if c.Item != nil {
+		c.Item.Normalize()
+	}
+}
+
+// SyncChangeResult - результат применения одного изменения клиента
+type SyncChangeResult struct {
+	Index    int         `json:"index"`
+	ClientId string      `json:"client_id,omitempty"`
+	Kind     string      `json:"kind"`
+	Id       int         `json:"id,omitempty"`
+	Status   string      `json:"status"`
+	Error    string      `json:"error,omitempty"`
+	Server   interface{} `json:"server,omitempty"` // Текущее состояние на сервере при конфликте
+}
+
+// RequestMeta - сведения о запросе, инициировавшем изменение
+type RequestMeta struct {
+	ActorId   int // Пользователь, от имени которого выполняется запрос
+	RequestId string
+	IP        string
+	UserAgent string
+}
+
+// AuditEntry - запись журнала аудита об изменении списка или item
+type AuditEntry struct {
+	Id        int64           `json:"id" db:"id"`
+	ActorId   int             `json:"actor_id" db:"actor_id"`
+	Action    string          `json:"action" db:"action"` // Тип события: list.created, item.completed, ...
+	Entity    string          `json:"entity" db:"entity"`
+	EntityId  int             `json:"entity_id" db:"entity_id"`
+	ListId    int             `json:"list_id,omitempty" db:"list_id"`
+	Before    json.RawMessage `json:"before,omitempty" db:"-"`
+	After     json.RawMessage `json:"after,omitempty" db:"-"`
+	Diff      json.RawMessage `json:"diff,omitempty" db:"-"` // {"field": {"from": ..., "to": ...}}
+	RequestId string          `json:"request_id,omitempty" db:"request_id"`
+	IP        string          `json:"ip,omitempty" db:"ip"`
+	UserAgent string          `json:"user_agent,omitempty" db:"user_agent"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+// Действия с записями учтенного времени в журнале аудита
+const (
+	AuditTimeEntryCreated = "time_entry.created"
+	AuditTimeEntryStopped = "time_entry.stopped"
+	AuditTimeEntryDeleted = "time_entry.deleted"
+)
+
+// AuditActions - действия, которые записываются в журнал аудита
+var AuditActions = []string{
+	EventListCreated, EventListUpdated, EventListArchived, EventListDeleted, EventListMerged,
+	EventItemCreated, EventItemUpdated, EventItemCompleted, EventItemArchived, EventItemMoved, EventItemDeleted,
+	EventItemAssigned, EventItemUnassigned,
+	AuditTimeEntryCreated, AuditTimeEntryStopped, AuditTimeEntryDeleted,
+}
+
+// IsAuditAction проверяет, что действие записывается в журнал аудита
+func IsAuditAction(action string) bool {
+	for _, a := range AuditActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// AuditFilter - фильтр выборки журнала аудита
+type AuditFilter struct {
+	Actions []string
+	Offset  int
+	Limit   int
+}
+
+// Revision - полный снимок списка или item в одной из версий
+type Revision struct {
+	Entity    string          `json:"entity" db:"entity"`
+	EntityId  int             `json:"entity_id" db:"entity_id"`
+	Version   int             `json:"version" db:"version"`
+	Snapshot  json.RawMessage `json:"snapshot" db:"snapshot"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+// FieldChange - изменение значения поля
+type FieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// RevisionDiff - изменения полей между двумя ревизиями
+type RevisionDiff struct {
+	Entity   string                 `json:"entity"`
+	EntityId int                    `json:"entity_id"`
+	From     int                    `json:"from"`
+	To       int                    `json:"to"`
+	Changes  map[string]FieldChange `json:"changes"`
+}
+
+// ErrForbidden возвращается, если ресурс доступен пользователю, но действие над ним запрещено
+var ErrForbidden = errors.New("action is not allowed")
+
+// ErrInvalidParentComment возвращается, если родительский комментарий не найден у того же item
+var ErrInvalidParentComment = errors.New("parent comment not found on this item")
+
+// Comment - комментарий к item. Текст хранится в Markdown; у удаленного комментария
+// текст и упоминания пустые, но он остается в ветке, чтобы ответы на него не терялись
+type Comment struct {
+	Id        int        `json:"id" db:"id"`
+	ItemId    int        `json:"item_id" db:"item_id"`
+	ParentId  *int       `json:"parent_id" db:"parent_id"`
+	AuthorId  int        `json:"author_id" db:"author_id"`
+	Author    string     `json:"author" db:"author"` // username автора
+	Body      string     `json:"body" db:"body"`
+	Mentions  []string   `json:"mentions" db:"-"`
+	Deleted   bool       `json:"deleted" db:"deleted"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+	EditedAt  *time.Time `json:"edited_at,omitempty" db:"edited_at"`
+}
+
+type CommentInput struct {
+	Body     string `json:"body" binding:"required,max=10000"`
+	ParentId *int   `json:"parent_id"`
+}
+
+type UpdateCommentInput struct {
+	Body string `json:"body" binding:"required,max=10000"`
+}
+
+// Normalize убирает пробельные символы по краям текста
+func (i *CommentInput) Normalize() {
+	i.Body = strings.TrimSpace(i.Body)
+}
+
+// Normalize убирает пробельные символы по краям текста
+func (i *UpdateCommentInput) Normalize() {
+	i.Body = strings.TrimSpace(i.Body)
+}
+
+// CommentEdit - предыдущая версия текста комментария
+type CommentEdit struct {
+	Body     string    `json:"body" db:"body"`
+	EditedAt time.Time `json:"edited_at" db:"edited_at"`
+}
+
+// Типы уведомлений
+const (
+	NotificationMention    = "mention"     // Пользователя упомянули в комментарии
+	NotificationListShared = "list_shared" // Пользователю открыли доступ к списку
+	NotificationAssigned   = "assigned"    // Пользователя назначили ответственным за item
+	NotificationUnassigned = "unassigned"  // Пользователя сняли с item
+	NotificationDueSoon    = "due_soon"    // Приближается срок item, сработало напоминание пользователя
+)
+
+// NotificationTypes - типы уведомлений, которые пользователь может отключить
+var NotificationTypes = []string{
+	NotificationMention, NotificationListShared, NotificationAssigned, NotificationUnassigned, NotificationDueSoon,
+}
+
+// IsNotificationType проверяет, что тип уведомления существует
+func IsNotificationType(notificationType string) bool {
+	for _, t := range NotificationTypes {
+		if t == notificationType {
+			return true
+		}
+	}
+	return false
+}
+
+// Notification - уведомление во входящих пользователя
+type Notification struct {
+	Id        int64      `json:"id" db:"id"`
+	UserId    int        `json:"-" db:"user_id"`
+	Type      string     `json:"type" db:"type"`
+	ActorId   int        `json:"actor_id" db:"actor_id"`
+	ListId    int        `json:"list_id,omitempty" db:"list_id"`
+	ItemId    int        `json:"item_id,omitempty" db:"item_id"`
+	CommentId int        `json:"comment_id,omitempty" db:"comment_id"`
+	ReadAt    *time.Time `json:"read_at" db:"read_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// CommentEventData - данные событий comment.*. Mentioned - пользователи,
+// впервые упомянутые этим изменением комментария
+type CommentEventData struct {
+	Comment
+	Mentioned []int `json:"mentioned,omitempty"`
+}
+
+// ListMember - пользователь с доступом к списку
+type ListMember struct {
+	UserId   int    `json:"user_id" db:"user_id"`
+	Name     string `json:"name" db:"name"`
+	Username string `json:"username" db:"username"`
+}
+
+type AddListMemberInput struct {
+	Username string `json:"username" binding:"required,max=255"`
+}
+
+// Normalize убирает пробельные символы по краям username
+func (i *AddListMemberInput) Normalize() {
+	i.Username = strings.TrimSpace(i.Username)
+}
+
+// ErrUserNotFound возвращается, если пользователь с указанным username не существует
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrLastListMember возвращается при попытке закрыть доступ к списку последнему участнику
+var ErrLastListMember = errors.New("cannot remove the last member of a list")
+
+// AssignItemInput - назначение ответственного за item
+type AssignItemInput struct {
+	UserId int `json:"user_id" binding:"required,gt=0"`
+}
+
+// AssignmentEventData - данные событий item.assigned и item.unassigned: снимок item
+// и пользователь, которого назначили или сняли
+type AssignmentEventData struct {
+	TodoItem
+	AssigneeId int `json:"assignee_id"`
+}
+
+// ErrAssigneeNoAccess возвращается при назначении пользователя без доступа к списку item
+var ErrAssigneeNoAccess = errors.New("assignee has no access to the list")
+
+// UserSettings - контактные данные и часовой пояс пользователя для писем и напоминаний
+type UserSettings struct {
+	Email    string `json:"email" db:"email"`
+	TimeZone string `json:"time_zone" db:"time_zone"`
+}
+
+// UpdateSettingsInput - изменение настроек; пустой email отключает письма с напоминаниями
+type UpdateSettingsInput struct {
+	Email    *string `json:"email" binding:"omitempty,max=255,email|len=0"`
+	TimeZone *string `json:"time_zone" binding:"omitempty,time_zone"`
+}
+
+// Normalize убирает пробельные символы по краям переданных строковых полей
+func (i *UpdateSettingsInput) Normalize() {
+	trimPtr(i.Email)
+	trimPtr(i.TimeZone)
+}
+
+func (i *UpdateSettingsInput) Validate() error {
+	if i.Email == nil && i.TimeZone == nil {
 		return errors.New("update structure has no values")
 	}
 	return nil
 }
 
+// IsTimeZone проверяет, что name - часовой пояс из базы IANA
+func IsTimeZone(name string) bool {
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// Reminder - напоминание пользователя о сроке item. Срабатывает за OffsetMinutes минут до срока
+// либо в местное время AtTime за DaysBefore дней до дня срока
+type Reminder struct {
+	Id            int        `json:"id" db:"id"`
+	ItemId        int        `json:"item_id" db:"item_id"`
+	OffsetMinutes *int       `json:"offset_minutes,omitempty" db:"offset_minutes"`
+	DaysBefore    int        `json:"days_before" db:"days_before"`
+	AtTime        *string    `json:"at_time,omitempty" db:"at_time"` // ЧЧ:ММ
+	FireAt        *time.Time `json:"fire_at" db:"fire_at"`           // Ближайшее срабатывание по текущему сроку, nil - у item нет срока
+	SentAt        *time.Time `json:"sent_at" db:"sent_at"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+}
+
+type ReminderInput struct {
+	OffsetMinutes *int    `json:"offset_minutes" binding:"required_without=AtTime,excluded_with=AtTime,omitempty,gte=0,lte=43200"`
+	DaysBefore    int     `json:"days_before" binding:"gte=0,lte=30"`
+	AtTime        *string `json:"at_time" binding:"required_without=OffsetMinutes,omitempty,clock"`
+}
+
+// IsClock проверяет время суток в формате ЧЧ:ММ
+func IsClock(value string) bool {
+	_, err := time.Parse("15:04", value)
+	return err == nil && len(value) == 5
+}
+
+// ReminderDelivery - сработавшее напоминание, захваченное планировщиком для отправки.
+// Пустой Email означает, что письмо не отправляется, а остается только уведомление
+type ReminderDelivery struct {
+	ReminderId int       `db:"reminder_id"`
+	FireAt     time.Time `db:"fire_at"`
+	UserId     int       `db:"user_id"`
+	Name       string    `db:"name"`
+	Email      string    `db:"email"`
+	TimeZone   string    `db:"time_zone"`
+	ItemId     int       `db:"item_id"`
+	ItemTitle  string    `db:"item_title"`
+	ListId     int       `db:"list_id"`
+	ListTitle  string    `db:"list_title"`
+	DueAt      time.Time `db:"due_at"`
+}
+
+// DueSoonEventData - данные события item.due_soon: пользователь, чье напоминание
+// сработало, и срок item
+type DueSoonEventData struct {
+	UserId int       `json:"user_id"`
+	DueAt  time.Time `json:"due_at"`
+}
+
 type User struct {
 	Id       int    `json:"-" db:"id"`
-	Name     string `json:"name" binding:"required" db:"name"`
-	Username string `json:"username" binding:"required" db:"username"`
+	Name     string `json:"name" binding:"required,max=255" db:"name"`
+	Username string `json:"username" binding:"required,max=255" db:"username"`
 	Password string `json:"password" binding:"required" db:"password_hash"`
 }
+
+// Периодичность дайджеста
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// DigestPreferences - настройки писем-дайджестов. Время отправки и день недели отсчитываются
+// в часовом поясе пользователя, он же используется в настройках пользователя
+type DigestPreferences struct {
+	Enabled   bool       `json:"enabled" db:"enabled"`
+	Frequency string     `json:"frequency" db:"frequency"`
+	SendAt    string     `json:"send_at" db:"send_at"` // ЧЧ:ММ
+	Weekday   int        `json:"weekday" db:"weekday"` // День отправки weekly: 1 - понедельник, 7 - воскресенье
+	TimeZone  string     `json:"time_zone" db:"time_zone"`
+	SentAt    *time.Time `json:"sent_at" db:"sent_at"`
+}
+
+type UpdateDigestPreferencesInput struct {
+	Enabled   *bool   `json:"enabled"`
+	Frequency *string `json:"frequency" binding:"omitempty,oneof=daily weekly"`
+	SendAt    *string `json:"send_at" binding:"omitempty,clock"`
+	Weekday   *int    `json:"weekday" binding:"omitempty,gte=1,lte=7"`
+	TimeZone  *string `json:"time_zone" binding:"omitempty,time_zone"`
+}
+
+// Normalize убирает пробельные символы по краям переданных строковых полей
+func (i *UpdateDigestPreferencesInput) Normalize() {
+	trimPtr(i.Frequency)
+	trimPtr(i.SendAt)
+	trimPtr(i.TimeZone)
+}
+
+func (i *UpdateDigestPreferencesInput) Validate() error {
+	if i.Enabled == nil && i.Frequency == nil && i.SendAt == nil && i.Weekday == nil && i.TimeZone == nil {
+		return errors.New("update structure has no values")
+	}
+	return nil
+}
+
+// Digest - сводка по спискам пользователя на местную дату Date
+type Digest struct {
+	Frequency string       `json:"frequency"`
+	Date      string       `json:"date"` // ГГГГ-ММ-ДД
+	TimeZone  string       `json:"time_zone"`
+	Due       []DigestItem `json:"due"`       // Срок сегодня, для weekly - в ближайшие 7 дней
+	Overdue   []DigestItem `json:"overdue"`   // Срок прошел до начала дня
+	Completed []DigestItem `json:"completed"` // Выполнено другими участниками за вчера, для weekly - за 7 дней
+}
+
+// IsEmpty сообщает, что в дайджесте нечего показать
+func (d Digest) IsEmpty() bool {
+	return len(d.Due) == 0 && len(d.Overdue) == 0 && len(d.Completed) == 0
+}
+
+type DigestItem struct {
+	ItemId      int        `json:"item_id" db:"item_id"`
+	Title       string     `json:"title" db:"title"`
+	ListId      int        `json:"list_id" db:"list_id"`
+	ListTitle   string     `json:"list_title" db:"list_title"`
+	DueAt       *time.Time `json:"due_at,omitempty" db:"due_at"`
+	CompletedBy string     `json:"completed_by,omitempty" db:"completed_by"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// DigestDelivery - дайджест, захваченный планировщиком для отправки за местную дату Date
+type DigestDelivery struct {
+	UserId    int       `db:"user_id"`
+	Name      string    `db:"name"`
+	Email     string    `db:"email"`
+	TimeZone  string    `db:"time_zone"`
+	Frequency string    `db:"frequency"`
+	Date      time.Time `db:"local_date"`
+}
+
+// Attachment - файл, прикрепленный к item. Содержимое хранится в BlobStore
+type Attachment struct {
+	Id          int       `json:"id" db:"id"`
+	ItemId      int       `json:"item_id" db:"item_id"`
+	UploadedBy  int       `json:"uploaded_by" db:"uploaded_by"`
+	Filename    string    `json:"filename" db:"filename"`
+	ContentType string    `json:"content_type" db:"content_type"` // Определяется по содержимому, а не по заголовку клиента
+	Size        int64     `json:"size" db:"size"`
+	Checksum    string    `json:"checksum" db:"checksum"` // sha256 содержимого, hex
+	StorageKey  string    `json:"-" db:"storage_key"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// AttachmentUpload - загружаемый файл с уже проверенными размером и типом
+type AttachmentUpload struct {
+	Filename    string
+	ContentType string
+	Size        int64
+	Checksum    string
+	Body        io.Reader
+}
+
+// BlobDeletion - объект BlobStore, ожидающий удаления
+type BlobDeletion struct {
+	Id         int64  `db:"id"`
+	StorageKey string `db:"storage_key"`
+}
+
+// AttachmentURL - подписанная ссылка на скачивание вложения без заголовка авторизации
+type AttachmentURL struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ChecklistItem - пункт чек-листа item. Позиции пунктов одного item идут подряд с нуля
+type ChecklistItem struct {
+	Id        int       `json:"id" db:"id"`
+	ItemId    int       `json:"item_id" db:"item_id"`
+	Position  int       `json:"position" db:"position"`
+	Text      string    `json:"text" db:"text"`
+	Checked   bool      `json:"checked" db:"checked"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// ChecklistItemInput - новый пункт чек-листа; без position пункт добавляется в конец
+type ChecklistItemInput struct {
+	Text     string `json:"text" binding:"required,max=255"`
+	Checked  bool   `json:"checked"`
+	Position *int   `json:"position" binding:"omitempty,gte=0"`
+}
+
+// Normalize убирает пробельные символы по краям текста
+func (i *ChecklistItemInput) Normalize() {
+	i.Text = strings.TrimSpace(i.Text)
+}
+
+type UpdateChecklistItemInput struct {
+	Text    *string `json:"text" binding:"omitempty,min=1,max=255"`
+	Checked *bool   `json:"checked"`
+}
+
+// Normalize убирает пробельные символы по краям текста
+func (i *UpdateChecklistItemInput) Normalize() {
+	trimPtr(i.Text)
+}
+
+func (i *UpdateChecklistItemInput) Validate() error {
+	if i.Text == nil && i.Checked == nil {
+		return errors.New("update structure has no values")
+	}
+	return nil
+}
+
+// ReorderChecklistInput - новый порядок пунктов чек-листа: id всех пунктов item
+type ReorderChecklistInput struct {
+	Ids []int `json:"ids" binding:"required,min=1,dive,gt=0"`
+}
+
+func (i *ReorderChecklistInput) Validate() error {
+	seen := make(map[int]bool, len(i.Ids))
+	for _, id := range i.Ids {
+		if seen[id] {
+			return fmt.Errorf("checklist item %d is listed more than once", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// ErrChecklistOrder возвращается, если новый порядок не перечисляет все пункты чек-листа item
+var ErrChecklistOrder = errors.New("ids must list every checklist item of the item exactly once")
+
+// ErrItemBlocked возвращается при попытке отметить выполненным item, у которого есть
+// невыполненные блокирующие items
+var ErrItemBlocked = errors.New("item is blocked by unfinished items")
+
+// ErrDependencyCycle возвращается, если новая зависимость замкнула бы цикл
+var ErrDependencyCycle = errors.New("dependency would create a cycle")
+
+// ErrBlockerNotFound возвращается, если блокирующий item не найден или недоступен пользователю
+var ErrBlockerNotFound = errors.New("blocking item not found")
+
+// AddDependencyInput - зависимость item от другого item, который нужно выполнить раньше
+type AddDependencyInput struct {
+	BlockedById int `json:"blocked_by_id" binding:"required,gt=0"`
+}
+
+// DependencyNode - item в графе зависимостей
+type DependencyNode struct {
+	ItemId       int    `json:"item_id" db:"item_id"`
+	ListId       int    `json:"list_id" db:"list_id"`
+	Title        string `json:"title" db:"title"`
+	Done         bool   `json:"done" db:"done"`
+	BlockedByIds IdList `json:"blocked_by_ids" db:"blocked_by_ids"` // Только items из графа
+}
+
+// DependencyGraph - items, связанные зависимостями, в топологическом порядке:
+// каждый item идет после всех items, которые его блокируют
+type DependencyGraph struct {
+	Nodes []DependencyNode `json:"nodes"`
+}
+
+// ItemFilter - условия выборки items; нулевые значения не ограничивают выборку
+type ItemFilter struct {
+	ListId     int
+	AssigneeId int    // Ответственный
+	Completed  string // "true" или "false"
+	Blocked    string // "true" - есть невыполненные блокирующие items, "false" - нет
+	StatusIds  []int  // Любой из статусов workflow
+}
+
+// Категории статусов workflow: items в закрытых статусах считаются выполненными
+const (
+	StatusCategoryOpen   = "open"
+	StatusCategoryClosed = "closed"
+)
+
+// ListStatus - статус workflow списка
+type ListStatus struct {
+	Id       int    `json:"id" db:"id"`
+	ListId   int    `json:"list_id" db:"list_id"`
+	Name     string `json:"name" db:"name"`
+	Category string `json:"category" db:"category"`
+	Position int    `json:"position" db:"position"`
+}
+
+// StatusInput - статус в новой конфигурации workflow; без id статус создается
+type StatusInput struct {
+	Id       int    `json:"id,omitempty" binding:"gte=0"`
+	Name     string `json:"name" binding:"required,max=50"`
+	Category string `json:"category" binding:"required,oneof=open closed"`
+}
+
+// WorkflowInput - упорядоченный набор статусов workflow списка. Статусы, которых нет в наборе,
+// удаляются; их items переходят в первый статус той же категории. Пустой набор отключает workflow
+type WorkflowInput struct {
+	Statuses []StatusInput `json:"statuses" binding:"max=20,dive"`
+}
+
+// Normalize убирает пробельные символы по краям названий статусов
+func (i *WorkflowInput) Normalize() {
+	for j := range i.Statuses {
+		i.Statuses[j].Name = strings.TrimSpace(i.Statuses[j].Name)
+	}
+}
+
+func (i *WorkflowInput) Validate() error {
+	if len(i.Statuses) == 0 {
+		return nil
+	}
+
+	names := make(map[string]bool, len(i.Statuses))
+	ids := make(map[int]bool, len(i.Statuses))
+	categories := make(map[string]bool, 2)
+	for _, status := range i.Statuses {
+		name := strings.ToLower(status.Name)
+		if names[name] {
+			return fmt.Errorf("status %q is listed more than once", status.Name)
+		}
+		names[name] = true
+
+		if status.Id > 0 {
+			if ids[status.Id] {
+				return fmt.Errorf("status %d is listed more than once", status.Id)
+			}
+			ids[status.Id] = true
+		}
+		categories[status.Category] = true
+	}
+
+	if !categories[StatusCategoryOpen] || !categories[StatusCategoryClosed] {
+		return errors.New("workflow needs at least one open and one closed status")
+	}
+	return nil
+}
+
+// Workflow - статусы списка по порядку; пустой набор означает, что у списка нет workflow
+type Workflow struct {
+	ListId   int          `json:"list_id"`
+	Statuses []ListStatus `json:"statuses"`
+}
+
+// ErrInvalidStatus возвращается, если статус не относится к workflow списка item
+var ErrInvalidStatus = errors.New("status does not belong to the list workflow")
+
+// BoardColumn - колонка Kanban-доски: статус workflow и его items. У списка без workflow
+// колонки соответствуют категориям, а StatusId пуст
+type BoardColumn struct {
+	StatusId *int       `json:"status_id"`
+	Name     string     `json:"name"`
+	Category string     `json:"category"`
+	Items    []TodoItem `json:"items"`
+	Total    int        `json:"total"` // Всего items в колонке; Items может быть обрезан лимитом
+}
+
+// Board - Kanban-доска списка
+type Board struct {
+	ListId  int           `json:"list_id"`
+	Columns []BoardColumn `json:"columns"`
+}
+
+// TimeEntry - учтенное пользователем время по item. Запись без EndedAt - запущенный таймер,
+// Minutes для него считается до текущего момента
+type TimeEntry struct {
+	Id        int        `json:"id" db:"id"`
+	ItemId    int        `json:"item_id" db:"item_id"`
+	UserId    int        `json:"user_id" db:"user_id"`
+	StartedAt time.Time  `json:"started_at" db:"started_at"`
+	EndedAt   *time.Time `json:"ended_at" db:"ended_at"`
+	Minutes   int        `json:"minutes" db:"minutes"`
+	Note      string     `json:"note" db:"note"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// StartTimerInput - запуск таймера по item
+type StartTimerInput struct {
+	Note string `json:"note" binding:"max=255"`
+}
+
+// TimeEntryInput - ручной учет времени: интервал длиной Minutes с начала StartedAt
+type TimeEntryInput struct {
+	StartedAt time.Time `json:"started_at" binding:"required"`
+	Minutes   int       `json:"minutes" binding:"required,gt=0,lte=1440"`
+	Note      string    `json:"note" binding:"max=255"`
+}
+
+// Normalize убирает пробельные символы по краям заметки
+func (i *TimeEntryInput) Normalize() {
+	i.Note = strings.TrimSpace(i.Note)
+}
+
+func (i *TimeEntryInput) Validate() error {
+	if i.EndedAt().After(time.Now()) {
+		return errors.New("time entry can't end in the future")
+	}
+	return nil
+}
+
+// EndedAt возвращает окончание интервала
+func (i TimeEntryInput) EndedAt() time.Time {
+	return i.StartedAt.Add(time.Duration(i.Minutes) * time.Minute)
+}
+
+// TimeReportRow - время, учтенное по items списка за день в часовом поясе пользователя
+type TimeReportRow struct {
+	Date    string `json:"date" db:"date"` // ГГГГ-ММ-ДД
+	ListId  int    `json:"list_id" db:"list_id"`
+	Minutes int    `json:"minutes" db:"minutes"`
+}
+
+// TimeReportFilter - период отчета по дням включительно и необязательные список и пользователь
+type TimeReportFilter struct {
+	From   time.Time
+	To     time.Time
+	ListId int
+	UserId int
+}
+
+// Folder - папка пользователя для группировки списков; папки вкладываются друг в друга
+type Folder struct {
+	Id        int       `json:"id" db:"id"`
+	ParentId  *int      `json:"parent_id" db:"parent_id"` // nil - папка в корне
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type FolderInput struct {
+	Name     string `json:"name" binding:"required,max=255"`
+	ParentId *int   `json:"parent_id" binding:"omitempty,gt=0"`
+}
+
+// Normalize убирает пробельные символы по краям названия
+func (i *FolderInput) Normalize() {
+	i.Name = strings.TrimSpace(i.Name)
+}
+
+func (i *FolderInput) Validate() error {
+	if i.Name == "" {
+		return errors.New("name must not be empty")
+	}
+	return nil
+}
+
+// UpdateFolderInput - переименование и перенос папки; parent_id = null переносит папку в корень
+type UpdateFolderInput struct {
+	Name     *string     `json:"name" binding:"omitempty,max=255"`
+	ParentId OptionalInt `json:"parent_id" swaggertype:"integer"`
+}
+
+// Normalize убирает пробельные символы по краям переданного названия
+func (i *UpdateFolderInput) Normalize() {
+	trimPtr(i.Name)
+}
+
+func (i *UpdateFolderInput) Validate() error {
+	if i.Name == nil && !i.ParentId.Set {
+		return errors.New("update structure has no values")
+	}
+	if i.Name != nil && *i.Name == "" {
+		return errors.New("name must not be empty")
+	}
+	if i.ParentId.Value != nil && *i.ParentId.Value < 1 {
+		return errors.New("parent_id must be positive")
+	}
+	return nil
+}
+
+// MoveListInput - перенос списка в папку текущего пользователя; folder_id = null - в корень
+type MoveListInput struct {
+	FolderId *int `json:"folder_id" binding:"omitempty,gt=0"`
+}
+
+// Режимы удаления папки: вложенные папки и списки переносятся в родительскую папку
+// либо удаляются вместе с ней
+const (
+	FolderDeleteReparent = "reparent"
+	FolderDeleteCascade  = "cascade"
+)
+
+var (
+	// ErrFolderCycle возвращается при переносе папки в саму себя или во вложенную папку
+	ErrFolderCycle = errors.New("folder can't be moved into itself or its subfolder")
+	// ErrFolderNotFound возвращается, если родительская или целевая папка не найдена у пользователя
+	ErrFolderNotFound = errors.New("folder not found")
+)
+
+// Виды строк дерева папок
+const (
+	FolderTreeFolder = "folder"
+	FolderTreeList   = "list"
+)
+
+// FolderTreeRow - папка или список дерева пользователя. ParentId - родительская папка
+// или папка списка; ItemCount - число неархивированных items списка
+type FolderTreeRow struct {
+	Kind      string `db:"kind"`
+	Id        int    `db:"id"`
+	ParentId  *int   `db:"parent_id"`
+	Name      string `db:"name"`
+	ItemCount int    `db:"item_count"`
+}
+
+// FolderListNode - список в дереве папок
+type FolderListNode struct {
+	Id        int    `json:"id"`
+	Title     string `json:"title"`
+	ItemCount int    `json:"item_count"`
+}
+
+// FolderNode - папка в дереве с вложенными папками и списками. ItemCount - число items
+// во всех списках папки, включая вложенные папки
+type FolderNode struct {
+	Id        int              `json:"id"`
+	Name      string           `json:"name"`
+	ItemCount int              `json:"item_count"`
+	Folders   []FolderNode     `json:"folders"`
+	Lists     []FolderListNode `json:"lists"`
+}
+
+// FolderTree - папки и списки пользователя; Folders и Lists - содержимое корня
+type FolderTree struct {
+	ItemCount int              `json:"item_count"`
+	Folders   []FolderNode     `json:"folders"`
+	Lists     []FolderListNode `json:"lists"`
+}
+
+// ListSection - именованный раздел списка, например "Овощи" или "На этой неделе"
+type ListSection struct {
+	Id        int       `json:"id" db:"id"`
+	ListId    int       `json:"list_id" db:"list_id"`
+	Name      string    `json:"name" db:"name"`
+	Position  int       `json:"position" db:"position"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// SectionInput - новый раздел; без Position раздел добавляется в конец
+type SectionInput struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	Position *int   `json:"position" binding:"omitempty,gte=0"`
+}
+
+// Normalize убирает пробельные символы по краям названия
+func (i *SectionInput) Normalize() {
+	i.Name = strings.TrimSpace(i.Name)
+}
+
+func (i *SectionInput) Validate() error {
+	if i.Name == "" {
+		return errors.New("name must not be empty")
+	}
+	return nil
+}
+
+type UpdateSectionInput struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// Normalize убирает пробельные символы по краям названия
+func (i *UpdateSectionInput) Normalize() {
+	i.Name = strings.TrimSpace(i.Name)
+}
+
+func (i *UpdateSectionInput) Validate() error {
+	if i.Name == "" {
+		return errors.New("name must not be empty")
+	}
+	return nil
+}
+
+// ReorderSectionsInput - новый порядок разделов: id всех разделов списка
+type ReorderSectionsInput struct {
+	Ids []int `json:"ids" binding:"required,min=1,dive,gt=0"`
+}
+
+func (i *ReorderSectionsInput) Validate() error {
+	return uniqueIds("section", i.Ids)
+}
+
+// MoveItemsInput - items списка, которые переносятся в раздел SectionId (nil - без раздела)
+// и встают в его начало в указанном порядке; остальные items раздела следуют за ними
+type MoveItemsInput struct {
+	SectionId *int  `json:"section_id" binding:"omitempty,gt=0"`
+	Ids       []int `json:"ids" binding:"required,min=1,max=500,dive,gt=0"`
+}
+
+func (i *MoveItemsInput) Validate() error {
+	return uniqueIds("item", i.Ids)
+}
+
+// uniqueIds возвращает ошибку, если id в ids повторяется
+func uniqueIds(entity string, ids []int) error {
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return fmt.Errorf("%s %d is listed more than once", entity, id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// SectionItems - раздел с его неархивированными items по порядку
+type SectionItems struct {
+	ListSection
+	Items []TodoItem `json:"items"`
+}
+
+// ListSections - items списка, сгруппированные по разделам
+type ListSections struct {
+	ListId      int            `json:"list_id"`
+	Unsectioned []TodoItem     `json:"unsectioned"` // Items без раздела, идут перед разделами
+	Sections    []SectionItems `json:"sections"`
+}
+
+var (
+	// ErrInvalidSection возвращается, если раздел не относится к списку item
+	ErrInvalidSection = errors.New("section does not belong to the list")
+	// ErrSectionOrder возвращается, если новый порядок не перечисляет все разделы списка
+	ErrSectionOrder = errors.New("ids must list every section of the list exactly once")
+)
+
+// ListTemplate - шаблон списка: снимок названия, разделов, items и их чек-листов.
+// Сроки items хранятся смещением от опорной даты и вычисляются при создании списка
+type ListTemplate struct {
+	Id          int             `json:"id" db:"id"`
+	Name        string          `json:"name" db:"name"`
+	Description string          `json:"description" db:"description"`
+	Content     TemplateContent `json:"content" db:"content"`
+	// Variables - переменные {{name}} из содержимого, значения которых задаются при создании списка
+	Variables []string  `json:"variables" db:"-"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// TemplateContent - содержимое шаблона. В названиях и описаниях списка, разделов, items
+// и в пунктах чек-листов можно использовать переменные вида {{name}}
+type TemplateContent struct {
+	Title       string         `json:"title" binding:"required,max=255"`
+	Description string         `json:"description" binding:"max=255"`
+	Color       string         `json:"color,omitempty" binding:"omitempty,max=50,list_color"`
+	Priority    int            `json:"priority" binding:"gte=0,lte=5"`
+	Sections    []string       `json:"sections" binding:"max=100,dive,max=100"`
+	Items       []TemplateItem `json:"items" binding:"max=500,dive"`
+}
+
+type TemplateItem struct {
+	Title       string `json:"title" binding:"required,max=255"`
+	Description string `json:"description" binding:"max=255"`
+	// Section - индекс раздела в Sections, nil - без раздела
+	Section         *int `json:"section" binding:"omitempty,gte=0"`
+	Priority        *int `json:"priority" binding:"omitempty,gte=0,lte=3"`
+	EstimateMinutes *int `json:"estimate_minutes" binding:"omitempty,gt=0,lte=525600"`
+	// DueOffsetMinutes - срок относительно опорной даты, nil - без срока
+	DueOffsetMinutes *int     `json:"due_offset_minutes"`
+	AutoComplete     bool     `json:"checklist_auto_complete"`
+	Checklist        []string `json:"checklist" binding:"max=100,dive,max=255"`
+}
+
+func (c *TemplateContent) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	default:
+		return errors.New("unsupported type for TemplateContent")
+	}
+}
+
+// Normalize убирает пробельные символы по краям строковых полей
+func (c *TemplateContent) Normalize() {
+	c.Title = strings.TrimSpace(c.Title)
+	c.Description = strings.TrimSpace(c.Description)
+	// Пустые наборы хранятся массивами, а не null
+	if c.Sections == nil {
+		c.Sections = make([]string, 0)
+	}
+	if c.Items == nil {
+		c.Items = make([]TemplateItem, 0)
+	}
+	for i := range c.Sections {
+		c.Sections[i] = strings.TrimSpace(c.Sections[i])
+	}
+	for i := range c.Items {
+		item := &c.Items[i]
+		item.Title = strings.TrimSpace(item.Title)
+		item.Description = strings.TrimSpace(item.Description)
+		if item.Checklist == nil {
+			item.Checklist = make([]string, 0)
+		}
+		for j := range item.Checklist {
+			item.Checklist[j] = strings.TrimSpace(item.Checklist[j])
+		}
+	}
+}
+
+// Validate проверяет содержимое целиком; вызывается и для содержимого с подставленными
+// переменными, т.к. подстановка может сделать строки пустыми или слишком длинными
+func (c *TemplateContent) Validate() error {
+	if err := checkText("title", c.Title, 255); err != nil {
+		return err
+	}
+	if utf8.RuneCountInString(c.Description) > 255 {
+		return errors.New("description must be at most 255 characters")
+	}
+	for i, section := range c.Sections {
+		if err := checkText(fmt.Sprintf("sections[%d]", i), section, 100); err != nil {
+			return err
+		}
+	}
+	for i, item := range c.Items {
+		if err := checkText(fmt.Sprintf("items[%d].title", i), item.Title, 255); err != nil {
+			return err
+		}
+		if utf8.RuneCountInString(item.Description) > 255 {
+			return fmt.Errorf("items[%d].description must be at most 255 characters", i)
+		}
+		if item.Section != nil && (*item.Section < 0 || *item.Section >= len(c.Sections)) {
+			return fmt.Errorf("items[%d].section must be an index of sections", i)
+		}
+		if v := item.Priority; v != nil && (*v < MinItemPriority || *v > MaxItemPriority) {
+			return fmt.Errorf("items[%d].priority must be between %d and %d", i, MinItemPriority, MaxItemPriority)
+		}
+		if v := item.EstimateMinutes; v != nil && (*v < 1 || *v > MaxEstimateMinutes) {
+			return fmt.Errorf("items[%d].estimate_minutes must be between 1 and %d", i, MaxEstimateMinutes)
+		}
+		for j, text := range item.Checklist {
+			if err := checkText(fmt.Sprintf("items[%d].checklist[%d]", i, j), text, 255); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkText проверяет, что строка не пустая и не длиннее max символов
+func checkText(field, value string, max int) error {
+	if value == "" {
+		return fmt.Errorf("%s must not be empty", field)
+	}
+	if utf8.RuneCountInString(value) > max {
+		return fmt.Errorf("%s must be at most %d characters", field, max)
+	}
+	return nil
+}
+
+// templateVariable - переменная шаблона: {{name}}, пробелы внутри скобок допускаются
+var templateVariable = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// texts возвращает указатели на все строки содержимого, в которых подставляются переменные
+func (c *TemplateContent) texts() []*string {
+	texts := []*string{&c.Title, &c.Description}
+	for i := range c.Sections {
+		texts = append(texts, &c.Sections[i])
+	}
+	for i := range c.Items {
+		item := &c.Items[i]
+		texts = append(texts, &item.Title, &item.Description)
+		for j := range item.Checklist {
+			texts = append(texts, &item.Checklist[j])
+		}
+	}
+	return texts
+}
+
+// Variables возвращает имена переменных содержимого по алфавиту
+func (c TemplateContent) Variables() []string {
+	seen := make(map[string]bool)
+	variables := make([]string, 0)
+	for _, text := range c.texts() {
+		for _, match := range templateVariable.FindAllStringSubmatch(*text, -1) {
+			if !seen[match[1]] {
+				seen[match[1]] = true
+				variables = append(variables, match[1])
+			}
+		}
+	}
+	sort.Strings(variables)
+	return variables
+}
+
+// Render возвращает копию содержимого с подставленными значениями переменных. Если для
+// переменной нет значения или после подстановки содержимое неверно, возвращается ErrTemplateRender
+func (c TemplateContent) Render(values map[string]string) (TemplateContent, error) {
+	missing := make([]string, 0)
+	for _, name := range c.Variables() {
+		if _, ok := values[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return TemplateContent{}, fmt.Errorf("%w: no values for variables %s", ErrTemplateRender, strings.Join(missing, ", "))
+	}
+
+	rendered := c
+	rendered.Sections = append([]string(nil), c.Sections...)
+	rendered.Items = make([]TemplateItem, len(c.Items))
+	for i, item := range c.Items {
+		item.Checklist = append([]string(nil), item.Checklist...)
+		rendered.Items[i] = item
+	}
+	for _, text := range rendered.texts() {
+		*text = templateVariable.ReplaceAllStringFunc(*text, func(match string) string {
+			return values[templateVariable.FindStringSubmatch(match)[1]]
+		})
+	}
+	rendered.Normalize()
+	if err := rendered.Validate(); err != nil {
+		return TemplateContent{}, fmt.Errorf("%w: %v", ErrTemplateRender, err)
+	}
+	return rendered, nil
+}
+
+type TemplateInput struct {
+	Name        string          `json:"name" binding:"required,max=255"`
+	Description string          `json:"description" binding:"max=255"`
+	Content     TemplateContent `json:"content" binding:"required"`
+}
+
+// Normalize убирает пробельные символы по краям строковых полей
+func (i *TemplateInput) Normalize() {
+	i.Name = strings.TrimSpace(i.Name)
+	i.Description = strings.TrimSpace(i.Description)
+	i.Content.Normalize()
+}
+
+func (i *TemplateInput) Validate() error {
+	if i.Name == "" {
+		return errors.New("name must not be empty")
+	}
+	return i.Content.Validate()
+}
+
+type UpdateTemplateInput struct {
+	Name        *string          `json:"name" binding:"omitempty,max=255"`
+	Description *string          `json:"description" binding:"omitempty,max=255"`
+	Content     *TemplateContent `json:"content"`
+}
+
+// Normalize убирает пробельные символы по краям переданных строковых полей
+func (i *UpdateTemplateInput) Normalize() {
+	trimPtr(i.Name)
+	trimPtr(i.Description)
+	if i.Content != nil {
+		i.Content.Normalize()
+	}
+}
+
+func (i *UpdateTemplateInput) Validate() error {
+	if i.Name == nil && i.Description == nil && i.Content == nil {
+		return errors.New("update structure has no values")
+	}
+	if i.Name != nil && *i.Name == "" {
+		return errors.New("name must not be empty")
+	}
+	if i.Content != nil {
+		return i.Content.Validate()
+	}
+	return nil
+}
+
+// SaveTemplateInput - сохранение списка шаблоном. Сроки items сохраняются смещением
+// от Anchor; без Anchor - от даты создания списка
+type SaveTemplateInput struct {
+	Name        string     `json:"name" binding:"required,max=255"`
+	Description string     `json:"description" binding:"max=255"`
+	Anchor      *time.Time `json:"anchor"`
+}
+
+// Normalize убирает пробельные символы по краям строковых полей
+func (i *SaveTemplateInput) Normalize() {
+	i.Name = strings.TrimSpace(i.Name)
+	i.Description = strings.TrimSpace(i.Description)
+}
+
+func (i *SaveTemplateInput) Validate() error {
+	if i.Name == "" {
+		return errors.New("name must not be empty")
+	}
+	return nil
+}
+
+// InstantiateTemplateInput - создание списка из шаблона: сроки items отсчитываются
+// от Anchor, Variables задают значения переменных {{name}}
+type InstantiateTemplateInput struct {
+	Anchor    time.Time         `json:"anchor" binding:"required"`
+	Variables map[string]string `json:"variables"`
+}
+
+// ErrTemplateRender возвращается, если по шаблону нельзя создать список с переданными значениями переменных
+var ErrTemplateRender = errors.New("template can't be instantiated")
+
+// DuplicateListInput - параметры копирования списка. Копируются разделы, workflow, items
+// с чек-листами, зависимостями между копируемыми items и ответственными с доступом к копии
+type DuplicateListInput struct {
+	// Title - название копии, по умолчанию название исходного списка
+	Title            *string `json:"title" binding:"omitempty,max=255"`
+	IncludeCompleted bool    `json:"include_completed"` // По умолчанию true
+	IncludeArchived  bool    `json:"include_archived"`
+	// IncludeMembers открывает доступ к копии участникам исходного списка
+	IncludeMembers bool `json:"include_members"`
+	// ResetDone снимает отметки выполнения с items и пунктов чек-листов
+	ResetDone bool `json:"reset_done"`
+}
+
+// NewDuplicateListInput возвращает параметры копирования по умолчанию
+func NewDuplicateListInput() DuplicateListInput {
+	return DuplicateListInput{IncludeCompleted: true}
+}
+
+// Normalize убирает пробельные символы по краям переданного названия
+func (i *DuplicateListInput) Normalize() {
+	trimPtr(i.Title)
+}
+
+func (i *DuplicateListInput) Validate() error {
+	if i.Title != nil && *i.Title == "" {
+		return ErrEmptyTitle
+	}
+	return nil
+}
+
+// ErrEmptyTitle возвращается, если переданное название копии пусто
+var ErrEmptyTitle = errors.New("title must not be empty")
+
+// MergeListInput - параметры слияния списка-источника со списком. Items переносятся
+// с разделами и порядком, участники источника получают доступ к списку
+type MergeListInput struct {
+	SourceListId int `json:"source_list_id" binding:"required,gt=0"`
+	// DeleteSource удаляет источник после слияния, по умолчанию он архивируется
+	DeleteSource bool `json:"delete_source"`
+}
+
+// ErrMergeIntoItself возвращается, если список-источник совпадает со списком
+var ErrMergeIntoItself = errors.New("list can't be merged into itself")
+
+// ListMergeEventData - данные события list.merged: снимок списка после слияния,
+// список-источник и перенесенные из него items
+type ListMergeEventData struct {
+	TodoList
+	SourceListId int   `json:"source_list_id"`
+	ItemIds      []int `json:"item_ids"`
+}
@@ -0,0 +1,130 @@
+package todo
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIsListColor(t *testing.T) {
+	tests := []struct {
+		color string
+		want  bool
+	}{
+		{"#fff", true},
+		{"#A0b1C2", true},
+		{"#ffff", false},
+		{"#ggg", false},
+		{"#", false},
+		{"teal", true},
+		{"Teal", false},
+		{"magenta", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsListColor(tt.color); got != tt.want {
+			t.Errorf("IsListColor(%q) = %v, want %v", tt.color, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateListInputNormalize(t *testing.T) {
+	title, color := "  Groceries ", " #ABC "
+	input := UpdateListInput{Title: &title, Color: &color}
+	input.Normalize()
+
+	if *input.Title != "Groceries" || *input.Color != "#abc" || input.Description != nil {
+		t.Errorf("normalized = %q %q %v", *input.Title, *input.Color, input.Description)
+	}
+	if err := input.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	if err := (&UpdateListInput{}).Validate(); err == nil {
+		t.Error("empty update must be rejected")
+	}
+}
+
+func TestReorderChecklistInputValidate(t *testing.T) {
+	if err := (&ReorderChecklistInput{Ids: []int{3, 1, 2}}).Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	if err := (&ReorderChecklistInput{Ids: []int{3, 1, 3}}).Validate(); err == nil {
+		t.Error("duplicate ids must be rejected")
+	}
+}
+
+func TestWorkflowInputValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []StatusInput
+		valid    bool
+	}{
+		{"empty disables workflow", nil, true},
+		{"open and closed", []StatusInput{{Name: "Todo", Category: StatusCategoryOpen}, {Name: "Done", Category: StatusCategoryClosed}}, true},
+		{"no closed status", []StatusInput{{Name: "Todo", Category: StatusCategoryOpen}}, false},
+		{"duplicate name", []StatusInput{{Name: "Done", Category: StatusCategoryOpen}, {Name: "done", Category: StatusCategoryClosed}}, false},
+		{"duplicate id", []StatusInput{{Id: 1, Name: "Todo", Category: StatusCategoryOpen}, {Id: 1, Name: "Done", Category: StatusCategoryClosed}}, false},
+	}
+	for _, tt := range tests {
+		input := WorkflowInput{Statuses: tt.statuses}
+		if err := input.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: Validate() = %v", tt.name, err)
+		}
+	}
+}
+
+func TestTimeEntryInputValidate(t *testing.T) {
+	past := TimeEntryInput{StartedAt: time.Now().Add(-2 * time.Hour), Minutes: 60}
+	if err := past.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	if ended := past.EndedAt(); !ended.Equal(past.StartedAt.Add(time.Hour)) {
+		t.Errorf("EndedAt() = %v", ended)
+	}
+
+	running := TimeEntryInput{StartedAt: time.Now().Add(-30 * time.Minute), Minutes: 60}
+	if err := running.Validate(); err == nil {
+		t.Error("entry ending in the future must be rejected")
+	}
+}
+
+func TestTemplateVariables(t *testing.T) {
+	content := TemplateContent{
+		Title:    "Trip to {{ city }}",
+		Sections: []string{"{{city}} prep"},
+		Items:    []TemplateItem{{Title: "Book {{hotel}}", Checklist: []string{"Pay {{ deposit }}", "{{not a var}}"}}},
+	}
+
+	if got, want := content.Variables(), []string{"city", "deposit", "hotel"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Variables() = %v, want %v", got, want)
+	}
+}
+
+func TestTemplateRender(t *testing.T) {
+	content := TemplateContent{
+		Title:    "Trip to {{city}}",
+		Sections: []string{"{{city}}"},
+		Items:    []TemplateItem{{Title: "Book {{hotel}}", Checklist: []string{"Call {{ hotel }}"}}},
+	}
+
+	rendered, err := content.Render(map[string]string{"city": "Rome", "hotel": "Hotel Roma"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rendered.Title != "Trip to Rome" || rendered.Sections[0] != "Rome" || rendered.Items[0].Checklist[0] != "Call Hotel Roma" {
+		t.Errorf("rendered = %+v", rendered)
+	}
+	// Исходное содержимое не меняется
+	if content.Sections[0] != "{{city}}" || content.Items[0].Checklist[0] != "Call {{ hotel }}" {
+		t.Errorf("original changed: %+v", content)
+	}
+
+	if _, err := content.Render(map[string]string{"city": "Rome"}); !errors.Is(err, ErrTemplateRender) {
+		t.Errorf("missing variable: err = %v, want template render", err)
+	}
+	// Пустой после подстановки раздел делает содержимое неверным
+	if _, err := content.Render(map[string]string{"city": "  ", "hotel": "Roma"}); !errors.Is(err, ErrTemplateRender) {
+		t.Errorf("empty section: err = %v, want template render", err)
+	}
+}